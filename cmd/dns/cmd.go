@@ -17,6 +17,7 @@ var (
 
 type dnsConfig struct {
 	vpcID      string
+	subnetID   string
 	debug      bool
 	region     string
 	awsProfile string
@@ -47,8 +48,9 @@ func NewCmdValidateDns() *cobra.Command {
 			awsVerifier.Logger.Warn(context.TODO(), "Using region: %s", config.region)
 
 			vdi := verifier.VerifyDnsInput{
-				VpcID: config.vpcID,
-				Ctx:   context.TODO(),
+				VpcID:    config.vpcID,
+				SubnetID: config.subnetID,
+				Ctx:      context.TODO(),
 			}
 			out := verifier.VerifyDns(awsVerifier, vdi)
 			out.Summary(config.debug)
@@ -62,14 +64,12 @@ func NewCmdValidateDns() *cobra.Command {
 	}
 
 	validateDnsCmd.Flags().StringVar(&config.vpcID, "vpc-id", "", "ID of the VPC under test")
+	validateDnsCmd.Flags().StringVar(&config.subnetID, "subnet-id", "", "ID of a subnet of the VPC under test (the VPC is looked up if --vpc-id is omitted). If set, custom DNS servers must be routed from this subnet")
 	validateDnsCmd.Flags().StringVar(&config.region, "region", getDefaultRegion(), fmt.Sprintf("Region to validate. Defaults to exported var %[1]v or '%[2]v' if not %[1]v set", regionEnvVarStr, regionDefault))
 	validateDnsCmd.Flags().BoolVar(&config.debug, "debug", false, "If true, enable additional debug-level logging")
 	validateDnsCmd.Flags().StringVar(&config.awsProfile, "profile", "", "(optional) AWS profile. If present, any credentials passed with CLI will be ignored.")
 
-	if err := validateDnsCmd.MarkFlagRequired("vpc-id"); err != nil {
-		validateDnsCmd.PrintErr(err)
-		os.Exit(1)
-	}
+	validateDnsCmd.MarkFlagsOneRequired("vpc-id", "subnet-id")
 
 	return validateDnsCmd
 
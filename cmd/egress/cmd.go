@@ -3,9 +3,6 @@ package egress
 import (
 	"bytes"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,12 +16,19 @@ import (
 	"github.com/openshift/osd-network-verifier/cmd/utils"
 	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"github.com/openshift/osd-network-verifier/pkg/probes/certchain"
+	"github.com/openshift/osd-network-verifier/pkg/probes/composite"
 	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+	"github.com/openshift/osd-network-verifier/pkg/probes/dns"
 	"github.com/openshift/osd-network-verifier/pkg/probes/legacy"
+	"github.com/openshift/osd-network-verifier/pkg/probes/proxydiag"
 	"github.com/openshift/osd-network-verifier/pkg/proxy"
 	"github.com/openshift/osd-network-verifier/pkg/verifier"
 	gcpverifier "github.com/openshift/osd-network-verifier/pkg/verifier/gcp"
 	kubeverifier "github.com/openshift/osd-network-verifier/pkg/verifier/kube"
+	localverifier "github.com/openshift/osd-network-verifier/pkg/verifier/local"
 )
 
 var (
@@ -32,16 +36,9 @@ var (
 	gcpDefaultTags = map[string]string{"osd-network-verifier": "owned", "red-hat-managed": "true", "name": "osd-network-verifier"}
 )
 
-const (
-	awsRegionEnvVarStr       = "AWS_REGION"
-	awsRegionDefault         = "us-east-2"
-	awsGovCloudRegionDefault = "us-gov-west-1"
-	gcpRegionEnvVarStr       = "GCP_REGION"
-	gcpRegionDefault         = "us-east1"
-)
-
 type egressConfig struct {
-	vpcSubnetID                string
+	vpcSubnetIDs               []string
+	maxParallelSubnets         int
 	cloudImageID               string
 	instanceType               string
 	cpuArchName                string
@@ -66,8 +63,23 @@ type egressConfig struct {
 	ForceTempSecurityGroup     bool
 	probeName                  string
 	podMode                    bool
+	localMode                  bool
+	staticAnalysis             bool
 	kubeConfigPath             string
 	namespace                  string
+	egressIPEchoURL            string
+	egressIPChecks             int
+	transferURLs               []string
+	transferSize               string
+	samples                    int
+	minPassingRatio            float64
+	maxBlockedRatio            float64
+	progress                   bool
+	pollInterval               time.Duration
+	pollTimeout                time.Duration
+	userDataTemplatePath       string
+	curlOptions                []string
+	ipFamily                   string
 }
 
 func NewCmdValidateEgress() *cobra.Command {
@@ -83,8 +95,17 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 # Verify that essential OpenShift domains are reachable from a given SUBNET_ID/SECURITY_GROUP association
 ./osd-network-verifier egress --subnet-id ${SUBNET_ID} --security-group-ids ${SECURITY_GROUP}
 
+# Verify egress from one subnet per availability zone in a single run, comparing the results across subnets
+./osd-network-verifier egress --subnet-id ${SUBNET_ID_A},${SUBNET_ID_B},${SUBNET_ID_C}
+
+# Predict whether essential OpenShift domains are reachable from a given SUBNET_ID using only the VPC's configuration, without launching an instance
+./osd-network-verifier egress --subnet-id ${SUBNET_ID} --static
+
 # Verify that essential OpenShift domains are reachable from a Pod within the connected cluster, for the clusters given region.
-./osd-network-verifier egress --pod-mode --region us-east-1`,
+./osd-network-verifier egress --pod-mode --region us-east-1
+
+# Verify that essential OpenShift domains are reachable from the current machine (e.g., a bastion host inside the VPC)
+./osd-network-verifier egress --local --region us-east-1`,
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := cmd.Context()
 
@@ -96,7 +117,7 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 
 			// Set Region
 			if config.region == "" {
-				config.region = getDefaultRegion(platformType)
+				config.region = utils.GetDefaultRegion(platformType)
 			}
 
 			if config.CaCert != "" {
@@ -122,7 +143,6 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 			// setup non cloud config options
 			vei := verifier.ValidateEgressInput{
 				Ctx:          ctx,
-				SubnetID:     config.vpcSubnetID,
 				CloudImageID: config.cloudImageID,
 				Timeout:      config.timeout,
 				Tags:         config.cloudTags,
@@ -130,10 +150,81 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 				PlatformType: platformType,
 				Proxy:        p,
 			}
+			// One or more subnets, verified concurrently if there are several (AWS only)
+			vei.SubnetIDs = config.vpcSubnetIDs
+			vei.MaxParallelSubnets = config.maxParallelSubnets
+			// Optional public egress IP discovery (curl probe only)
+			vei.EgressIPEchoURL = config.egressIPEchoURL
+			vei.EgressIPChecks = config.egressIPChecks
+			// Optional large-payload transfer check (curl probe only)
+			vei.TransferURLs = config.transferURLs
+			vei.TransferSize = config.transferSize
+			// Optional extra curl options (curl probe only)
+			vei.CurlOptions = config.curlOptions
+			// Optional IP family (or families) to check egress over (curl probe only)
+			if config.ipFamily != "" {
+				vei.IPFamily, err = ipfamily.ByName(config.ipFamily)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			}
+			// Live per-endpoint progress and console polling (cloud workflows only)
+			if config.progress {
+				vei.OnProgress = printProgress
+			}
+			vei.PollInterval = config.pollInterval
+			vei.PollTimeout = config.pollTimeout
+			// Optional custom userdata template (cloud workflows only)
+			if config.userDataTemplatePath != "" {
+				template, err := os.ReadFile(config.userDataTemplatePath)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				vei.UserDataTemplate = string(template)
+			}
+			// Local mode workflow
+			if config.localMode {
+				// Local mode only emulates the curl Probe
+				vei.Probe = config.curlProbe()
+
+				// Like pod mode, local mode doesn't require cloud provider access, so the region used for
+				// EgressList generation must come from the caller (or the usual defaults)
+				if vei.PlatformType.IsAWS() {
+					vei.AWS.Region = config.region
+				}
+
+				if config.egressListLocation != "" {
+					vei.EgressListYaml, err = utils.GetCustomEgressListFromFlag(config.egressListLocation)
+					if err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
+				}
+
+				localVerifier, err := localverifier.NewLocalVerifier(config.debug)
+				if err != nil {
+					fmt.Printf("could not build localVerifier: %v\n", err)
+					os.Exit(1)
+				}
+
+				out := verifier.ValidateEgress(localVerifier, vei)
+				out.Summary(config.debug)
+
+				if !out.IsSuccessful() {
+					localVerifier.Logger.Error(ctx, "Failure!")
+					os.Exit(1)
+				}
+
+				localVerifier.Logger.Info(ctx, "Success")
+				os.Exit(0)
+			}
+
 			// Pod mode workflow
 			if config.podMode {
 				// Pod mode only supports the curl Probe
-				vei.Probe = curl.Probe{}
+				vei.Probe = config.curlProbe()
 
 				// If on AWS, we need to configure the region for EgressList generation. This is primarily because
 				// PodMode doesn't require cloud provider access, so we can't infer the region. This means the caller
@@ -147,7 +238,7 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 				}
 
 				if config.egressListLocation != "" {
-					vei.EgressListYaml, err = getCustomEgressListFromFlag(config.egressListLocation)
+					vei.EgressListYaml, err = utils.GetCustomEgressListFromFlag(config.egressListLocation)
 					if err != nil {
 						fmt.Println(err)
 						os.Exit(1)
@@ -199,6 +290,7 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 				vei.AWS = verifier.AwsEgressConfig{
 					KmsKeyID:         config.kmsKeyID,
 					SecurityGroupIDs: config.securityGroupIDs,
+					StaticAnalysis:   config.staticAnalysis,
 				}
 
 				awsVerifier, err := utils.GetAwsVerifier(config.region, config.awsProfile, config.debug)
@@ -215,18 +307,18 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 				vei.ForceTempSecurityGroup = config.ForceTempSecurityGroup
 
 				// Probe selection
-				switch strings.ToLower(config.probeName) {
-				case "", "curl", "curlprobe", "curl.probe":
-					vei.Probe = curl.Probe{}
-					if config.egressListLocation != "" {
-						vei.EgressListYaml, err = getCustomEgressListFromFlag(config.egressListLocation)
-						if err != nil {
-							fmt.Println(err)
-							return
-						}
+				vei.Probe, err = config.probe()
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				// The legacy probe ignores egress lists
+				if _, isLegacy := vei.Probe.(legacy.Probe); !isLegacy && config.egressListLocation != "" {
+					vei.EgressListYaml, err = utils.GetCustomEgressListFromFlag(config.egressListLocation)
+					if err != nil {
+						fmt.Println(err)
+						return
 					}
-				case "legacy", "legacyprobe", "legacy.probe":
-					vei.Probe = legacy.Probe{}
 				}
 
 				// Map specified CPU architecture name to cpu.Architecture type
@@ -307,13 +399,14 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 
 	validateEgressCmd.Flags().StringVar(&config.platformType, "platform", cloud.AWSClassic.String(), fmt.Sprintf("(optional) infra platform type, which determines which endpoints to test. "+
 		"Either '%s', '%s', '%s', '%s', or '%s' (hypershift)", cloud.AWSClassic, cloud.AWSGovCloudClassic, cloud.GCPClassic, cloud.AWSHCP, cloud.AWSHCPZeroEgress))
-	validateEgressCmd.Flags().StringVar(&config.vpcSubnetID, "subnet-id", "", "target subnet ID")
+	validateEgressCmd.Flags().StringSliceVar(&config.vpcSubnetIDs, "subnet-id", []string{}, "target subnet ID. Repeat or comma-separate several IDs (e.g., one per availability zone) to verify them all in a single run (AWS only)")
+	validateEgressCmd.Flags().IntVar(&config.maxParallelSubnets, "max-parallel-subnets", verifier.DefaultMaxParallelSubnets, "(optional) maximum number of subnets verified at once when several --subnet-id values are given")
 	validateEgressCmd.Flags().StringVar(&config.cloudImageID, "image-id", "", "(optional) cloud image for the compute instance")
 	validateEgressCmd.Flags().StringVar(&config.instanceType, "instance-type", "", "(optional) compute instance type")
 	validateEgressCmd.Flags().StringVar(&config.cpuArchName, "cpu-arch", "", "(optional) compute instance CPU architecture. Ignored if valid instance-type specified")
 	validateEgressCmd.Flags().StringSliceVar(&config.securityGroupIDs, "security-group-ids", []string{}, "(optional) comma-separated list of sec. group IDs to attach to the created EC2 instance. If absent, one will be created")
 	validateEgressCmd.Flags().StringVar(&config.egressListLocation, "egress-list-location", "", "(optional) the location of the egress URL list to use. Can either be a local file path or an external URL starting with http(s). This value is ignored for the legacy probe.")
-	validateEgressCmd.Flags().StringVar(&config.region, "region", "", fmt.Sprintf("(optional) compute instance region. If absent, environment var %[1]v = %[2]v and %[3]v = %[4]v will be used", awsRegionEnvVarStr, awsRegionDefault, gcpRegionEnvVarStr, gcpRegionDefault))
+	validateEgressCmd.Flags().StringVar(&config.region, "region", "", fmt.Sprintf("(optional) compute instance region. If absent, environment var %[1]v = %[2]v and %[3]v = %[4]v will be used", utils.AwsRegionEnvVarStr, utils.AwsRegionDefault, utils.GcpRegionEnvVarStr, utils.GcpRegionDefault))
 	validateEgressCmd.Flags().StringToStringVar(&config.cloudTags, "cloud-tags", map[string]string{}, "(optional) comma-separated list of tags to assign to cloud resources e.g. --cloud-tags key1=value1,key2=value2")
 	validateEgressCmd.Flags().BoolVar(&config.debug, "debug", false, "(optional) if true, enable additional debug-level logging")
 	validateEgressCmd.Flags().DurationVar(&config.timeout, "timeout", time.Duration(0), "(optional) timeout for individual egress verification requests")
@@ -329,13 +422,29 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 	validateEgressCmd.Flags().StringVar(&config.terminateDebugInstance, "terminate-debug", "", "(optional) Takes the debug instance ID and terminates it")
 	validateEgressCmd.Flags().StringVar(&config.importKeyPair, "import-keypair", "", "(optional) Takes the path to your public key used to connect to Debug Instance. Automatically skips Termination")
 	validateEgressCmd.Flags().BoolVar(&config.ForceTempSecurityGroup, "force-temp-security-group", false, "(optional) Enforces creation of Temporary SG even if --security-group-ids flag is used")
-	validateEgressCmd.Flags().StringVar(&config.probeName, "probe", "Curl", "(optional) select the probe to be used for egress testing. Either 'Curl' (default) or 'Legacy'")
+	validateEgressCmd.Flags().StringVar(&config.probeName, "probe", "Curl", "(optional) select the probe to be used for egress testing. Either 'Curl' (default), 'DNS' (resolve every egress host via the subnet's resolver instead of connecting), 'CertChain' (record HTTPS endpoints' certificate chains and warn about TLS interception), 'ProxyDiag' (check how the configured proxy behaves; requires --http-proxy or --https-proxy), or 'Legacy'. A comma-separated list (e.g., 'Curl,DNS') runs several probes on the same instance")
+	validateEgressCmd.Flags().StringVar(&config.egressIPEchoURL, "egress-ip-url", "", "(optional) URL whose response body contains the caller's IP address (e.g., https://checkip.amazonaws.com), used by the curl probe to report the public IP the subnet egresses from")
+	validateEgressCmd.Flags().IntVar(&config.egressIPChecks, "egress-ip-checks", 1, "(optional) number of times to query --egress-ip-url, in order to detect multiple or unstable egress IPs (max 10)")
+	validateEgressCmd.Flags().StringSliceVar(&config.transferURLs, "transfer-urls", []string{}, "(optional) comma-separated list of URLs from which the curl probe downloads --transfer-size bytes, in order to detect path MTU problems that handshake-only checks miss")
+	validateEgressCmd.Flags().StringVar(&config.transferSize, "transfer-size", "1M", "(optional) amount of data to download from each of --transfer-urls, e.g. 512K or 1M (max 100M)")
+	validateEgressCmd.Flags().IntVar(&config.samples, "samples", 1, "(optional) number of times (max 10) the curl probe checks each endpoint, with random jitter between samples. Each endpoint is then classified as passing, flaky, or blocked")
+	validateEgressCmd.Flags().Float64Var(&config.minPassingRatio, "samples-min-passing-ratio", 1, "(optional) minimum ratio of successful samples for an endpoint to be classified as passing. Only has an effect when --samples > 1")
+	validateEgressCmd.Flags().Float64Var(&config.maxBlockedRatio, "samples-max-blocked-ratio", 0, "(optional) maximum ratio of successful samples for an endpoint to be classified as blocked; endpoints between the two ratios are flaky. Only has an effect when --samples > 1")
+	validateEgressCmd.Flags().BoolVar(&config.progress, "progress", false, "(optional) print the result of each endpoint check as soon as the probe reports it")
+	validateEgressCmd.Flags().DurationVar(&config.pollInterval, "poll-interval", time.Duration(0), "(optional) how often to read the probe instance's console output. Defaults to 10s on AWS and 30s on GCP")
+	validateEgressCmd.Flags().DurationVar(&config.pollTimeout, "poll-timeout", time.Duration(0), "(optional) how long to wait for the probe to finish. Defaults to a deadline derived from the probe, the number of endpoints, and --timeout")
+	validateEgressCmd.Flags().StringArrayVar(&config.curlOptions, "curl-opt", []string{}, "(optional) extra option passed through to curl for every endpoint check, as name=value (repeatable). Only resolve, connect-to, interface, ipv4, ipv6, and header are supported, e.g., --curl-opt resolve=quay.io:443:203.0.113.10")
+	validateEgressCmd.Flags().StringVar(&config.ipFamily, "ip-family", "", "(optional) IP family to check egress over: 'ipv4', 'ipv6', or 'both' (one pass per family, with results reported per family). Also allows IPv6 egress from the temporary security group when IPv6 is included. Defaults to letting curl choose")
+	validateEgressCmd.Flags().StringVar(&config.userDataTemplatePath, "userdata-template", "", "(optional) path to a userdata template replacing the curl probe's built-in one, e.g., to perform extra setup on hardened images. Must print ${USERDATA_BEGIN} and ${USERDATA_END} around the output of ${CURL_COMMAND}. Ignored in --pod-mode")
 	validateEgressCmd.Flags().BoolVar(&config.podMode, "pod-mode", false, "(optional) launch probe into a k8s cluster as a pod (vs. into a cloud account as a VM). Incompatible with cloud-related flags. See README for details")
+	validateEgressCmd.Flags().BoolVar(&config.localMode, "local", false, "(optional) check egress directly from the machine running the verifier (vs. from a VM or pod), e.g., from a bastion host inside the target network. Only the curl probe's checks are supported, and cloud-related flags are incompatible. See README for details")
+	validateEgressCmd.Flags().BoolVar(&config.staticAnalysis, "static", false, "(optional) predict whether each endpoint is reachable from the VPC's route tables, NAT/internet gateways, network ACLs, and security groups alone, without launching an instance (AWS only). See README for details")
 	validateEgressCmd.Flags().StringVar(&config.namespace, "namespace", "openshift-network-diagnostics", "(optional) k8s namespace to launch probe pods/jobs into. Only has an effect in --pod-mode")
 	validateEgressCmd.Flags().StringVar(&config.kubeConfigPath, "kubeconfig", "", "(optional) path to kubeconfig file. Defaults to KUBECONFIG env-var if set, otherwise ~/.kube/config")
 
-	// Require either --pod-mode or --subnet-id, but block most other flags when using pod mode
-	validateEgressCmd.MarkFlagsOneRequired("pod-mode", "subnet-id")
+	// Require either --pod-mode, --local, or --subnet-id, but block most other flags when using pod
+	// or local mode
+	validateEgressCmd.MarkFlagsOneRequired("pod-mode", "local", "subnet-id")
 	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "subnet-id")
 	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "instance-type")
 	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "security-group-ids")
@@ -347,83 +456,89 @@ AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY (also AWS_SESSION_TOKEN for STS credent
 	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "force-temp-security-group")
 	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "profile")
 	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "vpc-name")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "ip-family")
 	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "cpu-arch")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "egress-ip-url")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "transfer-urls")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("pod-mode", "max-parallel-subnets")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "pod-mode")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "subnet-id")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "image-id")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "instance-type")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "cpu-arch")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "security-group-ids")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "cloud-tags")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "kms-key-id")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "skip-termination")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "terminate-debug")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "import-keypair")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "force-temp-security-group")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "profile")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "vpc-name")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "probe")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "transfer-urls")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "curl-opt")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "userdata-template")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "poll-interval")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "poll-timeout")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("local", "max-parallel-subnets")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("static", "pod-mode")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("static", "local")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("static", "skip-termination")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("static", "terminate-debug")
+	validateEgressCmd.MarkFlagsMutuallyExclusive("static", "import-keypair")
 	validateEgressCmd.MarkFlagsMutuallyExclusive("cacert", "no-tls")
 
 	return validateEgressCmd
 }
 
-func getDefaultRegion(platformType cloud.Platform) string {
-	switch platformType {
-	case cloud.GCPClassic:
-		dRegion, ok := os.LookupEnv(gcpRegionEnvVarStr)
-		if !ok {
-			return gcpRegionDefault
-		}
-		return dRegion
-	case cloud.AWSGovCloudClassic:
-		dRegion, ok := os.LookupEnv(awsRegionEnvVarStr)
-		if !ok {
-			return awsGovCloudRegionDefault
-		}
-		return dRegion
-	default: // All other platforms, but we assume AWS
-		dRegion, ok := os.LookupEnv(awsRegionEnvVarStr)
-		if !ok {
-			return awsRegionDefault
-		}
-		return dRegion
-	}
-}
-
-func getCustomEgressListFromFlag(location string) (string, error) {
-	var egressListYaml string
-	if _, err := os.Stat(location); err == nil {
-		egressListYaml, err = getCustomLocalEgressList(location)
-		if err != nil {
-			return "", fmt.Errorf("failed to fetch egress URL list from %s: %v", location, err)
+// probe returns the probe selected by the --probe flag. A comma-separated list of probes selects a
+// composite.Probe that runs each of them on the same instance
+func (c egressConfig) probe() (probes.Probe, error) {
+	probeNames := strings.Split(c.probeName, ",")
+	selectedProbes := make([]probes.Probe, 0, len(probeNames))
+	for _, probeName := range probeNames {
+		switch strings.ToLower(strings.TrimSpace(probeName)) {
+		case "", "curl", "curlprobe", "curl.probe":
+			selectedProbes = append(selectedProbes, c.curlProbe())
+		case "dns", "dnsprobe", "dns.probe":
+			selectedProbes = append(selectedProbes, dns.Probe{})
+		case "certchain", "certchainprobe", "certchain.probe", "tls":
+			selectedProbes = append(selectedProbes, certchain.Probe{})
+		case "proxydiag", "proxydiagprobe", "proxydiag.probe", "proxy":
+			selectedProbes = append(selectedProbes, proxydiag.Probe{})
+		case "legacy", "legacyprobe", "legacy.probe":
+			selectedProbes = append(selectedProbes, legacy.Probe{})
+		default:
+			return nil, fmt.Errorf("unknown probe '%s'", probeName)
 		}
-		absPath, _ := filepath.Abs(location) // if we've gotten this far, we know the path is valid
-		fmt.Printf("Using local egress list from %s\n", absPath)
-		return egressListYaml, nil
-	}
-
-	parsedUrl, err := url.ParseRequestURI(location)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse URL %s: %w", location, err)
 	}
-	egressListYaml, err = getCustomExternalEgressList(parsedUrl.String())
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch egress URL list from %s: %w", parsedUrl.String(), err)
+	if len(selectedProbes) == 1 {
+		return selectedProbes[0], nil
 	}
-	fmt.Printf("Using external egress list from %s\n", parsedUrl.String())
-	return egressListYaml, nil
+	return composite.Probe{Probes: selectedProbes}, nil
 }
 
-func getCustomLocalEgressList(filePath string) (string, error) {
-	file, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", err
+// curlProbe returns a curl.Probe configured according to the sampling-related flags
+func (c egressConfig) curlProbe() curl.Probe {
+	return curl.Probe{
+		Samples:         c.samples,
+		MinPassingRatio: c.minPassingRatio,
+		MaxBlockedRatio: c.maxBlockedRatio,
 	}
-	return string(file), nil
 }
 
-func getCustomExternalEgressList(uri string) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, uri, nil)
-	if err != nil {
-		return "", err
+// printProgress prints a single line describing the result of one endpoint check
+func printProgress(event probes.ProgressEvent) {
+	status := "PASS"
+	if !event.Success {
+		status = "FAIL"
 	}
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-
-	b, err := io.ReadAll(res.Body)
-	if err != nil {
-		return "", err
+	if event.Detail == "" {
+		fmt.Printf("%s %s\n", status, event.Target)
+		return
 	}
-	return string(b), nil
+	fmt.Printf("%s %s (%s)\n", status, event.Target, event.Detail)
 }
 
 func getKubeConfigPath(flagValue string) string {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/openshift/osd-network-verifier/cmd/dns"
 	"github.com/openshift/osd-network-verifier/cmd/egress"
+	"github.com/openshift/osd-network-verifier/cmd/proxy"
 	"github.com/openshift/osd-network-verifier/version"
 	"github.com/spf13/cobra"
 	"os"
@@ -39,6 +40,7 @@ For more information see https://github.com/openshift/osd-network-verifier/blob/
 	// add sub commands
 	rootCmd.AddCommand(egress.NewCmdValidateEgress())
 	rootCmd.AddCommand(dns.NewCmdValidateDns())
+	rootCmd.AddCommand(proxy.NewCmdProxy())
 
 	return rootCmd
 }
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+)
+
+const (
+	AwsRegionEnvVarStr       = "AWS_REGION"
+	AwsRegionDefault         = "us-east-2"
+	AwsGovCloudRegionDefault = "us-gov-west-1"
+	GcpRegionEnvVarStr       = "GCP_REGION"
+	GcpRegionDefault         = "us-east1"
+)
+
+// GetDefaultRegion returns the region to use for platformType when none is specified, taken from
+// the platform's region environment variable if set
+func GetDefaultRegion(platformType cloud.Platform) string {
+	switch platformType {
+	case cloud.GCPClassic:
+		dRegion, ok := os.LookupEnv(GcpRegionEnvVarStr)
+		if !ok {
+			return GcpRegionDefault
+		}
+		return dRegion
+	case cloud.AWSGovCloudClassic:
+		dRegion, ok := os.LookupEnv(AwsRegionEnvVarStr)
+		if !ok {
+			return AwsGovCloudRegionDefault
+		}
+		return dRegion
+	default: // All other platforms, but we assume AWS
+		dRegion, ok := os.LookupEnv(AwsRegionEnvVarStr)
+		if !ok {
+			return AwsRegionDefault
+		}
+		return dRegion
+	}
+}
+
+// GetCustomEgressListFromFlag returns the egress list YAML at location, which can either be a local
+// file path or an external URL
+func GetCustomEgressListFromFlag(location string) (string, error) {
+	var egressListYaml string
+	if _, err := os.Stat(location); err == nil {
+		egressListYaml, err = getCustomLocalEgressList(location)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch egress URL list from %s: %v", location, err)
+		}
+		absPath, _ := filepath.Abs(location) // if we've gotten this far, we know the path is valid
+		fmt.Printf("Using local egress list from %s\n", absPath)
+		return egressListYaml, nil
+	}
+
+	parsedUrl, err := url.ParseRequestURI(location)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL %s: %w", location, err)
+	}
+	egressListYaml, err = getCustomExternalEgressList(parsedUrl.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch egress URL list from %s: %w", parsedUrl.String(), err)
+	}
+	fmt.Printf("Using external egress list from %s\n", parsedUrl.String())
+	return egressListYaml, nil
+}
+
+func getCustomLocalEgressList(filePath string) (string, error) {
+	file, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return string(file), nil
+}
+
+func getCustomExternalEgressList(uri string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
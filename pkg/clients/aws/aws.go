@@ -2,12 +2,15 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
 	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
 )
 
@@ -15,19 +18,38 @@ import (
 // For mocking the whole aws client, use the following:
 // mockgen -source=pkg/clients/aws/aws.go -package mocks -destination=pkg/mocks/mock_aws.go
 type Client struct {
-	ec2Client EC2Client
-	Region    string
+	ec2Client             EC2Client
+	route53Client         Route53Client
+	route53ResolverClient Route53ResolverClient
+	Region                string
 }
 
+// errRoute53ClientUnset and errRoute53ResolverClientUnset are returned by Route 53 calls on Clients
+// built without the corresponding client, e.g., mocked Clients that only called SetClient
+var (
+	errRoute53ClientUnset         = errors.New("route 53 client not configured")
+	errRoute53ResolverClientUnset = errors.New("route 53 resolver client not configured")
+)
+
 func (c *Client) SetClient(e EC2Client) {
 	c.ec2Client = e
 }
 
+func (c *Client) SetRoute53Client(r Route53Client) {
+	c.route53Client = r
+}
+
+func (c *Client) SetRoute53ResolverClient(r Route53ResolverClient) {
+	c.route53ResolverClient = r
+}
+
 // NewClientFromConfig creates an osd-network-verifier AWS Client from an aws-sdk-go-v2 Config
 func NewClientFromConfig(cfg aws.Config) (*Client, error) {
 	return &Client{
-		ec2Client: ec2.NewFromConfig(cfg),
-		Region:    cfg.Region,
+		ec2Client:             ec2.NewFromConfig(cfg),
+		route53Client:         route53.NewFromConfig(cfg),
+		route53ResolverClient: route53resolver.NewFromConfig(cfg),
+		Region:                cfg.Region,
 	}, nil
 }
 
@@ -46,6 +68,8 @@ func NewClient(ctx context.Context, accessID, accessSecret, sessiontoken, region
 			return &Client{}, err
 		}
 		c.ec2Client = ec2.NewFromConfig(cfg)
+		c.route53Client = route53.NewFromConfig(cfg)
+		c.route53ResolverClient = route53resolver.NewFromConfig(cfg)
 		return c, nil
 	}
 
@@ -62,6 +86,8 @@ func NewClient(ctx context.Context, accessID, accessSecret, sessiontoken, region
 	}
 
 	c.ec2Client = ec2.NewFromConfig(cfg)
+	c.route53Client = route53.NewFromConfig(cfg)
+	c.route53ResolverClient = route53resolver.NewFromConfig(cfg)
 	return c, nil
 }
 
@@ -84,6 +110,24 @@ type EC2Client interface {
 	DeleteKeyPair(ctx context.Context, params *ec2.DeleteKeyPairInput, optFns ...func(*ec2.Options)) (*ec2.DeleteKeyPairOutput, error)
 	DescribeKeyPairs(ctx context.Context, params *ec2.DescribeKeyPairsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeKeyPairsOutput, error)
 	ModifyInstanceAttribute(ctx context.Context, params *ec2.ModifyInstanceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error)
+	DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error)
+	DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error)
+	DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error)
+	DescribeStaleSecurityGroups(ctx context.Context, params *ec2.DescribeStaleSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeStaleSecurityGroupsOutput, error)
+	DescribeVpcEndpoints(ctx context.Context, params *ec2.DescribeVpcEndpointsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcEndpointsOutput, error)
+	DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	DescribeDhcpOptions(ctx context.Context, params *ec2.DescribeDhcpOptionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeDhcpOptionsOutput, error)
+}
+
+// Route53Client holds the Route 53 operations used to inspect private hosted zones
+type Route53Client interface {
+	ListHostedZonesByVPC(ctx context.Context, params *route53.ListHostedZonesByVPCInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByVPCOutput, error)
+}
+
+// Route53ResolverClient holds the Route 53 Resolver operations used to inspect resolver rules
+type Route53ResolverClient interface {
+	ListResolverRules(ctx context.Context, params *route53resolver.ListResolverRulesInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRulesOutput, error)
+	ListResolverRuleAssociations(ctx context.Context, params *route53resolver.ListResolverRuleAssociationsInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRuleAssociationsOutput, error)
 }
 
 func (c *Client) DescribeKeyPairs(ctx context.Context, params *ec2.DescribeKeyPairsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeKeyPairsOutput, error) {
@@ -146,6 +190,55 @@ func (c *Client) DeleteKeyPair(ctx context.Context, params *ec2.DeleteKeyPairInp
 	return c.ec2Client.DeleteKeyPair(ctx, params, optFns...)
 }
 
+func (c *Client) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return c.ec2Client.DescribeRouteTables(ctx, params, optFns...)
+}
+
+func (c *Client) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	return c.ec2Client.DescribeNetworkAcls(ctx, params, optFns...)
+}
+
+func (c *Client) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	return c.ec2Client.DescribeNatGateways(ctx, params, optFns...)
+}
+
+func (c *Client) DescribeStaleSecurityGroups(ctx context.Context, params *ec2.DescribeStaleSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeStaleSecurityGroupsOutput, error) {
+	return c.ec2Client.DescribeStaleSecurityGroups(ctx, params, optFns...)
+}
+
+func (c *Client) DescribeVpcEndpoints(ctx context.Context, params *ec2.DescribeVpcEndpointsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcEndpointsOutput, error) {
+	return c.ec2Client.DescribeVpcEndpoints(ctx, params, optFns...)
+}
+
+func (c *Client) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	return c.ec2Client.DescribeVpcs(ctx, params, optFns...)
+}
+
+func (c *Client) DescribeDhcpOptions(ctx context.Context, params *ec2.DescribeDhcpOptionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeDhcpOptionsOutput, error) {
+	return c.ec2Client.DescribeDhcpOptions(ctx, params, optFns...)
+}
+
+func (c *Client) ListHostedZonesByVPC(ctx context.Context, params *route53.ListHostedZonesByVPCInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByVPCOutput, error) {
+	if c.route53Client == nil {
+		return nil, errRoute53ClientUnset
+	}
+	return c.route53Client.ListHostedZonesByVPC(ctx, params, optFns...)
+}
+
+func (c *Client) ListResolverRules(ctx context.Context, params *route53resolver.ListResolverRulesInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRulesOutput, error) {
+	if c.route53ResolverClient == nil {
+		return nil, errRoute53ResolverClientUnset
+	}
+	return c.route53ResolverClient.ListResolverRules(ctx, params, optFns...)
+}
+
+func (c *Client) ListResolverRuleAssociations(ctx context.Context, params *route53resolver.ListResolverRuleAssociationsInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRuleAssociationsOutput, error) {
+	if c.route53ResolverClient == nil {
+		return nil, errRoute53ResolverClientUnset
+	}
+	return c.route53ResolverClient.ListResolverRuleAssociations(ctx, params, optFns...)
+}
+
 // TerminateEC2Instance terminates target ec2 instance
 func (c *Client) TerminateEC2Instance(ctx context.Context, instanceID string) error {
 	input := ec2.TerminateInstancesInput{
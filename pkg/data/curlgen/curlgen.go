@@ -2,7 +2,11 @@ package curlgen
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
+	"unicode"
 )
 
 // Options struct contains flag options that will be used to build
@@ -16,6 +20,133 @@ type Options struct {
 	NoTls           string
 	Urls            string
 	TlsDisabledUrls string
+	// Samples controls how many times the whole command is run; values below 2 run it once.
+	// When sampling, Retry is ignored so that retries can't hide the intermittent failures
+	// sampling is meant to catch
+	Samples int
+	// SampleJitter is the maximum number of seconds to sleep between samples (minimum 1)
+	SampleJitter int
+	// ExtraOptions are passed through to curl for every endpoint check. Use ParseExtraOptions to
+	// build them from user input, as only allowlisted options are supported
+	ExtraOptions []ExtraOption
+	// IPFamilies, if set, runs the whole command once per listed IP family ("ipv4" or "ipv6"),
+	// forcing curl to connect over that family and labeling each result with it (see
+	// IPFamilyLabel). Otherwise, the command runs once and curl picks a family for each endpoint
+	IPFamilies []string
+}
+
+// An ExtraOption is a single allowlisted curl option (see allowedExtraOptions) passed through to
+// the curl command, e.g., {Name: "resolve", Value: "quay.io:443:203.0.113.10"}
+type ExtraOption struct {
+	// Name is the option's long name, without leading dashes
+	Name string
+	// Value is the option's argument, or empty for options that don't take one
+	Value string
+}
+
+// extraOptionSpec describes an allowlisted extra curl option
+type extraOptionSpec struct {
+	// reValue matches valid arguments, or is nil if the option doesn't take an argument
+	reValue *regexp.Regexp
+	// normalize, if set, rewrites a valid argument before it's used
+	normalize func(string) string
+}
+
+// allowedExtraOptions lists the curl options that callers may pass through to the curl command.
+// These only change how endpoints are reached (e.g., overriding DNS resolution before a DNS
+// cutover), never what curl does with the results, so they can't break output parsing or run
+// anything else on the probe instance
+var allowedExtraOptions = map[string]extraOptionSpec{
+	// --resolve <[+]host:port:addr[,addr]...>
+	"resolve": {reValue: regexp.MustCompile(`^\+?[\w.*-]+:\d+:[\w.:\[\],-]+$`)},
+	// --connect-to <HOST1:PORT1:HOST2:PORT2>
+	"connect-to": {reValue: regexp.MustCompile(`^[\w.-]*:\d*:[\w.:\[\]-]*:\d*$`)},
+	// --interface <name>
+	"interface": {reValue: regexp.MustCompile(`^[\w.:%-]+$`)},
+	"ipv4":      {},
+	"ipv6":      {},
+	// --header <header>. Whitespace after the colon is dropped, as a ": " would otherwise break
+	// the YAML-formatted userdata the command is embedded in (curl ignores it anyway)
+	"header": {
+		reValue: regexp.MustCompile(`^[!#$%&*+.^\w|~-]+:[ \t]*[^'\r\n]*$`),
+		normalize: func(header string) string {
+			name, value, _ := strings.Cut(header, ":")
+			return name + ":" + strings.TrimLeft(value, " \t")
+		},
+	},
+}
+
+// extraOptionAliases maps the short names of allowlisted options to their long names
+var extraOptionAliases = map[string]string{
+	"4": "ipv4",
+	"6": "ipv6",
+	"H": "header",
+}
+
+// ParseExtraOptions validates a list of curl options against an allowlist of safe options and
+// returns them as ExtraOptions. Each option must be given as "name" or "name=value", with or
+// without leading dashes, e.g., "--resolve=quay.io:443:203.0.113.10", "ipv4", or "-H=X-Foo:bar".
+// Supported options are resolve, connect-to, interface, ipv4 (4), ipv6 (6), and header (H)
+func ParseExtraOptions(rawOptions []string) ([]ExtraOption, error) {
+	extraOptions := make([]ExtraOption, 0, len(rawOptions))
+	for _, rawOption := range rawOptions {
+		rawOption = strings.TrimSpace(rawOption)
+		if rawOption == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(rawOption, "-"), "=")
+		if longName, isAlias := extraOptionAliases[name]; isAlias {
+			name = longName
+		}
+
+		spec, allowed := allowedExtraOptions[name]
+		if !allowed {
+			return nil, fmt.Errorf("unsupported curl option %q: must be one of resolve, connect-to, interface, ipv4, ipv6, or header", rawOption)
+		}
+		if spec.reValue == nil {
+			if hasValue {
+				return nil, fmt.Errorf("invalid curl option %q: %s doesn't take a value", rawOption, name)
+			}
+			extraOptions = append(extraOptions, ExtraOption{Name: name})
+			continue
+		}
+		if !spec.reValue.MatchString(value) {
+			return nil, fmt.Errorf("invalid curl option %q: missing or invalid value for %s", rawOption, name)
+		}
+		if spec.normalize != nil {
+			value = spec.normalize(value)
+		}
+		// These would be misinterpreted when the command is embedded in YAML-formatted userdata
+		if strings.Contains(value, ": ") || strings.Contains(value, " #") {
+			return nil, fmt.Errorf("invalid curl option %q: value must not contain \": \" or \" #\"", rawOption)
+		}
+		extraOptions = append(extraOptions, ExtraOption{Name: name, Value: value})
+	}
+	return extraOptions, nil
+}
+
+// formatExtraOptions returns extraOptions as curl command-line arguments (each preceded by a
+// space), re-validating them in case they weren't built by ParseExtraOptions
+func formatExtraOptions(extraOptions []ExtraOption) (string, error) {
+	var formatted strings.Builder
+	for _, extraOption := range extraOptions {
+		rawOption := extraOption.Name
+		if extraOption.Value != "" {
+			rawOption += "=" + extraOption.Value
+		}
+		parsed, err := ParseExtraOptions([]string{rawOption})
+		if err != nil {
+			return "", err
+		}
+		for _, option := range parsed {
+			formatted.WriteString(" --" + option.Name)
+			if option.Value != "" {
+				// Values never contain single quotes (see allowedExtraOptions)
+				formatted.WriteString(" '" + option.Value + "'")
+			}
+		}
+	}
+	return formatted.String(), nil
 }
 
 const DefaultCurlOutputSeparator = "@NV@"
@@ -23,38 +154,196 @@ const DefaultCurlOutputSeparator = "@NV@"
 // GenerateString function will be used to transform the Configurations (options)
 // used to build the Options struct and build a full Curl command and return it as a string
 func GenerateString(cfg *Options) (string, error) {
-	command := fmt.Sprintf(`curl --capath %s --proxy-capath %s --retry %v --retry-connrefused -t B -Z -s -I -m %s -w "%%{stderr}%s%%{json}\n"`,
+	if len(cfg.IPFamilies) == 0 {
+		return generatePass(cfg, "")
+	}
+
+	// Check every endpoint once per IP family, one family after another. The passes are grouped
+	// so that any redirections applied to CURL_COMMAND apply to all of them
+	passes := make([]string, 0, len(cfg.IPFamilies))
+	for _, ipFamily := range cfg.IPFamilies {
+		pass, err := generatePass(cfg, ipFamily)
+		if err != nil {
+			return "", err
+		}
+		passes = append(passes, pass)
+	}
+	return "{ " + strings.Join(passes, "; ") + "; }", nil
+}
+
+// generatePass builds a curl command that checks every endpoint in cfg (repeatedly, if
+// sampling), forcing curl to use ipFamily ("ipv4" or "ipv6") and labeling its results with it
+// (see IPFamilyLabel) unless ipFamily is empty
+func generatePass(cfg *Options, ipFamily string) (string, error) {
+	writeOut := "%{json}"
+	retryOptions := fmt.Sprintf("--retry %v --retry-connrefused", cfg.Retry)
+	if cfg.Samples > 1 {
+		retryOptions = "--retry 0"
+	}
+
+	extraOptions, err := formatExtraOptions(cfg.ExtraOptions)
+	if err != nil {
+		return "", err
+	}
+
+	if ipFamily != "" {
+		if ipFamily != "ipv4" && ipFamily != "ipv6" {
+			return "", fmt.Errorf("invalid IP family %q: must be ipv4 or ipv6", ipFamily)
+		}
+		writeOut = IPFamilyLabel(ipFamily) + writeOut
+		extraOptions += " --" + ipFamily
+	}
+
+	urls, tlsDisabledUrls := cfg.Urls, cfg.TlsDisabledUrls
+
+	command := fmt.Sprintf(`curl --capath %s --proxy-capath %s %s -t B -Z -s -I -m %s -w "%%{stderr}%s%s\n"%s`,
 		cfg.CaPath,
 		cfg.ProxyCaPath,
-		cfg.Retry,
+		retryOptions,
 		cfg.MaxTime,
 		DefaultCurlOutputSeparator,
+		writeOut,
+		extraOptions,
 	)
 
 	if cfg.NoTLS() {
 		command += " --insecure"
 		// In addition to adding the curl flag, we can merge the list of "tlsDisabled" URLs
 		// with the list of "normal" URLs now (since all URLs will be "tlsDisabled")
-		cfg.Urls += " " + cfg.TlsDisabledUrls
-		cfg.TlsDisabledUrls = ""
+		urls += " " + tlsDisabledUrls
+		tlsDisabledUrls = ""
 	}
 
-	command += " " + cfg.Urls + " --proto =http,https,telnet"
+	command += " " + urls + " --proto =http,https,telnet"
 
-	if cfg.TlsDisabledUrls != "" {
+	if tlsDisabledUrls != "" {
 		command += fmt.Sprintf(
-			` --next --insecure --retry %v --retry-connrefused -s -I -m %s -w "%%{stderr}%s%%{json}\n" %s --proto =https`,
-			cfg.Retry,
+			` --next --insecure %s -s -I -m %s -w "%%{stderr}%s%s\n"%s %s --proto =https`,
+			retryOptions,
 			cfg.MaxTime,
 			DefaultCurlOutputSeparator,
-			cfg.TlsDisabledUrls,
+			writeOut,
+			extraOptions,
+			tlsDisabledUrls,
 		)
 	}
 
+	// Repeat the whole command with a random pause between samples so that intermittent
+	// failures aren't hidden (or caused) by samples running in lockstep
+	if cfg.Samples > 1 {
+		jitter := max(cfg.SampleJitter, 1)
+		command = fmt.Sprintf(`for _ in $(seq 1 %d); do %s; sleep $((RANDOM %% %d + 1)); done`, cfg.Samples, command, jitter)
+	}
+
 	return command, nil
+}
 
+// IPFamilyLabel returns the label inserted between DefaultCurlOutputSeparator and the JSON
+// results of an endpoint check forced to use ipFamily (see Options.IPFamilies), e.g.,
+// "@NV@ipv6@{...}"
+func IPFamilyLabel(ipFamily string) string {
+	return ipFamily + "@"
 }
+
 func (o *Options) NoTLS() bool {
 	noTLS, _ := strconv.ParseBool(o.NoTls)
 	return noTLS
 }
+
+// EgressIPOptions struct contains the options used to build a command that
+// discovers the public IP address a network egresses from by querying an
+// "IP echo" URL (i.e., one whose response body contains the caller's IP)
+type EgressIPOptions struct {
+	URL     string
+	Checks  int
+	Retry   int
+	MaxTime string
+}
+
+const DefaultEgressIPOutputSeparator = "@NVIP@"
+
+// GenerateEgressIPString builds a shell command that queries the IP echo URL
+// cfg.Checks times (pausing briefly between checks so that unstable egress IPs
+// have a chance to show up) and prints each response body on its own line of
+// stdout, prefixed with DefaultEgressIPOutputSeparator
+func GenerateEgressIPString(cfg *EgressIPOptions) (string, error) {
+	// The URL is single-quoted below, so it can't be allowed to contain single quotes
+	if cfg.URL == "" || strings.ContainsAny(cfg.URL, "'\n") {
+		return "", fmt.Errorf("invalid egress IP URL: %q", cfg.URL)
+	}
+	if cfg.Checks < 1 {
+		return "", fmt.Errorf("invalid number of egress IP checks: %d", cfg.Checks)
+	}
+
+	return fmt.Sprintf(
+		`for _ in $(seq 1 %d); do echo "%s$(curl --retry %v --retry-connrefused -s -m %s '%s' | head -c 256 | tr -d '\r\n')"; sleep 2; done`,
+		cfg.Checks,
+		DefaultEgressIPOutputSeparator,
+		cfg.Retry,
+		cfg.MaxTime,
+		cfg.URL,
+	), nil
+}
+
+// TransferOptions struct contains the options used to build a command that
+// downloads a fixed-size payload from each URL, in order to catch network
+// paths that complete TCP/TLS handshakes but stall on large packets (e.g.,
+// MTU blackholes)
+type TransferOptions struct {
+	CaPath      string
+	ProxyCaPath string
+	Urls        string
+	SizeBytes   int64
+	StallTime   int
+	MaxTime     string
+}
+
+const DefaultTransferOutputSeparator = "@NVXFER@"
+
+// transferURLForbiddenChars are characters that may not appear in transfer
+// URLs because they have special meaning to the shell or to cloud-init (even
+// though each URL is single-quoted). Characters commonly found in URLs (e.g.,
+// "?", "&", and "=") are still allowed
+const transferURLForbiddenChars = "'\"`$\\;|<>(){}!*"
+
+// GenerateTransferString builds a curl command that downloads the first
+// cfg.SizeBytes bytes of each URL (via a range request) and prints curl's
+// JSON-formatted results to stderr, prefixed with DefaultTransferOutputSeparator.
+// A transfer is aborted if it makes no progress for cfg.StallTime seconds, or if
+// the server ignores the range request and announces a larger response.
+// cfg.Urls is a space-separated list of http(s) URLs, each of which is
+// single-quoted in the returned command
+func GenerateTransferString(cfg *TransferOptions) (string, error) {
+	if cfg.Urls == "" {
+		return "", fmt.Errorf("no URLs provided for transfer check")
+	}
+	if cfg.SizeBytes < 1 {
+		return "", fmt.Errorf("invalid transfer size: %d", cfg.SizeBytes)
+	}
+
+	// Unlike the egress list, transfer URLs come straight from the user, so they're
+	// validated before being interpolated into a shell command
+	quotedURLs := []string{}
+	for _, rawURL := range strings.Fields(cfg.Urls) {
+		if strings.ContainsAny(rawURL, transferURLForbiddenChars) || strings.ContainsFunc(rawURL, unicode.IsControl) {
+			return "", fmt.Errorf("invalid transfer URL %q: must not contain any of %s or control characters", rawURL, transferURLForbiddenChars)
+		}
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return "", fmt.Errorf("invalid transfer URL %q: must be an http(s) URL", rawURL)
+		}
+		quotedURLs = append(quotedURLs, "'"+rawURL+"'")
+	}
+
+	return fmt.Sprintf(
+		`curl --capath %s --proxy-capath %s -Z -s -o /dev/null -r 0-%d --max-filesize %d --speed-limit 1 --speed-time %d -m %s -w "%%{stderr}%s%%{json}\n" %s --proto =http,https`,
+		cfg.CaPath,
+		cfg.ProxyCaPath,
+		cfg.SizeBytes-1,
+		cfg.SizeBytes,
+		cfg.StallTime,
+		cfg.MaxTime,
+		DefaultTransferOutputSeparator,
+		strings.Join(quotedURLs, " "),
+	), nil
+}
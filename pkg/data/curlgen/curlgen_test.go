@@ -1,6 +1,8 @@
 package curlgen
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -54,6 +56,22 @@ func TestGenerateString(t *testing.T) {
 			want:    "curl --capath /some/config/path/ --proxy-capath /some/config/path/ --retry 3 --retry-connrefused -t B -Z -s -I -m 4 -w \"%{stderr}@NV@%{json}\\n\" http://example.com:80 https://example.org:443 --proto =http,https,telnet",
 			wantErr: false,
 		},
+		{
+			name: "Sampling",
+			args: &Options{
+				CaPath:          "/some/config/path/",
+				ProxyCaPath:     "/some/config/path/",
+				Retry:           3,
+				MaxTime:         "4",
+				NoTls:           "false",
+				Urls:            "http://example.com:80 https://example.org:443",
+				TlsDisabledUrls: "",
+				Samples:         5,
+				SampleJitter:    3,
+			},
+			want:    "for _ in $(seq 1 5); do curl --capath /some/config/path/ --proxy-capath /some/config/path/ --retry 0 -t B -Z -s -I -m 4 -w \"%{stderr}@NV@%{json}\\n\" http://example.com:80 https://example.org:443 --proto =http,https,telnet; sleep $((RANDOM % 3 + 1)); done",
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -68,3 +86,308 @@ func TestGenerateString(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateStringSamplingDisablesRetries(t *testing.T) {
+	got, err := GenerateString(&Options{
+		CaPath:          "/some/config/path/",
+		ProxyCaPath:     "/some/config/path/",
+		Retry:           3,
+		MaxTime:         "4",
+		NoTls:           "false",
+		Urls:            "http://example.com:80",
+		TlsDisabledUrls: "https://example2.org:443",
+		Samples:         5,
+	})
+	if err != nil {
+		t.Fatalf("GenerateString() unexpected error = %v", err)
+	}
+	if strings.Contains(got, "--retry 3") || strings.Contains(got, "--retry-connrefused") {
+		t.Errorf("GenerateString() retries failed checks while sampling: %v", got)
+	}
+	if count := strings.Count(got, "--retry 0"); count != 2 {
+		t.Errorf("GenerateString() expected --retry 0 for both URL lists, got %d in %v", count, got)
+	}
+}
+
+func TestGenerateStringWithExtraOptions(t *testing.T) {
+	options := &Options{
+		CaPath:          "/some/config/path/",
+		ProxyCaPath:     "/some/config/path/",
+		Retry:           3,
+		MaxTime:         "4",
+		NoTls:           "false",
+		Urls:            "https://quay.io:443",
+		TlsDisabledUrls: "https://example.org:443",
+		ExtraOptions: []ExtraOption{
+			{Name: "resolve", Value: "quay.io:443:203.0.113.10"},
+			{Name: "ipv4"},
+		},
+	}
+	want := "curl --capath /some/config/path/ --proxy-capath /some/config/path/ --retry 3 --retry-connrefused -t B -Z -s -I -m 4 -w \"%{stderr}@NV@%{json}\\n\" --resolve 'quay.io:443:203.0.113.10' --ipv4 https://quay.io:443 --proto =http,https,telnet --next --insecure --retry 3 --retry-connrefused -s -I -m 4 -w \"%{stderr}@NV@%{json}\\n\" --resolve 'quay.io:443:203.0.113.10' --ipv4 https://example.org:443 --proto =https"
+	got, err := GenerateString(options)
+	if err != nil {
+		t.Fatalf("GenerateString() unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("GenerateString() got = %v, want %v", got, want)
+	}
+
+	// Options that didn't come from ParseExtraOptions are still validated
+	options.ExtraOptions = []ExtraOption{{Name: "output", Value: "/etc/passwd"}}
+	if _, err := GenerateString(options); err == nil {
+		t.Error("GenerateString() expected an error for a disallowed option, got nil")
+	}
+}
+
+func TestGenerateStringWithIPFamilies(t *testing.T) {
+	options := &Options{
+		CaPath:          "/some/config/path/",
+		ProxyCaPath:     "/some/config/path/",
+		Retry:           3,
+		MaxTime:         "4",
+		NoTls:           "true",
+		Urls:            "https://quay.io:443",
+		TlsDisabledUrls: "https://example.org:443",
+		IPFamilies:      []string{"ipv4", "ipv6"},
+	}
+	want := "{ curl --capath /some/config/path/ --proxy-capath /some/config/path/ --retry 3 --retry-connrefused -t B -Z -s -I -m 4 -w \"%{stderr}@NV@ipv4@%{json}\\n\" --ipv4 --insecure https://quay.io:443 https://example.org:443 --proto =http,https,telnet; " +
+		"curl --capath /some/config/path/ --proxy-capath /some/config/path/ --retry 3 --retry-connrefused -t B -Z -s -I -m 4 -w \"%{stderr}@NV@ipv6@%{json}\\n\" --ipv6 --insecure https://quay.io:443 https://example.org:443 --proto =http,https,telnet; }"
+	got, err := GenerateString(options)
+	if err != nil {
+		t.Fatalf("GenerateString() unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("GenerateString() got = %v, want %v", got, want)
+	}
+
+	options.IPFamilies = []string{"ipv5"}
+	if _, err := GenerateString(options); err == nil {
+		t.Error("GenerateString() expected an error for an invalid IP family, got nil")
+	}
+}
+
+func TestParseExtraOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		rawOptions []string
+		want       []ExtraOption
+		wantErr    bool
+	}{
+		{
+			name: "allowlisted options in various forms",
+			rawOptions: []string{
+				"--resolve=quay.io:443:203.0.113.10",
+				"connect-to=quay.io:443:[2001:db8::1]:443",
+				"--interface=eth1",
+				"-4",
+				"ipv6",
+				"-H=X-Request-Source: osd-network-verifier",
+				"",
+			},
+			want: []ExtraOption{
+				{Name: "resolve", Value: "quay.io:443:203.0.113.10"},
+				{Name: "connect-to", Value: "quay.io:443:[2001:db8::1]:443"},
+				{Name: "interface", Value: "eth1"},
+				{Name: "ipv4"},
+				{Name: "ipv6"},
+				{Name: "header", Value: "X-Request-Source:osd-network-verifier"},
+			},
+		},
+		{
+			name:       "disallowed option",
+			rawOptions: []string{"--output=/etc/passwd"},
+			wantErr:    true,
+		},
+		{
+			name:       "missing value",
+			rawOptions: []string{"--resolve"},
+			wantErr:    true,
+		},
+		{
+			name:       "value for flag",
+			rawOptions: []string{"--ipv4=yes"},
+			wantErr:    true,
+		},
+		{
+			name:       "shell injection",
+			rawOptions: []string{"--header=X-Foo:bar'; rm -rf /; echo '"},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid resolve",
+			rawOptions: []string{"--resolve=quay.io 1.2.3.4"},
+			wantErr:    true,
+		},
+		{
+			name:       "YAML-breaking header",
+			rawOptions: []string{"--header=X-Foo:bar #baz"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExtraOptions(tt.rawOptions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExtraOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseExtraOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateEgressIPString(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    *EgressIPOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Happy Path",
+			args: &EgressIPOptions{
+				URL:     "https://checkip.amazonaws.com",
+				Checks:  3,
+				Retry:   3,
+				MaxTime: "4",
+			},
+			want: `for _ in $(seq 1 3); do echo "@NVIP@$(curl --retry 3 --retry-connrefused -s -m 4 'https://checkip.amazonaws.com' | head -c 256 | tr -d '\r\n')"; sleep 2; done`,
+		},
+		{
+			name: "URL with single quote",
+			args: &EgressIPOptions{
+				URL:     "https://example.com/'; rm -rf /",
+				Checks:  1,
+				Retry:   3,
+				MaxTime: "4",
+			},
+			wantErr: true,
+		},
+		{
+			name: "No checks",
+			args: &EgressIPOptions{
+				URL:     "https://checkip.amazonaws.com",
+				Retry:   3,
+				MaxTime: "4",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateEgressIPString(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateEgressIPString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GenerateEgressIPString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateTransferString(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    *TransferOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Happy Path",
+			args: &TransferOptions{
+				CaPath:      "/some/config/path/",
+				ProxyCaPath: "/some/config/path/",
+				Urls:        "https://example.org:443/big.iso",
+				SizeBytes:   1048576,
+				StallTime:   10,
+				MaxTime:     "60",
+			},
+			want: "curl --capath /some/config/path/ --proxy-capath /some/config/path/ -Z -s -o /dev/null -r 0-1048575 --max-filesize 1048576 --speed-limit 1 --speed-time 10 -m 60 -w \"%{stderr}@NVXFER@%{json}\\n\" 'https://example.org:443/big.iso' --proto =http,https",
+		},
+		{
+			name: "Multiple URLs quoted",
+			args: &TransferOptions{
+				CaPath:      "/some/config/path/",
+				ProxyCaPath: "/some/config/path/",
+				Urls:        "https://example.org/big.iso?sig=abc&exp=1 http://example.net/big.iso",
+				SizeBytes:   1024,
+				StallTime:   10,
+				MaxTime:     "60",
+			},
+			want: "curl --capath /some/config/path/ --proxy-capath /some/config/path/ -Z -s -o /dev/null -r 0-1023 --max-filesize 1024 --speed-limit 1 --speed-time 10 -m 60 -w \"%{stderr}@NVXFER@%{json}\\n\" 'https://example.org/big.iso?sig=abc&exp=1' 'http://example.net/big.iso' --proto =http,https",
+		},
+		{
+			name: "Shell metacharacters",
+			args: &TransferOptions{
+				Urls:      "https://example.org/$(reboot)",
+				SizeBytes: 1024,
+				StallTime: 10,
+				MaxTime:   "60",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Single quote",
+			args: &TransferOptions{
+				Urls:      "https://example.org/'; reboot; '",
+				SizeBytes: 1024,
+				StallTime: 10,
+				MaxTime:   "60",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unsupported scheme",
+			args: &TransferOptions{
+				Urls:      "ftp://example.org/big.iso",
+				SizeBytes: 1024,
+				StallTime: 10,
+				MaxTime:   "60",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Not a URL",
+			args: &TransferOptions{
+				Urls:      "example.org",
+				SizeBytes: 1024,
+				StallTime: 10,
+				MaxTime:   "60",
+			},
+			wantErr: true,
+		},
+		{
+			name: "No URLs",
+			args: &TransferOptions{
+				SizeBytes: 1048576,
+				StallTime: 10,
+				MaxTime:   "60",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid size",
+			args: &TransferOptions{
+				Urls:      "https://example.org:443/big.iso",
+				StallTime: 10,
+				MaxTime:   "60",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateTransferString(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateTransferString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GenerateTransferString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package ipfamily
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Family type represents the IP address family (or families) over which the verifier checks
+// egress. The zero value means "unspecified," i.e., the probe lets the OS pick an address family
+// for each endpoint (in practice, usually IPv4)
+type Family struct {
+	// names holds 3 unique lowercase names of the Family (e.g., "ipv4"). We use a fixed-
+	// size array so that this struct remains comparable. Any of the 3 values can be used to refer
+	// to this specific Family via ipfamily.ByName(), but only the first (element
+	// 0) element will be the "preferred name" returned by Family.String()
+	names [3]string
+}
+
+var (
+	IPv4 = Family{
+		names: [3]string{"ipv4", "v4", "4"},
+	}
+	IPv6 = Family{
+		names: [3]string{"ipv6", "v6", "6"},
+	}
+	DualStack = Family{
+		names: [3]string{"both", "dual-stack", "dualstack"},
+	}
+)
+
+// String returns the "preferred name" of the Family
+func (f Family) String() string {
+	return f.names[0]
+}
+
+// ByName returns a Family supported by the verifier if the given name matches any known common
+// names for a supported Family. It returns an empty/invalid Family if the provided name isn't
+// supported
+func ByName(name string) (Family, error) {
+	normalizedName := strings.TrimSpace(strings.ToLower(name))
+	if normalizedName == "" {
+		return Family{}, fmt.Errorf("attempted to lookup IP family with empty string")
+	}
+
+	for _, family := range []Family{IPv4, IPv6, DualStack} {
+		if slices.Contains(family.names[:], normalizedName) {
+			return family, nil
+		}
+	}
+
+	return Family{}, fmt.Errorf("no IP family with name %s: must be one of ipv4, ipv6, or both", name)
+}
+
+// IsValid returns true if the Family is non-empty and supported by the network verifier
+func (f Family) IsValid() bool {
+	switch f {
+	case IPv4, IPv6, DualStack:
+		return true
+	default:
+		return false
+	}
+}
+
+// Passes returns the single-family passes a probe must make over its endpoints to check egress
+// over f, i.e., [IPv4, IPv6] for DualStack, [f] for IPv4 or IPv6, and nil for an invalid Family
+// (meaning a single pass without forcing an address family)
+func (f Family) Passes() []Family {
+	switch f {
+	case DualStack:
+		return []Family{IPv4, IPv6}
+	case IPv4, IPv6:
+		return []Family{f}
+	default:
+		return nil
+	}
+}
+
+// IncludesIPv6 returns true if egress must be checked over IPv6, i.e., if f is IPv6 or DualStack
+func (f Family) IncludesIPv6() bool {
+	return f == IPv6 || f == DualStack
+}
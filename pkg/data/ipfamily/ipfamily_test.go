@@ -0,0 +1,83 @@
+package ipfamily
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestFamily_Comparable simply forces the compiler to confirm that the Family type is comparable.
+// If not (e.g, because a non-comparable field was added to the struct type), this test will fail
+// to compile
+func TestFamily_Comparable(t *testing.T) {
+	if IPv4 != IPv6 {
+		return
+	}
+}
+
+func TestByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Family
+		wantErr bool
+	}{
+		{
+			name: "ipv4",
+			want: IPv4,
+		},
+		{
+			name: " IPv6 ",
+			want: IPv6,
+		},
+		{
+			name: "6",
+			want: IPv6,
+		},
+		{
+			name: "dual-stack",
+			want: DualStack,
+		},
+		{
+			name:    "",
+			wantErr: true,
+		},
+		{
+			name:    "ipx",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ByName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ByName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ByName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFamily_Passes(t *testing.T) {
+	tests := []struct {
+		family           Family
+		want             []Family
+		wantIncludesIPv6 bool
+	}{
+		{family: Family{}, want: nil},
+		{family: IPv4, want: []Family{IPv4}},
+		{family: IPv6, want: []Family{IPv6}, wantIncludesIPv6: true},
+		{family: DualStack, want: []Family{IPv4, IPv6}, wantIncludesIPv6: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.family.String(), func(t *testing.T) {
+			if got := tt.family.Passes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Family.Passes() = %v, want %v", got, tt.want)
+			}
+			if got := tt.family.IncludesIPv6(); got != tt.wantIncludesIPv6 {
+				t.Errorf("Family.IncludesIPv6() = %v, want %v", got, tt.wantIncludesIPv6)
+			}
+		})
+	}
+}
@@ -17,6 +17,31 @@ type KmsError struct {
 	message string
 }
 
+// TransferError indicates that a large-payload download from an egress endpoint failed or stalled
+// even though the endpoint may be reachable, which usually points to path MTU problems (e.g., VPN or
+// transit gateway MTU blackholes) rather than firewall rules
+type TransferError struct {
+	egressURL string
+	message   string
+}
+
+// TLSInterceptionWarning indicates that an HTTPS egress endpoint presented a certificate chain
+// suggesting that traffic is being intercepted (e.g., by a TLS-inspecting firewall or proxy).
+// Such endpoints are reachable, but clients that pin certificates will fail to connect to them
+type TLSInterceptionWarning struct {
+	egressURL string
+	message   string
+}
+
+// ProxyFinding indicates that proxy diagnostics found the configured proxy misbehaving (e.g.,
+// refusing CONNECT to a required port or answering failed requests with its own 200 page). These
+// are reported apart from egress failures, as they describe the proxy rather than an endpoint
+type ProxyFinding struct {
+	check   string
+	target  string
+	message string
+}
+
 func (e *GenericError) Error() string {
 	return e.message
 }
@@ -29,9 +54,131 @@ func (k *KmsError) Error() string {
 	return k.message
 }
 
+func (e *TransferError) Error() string {
+	return e.message
+}
+
+func (e *TransferError) EgressURL() string {
+	return e.egressURL
+}
+
+func (w *TLSInterceptionWarning) Error() string {
+	return w.message
+}
+
+func (w *TLSInterceptionWarning) EgressURL() string {
+	return w.egressURL
+}
+
+func (f *ProxyFinding) Error() string {
+	return f.message
+}
+
+// Check returns the name of the proxy diagnostic check that produced the finding (e.g., "connect")
+func (f *ProxyFinding) Check() string {
+	return f.check
+}
+
+// Target returns what the check was run against (e.g., "quay.io:9997"), if anything
+func (f *ProxyFinding) Target() string {
+	return f.target
+}
+
+// SecurityGroupFinding indicates that a user-provided security group is expected to block egress
+// to an endpoint, or references resources that no longer exist. Such findings are reported before
+// any probe runs, as warnings
+type SecurityGroupFinding struct {
+	groupIDs []string
+	message  string
+}
+
+func (f *SecurityGroupFinding) Error() string {
+	return f.message
+}
+
+// GroupIDs returns the IDs of the security groups the finding is about
+func (f *SecurityGroupFinding) GroupIDs() []string {
+	return f.groupIDs
+}
+
+// NetworkACLFinding indicates that a subnet's network ACL is expected to block egress to an
+// endpoint, or the return traffic. Such findings are reported before any probe runs, as warnings
+type NetworkACLFinding struct {
+	networkACLID string
+	message      string
+}
+
+func (f *NetworkACLFinding) Error() string {
+	return f.message
+}
+
+// NetworkACLID returns the ID of the network ACL the finding is about
+func (f *NetworkACLFinding) NetworkACLID() string {
+	return f.networkACLID
+}
+
+// RouteFinding indicates that the route table effective for a subnet is misconfigured (e.g., a
+// blackhole route) or conflicts with what the platform expects. Such findings are reported before
+// any probe runs, as warnings
+type RouteFinding struct {
+	subnetID string
+	message  string
+}
+
+func (f *RouteFinding) Error() string {
+	return f.message
+}
+
+// SubnetID returns the ID of the subnet the finding is about
+func (f *RouteFinding) SubnetID() string {
+	return f.subnetID
+}
+
+// VpcEndpointFinding indicates that a VPC endpoint clusters depend on is missing or can't be used
+// from the subnet being verified. Such findings are reported before any probe runs, as warnings
+type VpcEndpointFinding struct {
+	serviceName string
+	message     string
+}
+
+func (f *VpcEndpointFinding) Error() string {
+	return f.message
+}
+
+// ServiceName returns the name of the VPC endpoint service the finding is about, e.g.,
+// "com.amazonaws.us-east-1.sts"
+func (f *VpcEndpointFinding) ServiceName() string {
+	return f.serviceName
+}
+
+// DnsFinding indicates that a VPC's DNS configuration (e.g., its DHCP options set or Route 53
+// Resolver rules) may break name resolution for clusters. Such findings are reported as warnings
+type DnsFinding struct {
+	resourceID string
+	message    string
+}
+
+func (f *DnsFinding) Error() string {
+	return f.message
+}
+
+// ResourceID returns the ID of the resource the finding is about, e.g., a DHCP options set or a
+// Route 53 Resolver rule
+func (f *DnsFinding) ResourceID() string {
+	return f.resourceID
+}
+
 // Ensure GenericError implements the error interface
 var _ error = &GenericError{}
 var _ error = &KmsError{}
+var _ error = &TransferError{}
+var _ error = &TLSInterceptionWarning{}
+var _ error = &ProxyFinding{}
+var _ error = &SecurityGroupFinding{}
+var _ error = &NetworkACLFinding{}
+var _ error = &RouteFinding{}
+var _ error = &VpcEndpointFinding{}
+var _ error = &DnsFinding{}
 
 // NewGenericError does some preprocessing if the provided error contains an aws-sdk-go-v2 error, otherwise just
 // prepends `network verifier error: `
@@ -71,8 +218,80 @@ func NewEgressURLError(url string) error {
 	}
 }
 
+// NewTransferError prepends the provided message with `transfer error: `
+func NewTransferError(url string) error {
+	return &TransferError{
+		egressURL: url,
+		message:   fmt.Sprintf("transfer error: %s", url),
+	}
+}
+
 func NewKmsError(msg string) error {
 	return &KmsError{
 		message: msg,
 	}
 }
+
+// NewTLSInterceptionWarning prepends the provided reason with `possible TLS interception: ` and
+// the URL of the affected egress endpoint
+func NewTLSInterceptionWarning(url string, reason string) error {
+	return &TLSInterceptionWarning{
+		egressURL: url,
+		message:   fmt.Sprintf("possible TLS interception: %s %s", url, reason),
+	}
+}
+
+// NewProxyFinding prepends the provided detail with `proxy <check> check failed: ` and the
+// target of the check (if any)
+func NewProxyFinding(check string, target string, detail string) error {
+	message := fmt.Sprintf("proxy %s check failed: %s", check, detail)
+	if target != "" {
+		message = fmt.Sprintf("proxy %s check failed: %s %s", check, target, detail)
+	}
+	return &ProxyFinding{
+		check:   check,
+		target:  target,
+		message: message,
+	}
+}
+
+// NewSecurityGroupFinding prepends the provided detail with `security group finding: `
+func NewSecurityGroupFinding(groupIDs []string, detail string) error {
+	return &SecurityGroupFinding{
+		groupIDs: groupIDs,
+		message:  fmt.Sprintf("security group finding: %s", detail),
+	}
+}
+
+// NewNetworkACLFinding prepends the provided detail with `network ACL finding: `
+func NewNetworkACLFinding(networkACLID string, detail string) error {
+	return &NetworkACLFinding{
+		networkACLID: networkACLID,
+		message:      fmt.Sprintf("network ACL finding: %s", detail),
+	}
+}
+
+// NewRouteFinding prepends the provided detail with `routing finding: `
+func NewRouteFinding(subnetID string, detail string) error {
+	return &RouteFinding{
+		subnetID: subnetID,
+		message:  fmt.Sprintf("routing finding: %s", detail),
+	}
+}
+
+// NewVpcEndpointFinding prepends the provided detail with `VPC endpoint finding: ` and the name of
+// the service the finding is about
+func NewVpcEndpointFinding(serviceName string, detail string) error {
+	return &VpcEndpointFinding{
+		serviceName: serviceName,
+		message:     fmt.Sprintf("VPC endpoint finding: %s: %s", serviceName, detail),
+	}
+}
+
+// NewDnsFinding prepends the provided detail with `DNS finding: `
+func NewDnsFinding(resourceID string, detail string) error {
+	return &DnsFinding{
+		resourceID: resourceID,
+		message:    fmt.Sprintf("DNS finding: %s", detail),
+	}
+}
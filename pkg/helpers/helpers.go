@@ -2,8 +2,10 @@ package helpers
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
 	"math/big"
 	"regexp"
 	"strconv"
@@ -222,3 +224,56 @@ func DurationToBareSeconds(possibleDurationStr string) float64 {
 	// possibleDurationStr looks nothing like a duration: fall back to 0
 	return 0
 }
+
+// NormalizeSaneNonzeroDuration first converts a given string expected to hold a duration
+// (e.g., "3s" or "2") to a float64 using DurationToBareSeconds(). It then ensures the
+// float duration is "sane," i.e., greater than 0 seconds but less than 3 hours*. If sane, the
+// duration in seconds is Sprintf'd using the provided fmtStr and returned. If not sane, an error
+// is returned.
+// * We max at 3 hours under the assumption that the verifier isn't doing anything for >3hrs
+func NormalizeSaneNonzeroDuration(possibleDurationStr string, fmtStr string) (string, error) {
+	durationSeconds := DurationToBareSeconds(possibleDurationStr)
+	if durationSeconds <= 0 {
+		return "", fmt.Errorf("invalid %s value (parsed as %.2f sec)", possibleDurationStr, durationSeconds)
+	}
+
+	if durationSeconds > 10800 {
+		return "", fmt.Errorf("value %s (parsed as %.2f sec) is too large", possibleDurationStr, durationSeconds)
+	}
+
+	return fmt.Sprintf(fmtStr, durationSeconds), nil
+}
+
+// NewNonce returns a random hex string suitable for labeling a single verifier run's probe output
+// (see TokenWithNonce), so that stale output left on a console by a previous run can be ignored
+func NewNonce() string {
+	nonce := make([]byte, 8)
+	// crypto/rand.Read never returns an error (it crashes the program instead)
+	_, _ = rand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
+
+// TokenWithNonce returns the given probe output token (e.g., a probe's startingToken) labeled
+// with nonce, or token as-is if nonce is empty
+func TokenWithNonce(token string, nonce string) string {
+	if nonce == "" {
+		return token
+	}
+	return token + "_" + nonce
+}
+
+// NoncedPresetUserDataVariables returns a copy of a probe's presetUserDataVariables in which the
+// USERDATA_BEGIN and USERDATA_END tokens are labeled with nonce (see TokenWithNonce) and
+// SEQUENCE_COMMAND (if present) labels each line it prints with nonce (see SequenceLinesCommand)
+func NoncedPresetUserDataVariables(presetUserDataVariables map[string]string, nonce string) map[string]string {
+	noncedPresetUserDataVariables := maps.Clone(presetUserDataVariables)
+	for _, tokenVariable := range []string{"USERDATA_BEGIN", "USERDATA_END"} {
+		if token, isPreset := presetUserDataVariables[tokenVariable]; isPreset {
+			noncedPresetUserDataVariables[tokenVariable] = TokenWithNonce(token, nonce)
+		}
+	}
+	if _, isPreset := presetUserDataVariables["SEQUENCE_COMMAND"]; isPreset {
+		noncedPresetUserDataVariables["SEQUENCE_COMMAND"] = SequenceLinesCommand(nonce)
+	}
+	return noncedPresetUserDataVariables
+}
@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"fmt"
 	"reflect"
+	"regexp"
 	"testing"
 
 	awsTools "github.com/aws/aws-sdk-go-v2/aws"
@@ -654,3 +655,118 @@ func Test_RandBigInt(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestNormalizeSaneNonzeroDuration tests the probes' duration string normalization
+// and sanity checking function. This test assumes fmtStr = "%.2f"
+func TestNormalizeSaneNonzeroDuration(t *testing.T) {
+	tests := []struct {
+		name                string
+		possibleDurationStr string
+		want                string
+		wantErr             bool
+	}{
+		{
+			name:                "integer with unit",
+			possibleDurationStr: "3s",
+			want:                "3.00",
+			wantErr:             false,
+		},
+		{
+			name:                "float with unit",
+			possibleDurationStr: "1.2s",
+			want:                "1.20",
+			wantErr:             false,
+		},
+		{
+			name:                "bare integer",
+			possibleDurationStr: "7",
+			want:                "7.00",
+			wantErr:             false,
+		},
+		{
+			name:                "bare float",
+			possibleDurationStr: "6.5",
+			want:                "6.50",
+			wantErr:             false,
+		},
+		{
+			name:                "too large",
+			possibleDurationStr: "4h",
+			wantErr:             true,
+		},
+		{
+			name:                "negative integer",
+			possibleDurationStr: "-3",
+			wantErr:             true,
+		},
+		{
+			name:                "negative with unit",
+			possibleDurationStr: "-1m",
+			wantErr:             true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeSaneNonzeroDuration(tt.possibleDurationStr, "%.2f")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NormalizeSaneNonzeroDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeSaneNonzeroDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNonce(t *testing.T) {
+	nonce := NewNonce()
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(nonce) {
+		t.Errorf("NewNonce() = %q, want 16 hex characters", nonce)
+	}
+	if otherNonce := NewNonce(); otherNonce == nonce {
+		t.Errorf("NewNonce() returned %q twice", nonce)
+	}
+}
+
+func TestNoncedPresetUserDataVariables(t *testing.T) {
+	presetUserDataVariables := map[string]string{
+		"USERDATA_BEGIN":   "NV_BEGIN",
+		"USERDATA_END":     "NV_END",
+		"SEQUENCE_COMMAND": SequenceLinesCommand(""),
+		"IMAGE":            "$IMAGE",
+	}
+
+	tests := []struct {
+		name  string
+		nonce string
+		want  map[string]string
+	}{
+		{
+			name:  "no nonce",
+			nonce: "",
+			want:  presetUserDataVariables,
+		},
+		{
+			name:  "nonce",
+			nonce: "abc123",
+			want: map[string]string{
+				"USERDATA_BEGIN":   "NV_BEGIN_abc123",
+				"USERDATA_END":     "NV_END_abc123",
+				"SEQUENCE_COMMAND": SequenceLinesCommand("abc123"),
+				"IMAGE":            "$IMAGE",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NoncedPresetUserDataVariables(presetUserDataVariables, tt.nonce)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NoncedPresetUserDataVariables() = %v, want %v", got, tt.want)
+			}
+			if presetUserDataVariables["USERDATA_BEGIN"] != "NV_BEGIN" {
+				t.Errorf("NoncedPresetUserDataVariables() modified its input")
+			}
+		})
+	}
+}
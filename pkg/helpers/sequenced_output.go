@@ -0,0 +1,323 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SequencedLinePrefix is prepended (along with the run's nonce, if any, and a sequence number)
+// to each line of probe output by the command returned by SequenceLinesCommand, e.g.,
+// "@NVSEQ@<nonce>@12@<original line>". After the last line, a final
+// "@NVSEQ@<nonce>@END@<total line count>" line is printed
+const SequencedLinePrefix = "@NVSEQ@"
+
+// SequenceLinesCommand returns a shell command that reads probe output from stdin and writes it
+// to stdout as sequence-numbered lines labeled with nonce (see SequencedLinePrefix). Serial
+// consoles only retain a limited amount of recent output (e.g., AWS's GetConsoleOutput only
+// returns the latest 64KB), so output is paced at roughly 4KB per second; this gives verifiers
+// polling the console a chance to read every line before it scrolls out of the buffer. A
+// SequencedOutputCollector can then reassemble the complete output from several console reads,
+// and detect any lines lost anyway. Probes can pass this command to their userdata templates as
+// a preset variable (see NoncedPresetUserDataVariables). nonce must only contain characters that
+// are safe to use unquoted in a shell command (e.g., one generated by NewNonce)
+func SequenceLinesCommand(nonce string) string {
+	prefix := sequencedLinePrefixWithNonce(nonce)
+	return fmt.Sprintf(
+		`awk '{ printf "%[1]s%%d@%%s\n", NR, $0; fflush(); n += length($0); if (n >= 4096) { system("sleep 1"); n = 0 } } END { printf "%[1]sEND@%%d\n", NR; fflush() }'`,
+		prefix,
+	)
+}
+
+// sequencedLinePrefixWithNonce returns the prefix preceding the sequence number of each line
+// printed by SequenceLinesCommand(nonce)
+func sequencedLinePrefixWithNonce(nonce string) string {
+	if nonce == "" {
+		return SequencedLinePrefix
+	}
+	return SequencedLinePrefix + nonce + "@"
+}
+
+// SubstreamLinePrefix is prepended (along with the sub-stream's name) to each line collected from a
+// sub-stream (see SubstreamNonce) when a SequencedOutputCollector reports it, e.g.,
+// "@NVSUB@<stream>@<original line>"
+const SubstreamLinePrefix = "@NVSUB@"
+
+// SubstreamNonce returns the nonce labeling the sub-stream named stream of a run's output labeled
+// with nonce. A probe combining the output of several others (e.g., composite.Probe) can give
+// each of them its own sub-stream nonce, so that a single SequencedOutputCollector reassembles
+// all of their output without mixing up their sequence numbers. stream must only contain letters,
+// digits, and underscores
+func SubstreamNonce(nonce string, stream string) string {
+	return nonce + "." + stream
+}
+
+// CutSubstreamLine splits a line reported by a SequencedOutputCollector into the name of the
+// sub-stream it was collected from and the original line. ok is false if the line wasn't
+// collected from a sub-stream
+func CutSubstreamLine(line string) (stream string, originalLine string, ok bool) {
+	rest, found := strings.CutPrefix(line, SubstreamLinePrefix)
+	if !found {
+		return "", "", false
+	}
+	return strings.Cut(rest, "@")
+}
+
+// A SequencedOutputCollector reassembles the sequence-numbered lines printed by
+// SequenceLinesCommand from (possibly overlapping or incomplete) snapshots of console output.
+// Only lines labeled with the collector's nonce (or one of its sub-stream nonces, see
+// SubstreamNonce) are collected, so stale output left on the console by a previous run is
+// ignored. The zero value is not usable; use NewSequencedOutputCollector() instead
+type SequencedOutputCollector struct {
+	reSequencedLine *regexp.Regexp
+	// streams holds the lines collected from the main stream (keyed by "") and from each
+	// sub-stream (keyed by its name)
+	streams map[string]*sequencedStream
+}
+
+// sequencedStream holds the lines collected from a single stream of sequence-numbered output
+type sequencedStream struct {
+	lines map[int]string
+	// total is the number of lines reported by the end-of-output line, or -1 if that line
+	// hasn't been seen yet
+	total int
+}
+
+// NewSequencedOutputCollector returns an empty SequencedOutputCollector that only collects lines
+// printed by SequenceLinesCommand(nonce) or SequenceLinesCommand(SubstreamNonce(nonce, <any>))
+func NewSequencedOutputCollector(nonce string) *SequencedOutputCollector {
+	mainPrefix := regexp.QuoteMeta(sequencedLinePrefixWithNonce(nonce))
+	substreamPrefix := regexp.QuoteMeta(SequencedLinePrefix+SubstreamNonce(nonce, "")) + `(\w+)@`
+	return &SequencedOutputCollector{
+		reSequencedLine: regexp.MustCompile(`^(?:` + mainPrefix + `|` + substreamPrefix + `)(\d+|END)@(.*)$`),
+		streams:         map[string]*sequencedStream{"": {lines: make(map[int]string), total: -1}},
+	}
+}
+
+// stream returns the collected stream with the given name, adding it if necessary
+func (soc *SequencedOutputCollector) stream(name string) *sequencedStream {
+	if _, found := soc.streams[name]; !found {
+		soc.streams[name] = &sequencedStream{lines: make(map[int]string), total: -1}
+	}
+	return soc.streams[name]
+}
+
+// streamNames returns the names of all collected streams in order, starting with the main stream
+// (see compareStreamNames)
+func (soc *SequencedOutputCollector) streamNames() []string {
+	names := slices.Collect(maps.Keys(soc.streams))
+	slices.SortFunc(names, compareStreamNames)
+	return names
+}
+
+// compareStreamNames orders stream names so that the main stream ("") comes first, followed by
+// numeric names in numeric order (e.g., composite.Probe's child "2" before child "10"), followed by
+// any other names in lexical order
+func compareStreamNames(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case a == "" || b == "":
+		return strings.Compare(a, b)
+	case aErr == nil && bErr == nil:
+		return aNum - bNum
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// report returns line as reported by Collect and String, i.e., prefixed with the name of its
+// sub-stream (if any)
+func report(streamName string, line string) string {
+	if streamName == "" {
+		return line
+	}
+	return SubstreamLinePrefix + streamName + "@" + line
+}
+
+// Collect records every complete sequence-numbered line found in consoleOutput. Lines already
+// recorded by a previous call are ignored, so it's safe to pass overlapping console snapshots.
+// The final line of consoleOutput is skipped unless it's newline-terminated, as the probe may
+// still be in the middle of printing it. The lines newly recorded by this call (without their
+// sequence number prefixes) are returned in sequence order, e.g., so callers can report progress
+// while the probe is still running. Lines from sub-streams are returned after those from the main
+// stream and are prefixed with the name of their sub-stream (see CutSubstreamLine)
+func (soc *SequencedOutputCollector) Collect(consoleOutput string) []string {
+	consoleOutput = strings.ReplaceAll(consoleOutput, "\r\n", "\n")
+	lastNewline := strings.LastIndex(consoleOutput, "\n")
+	if lastNewline < 0 {
+		return nil
+	}
+
+	newSequenceNumbers := make(map[string][]int)
+	for _, line := range strings.Split(consoleOutput[:lastNewline], "\n") {
+		submatches := soc.reSequencedLine.FindStringSubmatch(line)
+		if submatches == nil {
+			continue
+		}
+		streamName := submatches[1]
+		stream := soc.stream(streamName)
+		if submatches[2] == "END" {
+			if total, err := strconv.Atoi(strings.TrimSpace(submatches[3])); err == nil {
+				stream.total = total
+			}
+			continue
+		}
+		sequenceNumber, err := strconv.Atoi(submatches[2])
+		if err != nil {
+			continue
+		}
+		if _, seen := stream.lines[sequenceNumber]; !seen {
+			stream.lines[sequenceNumber] = submatches[3]
+			newSequenceNumbers[streamName] = append(newSequenceNumbers[streamName], sequenceNumber)
+		}
+	}
+
+	newLines := []string{}
+	for _, streamName := range soc.streamNames() {
+		sequenceNumbers := newSequenceNumbers[streamName]
+		slices.Sort(sequenceNumbers)
+		for _, sequenceNumber := range sequenceNumbers {
+			newLines = append(newLines, report(streamName, soc.streams[streamName].lines[sequenceNumber]))
+		}
+	}
+	return newLines
+}
+
+// Started returns true if any sequence-numbered lines (or end-of-output lines) have been
+// collected, i.e., the probe is using sequenced output
+func (soc *SequencedOutputCollector) Started() bool {
+	for _, stream := range soc.streams {
+		if len(stream.lines) > 0 || stream.total >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Ended returns true once the main stream's end-of-output line has been collected
+func (soc *SequencedOutputCollector) Ended() bool {
+	return soc.streams[""].total >= 0
+}
+
+// Missing returns the sequence numbers of any lines of the main stream that were never collected,
+// in ascending order. The result is only meaningful once Ended() returns true
+func (soc *SequencedOutputCollector) Missing() []int {
+	return soc.streams[""].missing()
+}
+
+// missing returns the sequence numbers of any lines of the stream that were never collected, in
+// ascending order
+func (ss *sequencedStream) missing() []int {
+	var missing []int
+	for sequenceNumber := 1; sequenceNumber <= ss.total; sequenceNumber++ {
+		if _, seen := ss.lines[sequenceNumber]; !seen {
+			missing = append(missing, sequenceNumber)
+		}
+	}
+	return missing
+}
+
+// MissingError returns an error describing which lines are missing from each stream (see
+// Missing()), including any sub-streams whose end-of-output line was never collected, or nil if
+// none are
+func (soc *SequencedOutputCollector) MissingError() error {
+	var errs []error
+	for _, streamName := range soc.streamNames() {
+		stream := soc.streams[streamName]
+		prefix := "probe output incomplete: "
+		if streamName != "" {
+			prefix = fmt.Sprintf("probe output incomplete (sub-stream %s): ", streamName)
+			if stream.total < 0 {
+				errs = append(errs, errors.New(prefix+"end of output never seen; console output likely overflowed between reads"))
+				continue
+			}
+		}
+		if missing := stream.missing(); len(missing) > 0 {
+			errs = append(errs, fmt.Errorf(
+				"%s%d of %d lines missing (sequence numbers %s); console output likely overflowed between reads",
+				prefix, len(missing), stream.total, formatRanges(missing),
+			))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// UnfinishedError returns an error describing which lines are missing from each stream of output
+// that the probe never finished printing (i.e., whose end-of-output line was never collected):
+// any gaps in the lines collected so far, and every line after the last one collected. Streams
+// that were finished are described as in MissingError(). Returns nil if nothing is missing
+func (soc *SequencedOutputCollector) UnfinishedError() error {
+	var errs []error
+	for _, streamName := range soc.streamNames() {
+		stream := soc.streams[streamName]
+		prefix := "probe did not finish; "
+		if streamName != "" {
+			prefix = fmt.Sprintf("probe did not finish (sub-stream %s); ", streamName)
+		}
+		if stream.total >= 0 {
+			if missing := stream.missing(); len(missing) > 0 {
+				errs = append(errs, fmt.Errorf("%smissing lines %s (%d of %d lines collected)", prefix, formatRanges(missing), len(stream.lines), stream.total))
+			}
+			continue
+		}
+
+		last := 0
+		if len(stream.lines) > 0 {
+			last = slices.Max(slices.Collect(maps.Keys(stream.lines)))
+		}
+		var gaps []int
+		for sequenceNumber := 1; sequenceNumber < last; sequenceNumber++ {
+			if _, seen := stream.lines[sequenceNumber]; !seen {
+				gaps = append(gaps, sequenceNumber)
+			}
+		}
+		missing := fmt.Sprintf("%d onward", last+1)
+		if len(gaps) > 0 {
+			missing = formatRanges(gaps) + ", " + missing
+		}
+		errs = append(errs, fmt.Errorf("%smissing lines %s (%d lines collected)", prefix, missing, len(stream.lines)))
+	}
+	return errors.Join(errs...)
+}
+
+// String returns the collected lines (without their sequence number prefixes) in order,
+// separated by newlines. Lines from sub-streams follow those from the main stream and are
+// prefixed with the name of their sub-stream (see CutSubstreamLine)
+func (soc *SequencedOutputCollector) String() string {
+	var lines []string
+	for _, streamName := range soc.streamNames() {
+		stream := soc.streams[streamName]
+		sequenceNumbers := slices.Sorted(maps.Keys(stream.lines))
+		for _, sequenceNumber := range sequenceNumbers {
+			lines = append(lines, report(streamName, stream.lines[sequenceNumber]))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatRanges summarizes a sorted slice of integers as comma-separated ranges,
+// e.g., [1 2 3 5 7 8] -> "1-3, 5, 7-8"
+func formatRanges(sortedInts []int) string {
+	var ranges []string
+	for i := 0; i < len(sortedInts); {
+		j := i
+		for j+1 < len(sortedInts) && sortedInts[j+1] == sortedInts[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(sortedInts[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", sortedInts[i], sortedInts[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ", ")
+}
@@ -0,0 +1,247 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSequencedOutputCollector(t *testing.T) {
+	tests := []struct {
+		name            string
+		nonce           string
+		consoleOutputs  []string
+		wantStarted     bool
+		wantEnded       bool
+		wantMissing     []int
+		wantMissingErr  string
+		wantProbeOutput string
+	}{
+		{
+			name:           "no sequenced output",
+			consoleOutputs: []string{"NV_CURLJSON_BEGIN\n@NV@{}\nNV_CURLJSON_END\n"},
+		},
+		{
+			name:            "complete output in one read",
+			consoleOutputs:  []string{"boot noise\n@NVSEQ@1@@NV@{\"a\":1}\n@NVSEQ@2@@NV@{\"b\":2}\n@NVSEQ@END@2\nNV_CURLJSON_END\n"},
+			wantStarted:     true,
+			wantEnded:       true,
+			wantProbeOutput: "@NV@{\"a\":1}\n@NV@{\"b\":2}",
+		},
+		{
+			name: "output reassembled from overlapping reads",
+			consoleOutputs: []string{
+				"NV_CURLJSON_BEGIN\r\n@NVSEQ@1@one\r\n@NVSEQ@2@two\r\n",
+				"@NVSEQ@2@two\n@NVSEQ@3@three\n@NVSEQ@END@3\n",
+			},
+			wantStarted:     true,
+			wantEnded:       true,
+			wantProbeOutput: "one\ntwo\nthree",
+		},
+		{
+			name:            "unterminated final line ignored until complete",
+			consoleOutputs:  []string{"@NVSEQ@1@one\n@NVSEQ@2@tw"},
+			wantStarted:     true,
+			wantProbeOutput: "one",
+		},
+		{
+			name: "lines lost between reads",
+			consoleOutputs: []string{
+				"@NVSEQ@1@one\n@NVSEQ@2@two\n",
+				"@NVSEQ@5@five\n@NVSEQ@7@seven\n@NVSEQ@END@7\n",
+			},
+			wantStarted:     true,
+			wantEnded:       true,
+			wantMissing:     []int{3, 4, 6},
+			wantMissingErr:  "probe output incomplete: 3 of 7 lines missing (sequence numbers 3-4, 6); console output likely overflowed between reads",
+			wantProbeOutput: "one\ntwo\nfive\nseven",
+		},
+		{
+			name:  "stale output from a previous run ignored",
+			nonce: "abc123",
+			consoleOutputs: []string{
+				"@NVSEQ@1@stale\n@NVSEQ@ffff00@1@stale\n@NVSEQ@END@1\n@NVSEQ@abc123@1@one\n@NVSEQ@abc123@END@1\n",
+			},
+			wantStarted:     true,
+			wantEnded:       true,
+			wantProbeOutput: "one",
+		},
+		{
+			name:  "sub-streams reassembled separately",
+			nonce: "abc123",
+			consoleOutputs: []string{
+				"@NVSEQ@abc123.1@1@curl one\n@NVSEQ@abc123.1@2@curl two\n@NVSEQ@abc123.1@END@2\n@NVSEQ@abc123.2@1@dns one\n",
+				"@NVSEQ@abc123.2@END@1\n@NVSEQ@abc123@END@0\n",
+			},
+			wantStarted:     true,
+			wantEnded:       true,
+			wantProbeOutput: "@NVSUB@1@curl one\n@NVSUB@1@curl two\n@NVSUB@2@dns one",
+		},
+		{
+			name:  "incomplete sub-streams reported",
+			nonce: "abc123",
+			consoleOutputs: []string{
+				"@NVSEQ@abc123.1@2@curl two\n@NVSEQ@abc123.1@END@2\n@NVSEQ@abc123.2@1@dns one\n@NVSEQ@abc123@END@0\n",
+			},
+			wantStarted: true,
+			wantEnded:   true,
+			wantMissingErr: "probe output incomplete (sub-stream 1): 1 of 2 lines missing (sequence numbers 1); console output likely overflowed between reads\n" +
+				"probe output incomplete (sub-stream 2): end of output never seen; console output likely overflowed between reads",
+			wantProbeOutput: "@NVSUB@1@curl two\n@NVSUB@2@dns one",
+		},
+		{
+			name:  "sub-streams ordered numerically",
+			nonce: "abc123",
+			consoleOutputs: []string{
+				"@NVSEQ@abc123.10@1@ten\n@NVSEQ@abc123.10@END@1\n@NVSEQ@abc123.2@1@two\n@NVSEQ@abc123.2@END@1\n@NVSEQ@abc123@END@0\n",
+			},
+			wantStarted:     true,
+			wantEnded:       true,
+			wantProbeOutput: "@NVSUB@2@two\n@NVSUB@10@ten",
+		},
+		{
+			name:           "empty sequenced output",
+			consoleOutputs: []string{"@NVSEQ@END@0\n"},
+			wantStarted:    true,
+			wantEnded:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewSequencedOutputCollector(tt.nonce)
+			for _, consoleOutput := range tt.consoleOutputs {
+				collector.Collect(consoleOutput)
+			}
+
+			if got := collector.Started(); got != tt.wantStarted {
+				t.Errorf("Started() = %v, want %v", got, tt.wantStarted)
+			}
+			if got := collector.Ended(); got != tt.wantEnded {
+				t.Errorf("Ended() = %v, want %v", got, tt.wantEnded)
+			}
+			if got := collector.Missing(); !reflect.DeepEqual(got, tt.wantMissing) {
+				t.Errorf("Missing() = %v, want %v", got, tt.wantMissing)
+			}
+			gotMissingErr := ""
+			if err := collector.MissingError(); err != nil {
+				gotMissingErr = err.Error()
+			}
+			if gotMissingErr != tt.wantMissingErr {
+				t.Errorf("MissingError() = %q, want %q", gotMissingErr, tt.wantMissingErr)
+			}
+			if got := collector.String(); got != tt.wantProbeOutput {
+				t.Errorf("String() = %q, want %q", got, tt.wantProbeOutput)
+			}
+		})
+	}
+}
+
+func TestSequencedOutputCollector_CollectReturnsNewLines(t *testing.T) {
+	collector := NewSequencedOutputCollector("")
+	reads := []struct {
+		consoleOutput string
+		wantNewLines  []string
+	}{
+		{"@NVSEQ@2@two\n@NVSEQ@1@one\n@NVSEQ@3@thr", []string{"one", "two"}},
+		{"@NVSEQ@2@two\n@NVSEQ@1@one\n@NVSEQ@3@three\n", []string{"three"}},
+		{"@NVSEQ@3@three\n@NVSEQ@END@3\n", []string{}},
+	}
+	for i, read := range reads {
+		if got := collector.Collect(read.consoleOutput); !reflect.DeepEqual(got, read.wantNewLines) {
+			t.Errorf("read %d: Collect() = %q, want %q", i+1, got, read.wantNewLines)
+		}
+	}
+}
+
+func TestSequencedOutputCollector_UnfinishedError(t *testing.T) {
+	tests := []struct {
+		name          string
+		consoleOutput string
+		want          string
+	}{
+		{
+			name:          "lines after the last one collected",
+			consoleOutput: "@NVSEQ@abc123@1@one\n@NVSEQ@abc123@2@two\n",
+			want:          "probe did not finish; missing lines 3 onward (2 lines collected)",
+		},
+		{
+			name:          "gaps and lines after the last one collected",
+			consoleOutput: "@NVSEQ@abc123@1@one\n@NVSEQ@abc123@4@four\n",
+			want:          "probe did not finish; missing lines 2-3, 5 onward (2 lines collected)",
+		},
+		{
+			name:          "sub-streams",
+			consoleOutput: "@NVSEQ@abc123.1@2@curl two\n@NVSEQ@abc123.1@END@2\n@NVSEQ@abc123.2@1@dns one\n",
+			want: "probe did not finish; missing lines 1 onward (0 lines collected)\n" +
+				"probe did not finish (sub-stream 1); missing lines 1 (1 of 2 lines collected)\n" +
+				"probe did not finish (sub-stream 2); missing lines 2 onward (1 lines collected)",
+		},
+		{
+			name:          "finished",
+			consoleOutput: "@NVSEQ@abc123@1@one\n@NVSEQ@abc123@END@1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewSequencedOutputCollector("abc123")
+			collector.Collect(tt.consoleOutput)
+			got := ""
+			if err := collector.UnfinishedError(); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("UnfinishedError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutSubstreamLine(t *testing.T) {
+	tests := []struct {
+		line             string
+		wantStream       string
+		wantOriginalLine string
+		wantOK           bool
+	}{
+		{"@NVSUB@2@@NV@{}", "2", "@NV@{}", true},
+		{"@NV@{}", "", "", false},
+	}
+	for _, tt := range tests {
+		gotStream, gotOriginalLine, gotOK := CutSubstreamLine(tt.line)
+		if gotStream != tt.wantStream || gotOriginalLine != tt.wantOriginalLine || gotOK != tt.wantOK {
+			t.Errorf("CutSubstreamLine(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.line, gotStream, gotOriginalLine, gotOK, tt.wantStream, tt.wantOriginalLine, tt.wantOK)
+		}
+	}
+}
+
+func TestSequenceLinesCommand(t *testing.T) {
+	tests := []struct {
+		nonce      string
+		wantPrefix string
+	}{
+		{"", `printf "@NVSEQ@%d@%s\n"`},
+		{"abc123", `printf "@NVSEQ@abc123@%d@%s\n"`},
+	}
+	for _, tt := range tests {
+		got := SequenceLinesCommand(tt.nonce)
+		if !strings.Contains(got, tt.wantPrefix) {
+			t.Errorf("SequenceLinesCommand(%q) = %s, want it to contain %s", tt.nonce, got, tt.wantPrefix)
+		}
+	}
+}
+
+func TestFormatRanges(t *testing.T) {
+	tests := []struct {
+		input []int
+		want  string
+	}{
+		{nil, ""},
+		{[]int{4}, "4"},
+		{[]int{1, 2, 3, 5, 7, 8}, "1-3, 5, 7-8"},
+	}
+	for _, tt := range tests {
+		if got := formatRanges(tt.input); got != tt.want {
+			t.Errorf("formatRanges(%v) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
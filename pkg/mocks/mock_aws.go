@@ -14,6 +14,8 @@ import (
 	reflect "reflect"
 
 	ec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	route53 "github.com/aws/aws-sdk-go-v2/service/route53"
+	route53resolver "github.com/aws/aws-sdk-go-v2/service/route53resolver"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -21,6 +23,7 @@ import (
 type MockEC2Client struct {
 	ctrl     *gomock.Controller
 	recorder *MockEC2ClientMockRecorder
+	isgomock struct{}
 }
 
 // MockEC2ClientMockRecorder is the mock recorder for MockEC2Client.
@@ -120,6 +123,26 @@ func (mr *MockEC2ClientMockRecorder) DeleteSecurityGroup(ctx, params any, optFns
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSecurityGroup", reflect.TypeOf((*MockEC2Client)(nil).DeleteSecurityGroup), varargs...)
 }
 
+// DescribeDhcpOptions mocks base method.
+func (m *MockEC2Client) DescribeDhcpOptions(ctx context.Context, params *ec2.DescribeDhcpOptionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeDhcpOptionsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeDhcpOptions", varargs...)
+	ret0, _ := ret[0].(*ec2.DescribeDhcpOptionsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeDhcpOptions indicates an expected call of DescribeDhcpOptions.
+func (mr *MockEC2ClientMockRecorder) DescribeDhcpOptions(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeDhcpOptions", reflect.TypeOf((*MockEC2Client)(nil).DescribeDhcpOptions), varargs...)
+}
+
 // DescribeInstanceTypes mocks base method.
 func (m *MockEC2Client) DescribeInstanceTypes(ctx context.Context, input *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
 	m.ctrl.T.Helper()
@@ -180,6 +203,66 @@ func (mr *MockEC2ClientMockRecorder) DescribeKeyPairs(ctx, params any, optFns ..
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeKeyPairs", reflect.TypeOf((*MockEC2Client)(nil).DescribeKeyPairs), varargs...)
 }
 
+// DescribeNatGateways mocks base method.
+func (m *MockEC2Client) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeNatGateways", varargs...)
+	ret0, _ := ret[0].(*ec2.DescribeNatGatewaysOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeNatGateways indicates an expected call of DescribeNatGateways.
+func (mr *MockEC2ClientMockRecorder) DescribeNatGateways(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeNatGateways", reflect.TypeOf((*MockEC2Client)(nil).DescribeNatGateways), varargs...)
+}
+
+// DescribeNetworkAcls mocks base method.
+func (m *MockEC2Client) DescribeNetworkAcls(ctx context.Context, params *ec2.DescribeNetworkAclsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeNetworkAcls", varargs...)
+	ret0, _ := ret[0].(*ec2.DescribeNetworkAclsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeNetworkAcls indicates an expected call of DescribeNetworkAcls.
+func (mr *MockEC2ClientMockRecorder) DescribeNetworkAcls(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeNetworkAcls", reflect.TypeOf((*MockEC2Client)(nil).DescribeNetworkAcls), varargs...)
+}
+
+// DescribeRouteTables mocks base method.
+func (m *MockEC2Client) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeRouteTables", varargs...)
+	ret0, _ := ret[0].(*ec2.DescribeRouteTablesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeRouteTables indicates an expected call of DescribeRouteTables.
+func (mr *MockEC2ClientMockRecorder) DescribeRouteTables(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeRouteTables", reflect.TypeOf((*MockEC2Client)(nil).DescribeRouteTables), varargs...)
+}
+
 // DescribeSecurityGroups mocks base method.
 func (m *MockEC2Client) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
 	m.ctrl.T.Helper()
@@ -200,6 +283,26 @@ func (mr *MockEC2ClientMockRecorder) DescribeSecurityGroups(ctx, params any, opt
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeSecurityGroups", reflect.TypeOf((*MockEC2Client)(nil).DescribeSecurityGroups), varargs...)
 }
 
+// DescribeStaleSecurityGroups mocks base method.
+func (m *MockEC2Client) DescribeStaleSecurityGroups(ctx context.Context, params *ec2.DescribeStaleSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeStaleSecurityGroupsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeStaleSecurityGroups", varargs...)
+	ret0, _ := ret[0].(*ec2.DescribeStaleSecurityGroupsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeStaleSecurityGroups indicates an expected call of DescribeStaleSecurityGroups.
+func (mr *MockEC2ClientMockRecorder) DescribeStaleSecurityGroups(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeStaleSecurityGroups", reflect.TypeOf((*MockEC2Client)(nil).DescribeStaleSecurityGroups), varargs...)
+}
+
 // DescribeSubnets mocks base method.
 func (m *MockEC2Client) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
 	m.ctrl.T.Helper()
@@ -240,6 +343,46 @@ func (mr *MockEC2ClientMockRecorder) DescribeVpcAttribute(ctx, input any, optFns
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeVpcAttribute", reflect.TypeOf((*MockEC2Client)(nil).DescribeVpcAttribute), varargs...)
 }
 
+// DescribeVpcEndpoints mocks base method.
+func (m *MockEC2Client) DescribeVpcEndpoints(ctx context.Context, params *ec2.DescribeVpcEndpointsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcEndpointsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeVpcEndpoints", varargs...)
+	ret0, _ := ret[0].(*ec2.DescribeVpcEndpointsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeVpcEndpoints indicates an expected call of DescribeVpcEndpoints.
+func (mr *MockEC2ClientMockRecorder) DescribeVpcEndpoints(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeVpcEndpoints", reflect.TypeOf((*MockEC2Client)(nil).DescribeVpcEndpoints), varargs...)
+}
+
+// DescribeVpcs mocks base method.
+func (m *MockEC2Client) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeVpcs", varargs...)
+	ret0, _ := ret[0].(*ec2.DescribeVpcsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeVpcs indicates an expected call of DescribeVpcs.
+func (mr *MockEC2ClientMockRecorder) DescribeVpcs(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeVpcs", reflect.TypeOf((*MockEC2Client)(nil).DescribeVpcs), varargs...)
+}
+
 // GetConsoleOutput mocks base method.
 func (m *MockEC2Client) GetConsoleOutput(ctx context.Context, input *ec2.GetConsoleOutputInput, optFns ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error) {
 	m.ctrl.T.Helper()
@@ -359,3 +502,111 @@ func (mr *MockEC2ClientMockRecorder) TerminateInstances(ctx, input any, optFns .
 	varargs := append([]any{ctx, input}, optFns...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TerminateInstances", reflect.TypeOf((*MockEC2Client)(nil).TerminateInstances), varargs...)
 }
+
+// MockRoute53Client is a mock of Route53Client interface.
+type MockRoute53Client struct {
+	ctrl     *gomock.Controller
+	recorder *MockRoute53ClientMockRecorder
+	isgomock struct{}
+}
+
+// MockRoute53ClientMockRecorder is the mock recorder for MockRoute53Client.
+type MockRoute53ClientMockRecorder struct {
+	mock *MockRoute53Client
+}
+
+// NewMockRoute53Client creates a new mock instance.
+func NewMockRoute53Client(ctrl *gomock.Controller) *MockRoute53Client {
+	mock := &MockRoute53Client{ctrl: ctrl}
+	mock.recorder = &MockRoute53ClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRoute53Client) EXPECT() *MockRoute53ClientMockRecorder {
+	return m.recorder
+}
+
+// ListHostedZonesByVPC mocks base method.
+func (m *MockRoute53Client) ListHostedZonesByVPC(ctx context.Context, params *route53.ListHostedZonesByVPCInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByVPCOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListHostedZonesByVPC", varargs...)
+	ret0, _ := ret[0].(*route53.ListHostedZonesByVPCOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListHostedZonesByVPC indicates an expected call of ListHostedZonesByVPC.
+func (mr *MockRoute53ClientMockRecorder) ListHostedZonesByVPC(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListHostedZonesByVPC", reflect.TypeOf((*MockRoute53Client)(nil).ListHostedZonesByVPC), varargs...)
+}
+
+// MockRoute53ResolverClient is a mock of Route53ResolverClient interface.
+type MockRoute53ResolverClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockRoute53ResolverClientMockRecorder
+	isgomock struct{}
+}
+
+// MockRoute53ResolverClientMockRecorder is the mock recorder for MockRoute53ResolverClient.
+type MockRoute53ResolverClientMockRecorder struct {
+	mock *MockRoute53ResolverClient
+}
+
+// NewMockRoute53ResolverClient creates a new mock instance.
+func NewMockRoute53ResolverClient(ctrl *gomock.Controller) *MockRoute53ResolverClient {
+	mock := &MockRoute53ResolverClient{ctrl: ctrl}
+	mock.recorder = &MockRoute53ResolverClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRoute53ResolverClient) EXPECT() *MockRoute53ResolverClientMockRecorder {
+	return m.recorder
+}
+
+// ListResolverRuleAssociations mocks base method.
+func (m *MockRoute53ResolverClient) ListResolverRuleAssociations(ctx context.Context, params *route53resolver.ListResolverRuleAssociationsInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRuleAssociationsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListResolverRuleAssociations", varargs...)
+	ret0, _ := ret[0].(*route53resolver.ListResolverRuleAssociationsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListResolverRuleAssociations indicates an expected call of ListResolverRuleAssociations.
+func (mr *MockRoute53ResolverClientMockRecorder) ListResolverRuleAssociations(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListResolverRuleAssociations", reflect.TypeOf((*MockRoute53ResolverClient)(nil).ListResolverRuleAssociations), varargs...)
+}
+
+// ListResolverRules mocks base method.
+func (m *MockRoute53ResolverClient) ListResolverRules(ctx context.Context, params *route53resolver.ListResolverRulesInput, optFns ...func(*route53resolver.Options)) (*route53resolver.ListResolverRulesOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListResolverRules", varargs...)
+	ret0, _ := ret[0].(*route53resolver.ListResolverRulesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListResolverRules indicates an expected call of ListResolverRules.
+func (mr *MockRoute53ResolverClientMockRecorder) ListResolverRules(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListResolverRules", reflect.TypeOf((*MockRoute53ResolverClient)(nil).ListResolverRules), varargs...)
+}
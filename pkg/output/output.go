@@ -19,12 +19,34 @@ type Output struct {
 	exceptions []error
 	// errors is collection of unhandled errors
 	errors []error
+	// info holds informational results (e.g., DNS answers) that don't affect success
+	info []string
+	// warnings represents findings worth investigating that don't affect success (e.g., TLS interception)
+	warnings []error
+	// proxyFindings represents problems with the configured proxy found by proxy diagnostics, kept
+	// apart from egress failures
+	proxyFindings []error
+	// subnetResults holds the results of each subnet verified in a multi-subnet run
+	subnetResults []SubnetResult
 }
 
 func (o *Output) AddDebugLogs(log string) {
 	o.debugLogs = append(o.debugLogs, log)
 }
 
+// AddInfo adds an informational result to be shown in the summary regardless of success
+func (o *Output) AddInfo(info string) {
+	o.info = append(o.info, info)
+}
+
+// AddWarning adds a warning to the list of warnings. Warnings are shown in the summary but
+// don't cause the verifier to fail
+func (o *Output) AddWarning(warning error) {
+	if warning != nil {
+		o.warnings = append(o.warnings, warning)
+	}
+}
+
 // AddError adds error as generic to the list of errors
 func (o *Output) AddError(err error) *Output {
 	if err != nil {
@@ -39,6 +61,13 @@ func (o *Output) AddException(message error) {
 	o.exceptions = append(o.exceptions, message)
 }
 
+// AddProxyFinding adds a problem found by proxy diagnostics to the list of proxy findings
+func (o *Output) AddProxyFinding(finding error) {
+	if finding != nil {
+		o.proxyFindings = append(o.proxyFindings, finding)
+	}
+}
+
 // SetEgressFailures sets egress endpoint failures as a bulk update
 func (o *Output) SetEgressFailures(failures []string) {
 	for _, f := range failures {
@@ -46,13 +75,21 @@ func (o *Output) SetEgressFailures(failures []string) {
 	}
 }
 
+// SetTransferFailures sets large-payload transfer failures as a bulk update. These are stored
+// alongside egress failures but can be told apart using GetTransferFailures()
+func (o *Output) SetTransferFailures(failures []string) {
+	for _, f := range failures {
+		o.failures = append(o.failures, handledErrors.NewTransferError(f))
+	}
+}
+
 // IsSuccessful checks whether the output contains any item, returns false if there's any
 func (o *Output) IsSuccessful() bool {
-	if len(o.errors) > 0 || len(o.exceptions) > 0 || len(o.failures) > 0 {
+	if len(o.errors) > 0 || len(o.exceptions) > 0 || len(o.failures) > 0 || len(o.proxyFindings) > 0 {
 		return false
 	}
 
-	return true
+	return o.subnetsSuccessful()
 }
 
 // Format can be used to retrieve the string for the output structure
@@ -65,12 +102,25 @@ func (o *Output) Format(debug bool) string {
 		output += "printing out debug logs from the execution:\n"
 		output += format(o.debugLogs)
 	}
+	if len(o.info) > 0 {
+		output += "printing out informational results:\n"
+		output += format(o.info)
+	}
+	if len(o.warnings) > 0 {
+		output += "printing out warnings:\n"
+		output += format(o.warnings)
+	}
+	output += o.formatSubnetResults(debug)
 	if o.IsSuccessful() {
 		output += "All tests passed!\n"
 		return output
 	}
 	output += "printing out failures:\n"
 	output += format(o.failures)
+	if len(o.proxyFindings) > 0 {
+		output += "printing out proxy findings:\n"
+		output += format(o.proxyFindings)
+	}
 	output += "printing out exceptions preventing the verifier from running the specific test:\n"
 	output += format(o.exceptions)
 	output += "printing out errors faced during the execution:\n"
@@ -103,6 +153,21 @@ func (o *Output) Parse() ([]error, []error, []error) {
 	return o.failures, o.exceptions, o.errors
 }
 
+// GetInfo returns the informational results stored on output
+func (o *Output) GetInfo() []string {
+	return o.info
+}
+
+// GetWarnings returns the warnings stored on output
+func (o *Output) GetWarnings() []error {
+	return o.warnings
+}
+
+// GetProxyFindings returns the problems with the configured proxy found by proxy diagnostics
+func (o *Output) GetProxyFindings() []error {
+	return o.proxyFindings
+}
+
 // GetEgressURLFailures returns only errors related to network egress failures.
 // Use the EgressURL() method to obtain the specific url for each error.
 func (o *Output) GetEgressURLFailures() []*handledErrors.GenericError {
@@ -119,3 +184,18 @@ func (o *Output) GetEgressURLFailures() []*handledErrors.GenericError {
 
 	return egressErrs
 }
+
+// GetTransferFailures returns only errors related to large-payload transfer failures.
+// Use the EgressURL() method to obtain the specific url for each error.
+func (o *Output) GetTransferFailures() []*handledErrors.TransferError {
+	transferErrs := []*handledErrors.TransferError{}
+
+	for _, err := range o.failures {
+		var te *handledErrors.TransferError
+		if errors.As(err, &te) {
+			transferErrs = append(transferErrs, te)
+		}
+	}
+
+	return transferErrs
+}
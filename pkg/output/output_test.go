@@ -2,6 +2,8 @@ package output
 
 import (
 	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	nverr "github.com/openshift/osd-network-verifier/pkg/errors"
@@ -34,6 +36,7 @@ func TestGetEgressURLFailures(t *testing.T) {
 				failures: []error{
 					nverr.NewGenericError(errors.New("oops")),
 					nverr.NewEgressURLError("www.example.com:443"),
+					nverr.NewTransferError("www.example.com:443 (stalled)"),
 					errors.New("idk"),
 				},
 			},
@@ -50,3 +53,108 @@ func TestGetEgressURLFailures(t *testing.T) {
 		})
 	}
 }
+
+func TestWarningsDoNotAffectSuccess(t *testing.T) {
+	o := &Output{}
+	o.AddWarning(nverr.NewTLSInterceptionWarning("https://www.example.com:443", "(issued by proxy CA)"))
+	o.AddWarning(nil)
+
+	if !o.IsSuccessful() {
+		t.Errorf("expected output with only warnings to be successful")
+	}
+	if len(o.GetWarnings()) != 1 {
+		t.Errorf("expected 1 warning, got %d: %v", len(o.GetWarnings()), o.GetWarnings())
+	}
+}
+
+func TestProxyFindingsAreSeparateFromFailures(t *testing.T) {
+	o := &Output{}
+	o.AddProxyFinding(nverr.NewProxyFinding("connect", "www.example.com:9997", "proxy refused CONNECT (HTTP/1.1 403 Forbidden)"))
+	o.AddProxyFinding(nil)
+
+	if o.IsSuccessful() {
+		t.Errorf("expected output with a proxy finding to be unsuccessful")
+	}
+	if len(o.GetProxyFindings()) != 1 {
+		t.Errorf("expected 1 proxy finding, got %d: %v", len(o.GetProxyFindings()), o.GetProxyFindings())
+	}
+	if len(o.GetEgressURLFailures()) != 0 {
+		t.Errorf("expected no egress failures, got %v", o.GetEgressURLFailures())
+	}
+}
+
+func TestGetTransferFailures(t *testing.T) {
+	o := &Output{}
+	o.SetEgressFailures([]string{"www.example.com:443"})
+	o.SetTransferFailures([]string{"www.example.com:443 (stalled)"})
+
+	if o.IsSuccessful() {
+		t.Errorf("expected output with transfer failures to be unsuccessful")
+	}
+	if got := len(o.GetTransferFailures()); got != 1 {
+		t.Errorf("expected 1 transfer failure, got %d", got)
+	}
+	if got := len(o.GetEgressURLFailures()); got != 1 {
+		t.Errorf("expected 1 egress failure, got %d", got)
+	}
+}
+
+func TestSubnetEgressMatrix(t *testing.T) {
+	subnetA := &Output{}
+	subnetA.SetEgressFailures([]string{"https://quay.io:443 [ipv4] (Failed to connect)", "https://api.openshift.com:443 [ipv4] (Timed out)"})
+	subnetB := &Output{}
+	subnetB.SetEgressFailures([]string{"https://api.openshift.com:443 [ipv4] (Connection reset)"})
+	subnetC := &Output{}
+	subnetC.AddError(errors.New("unable to launch probe instance"))
+
+	o := &Output{}
+	o.AddSubnetResult("subnet-a (us-east-1a)", subnetA)
+	o.AddSubnetResult("subnet-b (us-east-1b)", subnetB)
+	o.AddSubnetResult("subnet-c (us-east-1c)", subnetC)
+
+	if o.IsSuccessful() {
+		t.Errorf("expected output with failed subnets to be unsuccessful")
+	}
+	if got := len(o.GetSubnetResults()); got != 3 {
+		t.Errorf("expected 3 subnet results, got %d", got)
+	}
+
+	want := []SubnetEgressRow{
+		{Endpoint: "https://quay.io:443 [ipv4]", Statuses: []SubnetEgressStatus{SubnetEgressFailed, SubnetEgressPassed, SubnetEgressUnknown}},
+		{Endpoint: "https://api.openshift.com:443 [ipv4]", Statuses: []SubnetEgressStatus{SubnetEgressFailed, SubnetEgressFailed, SubnetEgressUnknown}},
+	}
+	got := o.GetSubnetEgressMatrix()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetSubnetEgressMatrix() = %+v, want %+v", got, want)
+	}
+	if !got[0].Diverges() || got[1].Diverges() {
+		t.Errorf("expected only the first row to diverge, got %v and %v", got[0].Diverges(), got[1].Diverges())
+	}
+
+	summary := o.Format(false)
+	for _, want := range []string{
+		"printing out results for subnet subnet-b (us-east-1b):\n",
+		"printing out cross-subnet egress matrix",
+		"!  https://quay.io:443 [ipv4]",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Format() = %q, want it to contain %q", summary, want)
+		}
+	}
+}
+
+func TestSubnetResultsSuccessful(t *testing.T) {
+	o := &Output{}
+	o.AddSubnetResult("subnet-a", &Output{})
+	o.AddSubnetResult("subnet-b", nil)
+
+	if !o.IsSuccessful() {
+		t.Errorf("expected output with only successful subnets to be successful")
+	}
+	if matrix := o.GetSubnetEgressMatrix(); len(matrix) != 0 {
+		t.Errorf("expected an empty matrix, got %v", matrix)
+	}
+	if summary := o.Format(false); strings.Contains(summary, "matrix") {
+		t.Errorf("Format() = %q, want no matrix", summary)
+	}
+}
@@ -0,0 +1,152 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// SubnetResult holds the results of verifying egress from a single subnet as part of a
+// multi-subnet run
+type SubnetResult struct {
+	// Label identifies the subnet, e.g., "subnet-0123456789abcdef0 (us-east-1a)"
+	Label string
+	// Output holds the subnet's own failures, exceptions, errors, etc.
+	Output *Output
+}
+
+// SubnetEgressStatus describes whether an endpoint was reachable from a subnet
+type SubnetEgressStatus string
+
+const (
+	SubnetEgressPassed SubnetEgressStatus = "pass"
+	SubnetEgressFailed SubnetEgressStatus = "FAIL"
+	// SubnetEgressUnknown means the endpoint didn't fail, but errors prevented the subnet from
+	// being fully verified (e.g., the probe instance couldn't be launched)
+	SubnetEgressUnknown SubnetEgressStatus = "?"
+)
+
+// SubnetEgressRow is a row of the cross-subnet egress matrix, describing whether a single
+// endpoint was reachable from each subnet (in the same order as GetSubnetResults)
+type SubnetEgressRow struct {
+	Endpoint string
+	Statuses []SubnetEgressStatus
+}
+
+// Diverges returns true if the endpoint was reachable from at least one subnet but not from
+// another, e.g., because only one availability zone's route table or NACL blocks it
+func (r SubnetEgressRow) Diverges() bool {
+	var passed, failed bool
+	for _, status := range r.Statuses {
+		passed = passed || status == SubnetEgressPassed
+		failed = failed || status == SubnetEgressFailed
+	}
+	return passed && failed
+}
+
+// AddSubnetResult nests the results of verifying egress from a single subnet (identified by label)
+// within o. Nested results are shown in their own sections of the summary, followed by a
+// cross-subnet matrix of any failed endpoints, and count towards o's success
+func (o *Output) AddSubnetResult(label string, result *Output) {
+	if result == nil {
+		result = &Output{}
+	}
+	o.subnetResults = append(o.subnetResults, SubnetResult{Label: label, Output: result})
+}
+
+// GetSubnetResults returns the per-subnet results nested within o, in the order they were added
+func (o *Output) GetSubnetResults() []SubnetResult {
+	return o.subnetResults
+}
+
+// GetSubnetEgressMatrix returns a row for every endpoint that failed in at least one of o's
+// nested subnet results, in the order the failures were first seen
+func (o *Output) GetSubnetEgressMatrix() []SubnetEgressRow {
+	var rows []SubnetEgressRow
+	rowIndexes := map[string]int{}
+	for i, subnetResult := range o.subnetResults {
+		for _, failure := range subnetResult.Output.GetEgressURLFailures() {
+			endpoint := matrixEndpoint(failure.EgressURL())
+			rowIndex, ok := rowIndexes[endpoint]
+			if !ok {
+				rowIndex = len(rows)
+				rowIndexes[endpoint] = rowIndex
+				rows = append(rows, SubnetEgressRow{Endpoint: endpoint, Statuses: make([]SubnetEgressStatus, len(o.subnetResults))})
+			}
+			rows[rowIndex].Statuses[i] = SubnetEgressFailed
+		}
+	}
+
+	for _, row := range rows {
+		for i, subnetResult := range o.subnetResults {
+			if row.Statuses[i] != "" {
+				continue
+			}
+			row.Statuses[i] = SubnetEgressPassed
+			if len(subnetResult.Output.errors) > 0 {
+				row.Statuses[i] = SubnetEgressUnknown
+			}
+		}
+	}
+	return rows
+}
+
+// matrixEndpoint returns an egress failure's endpoint (e.g., "https://quay.io:443 [ipv4]") without
+// the error message that follows it, so that failures of the same endpoint in different subnets
+// share a row of the matrix
+func matrixEndpoint(egressURL string) string {
+	endpoint, _, _ := strings.Cut(egressURL, " (")
+	return endpoint
+}
+
+// subnetsSuccessful returns true if every nested subnet result is successful
+func (o *Output) subnetsSuccessful() bool {
+	for _, subnetResult := range o.subnetResults {
+		if !subnetResult.Output.IsSuccessful() {
+			return false
+		}
+	}
+	return true
+}
+
+// formatSubnetResults returns a section of the summary for each nested subnet result, followed by
+// the cross-subnet egress matrix (if there's more than one subnet and anything failed)
+func (o *Output) formatSubnetResults(debug bool) string {
+	output := ""
+	for _, subnetResult := range o.subnetResults {
+		output += fmt.Sprintf("printing out results for subnet %s:\n", subnetResult.Label)
+		for _, line := range strings.SplitAfter(subnetResult.Output.Format(debug), "\n") {
+			if strings.TrimSpace(line) != "" {
+				line = "  " + line
+			}
+			output += line
+		}
+		output += "\n"
+	}
+
+	matrix := o.GetSubnetEgressMatrix()
+	if len(o.subnetResults) < 2 || len(matrix) == 0 {
+		return output
+	}
+	output += "printing out cross-subnet egress matrix (! = reachable from some subnets but not others):\n"
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	header := "  \tENDPOINT"
+	for _, subnetResult := range o.subnetResults {
+		header += "\t" + subnetResult.Label
+	}
+	fmt.Fprintln(tw, header)
+	for _, row := range matrix {
+		marker := " "
+		if row.Diverges() {
+			marker = "!"
+		}
+		line := fmt.Sprintf("  %s\t%s", marker, row.Endpoint)
+		for _, status := range row.Statuses {
+			line += "\t" + string(status)
+		}
+		fmt.Fprintln(tw, line)
+	}
+	tw.Flush()
+	return output + table.String() + "\n"
+}
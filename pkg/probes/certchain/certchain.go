@@ -0,0 +1,182 @@
+package certchain
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+)
+
+// certchain.Probe is an implementation of the probes.Probe interface that detects TLS
+// interception (e.g., by TLS-inspecting firewalls) on HTTPS egress endpoints. It launches the
+// same unmodified RHEL9 image as curl.Probe and uses userdata to install a small Python script
+// that performs a TLS handshake with each HTTPS endpoint on the egress list (through the
+// configured proxy, if any) using `openssl s_client`, then summarizes the certificate chain
+// presented (subject, issuer, SANs, and expiry) via serial console. Endpoints whose chain
+// can't be verified against public CAs, or whose chain was issued by the user-provided proxy
+// CA certificate, are reported as TLS interception warnings; endpoints that can't be reached
+// at all are reported as egress failures. Endpoints marked tlsDisabled in the egress list are
+// skipped. This probe relies on cloud-init and therefore only supports AWS.
+type Probe struct{}
+
+//go:embed userdata-template.yaml
+var userDataTemplate string
+
+//go:embed inspect.py
+var inspectScript string
+
+const startingToken = "NV_CERTCHAINJSON_BEGIN" //nolint:gosec
+const endingToken = "NV_CERTCHAINJSON_END"     //nolint:gosec
+
+// outputLinePrefix is printed by inspect.py before each JSON-formatted endpoint result
+const outputLinePrefix = "@NV@"
+
+var presetUserDataVariables = map[string]string{
+	"USERDATA_BEGIN":   startingToken,
+	"USERDATA_END":     endingToken,
+	"SEQUENCE_COMMAND": helpers.SequenceLinesCommand(""),
+}
+
+// GetStartingToken returns the string token used to signal the beginning of the probe's output
+func (ccp Probe) GetStartingToken() string { return startingToken }
+
+// GetEndingToken returns the string token used to signal the end of the probe's output
+func (ccp Probe) GetEndingToken() string { return endingToken }
+
+// GetMachineImageID returns the string ID of the VM image to be used for the probe instance. This
+// probe only needs python3 and openssl (already present on RHEL images), so it shares its images
+// with curl.Probe
+func (ccp Probe) GetMachineImageID(platformType cloud.Platform, cpuArch cpu.Architecture, region string) (string, error) {
+	return curl.Probe{}.GetMachineImageID(platformType, cpuArch, region)
+}
+
+// GetExpandedUserData returns a YAML-formatted userdata string filled-in ("expanded") with
+// the values provided in userDataVariables according to os.Expand(). The endpoints to be
+// inspected are the HTTPS URLs in the URLS variable, so callers can pass the same variables
+// they would pass to curl.Probe; the CACERT variable (base64-encoded) is used to identify
+// chains issued by the user's proxy. Errors will be returned if values aren't provided for
+// required variables listed in the template's "network-verifier-required-variables" directive, or
+// if values *are* provided for variables that must be set to a certain value for the probe to
+// function correctly (presetUserDataVariables) -- this function will fill-in those values for you.
+func (ccp Probe) GetExpandedUserData(userDataVariables map[string]string) (string, error) {
+	// Platforms without cloud-init (e.g., GCP) ask for a systemd-based script instead
+	if userDataVariables["USE_SYSTEMD"] == "true" {
+		return "", errors.New("the certchain probe requires cloud-init and does not support systemd-based userdata")
+	}
+
+	// Extract required variables specified in template (if any)
+	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(userDataTemplate)
+
+	var err error
+	// TIMEOUT applies to each individual TLS handshake; inspect.py accepts fractional seconds
+	userDataVariables["TIMEOUT"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["TIMEOUT"], "%.2f")
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable TIMEOUT: %w", err)
+	}
+	// cloud-init only accepts a positive integer number of seconds for DELAY
+	userDataVariables["DELAY"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["DELAY"], "%.f")
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable DELAY: %w", err)
+	}
+
+	httpsURLs, err := httpsURLsFrom(userDataVariables["URLS"])
+	if err != nil {
+		return "", err
+	}
+	userDataVariables["HTTPS_URLS"] = strings.Join(httpsURLs, " ")
+	userDataVariables["INSPECT_SCRIPT"] = base64.StdEncoding.EncodeToString([]byte(inspectScript))
+
+	// Ensure userDataVariables complies with requiredVariables and presetUserDataVariables. See
+	// docstring for helpers.ValidateProvidedVariables() for more details
+	err = helpers.ValidateProvidedVariables(userDataVariables, presetUserDataVariables, requiredVariables)
+	if err != nil {
+		return "", err
+	}
+
+	// Label the probe's output with this run's nonce (if any) so that stale output left on the
+	// console by a previous run is ignored
+	noncedPresetUserDataVariables := helpers.NoncedPresetUserDataVariables(presetUserDataVariables, userDataVariables["NONCE"])
+
+	// Expand template
+	return os.Expand(directivelessUserDataTemplate, func(userDataVar string) string {
+		if presetVal, isPreset := noncedPresetUserDataVariables[userDataVar]; isPreset {
+			return presetVal
+		}
+		return userDataVariables[userDataVar]
+	}), nil
+}
+
+// ParseProgressLine implements probes.ProgressReporter, describing a single endpoint's
+// certificate chain as soon as it's printed. Chains suggesting TLS interception are reported as
+// successful, as the endpoint was reachable; they're only flagged in the final results
+func (ccp Probe) ParseProgressLine(line string) (probes.ProgressEvent, bool) {
+	probeResult, err := deserializeCertChainProbeResult(helpers.RemoveTimestamps(line))
+	if err != nil {
+		return probes.ProgressEvent{}, false
+	}
+	return probes.ProgressEvent{
+		Target:  probeResult.URL,
+		Success: probeResult.IsSuccessfulConnection(),
+		Detail:  probeResult.String(),
+	}, true
+}
+
+// ParseProbeOutput accepts a string containing all probe output that appeared between
+// the startingToken and the endingToken and a pointer to an Output object. outputDestination
+// will be filled with a summary of each endpoint's certificate chain (as informational
+// results), TLS interception warnings, and egress failures for unreachable endpoints.
+// ensurePrivate is ignored, as this probe doesn't record endpoints' IP addresses
+func (ccp Probe) ParseProbeOutput(_ bool, probeOutput string, outputDestination *output.Output) {
+	// AWS inserts timestamps into long lines of console output; these must be removed first
+	probeResults, errMap := bulkDeserializeCertChainProbeResult(helpers.RemoveTimestamps(probeOutput))
+	for _, probeResult := range probeResults {
+		outputDestination.AddDebugLogs(fmt.Sprintf("%+v\n", probeResult))
+		outputDestination.AddInfo(probeResult.String())
+		if !probeResult.IsSuccessfulConnection() {
+			outputDestination.SetEgressFailures(
+				[]string{fmt.Sprintf("%s (%s)", probeResult.URL, probeResult.Error)},
+			)
+			continue
+		}
+		if reasons := probeResult.InterceptionReasons(); len(reasons) > 0 {
+			outputDestination.AddWarning(
+				handledErrors.NewTLSInterceptionWarning(probeResult.URL, strings.Join(reasons, "; ")),
+			)
+		}
+	}
+	for lineNum, err := range errMap {
+		outputDestination.AddError(
+			handledErrors.NewGenericError(
+				fmt.Errorf("error processing line %d: %w", lineNum, err),
+			),
+		)
+	}
+}
+
+// httpsURLsFrom returns the HTTPS URLs among the space-separated URLs in urlsStr
+// (e.g., "https://quay.io:443 http://example.com:80")
+func httpsURLsFrom(urlsStr string) ([]string, error) {
+	var httpsURLs []string
+	for _, urlStr := range strings.Fields(urlsStr) {
+		parsedURL, err := url.Parse(urlStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse URL '%s': %w", urlStr, err)
+		}
+		if strings.EqualFold(parsedURL.Scheme, "https") && parsedURL.Hostname() != "" {
+			httpsURLs = append(httpsURLs, urlStr)
+		}
+	}
+
+	return httpsURLs, nil
+}
@@ -0,0 +1,109 @@
+package certchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A CertChainProbeResult represents the certificate chain presented by a single HTTPS egress
+// endpoint, as summarized by inspect.py
+type CertChainProbeResult struct {
+	URL           string              `json:"url"`
+	VerifyCode    int                 `json:"verify_code"`
+	VerifyMessage string              `json:"verify_message"`
+	Chain         []CertificateResult `json:"chain"`
+	ProxyCAIssued bool                `json:"proxy_ca_issued"`
+	Error         string              `json:"error"`
+}
+
+// A CertificateResult represents a single certificate within a presented chain. Sans only
+// contains the first few subject alternative names; SanCount holds the total number
+type CertificateResult struct {
+	Subject  string   `json:"subject"`
+	Issuer   string   `json:"issuer"`
+	Sans     []string `json:"sans"`
+	SanCount int      `json:"san_count"`
+	NotAfter string   `json:"not_after"`
+}
+
+// IsSuccessfulConnection returns true if a TLS handshake was completed far enough for
+// the endpoint to present its certificate chain
+func (res CertChainProbeResult) IsSuccessfulConnection() bool {
+	return res.Error == "" && len(res.Chain) > 0
+}
+
+// InterceptionReasons returns human-readable reasons why the presented chain suggests
+// TLS interception, or nil if the chain looks legitimate. A chain is suspicious if it
+// can't be verified against the public CAs trusted by the probe instance (OpenSSL
+// verify code 0 means "ok") or if any certificate in it was issued by the user-provided
+// proxy CA
+func (res CertChainProbeResult) InterceptionReasons() []string {
+	if !res.IsSuccessfulConnection() {
+		return nil
+	}
+
+	var reasons []string
+	if res.VerifyCode != 0 {
+		reasons = append(reasons, fmt.Sprintf("presented a certificate chain not signed by a public CA (%s; issuer: %s)", res.VerifyMessage, res.Chain[0].Issuer))
+	}
+	if res.ProxyCAIssued {
+		reasons = append(reasons, fmt.Sprintf("presented a certificate chain issued by the provided proxy CA (issuer: %s)", res.Chain[0].Issuer))
+	}
+	return reasons
+}
+
+// String returns a one-line summary of the presented chain suitable for display to users
+func (res CertChainProbeResult) String() string {
+	if !res.IsSuccessfulConnection() {
+		return fmt.Sprintf("%s: %s", res.URL, res.Error)
+	}
+
+	certs := make([]string, 0, len(res.Chain))
+	for _, cert := range res.Chain {
+		sans := strings.Join(cert.Sans, ", ")
+		if cert.SanCount > len(cert.Sans) {
+			sans += fmt.Sprintf(" (+%d more)", cert.SanCount-len(cert.Sans))
+		}
+		certs = append(certs, fmt.Sprintf("[subject: %s; issuer: %s; SANs: %s; expires: %s]", cert.Subject, cert.Issuer, sans, cert.NotAfter))
+	}
+	return fmt.Sprintf("%s: verify %q, chain %s", res.URL, res.VerifyMessage, strings.Join(certs, " "))
+}
+
+// bulkDeserializeCertChainProbeResult wraps deserializeCertChainProbeResult, creating a
+// CertChainProbeResult from each non-blank line (containing prefixed JSON) of the provided
+// string. A slice of successfully-deserialized CertChainProbeResult-pointers is returned
+// along with a mapping between any malformed lines and their line numbers
+func bulkDeserializeCertChainProbeResult(serializedLines string) ([]*CertChainProbeResult, map[int]error) {
+	var results []*CertChainProbeResult
+	deserializationErrs := make(map[int]error)
+	for lineNum, serializedLine := range strings.Split(serializedLines, "\n") {
+		if strings.TrimSpace(serializedLine) == "" {
+			continue
+		}
+		probeResultPtr, err := deserializeCertChainProbeResult(serializedLine)
+		if err != nil {
+			deserializationErrs[lineNum] = err
+		}
+		if probeResultPtr != nil {
+			results = append(results, probeResultPtr)
+		}
+	}
+	return results, deserializationErrs
+}
+
+// deserializeCertChainProbeResult creates a CertChainProbeResult from a single line of probe
+// console output, which should start with outputLinePrefix followed by a serialized JSON
+// string. If the prefix is missing or JSON deserialization (unmarshalling) fails, (nil, error)
+// is returned
+func deserializeCertChainProbeResult(prefixedJSON string) (*CertChainProbeResult, error) {
+	jsonStr, prefixFound := strings.CutPrefix(strings.TrimSpace(prefixedJSON), outputLinePrefix)
+	if !prefixFound {
+		return nil, fmt.Errorf("missing prefix '%s': %s", outputLinePrefix, prefixedJSON)
+	}
+	var result CertChainProbeResult
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,209 @@
+package certchain
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"gopkg.in/yaml.v3"
+)
+
+// TestCertChainProbe_ImplementsProbeInterface simply forces the compiler to confirm
+// that the Probe type properly implements the Probe interface
+func TestCertChainProbe_ImplementsProbeInterface(t *testing.T) {
+	var _ probes.Probe = (*Probe)(nil)
+	var _ probes.ProgressReporter = (*Probe)(nil)
+}
+
+// TestCertChainProbe_GetExpandedUserData tests the correctness of the userdata
+// produced by the probe using regexes and basic YAML syntax validation
+func TestCertChainProbe_GetExpandedUserData(t *testing.T) {
+	tests := []struct {
+		name              string
+		userDataVariables map[string]string
+		wantRegex         string
+		wantErr           bool
+	}{
+		{
+			name: "happy path",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "http://example.com:80 https://example.org:443 telnet://example.net:9997",
+			},
+			wantRegex: `#cloud-config[\s\S]*nv-inspect.py 1.00 /etc/nv-proxy-ca.pem https://example.org:443 2>&1 \| awk `,
+		},
+		{
+			name: "proxy and CA cert provided",
+			userDataVariables: map[string]string{
+				"TIMEOUT":     "1",
+				"DELAY":       "2",
+				"URLS":        "https://example.org:443",
+				"HTTPS_PROXY": "http://proxy.example.com:3128",
+				"CACERT":      "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==",
+			},
+			wantRegex: `#cloud-config[\s\S]*content: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg=="[\s\S]*https_proxy=http://proxy.example.com:3128`,
+		},
+		{
+			name: "no HTTPS URLs",
+			userDataVariables: map[string]string{
+				"TIMEOUT":          "1",
+				"DELAY":            "2",
+				"URLS":             "http://example.com:80",
+				"TLSDISABLED_URLS": "https://example.org:443",
+			},
+			wantErr: true,
+		},
+		{
+			name: "systemd requested",
+			userDataVariables: map[string]string{
+				"TIMEOUT":     "1",
+				"DELAY":       "2",
+				"URLS":        "https://example.org:443",
+				"USE_SYSTEMD": "true",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid DELAY",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "-2",
+				"URLS":    "https://example.org:443",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Probe{}.GetExpandedUserData(tt.userDataVariables)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("certchain.Probe.GetExpandedUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(tt.wantRegex) > 0 && !regexp.MustCompile(tt.wantRegex).MatchString(got) {
+				t.Errorf("certchain.Probe.GetExpandedUserData() output does not match regex `%s`, content=%v", tt.wantRegex, got)
+			}
+
+			var unmarshalled any
+			if err := yaml.Unmarshal([]byte(got), &unmarshalled); err != nil {
+				t.Errorf("certchain.Probe.GetExpandedUserData() produced invalid YAML (err: %v), content=%v", err, got)
+			}
+		})
+	}
+}
+
+// TestCertChainProbe_UserDataTemplateContainsDeclaredVariables ensures that this probe's
+// userdata-template.yaml contains all of the variables it declares as required or preset
+func TestCertChainProbe_UserDataTemplateContainsDeclaredVariables(t *testing.T) {
+	for presetVariableName := range presetUserDataVariables {
+		if !strings.Contains(userDataTemplate, "${"+presetVariableName+"}") {
+			t.Errorf("certchain.Probe.presetUserDataVariables has key %[1]s, but could not find required '${%[1]s}' in probe's userdata-template.yaml", presetVariableName)
+		}
+	}
+
+	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(userDataTemplate)
+	for _, requiredVariableName := range requiredVariables {
+		if !strings.Contains(directivelessUserDataTemplate, "${"+requiredVariableName+"}") {
+			t.Errorf("certchain.Probe's userdata-template.yaml declares %[1]s as required, but could not find '${%[1]s}' in file", requiredVariableName)
+		}
+	}
+}
+
+func TestCertChainProbe_ParseProbeOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		probeOutput  string
+		wantFailures int
+		wantWarnings []string
+		wantErrors   bool
+	}{
+		{
+			name:        "publicly-trusted chain",
+			probeOutput: `@NV@{"url":"https://quay.io:443","verify_code":0,"verify_message":"ok","chain":[{"subject":"CN=quay.io","issuer":"CN=DigiCert Global G2 TLS RSA SHA256 2020 CA1,O=DigiCert Inc,C=US","sans":["DNS:quay.io"],"san_count":1,"not_after":"2030-01-01T00:00:00Z"}],"proxy_ca_issued":false,"error":""}`,
+		},
+		{
+			name:         "chain not signed by public CA",
+			probeOutput:  `@NV@{"url":"https://quay.io:443","verify_code":20,"verify_message":"unable to get local issuer certificate","chain":[{"subject":"CN=quay.io","issuer":"CN=Corp Firewall","sans":["DNS:quay.io"],"san_count":1,"not_after":"2030-01-01T00:00:00Z"}],"proxy_ca_issued":false,"error":""}`,
+			wantWarnings: []string{"possible TLS interception: https://quay.io:443 presented a certificate chain not signed by a public CA (unable to get local issuer certificate; issuer: CN=Corp Firewall)"},
+		},
+		{
+			name:         "chain issued by proxy CA",
+			probeOutput:  `@NV@{"url":"https://quay.io:443","verify_code":0,"verify_message":"ok","chain":[{"subject":"CN=quay.io","issuer":"CN=Proxy CA","sans":["DNS:quay.io"],"san_count":1,"not_after":"2030-01-01T00:00:00Z"}],"proxy_ca_issued":true,"error":""}`,
+			wantWarnings: []string{"possible TLS interception: https://quay.io:443 presented a certificate chain issued by the provided proxy CA (issuer: CN=Proxy CA)"},
+		},
+		{
+			name:         "unreachable endpoint",
+			probeOutput:  `@NV@{"url":"https://quay.io:443","verify_code":-1,"verify_message":"","chain":[],"proxy_ca_issued":false,"error":"connect:errno=111"}`,
+			wantFailures: 1,
+		},
+		{
+			name:        "malformed line",
+			probeOutput: `Traceback (most recent call last):`,
+			wantErrors:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &output.Output{}
+			Probe{}.ParseProbeOutput(false, tt.probeOutput, out)
+
+			if got := len(out.GetEgressURLFailures()); got != tt.wantFailures {
+				t.Errorf("certchain.Probe.ParseProbeOutput() failures = %d, want %d", got, tt.wantFailures)
+			}
+			gotWarnings := out.GetWarnings()
+			if len(gotWarnings) != len(tt.wantWarnings) {
+				t.Fatalf("certchain.Probe.ParseProbeOutput() warnings = %v, want %v", gotWarnings, tt.wantWarnings)
+			}
+			for i, warning := range gotWarnings {
+				if warning.Error() != tt.wantWarnings[i] {
+					t.Errorf("certchain.Probe.ParseProbeOutput() warning[%d] = %s, want %s", i, warning.Error(), tt.wantWarnings[i])
+				}
+			}
+			_, _, gotErrors := out.Parse()
+			if (len(gotErrors) > 0) != tt.wantErrors {
+				t.Errorf("certchain.Probe.ParseProbeOutput() errors = %v, wantErrors %v", gotErrors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestCertChainProbe_ParseProgressLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantEvent probes.ProgressEvent
+		wantOK    bool
+	}{
+		{
+			name:      "unreachable endpoint",
+			line:      `@NV@{"url":"https://quay.io:443","verify_code":-1,"verify_message":"","chain":[],"proxy_ca_issued":false,"error":"connect:errno=111"}`,
+			wantEvent: probes.ProgressEvent{Target: "https://quay.io:443", Success: false, Detail: "https://quay.io:443: connect:errno=111"},
+			wantOK:    true,
+		},
+		{
+			name:      "unrelated line ignored",
+			line:      `cloud-init: running modules`,
+			wantEvent: probes.ProgressEvent{},
+			wantOK:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEvent, gotOK := Probe{}.ParseProgressLine(tt.line)
+			if gotOK != tt.wantOK {
+				t.Fatalf("certchain.Probe.ParseProgressLine() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+			if gotEvent != tt.wantEvent {
+				t.Errorf("certchain.Probe.ParseProgressLine() = %+v, want %+v", gotEvent, tt.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+#!/usr/bin/python3
+# inspect.py connects to each HTTPS URL given on the command line using `openssl s_client`
+# (honoring the https_proxy and no_proxy environment variables) and prints one JSON-formatted
+# summary of the certificate chain presented by each endpoint. The chain is verified against
+# the system's public CA bundle and, if a non-empty proxy CA file is provided, each certificate's
+# issuer is compared against that CA's subject. Each result line is prefixed with the separator
+# expected by certchain.Probe.ParseProbeOutput(). Only the Python standard library and the
+# openssl CLI are used so that this runs on an unmodified RHEL image.
+# Usage: inspect.py TIMEOUT_SECONDS PROXY_CA_FILE URL [URL...]
+import datetime
+import json
+import os
+import re
+import subprocess
+import sys
+import urllib.parse
+
+SEPARATOR = "@NV@"
+# Some endpoints present certificates with hundreds of SANs; only a few are needed to identify
+# a certificate, and console output is limited to 64KB
+MAX_SANS = 5
+PEM_RE = re.compile(r"-----BEGIN CERTIFICATE-----.+?-----END CERTIFICATE-----", re.S)
+VERIFY_RE = re.compile(r"Verify return code: (\d+) \((.*)\)")
+
+
+def openssl(args, stdin="", timeout=None):
+    return subprocess.run(["openssl"] + args, input=stdin, capture_output=True, text=True, timeout=timeout)
+
+
+def describe(pem):
+    out = openssl(["x509", "-noout", "-nameopt", "RFC2253", "-subject", "-issuer", "-enddate", "-ext", "subjectAltName"], pem).stdout
+    cert = {"subject": "", "issuer": "", "sans": [], "san_count": 0, "not_after": ""}
+    lines = out.splitlines()
+    for i, line in enumerate(lines):
+        if line.startswith("subject="):
+            cert["subject"] = line[len("subject="):].strip()
+        elif line.startswith("issuer="):
+            cert["issuer"] = line[len("issuer="):].strip()
+        elif line.startswith("notAfter="):
+            not_after = datetime.datetime.strptime(line[len("notAfter="):].strip(), "%b %d %H:%M:%S %Y %Z")
+            cert["not_after"] = not_after.strftime("%Y-%m-%dT%H:%M:%SZ")
+        elif "Subject Alternative Name" in line and i + 1 < len(lines):
+            sans = [san.strip() for san in lines[i + 1].split(",") if san.strip()]
+            cert["sans"], cert["san_count"] = sans[:MAX_SANS], len(sans)
+    return cert
+
+
+def bypasses_proxy(host):
+    for entry in os.environ.get("no_proxy", "").split(","):
+        entry = entry.strip().lstrip("*").lstrip(".")
+        if entry and (host == entry or host.endswith("." + entry)):
+            return True
+    return False
+
+
+def proxy_args(host):
+    proxy = os.environ.get("https_proxy", "")
+    if not proxy or bypasses_proxy(host):
+        return []
+    parsed = urllib.parse.urlparse(proxy if "://" in proxy else "http://" + proxy)
+    args = ["-proxy", "%s:%d" % (parsed.hostname, parsed.port or 80)]
+    if parsed.username:
+        args += ["-proxy_user", urllib.parse.unquote(parsed.username), "-proxy_pass", "pass:" + urllib.parse.unquote(parsed.password or "")]
+    return args
+
+
+def inspect(url, timeout, proxy_ca_subject):
+    result = {"url": url, "verify_code": -1, "verify_message": "", "chain": [], "proxy_ca_issued": False, "error": ""}
+    parsed = urllib.parse.urlparse(url)
+    host, port = parsed.hostname, parsed.port or 443
+    args = ["s_client", "-connect", "%s:%d" % (host, port), "-servername", host, "-verify_hostname", host, "-showcerts"]
+    try:
+        completed = openssl(args + proxy_args(host), timeout=timeout)
+    except subprocess.TimeoutExpired:
+        result["error"] = "timed out waiting for TLS handshake"
+        return result
+
+    pems = PEM_RE.findall(completed.stdout)
+    if not pems:
+        stderr_lines = completed.stderr.strip().splitlines()
+        result["error"] = stderr_lines[-1] if stderr_lines else "no certificates presented"
+        return result
+
+    result["chain"] = [describe(pem) for pem in pems]
+    verify = VERIFY_RE.search(completed.stdout)
+    if verify:
+        result["verify_code"], result["verify_message"] = int(verify.group(1)), verify.group(2)
+    if proxy_ca_subject:
+        result["proxy_ca_issued"] = any(cert["issuer"] == proxy_ca_subject for cert in result["chain"])
+    return result
+
+
+def main():
+    timeout = float(sys.argv[1])
+    proxy_ca_subject = ""
+    if os.path.isfile(sys.argv[2]) and os.path.getsize(sys.argv[2]) > 0:
+        with open(sys.argv[2]) as proxy_ca:
+            proxy_ca_subject = describe(proxy_ca.read())["subject"]
+    for url in sys.argv[3:]:
+        print(SEPARATOR + json.dumps(inspect(url, timeout, proxy_ca_subject), separators=(",", ":")), flush=True)
+
+
+if __name__ == "__main__":
+    main()
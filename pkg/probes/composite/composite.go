@@ -0,0 +1,237 @@
+package composite
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+)
+
+// composite.Probe is an implementation of the probes.Probe interface that runs several other
+// probes (e.g., curl.Probe and dns.Probe) on a single instance, saving the cost of launching one
+// instance per probe. The userdata of each child probe is combined into a single multi-part
+// cloud-init document whose parts are merged, so the children's commands run one after another.
+// Each child labels its output with its own sub-stream nonce (see helpers.SubstreamNonce), which
+// allows the verifier to reassemble every child's output separately; this probe then dispatches
+// each child's output to that child's parser. Children must produce cloud-init userdata and
+// sequence-numbered output (so legacy.Probe isn't supported), this probe only supports AWS, and
+// composite probes can't be nested
+type Probe struct {
+	// Probes are the child probes to run, in order. At least one is required
+	Probes []probes.Probe
+}
+
+//go:embed header-template.yaml
+var headerTemplate string
+
+//go:embed footer-template.yaml
+var footerTemplate string
+
+const startingToken = "NV_COMPOSITE_BEGIN" //nolint:gosec
+const endingToken = "NV_COMPOSITE_END"     //nolint:gosec
+
+// mimeBoundary separates the parts of the multi-part userdata document
+const mimeBoundary = "==NV_COMPOSITE_BOUNDARY=="
+
+// mergeType instructs cloud-init to append (rather than replace) lists like runcmd and write_files
+// when merging the parts of the userdata document, while keeping the first value of any other
+// setting (e.g., power_state)
+const mergeType = "list(append)+dict(no_replace,recurse_list)+str()"
+
+var presetUserDataVariables = map[string]string{
+	"USERDATA_BEGIN":   startingToken,
+	"USERDATA_END":     endingToken,
+	"SEQUENCE_COMMAND": helpers.SequenceLinesCommand(""),
+}
+
+// GetStartingToken returns the string token used to signal the beginning of the probe's output
+func (cp Probe) GetStartingToken() string { return startingToken }
+
+// GetEndingToken returns the string token used to signal the end of the probe's output
+func (cp Probe) GetEndingToken() string { return endingToken }
+
+// GetMachineImageID returns the string ID of the VM image to be used for the probe instance. All
+// children run on the same instance, so the first child's image is used
+func (cp Probe) GetMachineImageID(platformType cloud.Platform, cpuArch cpu.Architecture, region string) (string, error) {
+	if len(cp.Probes) == 0 {
+		return "", errors.New("composite probe requires at least one child probe")
+	}
+	return cp.Probes[0].GetMachineImageID(platformType, cpuArch, region)
+}
+
+// GetExpandedUserData returns a multi-part MIME userdata document containing the userdata of each
+// child probe, expanded with a copy of userDataVariables in which NONCE is replaced by the child's
+// sub-stream nonce. Errors will be returned if any child's userdata can't be expanded or isn't a
+// cloud-init document, or if values are provided for variables that must be set to a certain
+// value for the probe to function correctly (presetUserDataVariables)
+func (cp Probe) GetExpandedUserData(userDataVariables map[string]string) (string, error) {
+	if len(cp.Probes) == 0 {
+		return "", errors.New("composite probe requires at least one child probe")
+	}
+	// Platforms without cloud-init (e.g., GCP) ask for a systemd-based script instead
+	if userDataVariables["USE_SYSTEMD"] == "true" {
+		return "", errors.New("the composite probe requires cloud-init and does not support systemd-based userdata")
+	}
+
+	// Ensure userDataVariables complies with presetUserDataVariables. See docstring for
+	// helpers.ValidateProvidedVariables() for more details
+	err := helpers.ValidateProvidedVariables(userDataVariables, presetUserDataVariables, nil)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := userDataVariables["NONCE"]
+	parts := make([]string, 0, len(cp.Probes)+2)
+
+	// Label the probe's output with this run's nonce (if any) so that stale output left on the
+	// console by a previous run is ignored
+	noncedPresetUserDataVariables := helpers.NoncedPresetUserDataVariables(presetUserDataVariables, nonce)
+	parts = append(parts, os.Expand(headerTemplate, func(userDataVar string) string {
+		return noncedPresetUserDataVariables[userDataVar]
+	}))
+
+	for i, childProbe := range cp.Probes {
+		if _, isComposite := childProbe.(Probe); isComposite {
+			return "", fmt.Errorf("child probe %d: composite probes can't be nested", i+1)
+		}
+
+		// Children may modify the variables they're given, so each gets its own copy
+		childUserDataVariables := maps.Clone(userDataVariables)
+		childNonce := helpers.SubstreamNonce(nonce, substreamName(i))
+		childUserDataVariables["NONCE"] = childNonce
+		childUserData, err := childProbe.GetExpandedUserData(childUserDataVariables)
+		if err != nil {
+			return "", fmt.Errorf("child probe %d (%T): %w", i+1, childProbe, err)
+		}
+		if !strings.HasPrefix(childUserData, "#cloud-config") {
+			return "", fmt.Errorf("child probe %d (%T): userdata is not a cloud-init (#cloud-config) document", i+1, childProbe)
+		}
+		// Only sequence-numbered output can be told apart from the other children's output
+		if !strings.Contains(childUserData, helpers.SequencedLinePrefix+childNonce+"@") {
+			return "", fmt.Errorf("child probe %d (%T): output is not sequence-numbered (see helpers.SequenceLinesCommand)", i+1, childProbe)
+		}
+		parts = append(parts, childUserData)
+	}
+
+	// The footer runs after every child has finished, so its end-of-output line tells the
+	// verifier that all sub-streams are complete
+	parts = append(parts, os.Expand(footerTemplate, func(userDataVar string) string {
+		return noncedPresetUserDataVariables[userDataVar]
+	}))
+
+	return buildMultipartUserData(parts)
+}
+
+// buildMultipartUserData combines several cloud-init documents into a single multi-part MIME
+// document, each part of which cloud-init merges according to mergeType
+func buildMultipartUserData(parts []string) (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n", mimeBoundary)
+
+	mimeWriter := multipart.NewWriter(&buf)
+	if err := mimeWriter.SetBoundary(mimeBoundary); err != nil {
+		return "", err
+	}
+	for _, part := range parts {
+		partWriter, err := mimeWriter.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {`text/cloud-config; charset="us-ascii"`},
+			"Merge-Type":   {mergeType},
+		})
+		if err != nil {
+			return "", err
+		}
+		if _, err := partWriter.Write([]byte(part)); err != nil {
+			return "", err
+		}
+	}
+	if err := mimeWriter.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// substreamName returns the name of the sub-stream labeling the output of the child probe at the
+// given (zero-based) index
+func substreamName(childIndex int) string {
+	return strconv.Itoa(childIndex + 1)
+}
+
+// childIndex returns the (zero-based) index of the child probe whose output is labeled with the
+// given sub-stream name, or false if there is no such child
+func (cp Probe) childIndex(substream string) (int, bool) {
+	i, err := strconv.Atoi(substream)
+	if err != nil || i < 1 || i > len(cp.Probes) {
+		return 0, false
+	}
+	return i - 1, true
+}
+
+// MaxRuntime implements probes.RuntimeEstimator. The children run one after another, so the
+// composite probe may take as long as all of its children combined (see probes.MaxRuntime)
+func (cp Probe) MaxRuntime(endpointCount int, timeout time.Duration) time.Duration {
+	var runtime time.Duration
+	for _, childProbe := range cp.Probes {
+		runtime += probes.MaxRuntime(childProbe, endpointCount, timeout)
+	}
+	return runtime
+}
+
+// ParseProgressLine implements probes.ProgressReporter by passing a single line of a child
+// probe's output to that child, provided that it also implements probes.ProgressReporter
+func (cp Probe) ParseProgressLine(line string) (probes.ProgressEvent, bool) {
+	substream, childLine, ok := helpers.CutSubstreamLine(strings.TrimSpace(line))
+	if !ok {
+		return probes.ProgressEvent{}, false
+	}
+	i, ok := cp.childIndex(substream)
+	if !ok {
+		return probes.ProgressEvent{}, false
+	}
+	progressReporter, ok := cp.Probes[i].(probes.ProgressReporter)
+	if !ok {
+		return probes.ProgressEvent{}, false
+	}
+	return progressReporter.ParseProgressLine(childLine)
+}
+
+// ParseProbeOutput accepts a string containing all probe output that appeared between
+// the startingToken and the endingToken and a pointer to an Output object. Each child probe's
+// output (as reassembled by helpers.SequencedOutputCollector) is passed to that child's
+// ParseProbeOutput, which fills outputDestination with its results. Children that produced no
+// output at all are reported as errors
+func (cp Probe) ParseProbeOutput(ensurePrivate bool, probeOutput string, outputDestination *output.Output) {
+	childOutputLines := make([][]string, len(cp.Probes))
+	for _, line := range strings.Split(probeOutput, "\n") {
+		substream, childLine, ok := helpers.CutSubstreamLine(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+		if i, ok := cp.childIndex(substream); ok {
+			childOutputLines[i] = append(childOutputLines[i], childLine)
+		}
+	}
+
+	for i, childProbe := range cp.Probes {
+		if len(childOutputLines[i]) == 0 {
+			outputDestination.AddError(handledErrors.NewGenericError(
+				fmt.Errorf("no output from child probe %d (%T)", i+1, childProbe),
+			))
+			continue
+		}
+		childProbe.ParseProbeOutput(ensurePrivate, strings.Join(childOutputLines[i], "\n"), outputDestination)
+	}
+}
@@ -0,0 +1,268 @@
+package composite
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+	"github.com/openshift/osd-network-verifier/pkg/probes/dns"
+	"github.com/openshift/osd-network-verifier/pkg/probes/legacy"
+	"gopkg.in/yaml.v3"
+)
+
+// TestCompositeProbe_ImplementsProbeInterface simply forces the compiler to confirm
+// that the Probe type properly implements the Probe interface
+func TestCompositeProbe_ImplementsProbeInterface(t *testing.T) {
+	var _ probes.Probe = (*Probe)(nil)
+	var _ probes.ProgressReporter = (*Probe)(nil)
+	var _ probes.RuntimeEstimator = (*Probe)(nil)
+}
+
+// TestCompositeProbe_MaxRuntime ensures that the composite probe allows for each of its children
+// running one after another
+func TestCompositeProbe_MaxRuntime(t *testing.T) {
+	children := []probes.Probe{curl.Probe{Samples: 2}, dns.Probe{}, legacy.Probe{}}
+	var want time.Duration
+	for _, child := range children {
+		want += probes.MaxRuntime(child, 10, 5*time.Second)
+	}
+
+	got := Probe{Probes: children}.MaxRuntime(10, 5*time.Second)
+	if got != want || got <= probes.MaxRuntime(children[0], 10, 5*time.Second) {
+		t.Errorf("composite.Probe.MaxRuntime() = %s, want %s", got, want)
+	}
+}
+
+// readParts parses a multi-part MIME userdata document, returning the body of each part after
+// checking that it's a cloud-init document that cloud-init will merge
+func readParts(t *testing.T, userData string) []string {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		t.Fatalf("userdata is not a MIME document: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("unexpected userdata Content-Type %q (err: %v)", msg.Header.Get("Content-Type"), err)
+	}
+
+	var parts []string
+	mimeReader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mimeReader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read userdata part: %v", err)
+		}
+		if got := part.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/cloud-config") {
+			t.Errorf("part %d: unexpected Content-Type %q", len(parts)+1, got)
+		}
+		if got := part.Header.Get("Merge-Type"); got != mergeType {
+			t.Errorf("part %d: unexpected Merge-Type %q", len(parts)+1, got)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("failed to read userdata part: %v", err)
+		}
+		var unmarshalled map[string]interface{}
+		if err := yaml.Unmarshal(body, &unmarshalled); err != nil {
+			t.Errorf("part %d: invalid YAML: %v", len(parts)+1, err)
+		}
+		parts = append(parts, string(body))
+	}
+	return parts
+}
+
+func TestCompositeProbe_GetExpandedUserData(t *testing.T) {
+	userDataVariables := map[string]string{
+		"TIMEOUT": "1",
+		"DELAY":   "2",
+		"URLS":    "http://example.com:80 https://example.org:443",
+		"NONCE":   "abc123",
+	}
+	userData, err := Probe{Probes: []probes.Probe{curl.Probe{}, dns.Probe{}}}.GetExpandedUserData(userDataVariables)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parts := readParts(t, userData)
+	if len(parts) != 4 {
+		t.Fatalf("expected 4 userdata parts (header, 2 children, footer), got %d", len(parts))
+	}
+	wantSubstrings := []string{
+		`echo "NV_COMPOSITE_BEGIN_abc123"`,
+		`echo "NV_CURLJSON_BEGIN_abc123.1"`,
+		`echo "NV_DNSJSON_BEGIN_abc123.2"`,
+		`@NVSEQ@abc123@END@`,
+	}
+	for i, wantSubstring := range wantSubstrings {
+		if !strings.Contains(parts[i], wantSubstring) {
+			t.Errorf("part %d: expected to contain %q, got:\n%s", i+1, wantSubstring, parts[i])
+		}
+	}
+	if !strings.Contains(parts[3], `echo "NV_COMPOSITE_END_abc123"`) {
+		t.Errorf("footer: expected to contain ending token, got:\n%s", parts[3])
+	}
+
+	// Each child must be given its own copy of the variables
+	if userDataVariables["NONCE"] != "abc123" || userDataVariables["HOSTS"] != "" {
+		t.Errorf("userDataVariables modified: %v", userDataVariables)
+	}
+}
+
+// shellScriptProbe is a curl.Probe whose userdata is a shell script rather than a cloud-init document
+type shellScriptProbe struct{ curl.Probe }
+
+func (shellScriptProbe) GetExpandedUserData(map[string]string) (string, error) {
+	return "#!/bin/sh\necho hello >/dev/ttyS0\n", nil
+}
+
+func TestCompositeProbe_GetExpandedUserDataErrors(t *testing.T) {
+	userDataVariables := map[string]string{
+		"TIMEOUT": "1",
+		"DELAY":   "2",
+		"URLS":    "http://example.com:80",
+	}
+	tests := []struct {
+		name              string
+		probe             Probe
+		userDataVariables map[string]string
+	}{
+		{
+			name:              "no children",
+			probe:             Probe{},
+			userDataVariables: userDataVariables,
+		},
+		{
+			name:              "nested composite",
+			probe:             Probe{Probes: []probes.Probe{Probe{Probes: []probes.Probe{curl.Probe{}}}}},
+			userDataVariables: userDataVariables,
+		},
+		{
+			name:              "child without cloud-init userdata",
+			probe:             Probe{Probes: []probes.Probe{curl.Probe{}, shellScriptProbe{}}},
+			userDataVariables: userDataVariables,
+		},
+		{
+			name:  "child without sequenced output",
+			probe: Probe{Probes: []probes.Probe{legacy.Probe{}}},
+			userDataVariables: map[string]string{
+				"AWS_REGION":               "us-east-1",
+				"CONFIG_PATH":              "/app/build/config.yaml",
+				"DELAY":                    "2",
+				"IMAGE":                    "quay.io/app-sre/osd-network-verifier:latest",
+				"NOTLS":                    "false",
+				"TIMEOUT":                  "1",
+				"VALIDATOR_IMAGE":          "quay.io/app-sre/osd-network-verifier:latest",
+				"VALIDATOR_REPO":           "quay.io/app-sre/osd-network-verifier",
+				"VALIDATOR_START_VERIFIER": "VALIDATOR_START",
+				"VALIDATOR_END_VERIFIER":   "VALIDATOR_END",
+			},
+		},
+		{
+			name:  "systemd requested",
+			probe: Probe{Probes: []probes.Probe{curl.Probe{}}},
+			userDataVariables: map[string]string{
+				"TIMEOUT":     "1",
+				"DELAY":       "2",
+				"URLS":        "http://example.com:80",
+				"USE_SYSTEMD": "true",
+			},
+		},
+		{
+			name:  "preset variable provided",
+			probe: Probe{Probes: []probes.Probe{curl.Probe{}}},
+			userDataVariables: map[string]string{
+				"TIMEOUT":        "1",
+				"DELAY":          "2",
+				"URLS":           "http://example.com:80",
+				"USERDATA_BEGIN": "foo",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.probe.GetExpandedUserData(tt.userDataVariables); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestCompositeProbe_ParseProbeOutput(t *testing.T) {
+	probeOutput := strings.Join([]string{
+		`@NVSUB@1@@NV@{"url":"https://quay.io:443","exitcode":28,"errormsg":"Connection timed out","scheme":"","time_total":3}`,
+		`@NVSUB@2@@NV@{"host":"quay.io","type":"A","resolver":"10.0.0.2","rcode":"NXDOMAIN","answers":[],"time_ms":1.5,"error":""}`,
+		`@NVSUB@3@ignored`,
+		`ignored`,
+	}, "\n")
+
+	out := output.Output{}
+	Probe{Probes: []probes.Probe{curl.Probe{}, dns.Probe{}}}.ParseProbeOutput(false, probeOutput, &out)
+
+	failures := out.GetEgressURLFailures()
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 egress failures (1 per child), got: %v", failures)
+	}
+	if _, _, errs := out.Parse(); len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	// A child without any output is reported
+	out = output.Output{}
+	Probe{Probes: []probes.Probe{curl.Probe{}, dns.Probe{}}}.ParseProbeOutput(false, strings.Split(probeOutput, "\n")[0], &out)
+	if _, _, errs := out.Parse(); len(errs) != 1 {
+		t.Errorf("expected 1 error for the child without output, got: %v", errs)
+	}
+}
+
+func TestCompositeProbe_ParseProgressLine(t *testing.T) {
+	probe := Probe{Probes: []probes.Probe{curl.Probe{}, dns.Probe{}}}
+	tests := []struct {
+		name      string
+		line      string
+		wantEvent probes.ProgressEvent
+		wantOK    bool
+	}{
+		{
+			name:      "curl child",
+			line:      `@NVSUB@1@@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","time_total":0.25}`,
+			wantEvent: probes.ProgressEvent{Target: "https://quay.io:443", Success: true, Detail: "0.25s"},
+			wantOK:    true,
+		},
+		{
+			name:      "dns child",
+			line:      `@NVSUB@2@@NV@{"host":"quay.io","type":"A","resolver":"10.0.0.2","rcode":"NXDOMAIN","answers":[],"time_ms":1.5,"error":""}`,
+			wantEvent: probes.ProgressEvent{Target: "quay.io (A)", Success: false, Detail: "quay.io A via 10.0.0.2: NXDOMAIN"},
+			wantOK:    true,
+		},
+		{
+			name: "unknown child",
+			line: `@NVSUB@3@@NV@{}`,
+		},
+		{
+			name: "not from a child",
+			line: `@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","time_total":0.25}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEvent, gotOK := probe.ParseProgressLine(tt.line)
+			if gotOK != tt.wantOK {
+				t.Fatalf("composite.Probe.ParseProgressLine() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+			if gotEvent != tt.wantEvent {
+				t.Errorf("composite.Probe.ParseProgressLine() = %+v, want %+v", gotEvent, tt.wantEvent)
+			}
+		})
+	}
+}
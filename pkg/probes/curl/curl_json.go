@@ -5,18 +5,25 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
 	"github.com/openshift/osd-network-verifier/pkg/data/curlgen"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
 
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
 	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
 	"github.com/openshift/osd-network-verifier/pkg/helpers"
 	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
 )
 
 // curl.Probe is an implementation of the probes.Probe interface that uses the venerable curl tool to
@@ -25,9 +32,36 @@ import (
 // of egress URLs to which curl will attempt to connect. Curl will return the results as JSON via
 // serial console, which this probe can then parse into a standard output format. Any reported
 // egressURL errors will contain curl's detailed error messages. Additional command line options can
-// be provided to curl via the CURLOPT userdataVariable. This probe has been confirmed to support X86
-// instances on AWS. In theory, it should also support GCP and any CPU architecture supported by RHEL.
-type Probe struct{}
+// be provided to curl via the CURLOPT userdataVariable as a newline-separated list of allowlisted
+// options (see curlgen.ParseExtraOptions), e.g., to override DNS resolution. Setting the IP_FAMILY
+// userdataVariable to ipv4, ipv6, or both forces curl to check every endpoint over that IP family
+// (or over each family in turn), and endpoint results are then labeled with the family. This probe
+// has been confirmed to support X86 instances on AWS. In theory, it should also support GCP and any
+// CPU architecture supported by RHEL.
+// The zero value checks each endpoint once; set Samples to check each endpoint repeatedly and
+// classify it as passing, flaky, or blocked based on its success ratio.
+type Probe struct {
+	// Samples controls how many times (up to maxSamples) each endpoint is checked, with a random
+	// pause between samples. Values below 2 disable sampling
+	Samples int
+
+	// MinPassingRatio is the minimum ratio of successful samples for an endpoint to be classified
+	// as passing. Defaults to 1 (every sample must succeed) if unset. Only used when sampling
+	MinPassingRatio float64
+
+	// MaxBlockedRatio is the maximum ratio of successful samples for an endpoint to be classified
+	// as blocked; endpoints between MaxBlockedRatio and MinPassingRatio are classified as flaky.
+	// Defaults to 0 (no sample may succeed). Only used when sampling
+	MaxBlockedRatio float64
+
+	// UserDataTemplate, if set, replaces the built-in userdata template (including the
+	// systemd-based template used on platforms without cloud-init), e.g., to register a CA, set a
+	// DNS search domain, or enable FIPS on hardened images before checking egress. It's expanded
+	// and validated exactly like the built-in templates (including any required variables listed
+	// in its "network-verifier-required-variables" directive), and it must print the
+	// ${USERDATA_BEGIN} and ${USERDATA_END} tokens around the output of ${CURL_COMMAND}
+	UserDataTemplate string
+}
 
 //go:embed userdata-template.yaml
 var userDataTemplate string
@@ -38,9 +72,53 @@ var systemdTemplate string
 const startingToken = "NV_CURLJSON_BEGIN" //nolint:gosec
 const endingToken = "NV_CURLJSON_END"     //nolint:gosec
 
+// maxEgressIPChecks caps EGRESS_IP_CHECKS so that the probe finishes well within the
+// verifier's console output polling window
+const maxEgressIPChecks = 10
+
+// Large-payload transfer checks download defaultTransferSizeBytes (up to maxTransferSizeBytes)
+// from each TRANSFER_URLS entry. A transfer is considered stalled if it makes no progress for
+// transferStallSeconds, and is aborted after transferMaxTime seconds regardless
+const (
+	defaultTransferSizeBytes = 1 << 20
+	maxTransferSizeBytes     = 100 << 20
+	transferStallSeconds     = 10
+	transferMaxTime          = "60"
+)
+
 var presetUserDataVariables = map[string]string{
-	"USERDATA_BEGIN": startingToken,
-	"USERDATA_END":   endingToken,
+	"USERDATA_BEGIN":   startingToken,
+	"USERDATA_END":     endingToken,
+	"SEQUENCE_COMMAND": helpers.SequenceLinesCommand(""),
+}
+
+// reTemplateTokenVariables match references to the variables holding the probe's starting and
+// ending tokens within a userdata template, e.g., "${USERDATA_BEGIN}" or "$USERDATA_BEGIN"
+var (
+	reTemplateStartingTokenVariable = regexp.MustCompile(`\$(\{USERDATA_BEGIN\}|USERDATA_BEGIN\b)`)
+	reTemplateEndingTokenVariable   = regexp.MustCompile(`\$(\{USERDATA_END\}|USERDATA_END\b)`)
+)
+
+// validateUserDataTemplate returns an error if a custom userdata template can't possibly work,
+// i.e., if it doesn't print the probe's starting and ending tokens
+func validateUserDataTemplate(customTemplate string) error {
+	if !reTemplateStartingTokenVariable.MatchString(customTemplate) {
+		return fmt.Errorf("invalid userdata template: must print the probe's starting token (${USERDATA_BEGIN})")
+	}
+	if !reTemplateEndingTokenVariable.MatchString(customTemplate) {
+		return fmt.Errorf("invalid userdata template: must print the probe's ending token (${USERDATA_END})")
+	}
+	return nil
+}
+
+// WithUserDataTemplate implements probes.UserDataTemplateOverrider, returning a copy of the probe
+// that uses customTemplate instead of its built-in template (see Probe.UserDataTemplate)
+func (clp Probe) WithUserDataTemplate(customTemplate string) (probes.Probe, error) {
+	if err := validateUserDataTemplate(customTemplate); err != nil {
+		return nil, err
+	}
+	clp.UserDataTemplate = customTemplate
+	return clp, nil
 }
 
 // GetStartingToken returns the string token used to signal the beginning of the probe's output
@@ -91,27 +169,57 @@ func (clp Probe) GetMachineImageID(platformType cloud.Platform, cpuArch cpu.Arch
 func (clp Probe) GetExpandedUserData(userDataVariables map[string]string) (string, error) {
 	// Use systemd to run curl (instead of cloud-init) if requested. Useful for
 	// platforms that don't include cloud-init in their OS images (e.g., GCP)
+	template := userDataTemplate
 	if userDataVariables["USE_SYSTEMD"] == "true" {
-		userDataTemplate = systemdTemplate
+		template = systemdTemplate
+	}
+	// A caller-supplied template replaces either built-in template
+	if clp.UserDataTemplate != "" {
+		if err := validateUserDataTemplate(clp.UserDataTemplate); err != nil {
+			return "", err
+		}
+		template = clp.UserDataTemplate
 	}
 
 	// Extract required variables specified in template (if any)
-	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(userDataTemplate)
+	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(template)
 
 	// TIMEOUT might be a duration string (e.g., "3s"), but curl only accepts a naked
 	// positive decimal number of seconds
 
 	var err error
-	userDataVariables["TIMEOUT"], err = normalizeSaneNonzeroDuration(userDataVariables["TIMEOUT"], "%.2f")
+	userDataVariables["TIMEOUT"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["TIMEOUT"], "%.2f")
 	if err != nil {
 		return "", fmt.Errorf("invalid userdata variable TIMEOUT: %w", err)
 	}
 	// Same goes for DELAY, except cloud-init only accepts a positive integer number of seconds
-	userDataVariables["DELAY"], err = normalizeSaneNonzeroDuration(userDataVariables["DELAY"], "%.f")
+	userDataVariables["DELAY"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["DELAY"], "%.f")
 	if err != nil {
 		return "", fmt.Errorf("invalid userdata variable DELAY: %w", err)
 	}
 
+	if err := clp.ValidateSampling(); err != nil {
+		return "", err
+	}
+
+	extraCurlOptions, err := curlgen.ParseExtraOptions(strings.Split(userDataVariables["CURLOPT"], "\n"))
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable CURLOPT: %w", err)
+	}
+
+	// IP_FAMILY (if set) forces curl to check every endpoint over IPv4, IPv6, or both (in
+	// separate passes whose results are reported per family)
+	var ipFamilies []string
+	if ipFamilyName := userDataVariables["IP_FAMILY"]; ipFamilyName != "" {
+		ipFamily, err := ipfamily.ByName(ipFamilyName)
+		if err != nil {
+			return "", fmt.Errorf("invalid userdata variable IP_FAMILY: %w", err)
+		}
+		for _, pass := range ipFamily.Passes() {
+			ipFamilies = append(ipFamilies, pass.String())
+		}
+	}
+
 	curlOptions := curlgen.Options{
 		CaPath:          "/etc/pki/tls/certs/",
 		ProxyCaPath:     "/etc/pki/tls/certs/",
@@ -120,6 +228,10 @@ func (clp Probe) GetExpandedUserData(userDataVariables map[string]string) (strin
 		NoTls:           userDataVariables["NOTLS"],
 		Urls:            userDataVariables["URLS"],
 		TlsDisabledUrls: userDataVariables["TLSDISABLED_URLS"],
+		Samples:         clp.Samples,
+		SampleJitter:    SampleJitterSeconds,
+		ExtraOptions:    extraCurlOptions,
+		IPFamilies:      ipFamilies,
 	}
 
 	userDataVariables["CURL_COMMAND"], err = curlgen.GenerateString(&curlOptions)
@@ -127,6 +239,58 @@ func (clp Probe) GetExpandedUserData(userDataVariables map[string]string) (strin
 		return "", err
 	}
 
+	// Optionally discover the public IP the network egresses from using an IP echo URL.
+	// When disabled, the command is replaced with a no-op to keep the userdata valid
+	userDataVariables["EGRESS_IP_COMMAND"] = "true"
+	if egressIPURL := userDataVariables["EGRESS_IP_URL"]; egressIPURL != "" {
+		egressIPChecks := 1
+		if checksStr := userDataVariables["EGRESS_IP_CHECKS"]; checksStr != "" {
+			egressIPChecks, err = strconv.Atoi(checksStr)
+			if err != nil || egressIPChecks < 1 || egressIPChecks > maxEgressIPChecks {
+				return "", fmt.Errorf("invalid userdata variable EGRESS_IP_CHECKS: must be an integer between 1 and %d", maxEgressIPChecks)
+			}
+		}
+		if parsedURL, err := url.Parse(egressIPURL); err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return "", fmt.Errorf("invalid userdata variable EGRESS_IP_URL: must be an http(s) URL")
+		}
+
+		userDataVariables["EGRESS_IP_COMMAND"], err = curlgen.GenerateEgressIPString(&curlgen.EgressIPOptions{
+			URL:     egressIPURL,
+			Checks:  egressIPChecks,
+			Retry:   curlOptions.Retry,
+			MaxTime: curlOptions.MaxTime,
+		})
+		if err != nil {
+			return "", fmt.Errorf("invalid userdata variable EGRESS_IP_URL: %w", err)
+		}
+	}
+
+	// Optionally download a large payload from selected URLs to catch paths that complete
+	// handshakes but stall on large packets (e.g., MTU blackholes). When disabled, the command
+	// is replaced with a no-op to keep the userdata valid
+	userDataVariables["TRANSFER_COMMAND"] = "true"
+	if transferURLs := userDataVariables["TRANSFER_URLS"]; transferURLs != "" {
+		transferSize := int64(defaultTransferSizeBytes)
+		if sizeStr := userDataVariables["TRANSFER_SIZE"]; sizeStr != "" {
+			transferSize, err = parseByteSize(sizeStr)
+			if err != nil || transferSize < 1 || transferSize > maxTransferSizeBytes {
+				return "", fmt.Errorf("invalid userdata variable TRANSFER_SIZE: must be a size between 1 and %d bytes (e.g., 512K or 1M)", maxTransferSizeBytes)
+			}
+		}
+
+		userDataVariables["TRANSFER_COMMAND"], err = curlgen.GenerateTransferString(&curlgen.TransferOptions{
+			CaPath:      curlOptions.CaPath,
+			ProxyCaPath: curlOptions.ProxyCaPath,
+			Urls:        transferURLs,
+			SizeBytes:   transferSize,
+			StallTime:   transferStallSeconds,
+			MaxTime:     transferMaxTime,
+		})
+		if err != nil {
+			return "", fmt.Errorf("invalid userdata variable TRANSFER_URLS: %w", err)
+		}
+	}
+
 	// Ensure userDataVariables complies with requiredVariables and presetUserDataVariables. See
 	// docstring for helpers.ValidateProvidedVariables() for more details
 	err = helpers.ValidateProvidedVariables(userDataVariables, presetUserDataVariables, requiredVariables)
@@ -166,15 +330,43 @@ func (clp Probe) GetExpandedUserData(userDataVariables map[string]string) (strin
 		userDataVariables["CACERT_RENDERED"] = strings.TrimSpace(string(cloudInitYamlBytes))
 	}
 
+	// Label the probe's output with this run's nonce (if any) so that stale output left on the
+	// console by a previous run is ignored
+	noncedPresetUserDataVariables := helpers.NoncedPresetUserDataVariables(presetUserDataVariables, userDataVariables["NONCE"])
+
 	// Expand template
 	return os.Expand(directivelessUserDataTemplate, func(userDataVar string) string {
-		if presetVal, isPreset := presetUserDataVariables[userDataVar]; isPreset {
+		if presetVal, isPreset := noncedPresetUserDataVariables[userDataVar]; isPreset {
 			return presetVal
 		}
 		return userDataVariables[userDataVar]
 	}), nil
 }
 
+// ParseProgressLine implements probes.ProgressReporter, describing a single line of curl JSON
+// output (i.e., one endpoint check, or one sample thereof) as soon as it's printed. Lines
+// containing other output (e.g., egress IP discovery or transfer results) are ignored
+func (clp Probe) ParseProgressLine(line string) (probes.ProgressEvent, bool) {
+	repairedLine := helpers.FixLeadingZerosInJSON(helpers.RemoveTimestamps(line))
+	if !strings.HasPrefix(strings.TrimSpace(repairedLine), curlgen.DefaultCurlOutputSeparator) {
+		return probes.ProgressEvent{}, false
+	}
+	probeResult, err := deserializeCurlJSONProbeResult(repairedLine)
+	if err != nil {
+		return probes.ProgressEvent{}, false
+	}
+
+	event := probes.ProgressEvent{
+		Target:  probeResult.Target(),
+		Success: probeResult.IsSuccessfulConnection(),
+		Detail:  fmt.Sprintf("%.2fs", probeResult.TimeTotal),
+	}
+	if !event.Success {
+		event.Detail = probeResult.ErrorMsg
+	}
+	return event, true
+}
+
 // ParseProbeOutput accepts a string containing all probe output that appeared between
 // the startingToken and the endingToken and a pointer to an Output object. outputDestination
 // will be filled with the results from the egress check
@@ -182,28 +374,39 @@ func (clp Probe) GetExpandedUserData(userDataVariables map[string]string) (strin
 func (clp Probe) ParseProbeOutput(ensurePrivate bool, probeOutput string, outputDestination *output.Output) {
 	// probeOutput first needs to be "repaired" due to curl and AWS bugs
 	repairedProbeOutput := helpers.FixLeadingZerosInJSON(helpers.RemoveTimestamps(probeOutput))
+	// Egress IP discovery results (if any) aren't JSON, so they're handled separately
+	repairedProbeOutput, egressIPBodies := extractPrefixedLines(repairedProbeOutput, curlgen.DefaultEgressIPOutputSeparator)
+	if len(egressIPBodies) > 0 {
+		reportEgressIPs(egressIPBodies, outputDestination)
+	}
+	// Large-payload transfer results (if any) are reported in their own failure category
+	repairedProbeOutput, transferLines := extractPrefixedLines(repairedProbeOutput, curlgen.DefaultTransferOutputSeparator)
+	if len(transferLines) > 0 {
+		reportTransfers(transferLines, outputDestination)
+	}
 	probeResults, errMap := bulkDeserializeCurlJSONProbeResult(repairedProbeOutput)
+	// When sampling, results are aggregated per endpoint instead of being reported one by one
+	if clp.Samples > 1 {
+		clp.reportSamples(ensurePrivate, probeResults, outputDestination)
+		probeResults = nil
+	}
 	for _, probeResult := range probeResults {
 		outputDestination.AddDebugLogs(fmt.Sprintf("%+v\n", probeResult))
 		if !probeResult.IsSuccessfulConnection() {
-			// Replace "telnet" with "tcp" in output to prevent confusion over a probe
-			// implementation detail
-			url := strings.Replace(probeResult.URL, "telnet", "tcp", 1)
 			outputDestination.SetEgressFailures(
-				[]string{fmt.Sprintf("%s (%s)", url, probeResult.ErrorMsg)},
+				[]string{fmt.Sprintf("%s (%s)", probeResult.Target(), probeResult.ErrorMsg)},
 			)
 		}
 		// when ensurePrivate is set to true, we need to make sure the returned IP address is private
 		if ensurePrivate {
-			remoteIP := net.ParseIP(probeResult.RemoteIP)
-			if !remoteIP.IsPrivate() {
+			if !isPrivateAddress(probeResult.RemoteIP) {
 				probeResult.ErrorMsg = "The endpoint is non private"
-				url := strings.Replace(probeResult.URL, "telnet", "tcp", 1)
 				outputDestination.SetEgressFailures(
-					[]string{fmt.Sprintf("%s (%s)", url, probeResult.ErrorMsg)})
+					[]string{fmt.Sprintf("%s (%s)", probeResult.Target(), probeResult.ErrorMsg)})
 			}
 		}
 	}
+	reportIPFamilies(probeResults, outputDestination)
 	for lineNum, err := range errMap {
 		outputDestination.AddError(
 			handledErrors.NewGenericError(
@@ -213,21 +416,161 @@ func (clp Probe) ParseProbeOutput(ensurePrivate bool, probeOutput string, output
 	}
 }
 
-// normalizeSaneNonzeroDuration first converts a given string expected to hold a duration
-// (e.g., "3s" or "2") to a float64 using helpers.DurationToBareSeconds(). It then ensures the
-// float duration is "sane," i.e., greater than 0 seconds but less than 3 hours*. If sane, the
-// duration in seconds is Sprintf'd using the provided fmtStr and returned. If not sane, an error
-// is returned.
-// * We max at 3 hours under the assumption that the verifier isn't doing anything for >3hrs
-func normalizeSaneNonzeroDuration(possibleDurationStr string, fmtStr string) (string, error) {
-	durationSeconds := helpers.DurationToBareSeconds(possibleDurationStr)
-	if durationSeconds <= 0 {
-		return "", fmt.Errorf("invalid %s value (parsed as %.2f sec)", possibleDurationStr, durationSeconds)
+// isPrivateAddress returns true if addr is a private IP address, i.e., an IPv4 address within one
+// of the RFC 1918 ranges (including its IPv4-mapped IPv6 form) or an IPv6 unique local address
+// (RFC 4193, fc00::/7). Any IPv6 zone (e.g., "fd00::1%eth0") is ignored
+func isPrivateAddress(addr string) bool {
+	parsedAddr, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	return parsedAddr.Unmap().IsPrivate()
+}
+
+// reportIPFamilies records how many of the endpoints checked over each IP family were reachable as
+// informational results, so that (e.g.) a subnet with working IPv4 egress but broken IPv6 egress
+// stands out. Nothing is recorded unless curl was forced to use a particular IP family
+func reportIPFamilies(probeResults []*CurlJSONProbeResult, outputDestination *output.Output) {
+	var ipFamilies []string
+	checked, reachable := make(map[string]int), make(map[string]int)
+	for _, probeResult := range probeResults {
+		if probeResult.IPFamily == "" {
+			continue
+		}
+		if checked[probeResult.IPFamily] == 0 {
+			ipFamilies = append(ipFamilies, probeResult.IPFamily)
+		}
+		checked[probeResult.IPFamily]++
+		if probeResult.IsSuccessfulConnection() {
+			reachable[probeResult.IPFamily]++
+		}
+	}
+	for _, ipFamily := range ipFamilies {
+		outputDestination.AddInfo(fmt.Sprintf("%s egress: %d of %d endpoints reachable", ipFamily, reachable[ipFamily], checked[ipFamily]))
+	}
+}
+
+// ipAddressRegex loosely matches IPv4 and IPv6 addresses; candidates are validated with net.ParseIP
+var ipAddressRegex = regexp.MustCompile(`[0-9]{1,3}(\.[0-9]{1,3}){3}|[0-9a-fA-F]{0,4}(:[0-9a-fA-F]{0,4}){2,7}`)
+
+// extractPrefixedLines removes all lines starting with prefix from probeOutput, returning the
+// remaining lines along with the removed lines (with prefix removed)
+func extractPrefixedLines(probeOutput string, prefix string) (string, []string) {
+	var remainingLines, prefixedLines []string
+	for _, line := range strings.Split(probeOutput, "\n") {
+		if unprefixedLine, found := strings.CutPrefix(strings.TrimSpace(line), prefix); found {
+			prefixedLines = append(prefixedLines, unprefixedLine)
+			continue
+		}
+		remainingLines = append(remainingLines, line)
+	}
+	return strings.Join(remainingLines, "\n"), prefixedLines
+}
+
+// reportEgressIPs records the public egress IP(s) found in the IP echo URL's response bodies
+// as informational results. The first valid IP address in each body is used, which allows
+// both dedicated IP echo services (e.g., checkip.amazonaws.com) and URLs that reflect the
+// caller's address within a larger response (e.g., httpbin.org/ip). A warning is added if
+// no IP could be discovered or if repeated checks observed different IPs
+func reportEgressIPs(egressIPBodies []string, outputDestination *output.Output) {
+	var egressIPs []string
+	observations := make(map[string]int)
+	for _, body := range egressIPBodies {
+		outputDestination.AddDebugLogs(fmt.Sprintf("egress IP check response: %s\n", body))
+		for _, candidate := range ipAddressRegex.FindAllString(body, -1) {
+			if net.ParseIP(candidate) == nil {
+				continue
+			}
+			if observations[candidate] == 0 {
+				egressIPs = append(egressIPs, candidate)
+			}
+			observations[candidate]++
+			break
+		}
 	}
 
-	if durationSeconds > 10800 {
-		return "", fmt.Errorf("value %s (parsed as %.2f sec) is too large", possibleDurationStr, durationSeconds)
+	if len(egressIPs) == 0 {
+		outputDestination.AddWarning(fmt.Errorf("unable to discover public egress IP: none of the %d egress IP check(s) returned an IP address", len(egressIPBodies)))
+		return
 	}
 
-	return fmt.Sprintf(fmtStr, durationSeconds), nil
+	for _, egressIP := range egressIPs {
+		outputDestination.AddInfo(fmt.Sprintf("public egress IP: %s (observed in %d of %d checks)", egressIP, observations[egressIP], len(egressIPBodies)))
+	}
+	if len(egressIPs) > 1 {
+		outputDestination.AddWarning(fmt.Errorf("multiple public egress IPs observed (%s); egress IP is not stable, so allowlisting a single IP with third parties may not be sufficient", strings.Join(egressIPs, ", ")))
+	}
+}
+
+// reportTransfers records the results of large-payload transfer checks, which are
+// curl JSON results (with their prefix removed). Completed transfers are recorded as
+// informational results, while failed or stalled transfers are recorded as transfer failures
+func reportTransfers(transferLines []string, outputDestination *output.Output) {
+	for lineNum, transferLine := range transferLines {
+		probeResult, err := deserializeCurlJSONProbeResult(curlgen.DefaultCurlOutputSeparator + transferLine)
+		if err != nil {
+			outputDestination.AddError(
+				handledErrors.NewGenericError(
+					fmt.Errorf("error processing transfer result %d: %w", lineNum, err),
+				),
+			)
+			continue
+		}
+		outputDestination.AddDebugLogs(fmt.Sprintf("%+v\n", probeResult))
+
+		if failure := transferFailure(probeResult); failure != "" {
+			outputDestination.SetTransferFailures([]string{fmt.Sprintf("%s (%s)", probeResult.URL, failure)})
+			continue
+		}
+		outputDestination.AddInfo(fmt.Sprintf("large-payload transfer from %s: %d bytes in %.2fs", probeResult.URL, probeResult.SizeDownload, probeResult.TimeTotal))
+	}
+}
+
+// transferFailure returns a human-readable reason why a large-payload transfer failed, or an
+// empty string if the transfer completed. Transfers that connected successfully but then timed
+// out or were interrupted are flagged as possible path MTU problems, unless the server ignored
+// the range request, in which case the full response may simply have been too large
+func transferFailure(res *CurlJSONProbeResult) string {
+	const mtuHint = "possible path MTU blackhole or fragmentation problem"
+	const rangeHint = "use a URL that supports range requests"
+	switch res.ExitCode {
+	case 0:
+		if res.HTTPCode < 200 || res.HTTPCode > 299 {
+			return fmt.Sprintf("server responded with HTTP %d", res.HTTPCode)
+		}
+		return ""
+	case 28: // Operation timeout (includes --speed-limit/--speed-time stalls)
+		if res.SizeDownload > 0 {
+			if res.HTTPCode != http.StatusPartialContent {
+				return fmt.Sprintf("transfer timed out after %d bytes, but the server ignored the range request (HTTP %d); %s", res.SizeDownload, res.HTTPCode, rangeHint)
+			}
+			return fmt.Sprintf("transfer stalled after %d bytes; %s", res.SizeDownload, mtuHint)
+		}
+		if res.TimeConnect > 0 {
+			return fmt.Sprintf("connected but received no data before timing out; %s", mtuHint)
+		}
+	case 18, 56: // Partial file, failure receiving network data
+		return fmt.Sprintf("transfer interrupted after %d bytes (%s); %s", res.SizeDownload, res.ErrorMsg, mtuHint)
+	case 63: // Maximum file size exceeded (--max-filesize)
+		return fmt.Sprintf("server ignored the range request (HTTP %d) and announced a larger response than requested; %s", res.HTTPCode, rangeHint)
+	}
+	return res.ErrorMsg
+}
+
+// parseByteSize converts a size string consisting of an integer and an optional binary
+// unit suffix (K, M, or G) into a number of bytes, e.g., "512K" -> 524288
+func parseByteSize(sizeStr string) (int64, error) {
+	multiplier := int64(1)
+	trimmed := strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(sizeStr), "B"))
+	for suffix, suffixMultiplier := range map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30} {
+		if strings.HasSuffix(trimmed, suffix) {
+			trimmed, multiplier = strings.TrimSuffix(trimmed, suffix), suffixMultiplier
+			break
+		}
+	}
+	size, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %w", sizeStr, err)
+	}
+	return size * multiplier, nil
 }
@@ -51,6 +51,22 @@ type CurlJSONProbeResult struct {
 	URLEffective         string  `json:"url_effective"`
 	URLNum               int     `json:"urlnum"`
 	CurlVersion          string  `json:"curl_version"`
+
+	// IPFamily is the IP family ("ipv4" or "ipv6") that curl was forced to use for this check,
+	// or empty if curl picked one itself. It's parsed from the label preceding curl's JSON output
+	// (see curlgen.IPFamilyLabel) rather than from the JSON itself
+	IPFamily string `json:"-"`
+}
+
+// Target returns a human-readable description of the endpoint checked, i.e., its URL (with
+// "telnet" replaced by "tcp" to prevent confusion over a probe implementation detail), followed
+// by the IP family curl was forced to use (if any), e.g., "https://quay.io:443 [ipv6]"
+func (res CurlJSONProbeResult) Target() string {
+	target := strings.Replace(res.URL, "telnet", "tcp", 1)
+	if res.IPFamily != "" {
+		target += " [" + res.IPFamily + "]"
+	}
+	return target
 }
 
 // IsSuccessfulConnection returns true if the CurlJSONProbeResult reports a successful
@@ -110,6 +126,12 @@ func deserializeCurlJSONProbeResult(prefixedCurlJSON string) (*CurlJSONProbeResu
 		return nil, fmt.Errorf("missing prefix '%s': %s", curlgen.DefaultCurlOutputSeparator, prefixedCurlJSON)
 	}
 	var result CurlJSONProbeResult
+	for _, ipFamily := range []string{"ipv4", "ipv6"} {
+		if unlabeledJSONStr, labelFound := strings.CutPrefix(jsonStr, curlgen.IPFamilyLabel(ipFamily)); labelFound {
+			jsonStr, result.IPFamily = unlabeledJSONStr, ipFamily
+			break
+		}
+	}
 	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
 		return nil, err
 	}
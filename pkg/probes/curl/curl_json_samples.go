@@ -0,0 +1,173 @@
+package curl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openshift/osd-network-verifier/pkg/output"
+)
+
+// maxSamples caps Probe.Samples so that the probe finishes well within the verifier's console
+// output polling window
+const maxSamples = 10
+
+// SampleJitterSeconds is the maximum number of seconds the probe pauses between samples
+const SampleJitterSeconds = 5
+
+// Classifications assigned to sampled endpoints
+const (
+	SamplePassing = "passing"
+	SampleFlaky   = "flaky"
+	SampleBlocked = "blocked"
+)
+
+// ValidateSampling returns an error if the probe's sampling configuration is invalid
+func (clp Probe) ValidateSampling() error {
+	if clp.Samples > maxSamples {
+		return fmt.Errorf("invalid number of samples %d: must be at most %d", clp.Samples, maxSamples)
+	}
+	minPassing, maxBlocked := clp.sampleThresholds()
+	if minPassing <= 0 || minPassing > 1 || maxBlocked < 0 || maxBlocked >= minPassing {
+		return fmt.Errorf("invalid sample thresholds: need 0 <= MaxBlockedRatio (%g) < MinPassingRatio (%g) <= 1", maxBlocked, minPassing)
+	}
+	return nil
+}
+
+// MaxRuntime implements probes.RuntimeEstimator. When sampling, every endpoint is checked once per
+// sample, and the probe pauses for up to SampleJitterSeconds after each sample
+func (clp Probe) MaxRuntime(endpointCount int, timeout time.Duration) time.Duration {
+	runtime := time.Duration(endpointCount) * timeout
+	if clp.Samples > 1 {
+		runtime = time.Duration(clp.Samples) * (runtime + SampleJitterSeconds*time.Second)
+	}
+	return runtime
+}
+
+// sampleThresholds returns the probe's MinPassingRatio and MaxBlockedRatio, applying defaults
+func (clp Probe) sampleThresholds() (minPassing float64, maxBlocked float64) {
+	minPassing = clp.MinPassingRatio
+	if minPassing == 0 {
+		minPassing = 1
+	}
+	return minPassing, clp.MaxBlockedRatio
+}
+
+// SampleSummary aggregates all samples collected for a single endpoint
+type SampleSummary struct {
+	URL            string
+	Samples        int
+	Successes      int
+	Classification string
+	// LatencyP50, LatencyP90, and LatencyP99 are percentiles (in seconds) of the total time
+	// taken by successful samples. They're zero if no samples succeeded
+	LatencyP50, LatencyP90, LatencyP99 float64
+	// LastError holds curl's error message from the most recent failed sample, if any
+	LastError string
+	// NonPrivateIPs holds any non-private remote IPs seen across all samples
+	NonPrivateIPs []string
+}
+
+// SuccessRatio returns the fraction of samples that succeeded
+func (ss SampleSummary) SuccessRatio() float64 {
+	if ss.Samples == 0 {
+		return 0
+	}
+	return float64(ss.Successes) / float64(ss.Samples)
+}
+
+// String returns a one-line summary of the endpoint's samples suitable for display to users
+func (ss SampleSummary) String() string {
+	summary := fmt.Sprintf("%s: %s (%d/%d samples succeeded", ss.URL, ss.Classification, ss.Successes, ss.Samples)
+	if ss.Successes > 0 {
+		summary += fmt.Sprintf("; latency p50 %.3fs, p90 %.3fs, p99 %.3fs", ss.LatencyP50, ss.LatencyP90, ss.LatencyP99)
+	}
+	if ss.LastError != "" {
+		summary += fmt.Sprintf("; last error: %s", ss.LastError)
+	}
+	return summary + ")"
+}
+
+// summarizeSamples groups probeResults by target (i.e., URL and IP family, in order of first appearance) and classifies
+// each endpoint according to the probe's thresholds
+func (clp Probe) summarizeSamples(probeResults []*CurlJSONProbeResult) []SampleSummary {
+	minPassing, maxBlocked := clp.sampleThresholds()
+
+	var urls []string
+	resultsByURL := make(map[string][]*CurlJSONProbeResult)
+	for _, probeResult := range probeResults {
+		target := probeResult.Target()
+		if _, seen := resultsByURL[target]; !seen {
+			urls = append(urls, target)
+		}
+		resultsByURL[target] = append(resultsByURL[target], probeResult)
+	}
+
+	summaries := make([]SampleSummary, 0, len(urls))
+	for _, url := range urls {
+		summary := SampleSummary{URL: url, Samples: len(resultsByURL[url])}
+		var latencies []float64
+		seenNonPrivateIPs := make(map[string]bool)
+		for _, probeResult := range resultsByURL[url] {
+			if probeResult.IsSuccessfulConnection() {
+				summary.Successes++
+				latencies = append(latencies, probeResult.TimeTotal)
+			} else {
+				summary.LastError = probeResult.ErrorMsg
+			}
+			if probeResult.RemoteIP != "" && !isPrivateAddress(probeResult.RemoteIP) && !seenNonPrivateIPs[probeResult.RemoteIP] {
+				seenNonPrivateIPs[probeResult.RemoteIP] = true
+				summary.NonPrivateIPs = append(summary.NonPrivateIPs, probeResult.RemoteIP)
+			}
+		}
+
+		sort.Float64s(latencies)
+		summary.LatencyP50 = percentile(latencies, 50)
+		summary.LatencyP90 = percentile(latencies, 90)
+		summary.LatencyP99 = percentile(latencies, 99)
+
+		switch ratio := summary.SuccessRatio(); {
+		case ratio >= minPassing:
+			summary.Classification = SamplePassing
+		case ratio <= maxBlocked:
+			summary.Classification = SampleBlocked
+		default:
+			summary.Classification = SampleFlaky
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+// reportSamples records a summary of every sampled endpoint as an informational result.
+// Flaky and blocked endpoints are also recorded as egress failures, as are endpoints with
+// non-private remote IPs when ensurePrivate is set to true
+func (clp Probe) reportSamples(ensurePrivate bool, probeResults []*CurlJSONProbeResult, outputDestination *output.Output) {
+	for _, summary := range clp.summarizeSamples(probeResults) {
+		outputDestination.AddDebugLogs(fmt.Sprintf("%+v\n", summary))
+		outputDestination.AddInfo(summary.String())
+		if summary.Classification != SamplePassing {
+			outputDestination.SetEgressFailures(
+				[]string{fmt.Sprintf("%s (%s: %d/%d samples succeeded; last error: %s)", summary.URL, summary.Classification, summary.Successes, summary.Samples, summary.LastError)},
+			)
+		}
+		if ensurePrivate && len(summary.NonPrivateIPs) > 0 {
+			outputDestination.SetEgressFailures(
+				[]string{fmt.Sprintf("%s (The endpoint is non private: %s)", summary.URL, strings.Join(summary.NonPrivateIPs, ", "))},
+			)
+		}
+	}
+}
+
+// percentile returns the p-th percentile of sortedValues using the nearest-rank method,
+// or 0 if sortedValues is empty
+func percentile(sortedValues []float64, p float64) float64 {
+	if len(sortedValues) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sortedValues))))
+	return sortedValues[max(rank, 1)-1]
+}
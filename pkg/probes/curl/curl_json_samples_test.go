@@ -0,0 +1,118 @@
+package curl
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/osd-network-verifier/pkg/output"
+)
+
+func TestProbe_ValidateSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   Probe
+		wantErr bool
+	}{
+		{name: "zero value", probe: Probe{}},
+		{name: "custom thresholds", probe: Probe{Samples: 5, MinPassingRatio: 0.8, MaxBlockedRatio: 0.2}},
+		{name: "too many samples", probe: Probe{Samples: 100}, wantErr: true},
+		{name: "passing ratio above 1", probe: Probe{Samples: 5, MinPassingRatio: 1.5}, wantErr: true},
+		{name: "overlapping thresholds", probe: Probe{Samples: 5, MinPassingRatio: 0.5, MaxBlockedRatio: 0.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.probe.ValidateSampling(); (err != nil) != tt.wantErr {
+				t.Errorf("Probe.ValidateSampling() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProbe_MaxRuntime(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe Probe
+		want  time.Duration
+	}{
+		{name: "zero value", probe: Probe{}, want: 50 * time.Second},
+		{name: "single sample", probe: Probe{Samples: 1}, want: 50 * time.Second},
+		{name: "sampled", probe: Probe{Samples: 3}, want: 3 * (50*time.Second + SampleJitterSeconds*time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.probe.MaxRuntime(10, 5*time.Second); got != tt.want {
+				t.Errorf("Probe.MaxRuntime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbe_summarizeSamples(t *testing.T) {
+	success := func(url string, timeTotal float64) *CurlJSONProbeResult {
+		return &CurlJSONProbeResult{URL: url, Scheme: "HTTPS", ExitCode: 0, TimeTotal: timeTotal, RemoteIP: "10.0.0.1"}
+	}
+	failure := func(url string) *CurlJSONProbeResult {
+		return &CurlJSONProbeResult{URL: url, ExitCode: 28, ErrorMsg: "Connection timed out"}
+	}
+
+	probeResults := []*CurlJSONProbeResult{
+		success("https://passing.example:443", 0.1), success("https://flaky.example:443", 0.5), failure("https://blocked.example:443"),
+		success("https://passing.example:443", 0.3), failure("https://flaky.example:443"), failure("https://blocked.example:443"),
+		success("https://passing.example:443", 0.2), success("https://flaky.example:443", 0.4), failure("https://blocked.example:443"),
+		success("https://passing.example:443", 0.4), success("https://flaky.example:443", 0.6), success("https://blocked.example:443", 0.1),
+	}
+
+	summaries := Probe{Samples: 4, MinPassingRatio: 1, MaxBlockedRatio: 0.25}.summarizeSamples(probeResults)
+	if len(summaries) != 3 {
+		t.Fatalf("expected 3 summaries, got %d: %+v", len(summaries), summaries)
+	}
+
+	wantClassifications := map[string]string{
+		"https://passing.example:443": SamplePassing,
+		"https://flaky.example:443":   SampleFlaky,
+		"https://blocked.example:443": SampleBlocked,
+	}
+	for _, summary := range summaries {
+		if summary.Classification != wantClassifications[summary.URL] {
+			t.Errorf("%s classified as %s, want %s", summary.URL, summary.Classification, wantClassifications[summary.URL])
+		}
+		if summary.Samples != 4 {
+			t.Errorf("%s has %d samples, want 4", summary.URL, summary.Samples)
+		}
+	}
+
+	passing := summaries[0]
+	if passing.LatencyP50 != 0.2 || passing.LatencyP90 != 0.4 || passing.LatencyP99 != 0.4 {
+		t.Errorf("unexpected latency percentiles for %s: %+v", passing.URL, passing)
+	}
+}
+
+func TestCurlJSONProbe_ParseProbeOutput_Samples(t *testing.T) {
+	probeOutput := strings.Join([]string{
+		`@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"10.0.0.1","time_total":0.100000}`,
+		`@NV@{"url":"telnet://example.com:9997","exitcode":28,"errormsg":"Connection timed out","scheme":"","remote_ip":"","time_total":5.000000}`,
+		`@NV@{"url":"https://quay.io:443","exitcode":7,"errormsg":"Failed to connect","scheme":"","remote_ip":"","time_total":0.010000}`,
+		`@NV@{"url":"telnet://example.com:9997","exitcode":28,"errormsg":"Connection timed out","scheme":"","remote_ip":"","time_total":5.000000}`,
+	}, "\n")
+
+	out := &output.Output{}
+	Probe{Samples: 2}.ParseProbeOutput(false, probeOutput, out)
+
+	wantFailures := []string{
+		"egressURL error: https://quay.io:443 (flaky: 1/2 samples succeeded; last error: Failed to connect)",
+		"egressURL error: tcp://example.com:9997 (blocked: 0/2 samples succeeded; last error: Connection timed out)",
+	}
+	gotFailures := out.GetEgressURLFailures()
+	if len(gotFailures) != len(wantFailures) {
+		t.Fatalf("curl.Probe.ParseProbeOutput() failures = %v, want %v", gotFailures, wantFailures)
+	}
+	for i, failure := range gotFailures {
+		if failure.Error() != wantFailures[i] {
+			t.Errorf("curl.Probe.ParseProbeOutput() failure[%d] = %s, want %s", i, failure.Error(), wantFailures[i])
+		}
+	}
+	if len(out.GetInfo()) != 2 {
+		t.Errorf("curl.Probe.ParseProbeOutput() info = %v, want 2 entries", out.GetInfo())
+	}
+}
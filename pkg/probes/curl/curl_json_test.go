@@ -1,6 +1,7 @@
 package curl
 
 import (
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -8,6 +9,7 @@ import (
 	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
 	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
 	"github.com/openshift/osd-network-verifier/pkg/probes"
 	"gopkg.in/yaml.v3"
 )
@@ -18,6 +20,9 @@ import (
 // is missing), this test will fail to compile
 func TestCurlJSONProbe_ImplementsProbeInterface(t *testing.T) {
 	var _ probes.Probe = (*Probe)(nil)
+	var _ probes.ProgressReporter = (*Probe)(nil)
+	var _ probes.RuntimeEstimator = (*Probe)(nil)
+	var _ probes.UserDataTemplateOverrider = (*Probe)(nil)
 }
 
 // TestCurlJSONProbe_GetExpandedUserData tests the correctness of the user-
@@ -59,6 +64,16 @@ func TestCurlJSONProbe_GetExpandedUserData(t *testing.T) {
 			},
 			wantRegex: `#cloud-config[\s\S]*ca_certs[\s\S]*trusted:[\s\S]*BEGIN CERTIFICATE[\s\S]*END CERTIFICATE`,
 		},
+		{
+			name: "nonce provided",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "http://example.com:80",
+				"NONCE":   "abc123",
+			},
+			wantRegex: `#cloud-config[\s\S]*echo "NV_CURLJSON_BEGIN_abc123"[\s\S]*@NVSEQ@abc123@%d@[\s\S]*echo "NV_CURLJSON_END_abc123"`,
+		},
 		{
 			name: "set NO_PROXY",
 			userDataVariables: map[string]string{
@@ -100,6 +115,26 @@ func TestCurlJSONProbe_GetExpandedUserData(t *testing.T) {
 			},
 			wantRegex: `#cloud-config[\s\S]* --insecure [\s\S]*http:\/\/example.com:80 https:\/\/example.org:443 https:\/\/example.net:443`,
 		},
+		{
+			name: "both IP families",
+			userDataVariables: map[string]string{
+				"TIMEOUT":   "1",
+				"DELAY":     "2",
+				"URLS":      "https://example.org:443",
+				"IP_FAMILY": "both",
+			},
+			wantRegex: `#cloud-config[\s\S]*\( \{ curl [^;]*@NV@ipv4@[^;]* --ipv4 https:\/\/example.org:443[^;]*; curl [^;]*@NV@ipv6@[^;]* --ipv6 https:\/\/example.org:443[^;]*; \} 2>&1 >\/dev\/null;`,
+		},
+		{
+			name: "invalid IP family",
+			userDataVariables: map[string]string{
+				"TIMEOUT":   "1",
+				"DELAY":     "2",
+				"URLS":      "https://example.org:443",
+				"IP_FAMILY": "ipv5",
+			},
+			wantErr: true,
+		},
 		{
 			name:                      "missing variables required by directive",
 			userDataVariables:         map[string]string{},
@@ -116,6 +151,60 @@ func TestCurlJSONProbe_GetExpandedUserData(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "egress IP discovery",
+			userDataVariables: map[string]string{
+				"TIMEOUT":          "1",
+				"DELAY":            "2",
+				"URLS":             "http://example.com:80 https://example.org:443",
+				"EGRESS_IP_URL":    "https://checkip.amazonaws.com",
+				"EGRESS_IP_CHECKS": "3",
+			},
+			wantRegex: `#cloud-config[\s\S]*seq 1 3[\s\S]*'https:\/\/checkip.amazonaws.com'`,
+		},
+		{
+			name: "invalid EGRESS_IP_CHECKS",
+			userDataVariables: map[string]string{
+				"TIMEOUT":          "1",
+				"DELAY":            "2",
+				"URLS":             "http://example.com:80 https://example.org:443",
+				"EGRESS_IP_URL":    "https://checkip.amazonaws.com",
+				"EGRESS_IP_CHECKS": "100",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid EGRESS_IP_URL",
+			userDataVariables: map[string]string{
+				"TIMEOUT":       "1",
+				"DELAY":         "2",
+				"URLS":          "http://example.com:80 https://example.org:443",
+				"EGRESS_IP_URL": "checkip.amazonaws.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "large-payload transfer",
+			userDataVariables: map[string]string{
+				"TIMEOUT":       "1",
+				"DELAY":         "2",
+				"URLS":          "http://example.com:80 https://example.org:443",
+				"TRANSFER_URLS": "https://example.org:443/big.iso",
+				"TRANSFER_SIZE": "512K",
+			},
+			wantRegex: `#cloud-config[\s\S]*-r 0-524287 [\s\S]*https:\/\/example.org:443\/big.iso`,
+		},
+		{
+			name: "invalid TRANSFER_SIZE",
+			userDataVariables: map[string]string{
+				"TIMEOUT":       "1",
+				"DELAY":         "2",
+				"URLS":          "http://example.com:80 https://example.org:443",
+				"TRANSFER_URLS": "https://example.org:443/big.iso",
+				"TRANSFER_SIZE": "1T",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid DELAY",
 			userDataVariables: map[string]string{
@@ -134,6 +223,26 @@ func TestCurlJSONProbe_GetExpandedUserData(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "extra curl options provided",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "https://quay.io:443",
+				"CURLOPT": "--resolve=quay.io:443:203.0.113.10\n-H=X-Request-Source: osd-network-verifier",
+			},
+			wantRegex: `#cloud-config[\s\S]*--resolve 'quay.io:443:203.0.113.10' --header 'X-Request-Source:osd-network-verifier' https:\/\/quay.io:443`,
+		},
+		{
+			name: "disallowed curl option provided",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "https://quay.io:443",
+				"CURLOPT": "--output=/etc/passwd",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid NOTLS",
 			userDataVariables: map[string]string{
@@ -179,6 +288,124 @@ func TestCurlJSONProbe_GetExpandedUserData(t *testing.T) {
 	}
 }
 
+// TestCurlJSONProbe_CustomUserDataTemplate ensures that a caller-supplied userdata template
+// replaces the built-in templates while still being expanded and validated like them
+// TestCurlJSONProbe_SystemdTemplateStreamsOutput ensures that the systemd-based userdata (used on
+// platforms without cloud-init) pipes curl's output to the console as it's printed, rather than
+// only once every check has finished, so that progress can be reported while the probe runs
+func TestCurlJSONProbe_SystemdTemplateStreamsOutput(t *testing.T) {
+	got, err := Probe{}.GetExpandedUserData(map[string]string{
+		"TIMEOUT":     "1",
+		"DELAY":       "2",
+		"URLS":        "http://example.com:80",
+		"NONCE":       "abc123",
+		"USE_SYSTEMD": "true",
+	})
+	if err != nil {
+		t.Fatalf("curl.Probe.GetExpandedUserData() error = %v", err)
+	}
+	wantRegex := `\( curl [^\n]*http://example.com:80[^\n]*\) \| awk '[^\n]*@NVSEQ@abc123@%d@[^\n]*' >/dev/ttyS0`
+	if !regexp.MustCompile(wantRegex).MatchString(got) {
+		t.Errorf("curl.Probe.GetExpandedUserData() output does not match regex `%s`, content=%v", wantRegex, got)
+	}
+}
+
+func TestCurlJSONProbe_CustomUserDataTemplate(t *testing.T) {
+	customTemplate := `#cloud-config
+# network-verifier-required-variables=CURL_COMMAND,DNS_SEARCH_DOMAIN
+runcmd:
+  - echo "search ${DNS_SEARCH_DOMAIN}" >>/etc/resolv.conf
+  - echo "${USERDATA_BEGIN}" >/dev/ttyS0
+  - ${CURL_COMMAND} 2>&1 >/dev/null | ${SEQUENCE_COMMAND} >/dev/ttyS0
+  - echo "${USERDATA_END}" >/dev/ttyS0
+`
+	tests := []struct {
+		name              string
+		template          string
+		userDataVariables map[string]string
+		wantRegex         string
+		wantErr           bool
+	}{
+		{
+			name:     "custom template expanded",
+			template: customTemplate,
+			userDataVariables: map[string]string{
+				"TIMEOUT":           "1",
+				"DELAY":             "2",
+				"URLS":              "http://example.com:80",
+				"DNS_SEARCH_DOMAIN": "corp.example.com",
+				"NONCE":             "abc123",
+			},
+			wantRegex: `search corp.example.com[\s\S]*echo "NV_CURLJSON_BEGIN_abc123"[\s\S]*http:\/\/example.com:80[\s\S]*echo "NV_CURLJSON_END_abc123"`,
+		},
+		{
+			name:     "custom template also replaces systemd template",
+			template: customTemplate,
+			userDataVariables: map[string]string{
+				"TIMEOUT":           "1",
+				"DELAY":             "2",
+				"URLS":              "http://example.com:80",
+				"DNS_SEARCH_DOMAIN": "corp.example.com",
+				"USE_SYSTEMD":       "true",
+			},
+			wantRegex: `#cloud-config[\s\S]*search corp.example.com`,
+		},
+		{
+			name:     "custom template's required variable missing",
+			template: customTemplate,
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "http://example.com:80",
+			},
+			wantErr: true,
+		},
+		{
+			name:     "custom template without ending token",
+			template: strings.ReplaceAll(customTemplate, "${USERDATA_END}", "done"),
+			userDataVariables: map[string]string{
+				"TIMEOUT":           "1",
+				"DELAY":             "2",
+				"URLS":              "http://example.com:80",
+				"DNS_SEARCH_DOMAIN": "corp.example.com",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Probe{UserDataTemplate: tt.template}.GetExpandedUserData(tt.userDataVariables)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("curl.Probe.GetExpandedUserData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tt.wantRegex) > 0 && !regexp.MustCompile(tt.wantRegex).MatchString(got) {
+				t.Errorf("curl.Probe.GetExpandedUserData() output does not match regex `%s`, content=%v", tt.wantRegex, got)
+			}
+		})
+	}
+
+	// The built-in templates must be unaffected
+	got, err := Probe{}.GetExpandedUserData(map[string]string{"TIMEOUT": "1", "DELAY": "2", "URLS": "http://example.com:80"})
+	if err != nil || strings.Contains(got, "search corp.example.com") {
+		t.Errorf("built-in template affected by custom template (err: %v), content=%v", err, got)
+	}
+}
+
+func TestCurlJSONProbe_WithUserDataTemplate(t *testing.T) {
+	if _, err := (Probe{}).WithUserDataTemplate("#cloud-config\nruncmd:\n  - echo ${USERDATA_BEGIN}\n"); err == nil {
+		t.Error("expected an error for a template without the ending token, got nil")
+	}
+
+	template := "#cloud-config\nruncmd:\n  - echo $USERDATA_BEGIN; ${CURL_COMMAND}; echo $USERDATA_END\n"
+	got, err := Probe{Samples: 3}.WithUserDataTemplate(template)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotProbe, ok := got.(Probe); !ok || gotProbe.UserDataTemplate != template || gotProbe.Samples != 3 {
+		t.Errorf("curl.Probe.WithUserDataTemplate() = %+v, want a copy of the probe using the template", got)
+	}
+}
+
 // TestCurlJSONProbe_UserDataTemplateContainsDeclaredVariables ensures
 // that this probe's userdata-template.yaml contains all of the variables
 // required by the template itself (using #network-verifier-required-variables)
@@ -376,64 +603,272 @@ func TestCurlJSONProbe_GetMachineImageID(t *testing.T) {
 	}
 }
 
-// Test_normalizeSaneNonzeroDuration tests this probe's duration string normalization
-// and sanity checking function. This test assumes fmtStr = "%.2f"
-func Test_normalizeSaneNonzeroDuration(t *testing.T) {
+// TestCurlJSONProbe_ParseProbeOutput_EgressIP tests the reporting of public egress IPs
+// discovered using an IP echo URL, which are interleaved with curl's JSON output
+func TestCurlJSONProbe_ParseProbeOutput_EgressIP(t *testing.T) {
 	tests := []struct {
-		name                string
-		possibleDurationStr string
-		want                string
-		wantErr             bool
+		name         string
+		probeOutput  string
+		wantInfo     []string
+		wantWarnings int
 	}{
 		{
-			name:                "integer with unit",
-			possibleDurationStr: "3s",
-			want:                "3.00",
-			wantErr:             false,
+			name:        "stable egress IP",
+			probeOutput: "@NVIP@203.0.113.7\n@NVIP@{\"origin\": \"203.0.113.7\"}",
+			wantInfo:    []string{"public egress IP: 203.0.113.7 (observed in 2 of 2 checks)"},
 		},
 		{
-			name:                "float with unit",
-			possibleDurationStr: "1.2s",
-			want:                "1.20",
-			wantErr:             false,
+			name:         "unstable egress IP",
+			probeOutput:  "@NVIP@203.0.113.7\n@NVIP@203.0.113.8\n@NVIP@203.0.113.7",
+			wantInfo:     []string{"public egress IP: 203.0.113.7 (observed in 2 of 3 checks)", "public egress IP: 203.0.113.8 (observed in 1 of 3 checks)"},
+			wantWarnings: 1,
 		},
 		{
-			name:                "bare integer",
-			possibleDurationStr: "7",
-			want:                "7.00",
-			wantErr:             false,
+			name:         "no IP discovered",
+			probeOutput:  "@NVIP@\n@NVIP@<html>Forbidden</html>",
+			wantWarnings: 1,
 		},
 		{
-			name:                "bare float",
-			possibleDurationStr: "6.5",
-			want:                "6.50",
-			wantErr:             false,
+			name:        "egress IP check disabled",
+			probeOutput: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &output.Output{}
+			Probe{}.ParseProbeOutput(false, tt.probeOutput, out)
+
+			if !reflect.DeepEqual(out.GetInfo(), tt.wantInfo) {
+				t.Errorf("curl.Probe.ParseProbeOutput() info = %v, want %v", out.GetInfo(), tt.wantInfo)
+			}
+			if len(out.GetWarnings()) != tt.wantWarnings {
+				t.Errorf("curl.Probe.ParseProbeOutput() warnings = %v, want %d", out.GetWarnings(), tt.wantWarnings)
+			}
+		})
+	}
+}
+
+// TestCurlJSONProbe_ParseProbeOutput_Transfer tests the reporting of large-payload
+// transfer checks, which are interleaved with curl's regular JSON output
+func TestCurlJSONProbe_ParseProbeOutput_Transfer(t *testing.T) {
+	tests := []struct {
+		name             string
+		probeOutput      string
+		wantTransferFail []string
+		wantEgressFail   int
+		wantInfo         int
+	}{
+		{
+			name:        "completed transfer",
+			probeOutput: `@NVXFER@{"url":"https://example.org/big.iso","exitcode":0,"errormsg":null,"http_code":206,"size_download":1048576,"time_connect":0.01,"time_total":1.5}`,
+			wantInfo:    1,
 		},
 		{
-			name:                "too large",
-			possibleDurationStr: "4h",
-			wantErr:             true,
+			name:        "completed transfer ignoring range",
+			probeOutput: `@NVXFER@{"url":"https://example.org/small.iso","exitcode":0,"errormsg":null,"http_code":200,"size_download":2048,"time_connect":0.01,"time_total":0.5}`,
+			wantInfo:    1,
 		},
 		{
-			name:                "negative integer",
-			possibleDurationStr: "-3",
-			wantErr:             true,
+			name:             "error response",
+			probeOutput:      `@NVXFER@{"url":"https://example.org/big.iso","exitcode":0,"errormsg":null,"http_code":403,"size_download":243,"time_connect":0.01,"time_total":0.2}`,
+			wantTransferFail: []string{"transfer error: https://example.org/big.iso (server responded with HTTP 403)"},
 		},
 		{
-			name:                "negative with unit",
-			possibleDurationStr: "-1m",
-			wantErr:             true,
+			name:             "stalled transfer",
+			probeOutput:      `@NVXFER@{"url":"https://example.org/big.iso","exitcode":28,"errormsg":"Operation too slow","http_code":206,"size_download":2896,"time_connect":0.01,"time_total":10.0}`,
+			wantTransferFail: []string{"transfer error: https://example.org/big.iso (transfer stalled after 2896 bytes; possible path MTU blackhole or fragmentation problem)"},
+		},
+		{
+			name:             "timed out ignoring range",
+			probeOutput:      `@NVXFER@{"url":"https://example.org/big.iso","exitcode":28,"errormsg":"Operation timed out","http_code":200,"size_download":52428800,"time_connect":0.01,"time_total":60.0}`,
+			wantTransferFail: []string{"transfer error: https://example.org/big.iso (transfer timed out after 52428800 bytes, but the server ignored the range request (HTTP 200); use a URL that supports range requests)"},
+		},
+		{
+			name:             "oversized response ignoring range",
+			probeOutput:      `@NVXFER@{"url":"https://example.org/big.iso","exitcode":63,"errormsg":"Maximum file size exceeded","http_code":200,"size_download":0,"time_connect":0.01,"time_total":0.2}`,
+			wantTransferFail: []string{"transfer error: https://example.org/big.iso (server ignored the range request (HTTP 200) and announced a larger response than requested; use a URL that supports range requests)"},
+		},
+		{
+			name:             "connected without data",
+			probeOutput:      `@NVXFER@{"url":"https://example.org/big.iso","exitcode":28,"errormsg":"Operation timed out","size_download":0,"time_connect":0.01,"time_total":60.0}`,
+			wantTransferFail: []string{"transfer error: https://example.org/big.iso (connected but received no data before timing out; possible path MTU blackhole or fragmentation problem)"},
+		},
+		{
+			name:             "unreachable",
+			probeOutput:      `@NVXFER@{"url":"https://example.org/big.iso","exitcode":7,"errormsg":"Failed to connect","size_download":0,"time_connect":0,"time_total":1.0}`,
+			wantTransferFail: []string{"transfer error: https://example.org/big.iso (Failed to connect)"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := normalizeSaneNonzeroDuration(tt.possibleDurationStr, "%.2f")
+			out := &output.Output{}
+			Probe{}.ParseProbeOutput(false, tt.probeOutput, out)
+
+			gotTransferFail := out.GetTransferFailures()
+			if len(gotTransferFail) != len(tt.wantTransferFail) {
+				t.Fatalf("curl.Probe.ParseProbeOutput() transfer failures = %v, want %v", gotTransferFail, tt.wantTransferFail)
+			}
+			for i, failure := range gotTransferFail {
+				if failure.Error() != tt.wantTransferFail[i] {
+					t.Errorf("curl.Probe.ParseProbeOutput() transfer failure[%d] = %s, want %s", i, failure.Error(), tt.wantTransferFail[i])
+				}
+			}
+			if got := len(out.GetEgressURLFailures()); got != tt.wantEgressFail {
+				t.Errorf("curl.Probe.ParseProbeOutput() egress failures = %d, want %d", got, tt.wantEgressFail)
+			}
+			if got := len(out.GetInfo()); got != tt.wantInfo {
+				t.Errorf("curl.Probe.ParseProbeOutput() info = %d, want %d", got, tt.wantInfo)
+			}
+		})
+	}
+}
+
+// TestCurlJSONProbe_ParseProbeOutput_IPFamily tests that results of checks forced to use a
+// particular IP family are reported per family, and that IPv6 unique local addresses are
+// considered private
+func TestCurlJSONProbe_ParseProbeOutput_IPFamily(t *testing.T) {
+	tests := []struct {
+		name           string
+		ensurePrivate  bool
+		probeOutput    string
+		wantEgressFail []string
+		wantInfo       []string
+	}{
+		{
+			name: "IPv6 egress blocked",
+			probeOutput: `@NV@ipv4@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"203.0.113.10","time_total":0.25}
+@NV@ipv6@{"url":"https://quay.io:443","exitcode":7,"errormsg":"Failed to connect","scheme":"","remote_ip":"","time_total":1}`,
+			wantEgressFail: []string{"egressURL error: https://quay.io:443 [ipv6] (Failed to connect)"},
+			wantInfo:       []string{"ipv4 egress: 1 of 1 endpoints reachable", "ipv6 egress: 0 of 1 endpoints reachable"},
+		},
+		{
+			name:          "IPv6 unique local address is private",
+			ensurePrivate: true,
+			probeOutput: `@NV@ipv6@{"url":"https://sts.us-east-1.amazonaws.com:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"fd12:3456:789a::1","time_total":0.25}
+@NV@ipv6@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"2600:1f18::1","time_total":0.25}`,
+			wantEgressFail: []string{"egressURL error: https://quay.io:443 [ipv6] (The endpoint is non private)"},
+			wantInfo:       []string{"ipv6 egress: 2 of 2 endpoints reachable"},
+		},
+		{
+			name:          "unlabeled results",
+			ensurePrivate: true,
+			probeOutput:   `@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"::ffff:10.0.0.5","time_total":0.25}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &output.Output{}
+			Probe{}.ParseProbeOutput(tt.ensurePrivate, tt.probeOutput, out)
+
+			var gotEgressFail []string
+			for _, failure := range out.GetEgressURLFailures() {
+				gotEgressFail = append(gotEgressFail, failure.Error())
+			}
+			if !reflect.DeepEqual(gotEgressFail, tt.wantEgressFail) {
+				t.Errorf("curl.Probe.ParseProbeOutput() egress failures = %v, want %v", gotEgressFail, tt.wantEgressFail)
+			}
+			if !reflect.DeepEqual(out.GetInfo(), tt.wantInfo) {
+				t.Errorf("curl.Probe.ParseProbeOutput() info = %v, want %v", out.GetInfo(), tt.wantInfo)
+			}
+		})
+	}
+}
+
+func Test_isPrivateAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.5", true},
+		{"52.1.2.3", false},
+		{"::ffff:192.168.1.1", true},
+		{"fd00:ec2::23", true},
+		{"fd00::1%eth0", true},
+		{"2600:1f18::1", false},
+		{"fe80::1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isPrivateAddress(tt.addr); got != tt.want {
+			t.Errorf("isPrivateAddress(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func Test_parseByteSize(t *testing.T) {
+	tests := []struct {
+		sizeStr string
+		want    int64
+		wantErr bool
+	}{
+		{sizeStr: "1048576", want: 1048576},
+		{sizeStr: "512K", want: 524288},
+		{sizeStr: "1m", want: 1048576},
+		{sizeStr: "2MB", want: 2097152},
+		{sizeStr: "1G", want: 1073741824},
+		{sizeStr: "lots", wantErr: true},
+		{sizeStr: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sizeStr, func(t *testing.T) {
+			got, err := parseByteSize(tt.sizeStr)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("normalizeSaneNonzeroDuration() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("parseByteSize() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if got != tt.want {
-				t.Errorf("normalizeSaneNonzeroDuration() = %v, want %v", got, tt.want)
+				t.Errorf("parseByteSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurlJSONProbe_ParseProgressLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantEvent probes.ProgressEvent
+		wantOK    bool
+	}{
+		{
+			name:      "successful endpoint",
+			line:      `@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","time_total":0.25}`,
+			wantEvent: probes.ProgressEvent{Target: "https://quay.io:443", Success: true, Detail: "0.25s"},
+			wantOK:    true,
+		},
+		{
+			name:      "blocked telnet endpoint",
+			line:      `@NV@{"url":"telnet://example.com:9997","exitcode":28,"errormsg":"Connection timed out","scheme":"","time_total":3}`,
+			wantEvent: probes.ProgressEvent{Target: "tcp://example.com:9997", Success: false, Detail: "Connection timed out"},
+			wantOK:    true,
+		},
+		{
+			name:      "endpoint checked over IPv6",
+			line:      `@NV@ipv6@{"url":"https://quay.io:443","exitcode":7,"errormsg":"Failed to connect","scheme":"","time_total":1}`,
+			wantEvent: probes.ProgressEvent{Target: "https://quay.io:443 [ipv6]", Success: false, Detail: "Failed to connect"},
+			wantOK:    true,
+		},
+		{
+			name:      "egress IP line ignored",
+			line:      `@NVIP@52.1.2.3`,
+			wantEvent: probes.ProgressEvent{},
+			wantOK:    false,
+		},
+		{
+			name:      "malformed line ignored",
+			line:      `@NV@{"url":`,
+			wantEvent: probes.ProgressEvent{},
+			wantOK:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEvent, gotOK := Probe{}.ParseProgressLine(tt.line)
+			if gotOK != tt.wantOK {
+				t.Fatalf("curl.Probe.ParseProgressLine() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+			if gotEvent != tt.wantEvent {
+				t.Errorf("curl.Probe.ParseProgressLine() = %+v, want %+v", gotEvent, tt.wantEvent)
 			}
 		})
 	}
@@ -16,8 +16,10 @@ array=(1 2 3 4 27 41 42 43 45)
 if echo ${USERDATA_BEGIN} > /dev/ttyS0 ; then : ; else
     exit 255
 fi
-${CURL_COMMAND} 2>/dev/ttyS0
-ret=$?
+# stream output to the console as it's printed; curl's exit code is saved to a file, as commands
+# in a pipeline run in subshells
+( ${CURL_COMMAND} 2>&1 >/dev/null; echo $? >/tmp/nv-curl-exitcode; ${EGRESS_IP_COMMAND} 2>&1; ${TRANSFER_COMMAND} 2>&1 >/dev/null ) | ${SEQUENCE_COMMAND} >/dev/ttyS0
+ret=$(cat /tmp/nv-curl-exitcode)
 value="\<${ret}\>"
 if [[ " ${array[@]} " =~ $value ]]; then
     exit 255
@@ -0,0 +1,259 @@
+package dns
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+)
+
+// dns.Probe is an implementation of the probes.Probe interface that checks whether the resolver
+// handed out to the target subnet can actually resolve every host on the egress list. It launches
+// the same unmodified RHEL9 image as curl.Probe and uses userdata to install a small Python script
+// (standard library only) that sends A and AAAA queries for each host directly to the first
+// nameserver in the instance's /etc/resolv.conf. The script returns one JSON result per query via
+// serial console, which this probe then parses into a standard output format containing the
+// answers, the resolver used, the response time, any DNS error code (e.g., NXDOMAIN or SERVFAIL),
+// and whether each answer is a private or public address. Queries are sent concurrently within a
+// total time budget (see resolveBudget), so an unresponsive resolver can't stall the probe past the
+// verifier's deadline. This probe relies on cloud-init and therefore only supports AWS.
+type Probe struct{}
+
+//go:embed userdata-template.yaml
+var userDataTemplate string
+
+//go:embed resolve.py
+var resolveScript string
+
+const startingToken = "NV_DNSJSON_BEGIN" //nolint:gosec
+const endingToken = "NV_DNSJSON_END"     //nolint:gosec
+
+// outputLinePrefix is printed by resolve.py before each JSON-formatted query result
+const outputLinePrefix = "@NV@"
+
+// resolveRetries, resolveWorkers, and queriesPerHost mirror RETRIES, WORKERS, and the length of
+// QTYPES in resolve.py, respectively
+const (
+	resolveRetries = 3
+	resolveWorkers = 8
+	queriesPerHost = 2
+)
+
+// maxResolveBudget caps the total time resolve.py may spend sending queries, so that the probe
+// finishes within the verifier's deadline even when the resolver never answers
+const maxResolveBudget = 3 * time.Minute
+
+var presetUserDataVariables = map[string]string{
+	"USERDATA_BEGIN":   startingToken,
+	"USERDATA_END":     endingToken,
+	"SEQUENCE_COMMAND": helpers.SequenceLinesCommand(""),
+}
+
+// GetStartingToken returns the string token used to signal the beginning of the probe's output
+func (dnp Probe) GetStartingToken() string { return startingToken }
+
+// GetEndingToken returns the string token used to signal the end of the probe's output
+func (dnp Probe) GetEndingToken() string { return endingToken }
+
+// GetMachineImageID returns the string ID of the VM image to be used for the probe instance. This
+// probe only needs python3 (already present on any image with cloud-init), so it shares its
+// images with curl.Probe
+func (dnp Probe) GetMachineImageID(platformType cloud.Platform, cpuArch cpu.Architecture, region string) (string, error) {
+	return curl.Probe{}.GetMachineImageID(platformType, cpuArch, region)
+}
+
+// GetExpandedUserData returns a YAML-formatted userdata string filled-in ("expanded") with
+// the values provided in userDataVariables according to os.Expand(). The hosts to be resolved
+// are extracted from the URLS and TLSDISABLED_URLS variables, so callers can pass the same
+// variables they would pass to curl.Probe. Errors will be returned if values aren't provided for
+// required variables listed in the template's "network-verifier-required-variables" directive, or
+// if values *are* provided for variables that must be set to a certain value for the probe to
+// function correctly (presetUserDataVariables) -- this function will fill-in those values for you.
+func (dnp Probe) GetExpandedUserData(userDataVariables map[string]string) (string, error) {
+	// Platforms without cloud-init (e.g., GCP) ask for a systemd-based script instead
+	if userDataVariables["USE_SYSTEMD"] == "true" {
+		return "", errors.New("the dns probe requires cloud-init and does not support systemd-based userdata")
+	}
+
+	// Extract required variables specified in template (if any)
+	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(userDataTemplate)
+
+	var err error
+	// TIMEOUT applies to each individual DNS query; resolve.py accepts fractional seconds
+	userDataVariables["TIMEOUT"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["TIMEOUT"], "%.2f")
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable TIMEOUT: %w", err)
+	}
+	// cloud-init only accepts a positive integer number of seconds for DELAY
+	userDataVariables["DELAY"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["DELAY"], "%.f")
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable DELAY: %w", err)
+	}
+
+	hosts, err := hostsFromURLs(userDataVariables["URLS"] + " " + userDataVariables["TLSDISABLED_URLS"])
+	if err != nil {
+		return "", err
+	}
+	userDataVariables["HOSTS"] = strings.Join(hosts, " ")
+	// BUDGET caps the total time spent resolving every host (see resolveBudget)
+	timeoutSeconds, err := strconv.ParseFloat(userDataVariables["TIMEOUT"], 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable TIMEOUT: %w", err)
+	}
+	budget := resolveBudget(len(hosts), time.Duration(timeoutSeconds*float64(time.Second)))
+	userDataVariables["BUDGET"] = fmt.Sprintf("%.f", budget.Seconds())
+	userDataVariables["RESOLVE_SCRIPT"] = base64.StdEncoding.EncodeToString([]byte(resolveScript))
+
+	// Ensure userDataVariables complies with requiredVariables and presetUserDataVariables. See
+	// docstring for helpers.ValidateProvidedVariables() for more details
+	err = helpers.ValidateProvidedVariables(userDataVariables, presetUserDataVariables, requiredVariables)
+	if err != nil {
+		return "", err
+	}
+
+	// Label the probe's output with this run's nonce (if any) so that stale output left on the
+	// console by a previous run is ignored
+	noncedPresetUserDataVariables := helpers.NoncedPresetUserDataVariables(presetUserDataVariables, userDataVariables["NONCE"])
+
+	// Expand template
+	return os.Expand(directivelessUserDataTemplate, func(userDataVar string) string {
+		if presetVal, isPreset := noncedPresetUserDataVariables[userDataVar]; isPreset {
+			return presetVal
+		}
+		return userDataVariables[userDataVar]
+	}), nil
+}
+
+// MaxRuntime implements probes.RuntimeEstimator, returning the longest resolve.py may spend
+// resolving the hosts of endpointCount egress endpoints (see resolveBudget)
+func (dnp Probe) MaxRuntime(endpointCount int, timeout time.Duration) time.Duration {
+	return resolveBudget(endpointCount, timeout)
+}
+
+// resolveBudget returns the total time resolve.py is allowed to spend resolving hostCount hosts,
+// given the timeout applied to each query: enough for every query to exhaust its retries when the
+// resolver never answers, but no more than maxResolveBudget. Queries are sent resolveWorkers at a
+// time, so the budget grows with the number of rounds of queries rather than with the number of
+// hosts. Queries not yet sent when the budget runs out are reported as failures
+func resolveBudget(hostCount int, timeout time.Duration) time.Duration {
+	rounds := (hostCount*queriesPerHost + resolveWorkers - 1) / resolveWorkers
+	return min(maxResolveBudget, time.Duration(rounds*resolveRetries)*timeout)
+}
+
+// ParseProgressLine implements probes.ProgressReporter, describing a single DNS query result as
+// soon as it's printed
+func (dnp Probe) ParseProgressLine(line string) (probes.ProgressEvent, bool) {
+	probeResult, err := deserializeDNSProbeResult(helpers.RemoveTimestamps(line))
+	if err != nil {
+		return probes.ProgressEvent{}, false
+	}
+	return probes.ProgressEvent{
+		Target:  fmt.Sprintf("%s (%s)", probeResult.Host, probeResult.Type),
+		Success: probeResult.IsSuccessful(),
+		Detail:  probeResult.String(),
+	}, true
+}
+
+// ParseProbeOutput accepts a string containing all probe output that appeared between
+// the startingToken and the endingToken and a pointer to an Output object. outputDestination
+// will be filled with the results of the DNS queries: every answer is recorded as an
+// informational result, while hosts that failed to resolve are recorded as egress failures.
+// When ensurePrivate is set to true, hosts resolving to any non-private address are also
+// recorded as egress failures
+func (dnp Probe) ParseProbeOutput(ensurePrivate bool, probeOutput string, outputDestination *output.Output) {
+	// AWS inserts timestamps into long lines of console output; these must be removed first
+	probeResults, errMap := bulkDeserializeDNSProbeResult(helpers.RemoveTimestamps(probeOutput))
+
+	// Group results by host (preserving the order in which hosts were queried) so that each
+	// host is reported as failed at most once, even if both its A and AAAA queries failed
+	var hosts []string
+	resultsByHost := make(map[string][]*DNSProbeResult)
+	for _, probeResult := range probeResults {
+		outputDestination.AddDebugLogs(fmt.Sprintf("%+v\n", probeResult))
+		outputDestination.AddInfo(probeResult.String())
+		if _, seen := resultsByHost[probeResult.Host]; !seen {
+			hosts = append(hosts, probeResult.Host)
+		}
+		resultsByHost[probeResult.Host] = append(resultsByHost[probeResult.Host], probeResult)
+	}
+
+	for _, host := range hosts {
+		if failure := resolutionFailure(resultsByHost[host], ensurePrivate); failure != "" {
+			outputDestination.SetEgressFailures([]string{fmt.Sprintf("%s (%s)", host, failure)})
+		}
+	}
+
+	for lineNum, err := range errMap {
+		outputDestination.AddError(
+			handledErrors.NewGenericError(
+				fmt.Errorf("error processing line %d: %w", lineNum, err),
+			),
+		)
+	}
+}
+
+// resolutionFailure returns a human-readable reason why the given results (all for the same
+// host) indicate a DNS failure, or an empty string if the host resolved as expected. A host
+// fails if any of its queries failed outright, if none of its queries returned an address, or
+// (when ensurePrivate is true) if any returned address is public
+func resolutionFailure(hostResults []*DNSProbeResult, ensurePrivate bool) string {
+	answered := false
+	for _, result := range hostResults {
+		if !result.IsSuccessful() {
+			return fmt.Sprintf("DNS %s lookup via %s failed: %s", result.Type, result.Resolver, result.FailureReason())
+		}
+		answered = answered || len(result.Answers) > 0
+	}
+	if !answered {
+		return "DNS lookup returned no A or AAAA records"
+	}
+
+	if ensurePrivate {
+		for _, result := range hostResults {
+			if _, public := result.ClassifyAnswers(); len(public) > 0 {
+				return fmt.Sprintf("resolves to non-private address %s", strings.Join(public, ", "))
+			}
+		}
+	}
+
+	return ""
+}
+
+// hostsFromURLs returns the deduplicated hostnames of the space-separated URLs in urlsStr
+// (e.g., "https://quay.io:443 telnet://example.com:9997") in order of first appearance
+func hostsFromURLs(urlsStr string) ([]string, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, urlStr := range strings.Fields(urlsStr) {
+		parsedURL, err := url.Parse(urlStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to extract host from URL '%s': %w", urlStr, err)
+		}
+		host := parsedURL.Hostname()
+		if host == "" {
+			return nil, fmt.Errorf("unable to extract host from URL '%s'", urlStr)
+		}
+		// There's nothing to resolve for literal IP addresses
+		if net.ParseIP(host) != nil || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,109 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// A DNSProbeResult represents the outcome of a single DNS query (A or AAAA) that the
+// dns probe sent to the target subnet's resolver. This struct mirrors the JSON
+// printed by resolve.py
+type DNSProbeResult struct {
+	Host     string   `json:"host"`
+	Type     string   `json:"type"`
+	Resolver string   `json:"resolver"`
+	RCode    string   `json:"rcode"`
+	Answers  []string `json:"answers"`
+	TimeMs   float64  `json:"time_ms"`
+	Error    string   `json:"error"`
+}
+
+// IsSuccessful returns true if the resolver answered the query without error. Note that a
+// successful query may still return zero answers (e.g., an AAAA query for an IPv4-only host)
+func (res DNSProbeResult) IsSuccessful() bool {
+	return res.Error == "" && res.RCode == "NOERROR"
+}
+
+// FailureReason returns a human-readable explanation of why the query failed, preferring
+// transport-level errors (e.g., timeouts) over DNS response codes (e.g., NXDOMAIN)
+func (res DNSProbeResult) FailureReason() string {
+	if res.Error != "" {
+		return res.Error
+	}
+	return res.RCode
+}
+
+// ClassifyAnswers splits the query's answers into private (RFC 1918/RFC 4193) and public addresses
+func (res DNSProbeResult) ClassifyAnswers() (private []string, public []string) {
+	for _, answer := range res.Answers {
+		if net.ParseIP(answer).IsPrivate() {
+			private = append(private, answer)
+		} else {
+			public = append(public, answer)
+		}
+	}
+	return private, public
+}
+
+// String returns a one-line summary of the query result suitable for display to users, e.g.
+// "quay.io A via 10.0.0.2: NOERROR in 1.23ms -> 52.1.2.3 (public), 10.0.0.5 (private)"
+func (res DNSProbeResult) String() string {
+	summary := fmt.Sprintf("%s %s via %s: ", res.Host, res.Type, res.Resolver)
+	if !res.IsSuccessful() {
+		return summary + res.FailureReason()
+	}
+
+	summary += fmt.Sprintf("%s in %.2fms", res.RCode, res.TimeMs)
+	if len(res.Answers) == 0 {
+		return summary + " -> no records"
+	}
+	labeledAnswers := make([]string, 0, len(res.Answers))
+	for _, answer := range res.Answers {
+		label := "public"
+		if net.ParseIP(answer).IsPrivate() {
+			label = "private"
+		}
+		labeledAnswers = append(labeledAnswers, fmt.Sprintf("%s (%s)", answer, label))
+	}
+	return summary + " -> " + strings.Join(labeledAnswers, ", ")
+}
+
+// bulkDeserializeDNSProbeResult wraps deserializeDNSProbeResult, creating a DNSProbeResult
+// from each non-blank line (containing prefixed JSON) of the provided string. A slice of
+// successfully-deserialized DNSProbeResult-pointers is returned along with a mapping
+// between any malformed lines and their line numbers
+func bulkDeserializeDNSProbeResult(serializedLines string) ([]*DNSProbeResult, map[int]error) {
+	var results []*DNSProbeResult
+	deserializationErrs := make(map[int]error)
+	for lineNum, serializedLine := range strings.Split(serializedLines, "\n") {
+		if strings.TrimSpace(serializedLine) == "" {
+			continue
+		}
+		probeResultPtr, err := deserializeDNSProbeResult(serializedLine)
+		if err != nil {
+			deserializationErrs[lineNum] = err
+		}
+		if probeResultPtr != nil {
+			results = append(results, probeResultPtr)
+		}
+	}
+	return results, deserializationErrs
+}
+
+// deserializeDNSProbeResult creates a DNSProbeResult from a single line of probe console
+// output, which should start with outputLinePrefix followed by a serialized JSON string.
+// If the prefix is missing or JSON deserialization (unmarshalling) fails, (nil, error)
+// is returned
+func deserializeDNSProbeResult(prefixedJSON string) (*DNSProbeResult, error) {
+	jsonStr, prefixFound := strings.CutPrefix(strings.TrimSpace(prefixedJSON), outputLinePrefix)
+	if !prefixFound {
+		return nil, fmt.Errorf("missing prefix '%s': %s", outputLinePrefix, prefixedJSON)
+	}
+	var result DNSProbeResult
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
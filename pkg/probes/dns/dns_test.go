@@ -0,0 +1,274 @@
+package dns
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"gopkg.in/yaml.v3"
+)
+
+// TestDNSProbe_ImplementsProbeInterface simply forces the compiler to confirm
+// that the Probe type properly implements the Probe interface
+func TestDNSProbe_ImplementsProbeInterface(t *testing.T) {
+	var _ probes.Probe = (*Probe)(nil)
+	var _ probes.ProgressReporter = (*Probe)(nil)
+	var _ probes.RuntimeEstimator = (*Probe)(nil)
+}
+
+// TestDNSProbe_GetExpandedUserData tests the correctness of the userdata
+// produced by the probe using regexes and basic YAML syntax validation
+func TestDNSProbe_GetExpandedUserData(t *testing.T) {
+	tests := []struct {
+		name              string
+		userDataVariables map[string]string
+		wantRegex         string
+		wantErr           bool
+	}{
+		{
+			name: "happy path",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "http://example.com:80 https://example.org:443",
+			},
+			wantRegex: `#cloud-config[\s\S]*nv-resolve.py 1.00 3 example.com example.org 2>&1 \| awk `,
+		},
+		{
+			name: "hosts deduplicated across URLS and TLSDISABLED_URLS, IPs skipped",
+			userDataVariables: map[string]string{
+				"TIMEOUT":          "1",
+				"DELAY":            "2",
+				"URLS":             "https://example.com:443 telnet://example.com:9997 https://10.0.0.1:443",
+				"TLSDISABLED_URLS": "https://example.org:443",
+			},
+			wantRegex: `#cloud-config[\s\S]*nv-resolve.py 1.00 3 example.com example.org 2>&1 \| awk `,
+		},
+		{
+			name: "no hosts",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "https://10.0.0.1:443",
+			},
+			wantErr: true,
+		},
+		{
+			name: "systemd requested",
+			userDataVariables: map[string]string{
+				"TIMEOUT":     "1",
+				"DELAY":       "2",
+				"URLS":        "http://example.com:80",
+				"USE_SYSTEMD": "true",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid TIMEOUT",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "-1",
+				"DELAY":   "2",
+				"URLS":    "http://example.com:80",
+			},
+			wantErr: true,
+		},
+		{
+			name: "preset variable provided",
+			userDataVariables: map[string]string{
+				"TIMEOUT":        "1",
+				"DELAY":          "2",
+				"URLS":           "http://example.com:80",
+				"USERDATA_BEGIN": "foo",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Probe{}.GetExpandedUserData(tt.userDataVariables)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("dns.Probe.GetExpandedUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(tt.wantRegex) > 0 && !regexp.MustCompile(tt.wantRegex).MatchString(got) {
+				t.Errorf("dns.Probe.GetExpandedUserData() output does not match regex `%s`, content=%v", tt.wantRegex, got)
+			}
+
+			var unmarshalled any
+			if err := yaml.Unmarshal([]byte(got), &unmarshalled); err != nil {
+				t.Errorf("dns.Probe.GetExpandedUserData() produced invalid YAML (err: %v), content=%v", err, got)
+			}
+		})
+	}
+}
+
+// TestDNSProbe_UserDataTemplateContainsDeclaredVariables ensures that this probe's
+// userdata-template.yaml contains all of the variables it declares as required or preset
+func TestDNSProbe_UserDataTemplateContainsDeclaredVariables(t *testing.T) {
+	for presetVariableName := range presetUserDataVariables {
+		if !strings.Contains(userDataTemplate, "${"+presetVariableName+"}") {
+			t.Errorf("dns.Probe.presetUserDataVariables has key %[1]s, but could not find required '${%[1]s}' in probe's userdata-template.yaml", presetVariableName)
+		}
+	}
+
+	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(userDataTemplate)
+	for _, requiredVariableName := range requiredVariables {
+		if !strings.Contains(directivelessUserDataTemplate, "${"+requiredVariableName+"}") {
+			t.Errorf("dns.Probe's userdata-template.yaml declares %[1]s as required, but could not find '${%[1]s}' in file", requiredVariableName)
+		}
+	}
+}
+
+func TestDNSProbe_MaxRuntime(t *testing.T) {
+	tests := []struct {
+		name          string
+		endpointCount int
+		timeout       time.Duration
+		want          time.Duration
+	}{
+		{
+			name:          "single round of queries",
+			endpointCount: 4,
+			timeout:       5 * time.Second,
+			want:          15 * time.Second,
+		},
+		{
+			name:          "rounds grow with endpoints",
+			endpointCount: 38,
+			timeout:       5 * time.Second,
+			want:          150 * time.Second,
+		},
+		{
+			name:          "capped",
+			endpointCount: 100,
+			timeout:       5 * time.Second,
+			want:          maxResolveBudget,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Probe{}).MaxRuntime(tt.endpointCount, tt.timeout); got != tt.want {
+				t.Errorf("dns.Probe.MaxRuntime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSProbe_ParseProbeOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		ensurePrivate bool
+		probeOutput   string
+		wantFailures  []string
+		wantInfoCount int
+		wantErrors    bool
+	}{
+		{
+			name: "all hosts resolve",
+			probeOutput: `@NV@{"host":"quay.io","type":"A","resolver":"10.0.0.2","rcode":"NOERROR","answers":["52.1.2.3"],"time_ms":1.5,"error":""}
+@NV@{"host":"quay.io","type":"AAAA","resolver":"10.0.0.2","rcode":"NOERROR","answers":[],"time_ms":1.1,"error":""}`,
+			wantInfoCount: 2,
+		},
+		{
+			name: "NXDOMAIN reported once per host",
+			probeOutput: `@NV@{"host":"nope.example","type":"A","resolver":"10.0.0.2","rcode":"NXDOMAIN","answers":[],"time_ms":1.5,"error":""}
+@NV@{"host":"nope.example","type":"AAAA","resolver":"10.0.0.2","rcode":"NXDOMAIN","answers":[],"time_ms":1.1,"error":""}`,
+			wantFailures:  []string{"nope.example (DNS A lookup via 10.0.0.2 failed: NXDOMAIN)"},
+			wantInfoCount: 2,
+		},
+		{
+			name:          "resolver timeout",
+			probeOutput:   `@NV@{"host":"quay.io","type":"A","resolver":"10.0.0.2","rcode":"","answers":[],"time_ms":3000,"error":"timed out waiting for resolver"}`,
+			wantFailures:  []string{"quay.io (DNS A lookup via 10.0.0.2 failed: timed out waiting for resolver)"},
+			wantInfoCount: 1,
+		},
+		{
+			name:          "no records",
+			probeOutput:   `@NV@{"host":"quay.io","type":"A","resolver":"10.0.0.2","rcode":"NOERROR","answers":[],"time_ms":1,"error":""}`,
+			wantFailures:  []string{"quay.io (DNS lookup returned no A or AAAA records)"},
+			wantInfoCount: 1,
+		},
+		{
+			name:          "public answer with ensurePrivate",
+			ensurePrivate: true,
+			probeOutput:   `@NV@{"host":"quay.io","type":"A","resolver":"10.0.0.2","rcode":"NOERROR","answers":["10.0.0.5","52.1.2.3"],"time_ms":1,"error":""}`,
+			wantFailures:  []string{"quay.io (resolves to non-private address 52.1.2.3)"},
+			wantInfoCount: 1,
+		},
+		{
+			name:          "private answer with ensurePrivate",
+			ensurePrivate: true,
+			probeOutput:   `@NV@{"host":"quay.io","type":"A","resolver":"10.0.0.2","rcode":"NOERROR","answers":["10.0.0.5"],"time_ms":1,"error":""}`,
+			wantInfoCount: 1,
+		},
+		{
+			name:        "malformed line",
+			probeOutput: `Traceback (most recent call last):`,
+			wantErrors:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &output.Output{}
+			Probe{}.ParseProbeOutput(tt.ensurePrivate, tt.probeOutput, out)
+
+			gotFailures := out.GetEgressURLFailures()
+			if len(gotFailures) != len(tt.wantFailures) {
+				t.Fatalf("dns.Probe.ParseProbeOutput() failures = %v, want %v", gotFailures, tt.wantFailures)
+			}
+			for i, failure := range gotFailures {
+				if failure.EgressURL() != tt.wantFailures[i] {
+					t.Errorf("dns.Probe.ParseProbeOutput() failure[%d] = %s, want %s", i, failure.EgressURL(), tt.wantFailures[i])
+				}
+			}
+			if got := len(out.GetInfo()); got != tt.wantInfoCount {
+				t.Errorf("dns.Probe.ParseProbeOutput() info count = %d, want %d", got, tt.wantInfoCount)
+			}
+			_, _, gotErrors := out.Parse()
+			if (len(gotErrors) > 0) != tt.wantErrors {
+				t.Errorf("dns.Probe.ParseProbeOutput() errors = %v, wantErrors %v", gotErrors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestDNSProbe_ParseProgressLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantEvent probes.ProgressEvent
+		wantOK    bool
+	}{
+		{
+			name:      "NXDOMAIN",
+			line:      `@NV@{"host":"nope.example","type":"A","resolver":"10.0.0.2","rcode":"NXDOMAIN","answers":[],"time_ms":1.5,"error":""}`,
+			wantEvent: probes.ProgressEvent{Target: "nope.example (A)", Success: false, Detail: "nope.example A via 10.0.0.2: NXDOMAIN"},
+			wantOK:    true,
+		},
+		{
+			name:      "unrelated line ignored",
+			line:      `cloud-init: running modules`,
+			wantEvent: probes.ProgressEvent{},
+			wantOK:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEvent, gotOK := Probe{}.ParseProgressLine(tt.line)
+			if gotOK != tt.wantOK {
+				t.Fatalf("dns.Probe.ParseProgressLine() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+			if gotEvent != tt.wantEvent {
+				t.Errorf("dns.Probe.ParseProgressLine() = %+v, want %+v", gotEvent, tt.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+#!/usr/bin/python3
+# resolve.py sends A and AAAA queries for each host given on the command line directly to
+# the first nameserver listed in /etc/resolv.conf (i.e., the resolver the subnet hands out
+# via DHCP) and prints one JSON-formatted result per query to stdout. Each result line is
+# prefixed with the separator expected by dns.Probe.ParseProbeOutput(). Queries are sent by a
+# small pool of workers, and no query is (re)tried once BUDGET_SECONDS have elapsed, so that a
+# dead resolver can't keep the probe running past the verifier's deadline. Only the Python
+# standard library is used so that this runs on an unmodified RHEL image.
+# Usage: resolve.py TIMEOUT_SECONDS BUDGET_SECONDS HOST [HOST...]
+import concurrent.futures
+import json
+import random
+import socket
+import struct
+import sys
+import time
+
+SEPARATOR = "@NV@"
+RETRIES = 3
+WORKERS = 8
+QTYPES = (("A", 1, socket.AF_INET), ("AAAA", 28, socket.AF_INET6))
+RCODES = {0: "NOERROR", 1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED"}
+
+
+def get_nameserver():
+    try:
+        with open("/etc/resolv.conf") as resolv_conf:
+            for line in resolv_conf:
+                fields = line.split()
+                if len(fields) >= 2 and fields[0] == "nameserver":
+                    return fields[1]
+    except OSError:
+        pass
+    return "127.0.0.1"
+
+
+def build_query(query_id, host, qtype):
+    header = struct.pack(">HHHHHH", query_id, 0x0100, 1, 0, 0, 0)
+    qname = b"".join(bytes([len(label)]) + label.encode() for label in host.rstrip(".").split("."))
+    return header + qname + b"\x00" + struct.pack(">HH", qtype, 1)
+
+
+def skip_name(message, offset):
+    while True:
+        length = message[offset]
+        if length & 0xC0 == 0xC0:
+            return offset + 2
+        if length == 0:
+            return offset + 1
+        offset += length + 1
+
+
+def parse_response(message, qtype, family):
+    _, flags, qdcount, ancount, _, _ = struct.unpack(">HHHHHH", message[:12])
+    offset = 12
+    for _ in range(qdcount):
+        offset = skip_name(message, offset) + 4
+    answers = []
+    for _ in range(ancount):
+        offset = skip_name(message, offset)
+        rtype, _, _, rdlength = struct.unpack(">HHIH", message[offset:offset + 10])
+        offset += 10
+        if rtype == qtype:
+            answers.append(socket.inet_ntop(family, message[offset:offset + rdlength]))
+        offset += rdlength
+    return RCODES.get(flags & 0xF, str(flags & 0xF)), answers
+
+
+def resolve(nameserver, host, qname, qtype, family, timeout, deadline):
+    result = {"host": host, "type": qname, "resolver": nameserver, "rcode": "", "answers": [], "time_ms": 0.0, "error": ""}
+    server_family = socket.AF_INET6 if ":" in nameserver else socket.AF_INET
+    for _ in range(RETRIES):
+        remaining = deadline - time.monotonic()
+        if remaining <= 0:
+            if not result["error"]:
+                result["error"] = "not attempted: probe time budget exhausted"
+            break
+        query_id = random.randint(0, 65535)
+        start = time.monotonic()
+        try:
+            with socket.socket(server_family, socket.SOCK_DGRAM) as sock:
+                sock.settimeout(min(timeout, remaining))
+                sock.sendto(build_query(query_id, host, qtype), (nameserver, 53))
+                while True:
+                    message, _ = sock.recvfrom(4096)
+                    if len(message) >= 12 and struct.unpack(">H", message[:2])[0] == query_id:
+                        break
+            result["time_ms"] = round((time.monotonic() - start) * 1000, 2)
+            result["rcode"], result["answers"] = parse_response(message, qtype, family)
+            result["error"] = ""
+            return result
+        except socket.timeout:
+            result["time_ms"] = round((time.monotonic() - start) * 1000, 2)
+            result["error"] = "timed out waiting for resolver"
+        except (OSError, ValueError, IndexError, struct.error) as err:
+            result["error"] = str(err)
+    return result
+
+
+def main():
+    timeout = float(sys.argv[1])
+    deadline = time.monotonic() + float(sys.argv[2])
+    nameserver = get_nameserver()
+    queries = [(host, qname, qtype, family) for host in sys.argv[3:] for qname, qtype, family in QTYPES]
+    with concurrent.futures.ThreadPoolExecutor(max_workers=WORKERS) as executor:
+        # map() yields results in query order, each as soon as it (and every earlier query) is done
+        results = executor.map(lambda query: resolve(nameserver, *query, timeout, deadline), queries)
+        for result in results:
+            print(SEPARATOR + json.dumps(result, separators=(",", ":")), flush=True)
+
+
+if __name__ == "__main__":
+    main()
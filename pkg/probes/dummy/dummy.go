@@ -1,7 +1,10 @@
 package dummy
 
 import (
+	"fmt"
+
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
 	"github.com/openshift/osd-network-verifier/pkg/output"
 )
 
@@ -26,16 +29,17 @@ func (prb Probe) GetMachineImageID(string, cpu.Architecture, string) (string, er
 	return "rhel-9-v20240709", nil
 }
 
-// GetExpandedUserData returns a bash-formatted userdata string
-func (prb Probe) GetExpandedUserData(map[string]string) (string, error) {
-	return `#!/bin/sh
+// GetExpandedUserData returns a bash-formatted userdata string. Only the NONCE variable is used
+func (prb Probe) GetExpandedUserData(userDataVariables map[string]string) (string, error) {
+	nonce := userDataVariables["NONCE"]
+	return fmt.Sprintf(`#!/bin/sh
 	systemctl mask --now serial-getty@ttyS0.service
 	systemctl disable --now syslog.socket rsyslog.service
 	sysctl -w kernel.printk="0 4 0 7"
 	sleep 1
-	echo DUMMY_START > /dev/ttyS0
+	echo %s > /dev/ttyS0
 	echo "hello world" > /dev/ttyS0
-	echo DUMMY_END > /dev/ttyS0`, nil
+	echo %s > /dev/ttyS0`, helpers.TokenWithNonce(startingToken, nonce), helpers.TokenWithNonce(endingToken, nonce)), nil
 }
 
 // ParseProbeOutput is not implemented for this dummy probe
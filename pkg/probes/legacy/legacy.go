@@ -92,9 +92,13 @@ func (lgp Probe) GetExpandedUserData(userDataVariables map[string]string) (strin
 		return "", err
 	}
 
+	// Label the probe's output with this run's nonce (if any) so that stale output left on the
+	// console by a previous run is ignored
+	noncedPresetUserDataVariables := helpers.NoncedPresetUserDataVariables(presetUserDataVariables, userDataVariables["NONCE"])
+
 	// Expand template
 	return os.Expand(directivelessUserDataTemplate, func(userDataVar string) string {
-		if presetVal, isPreset := presetUserDataVariables[userDataVar]; isPreset {
+		if presetVal, isPreset := noncedPresetUserDataVariables[userDataVar]; isPreset {
 			return presetVal
 		}
 		return userDataVariables[userDataVar]
@@ -1,6 +1,9 @@
 package probes
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
 	"github.com/openshift/osd-network-verifier/pkg/output"
@@ -13,3 +16,80 @@ type Probe interface {
 	GetExpandedUserData(map[string]string) (string, error)
 	ParseProbeOutput(bool, string, *output.Output)
 }
+
+// A ProgressEvent describes the outcome of a single check performed by a probe (e.g., whether
+// one egress endpoint was reachable), reported while the probe is still running
+type ProgressEvent struct {
+	// Target identifies what was checked, e.g., an egress URL or hostname
+	Target string
+	// Success is true if the check passed
+	Success bool
+	// Detail is a short human-readable description of the outcome
+	Detail string
+}
+
+// A ProgressReporter is a Probe that can interpret individual lines of its output as they arrive,
+// allowing verifiers to report progress before the probe has finished. Implementing this
+// interface is optional
+type ProgressReporter interface {
+	// ParseProgressLine returns a ProgressEvent describing a single line of probe output, or false
+	// if the line doesn't describe the outcome of a check
+	ParseProgressLine(line string) (ProgressEvent, bool)
+}
+
+// ReportProgress passes a ProgressEvent to onProgress for each of lines that describes the outcome
+// of a check, provided that probe implements ProgressReporter and onProgress is non-nil
+func ReportProgress(probe Probe, lines []string, onProgress func(ProgressEvent)) {
+	progressReporter, ok := probe.(ProgressReporter)
+	if !ok || onProgress == nil {
+		return
+	}
+	for _, line := range lines {
+		if event, ok := progressReporter.ParseProgressLine(line); ok {
+			onProgress(event)
+		}
+	}
+}
+
+// A RuntimeEstimator is a Probe that can report the longest it may take to finish its checks,
+// allowing verifiers to derive how long to wait for its output. Implementing this interface is
+// optional
+type RuntimeEstimator interface {
+	// MaxRuntime returns the longest the probe may take to check endpointCount egress endpoints,
+	// given the timeout applied to each individual request, not counting the time it takes the
+	// probe instance to boot
+	MaxRuntime(endpointCount int, timeout time.Duration) time.Duration
+}
+
+// MaxRuntime returns the longest probe may take to check endpointCount egress endpoints (see
+// RuntimeEstimator). Probes that don't implement RuntimeEstimator are assumed to check each
+// endpoint once, taking up to timeout each
+func MaxRuntime(probe Probe, endpointCount int, timeout time.Duration) time.Duration {
+	if runtimeEstimator, ok := probe.(RuntimeEstimator); ok {
+		return runtimeEstimator.MaxRuntime(endpointCount, timeout)
+	}
+	return time.Duration(endpointCount) * timeout
+}
+
+// A UserDataTemplateOverrider is a Probe whose built-in userdata template can be replaced by a
+// caller-supplied one (e.g., to perform extra setup required by hardened images before checking
+// egress). Implementing this interface is optional
+type UserDataTemplateOverrider interface {
+	// WithUserDataTemplate returns a copy of the probe that uses userDataTemplate instead of its
+	// built-in template, or an error if userDataTemplate can't be used by the probe (e.g., because
+	// it doesn't print the probe's starting and ending tokens)
+	WithUserDataTemplate(userDataTemplate string) (Probe, error)
+}
+
+// WithUserDataTemplate returns a copy of probe that uses userDataTemplate instead of its built-in
+// userdata template (see UserDataTemplateOverrider), or probe as-is if userDataTemplate is empty
+func WithUserDataTemplate(probe Probe, userDataTemplate string) (Probe, error) {
+	if userDataTemplate == "" {
+		return probe, nil
+	}
+	overrider, ok := probe.(UserDataTemplateOverrider)
+	if !ok {
+		return nil, fmt.Errorf("probe %T does not support custom userdata templates", probe)
+	}
+	return overrider.WithUserDataTemplate(userDataTemplate)
+}
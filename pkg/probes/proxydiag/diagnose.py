@@ -0,0 +1,240 @@
+#!/usr/bin/python3
+# diagnose.py checks how the HTTP(S) proxy given by the https_proxy (or http_proxy) environment
+# variable behaves, beyond whether egress endpoints are reachable through it: whether it accepts
+# CONNECT to each given host:port, whether it accepts (or requires) the credentials in the proxy
+# URL, whether hosts matching the no_proxy environment variable are reachable without it, whether
+# it answers requests for a nonexistent host with its own 200 page, and whether the certificate
+# chain it presents for HTTPS endpoints matches the provided proxy CA. It prints one JSON-formatted
+# result per check, prefixed with the separator expected by proxydiag.Probe.ParseProbeOutput().
+# Only the Python standard library and the openssl CLI are used so that this runs on an unmodified
+# RHEL image.
+# Usage: diagnose.py TIMEOUT_SECONDS PROXY_CA_FILE CONNECT_TARGETS URL [URL...]
+# where CONNECT_TARGETS is a comma-separated list of host:port pairs
+import base64
+import ipaddress
+import json
+import os
+import re
+import socket
+import ssl
+import subprocess
+import sys
+import urllib.parse
+
+SEPARATOR = "@NV@"
+# RFC 2606 guarantees that names under .invalid never resolve, so a proxy that answers a request
+# for one with 200 must be serving its own page
+NONEXISTENT_HOST = "osd-network-verifier.invalid"
+VERIFY_RE = re.compile(r"Verify return code: (\d+) \((.*)\)")
+ISSUER_RE = re.compile(r"^issuer=(.*)$", re.M)
+
+
+def report(check, target, status, detail):
+    result = {"check": check, "target": target, "status": status, "detail": detail}
+    print(SEPARATOR + json.dumps(result, separators=(",", ":")), flush=True)
+
+
+def proxy_url():
+    proxy = os.environ.get("https_proxy") or os.environ.get("http_proxy") or ""
+    return urllib.parse.urlparse(proxy if "://" in proxy else "http://" + proxy)
+
+
+def open_proxy(proxy, timeout, ca_file):
+    https = proxy.scheme == "https"
+    sock = socket.create_connection((proxy.hostname, proxy.port or (443 if https else 80)), timeout)
+    if https:
+        context = ssl.create_default_context()
+        if ca_file:
+            context.load_verify_locations(ca_file)
+        sock = context.wrap_socket(sock, server_hostname=proxy.hostname)
+    return sock
+
+
+def auth_header(proxy):
+    if not proxy.username:
+        return ""
+    credentials = "%s:%s" % (urllib.parse.unquote(proxy.username), urllib.parse.unquote(proxy.password or ""))
+    return "Proxy-Authorization: Basic %s\r\n" % base64.b64encode(credentials.encode()).decode()
+
+
+def send(proxy, timeout, ca_file, request):
+    """Sends a raw request to the proxy, returning the status code and status line of its response"""
+    sock = open_proxy(proxy, timeout, ca_file)
+    try:
+        sock.sendall(request.encode())
+        response = b""
+        while b"\r\n" not in response and len(response) < 65536:
+            chunk = sock.recv(4096)
+            if not chunk:
+                break
+            response += chunk
+    finally:
+        sock.close()
+    status_line = response.split(b"\r\n", 1)[0].decode("latin-1").strip()
+    parts = status_line.split(" ", 2)
+    code = int(parts[1]) if len(parts) > 1 and parts[1].isdigit() else 0
+    return code, status_line or "empty response"
+
+
+def connect(proxy, timeout, ca_file, target, with_auth=True):
+    request = "CONNECT %s HTTP/1.1\r\nHost: %s\r\n%s\r\n" % (target, target, auth_header(proxy) if with_auth else "")
+    return send(proxy, timeout, ca_file, request)
+
+
+def check_connect(proxy, timeout, ca_file, target):
+    try:
+        code, status_line = connect(proxy, timeout, ca_file, target)
+    except (OSError, ValueError) as e:
+        report("connect", target, "fail", "unable to reach proxy: %s" % e)
+        return
+    if code == 200:
+        report("connect", target, "pass", "proxy established a tunnel (%s)" % status_line)
+    elif code == 407:
+        report("connect", target, "skip", "proxy authentication failed (%s); see the auth check" % status_line)
+    elif code in (502, 503, 504):
+        # The proxy allowed the tunnel but couldn't reach the endpoint, which egress checks report
+        report("connect", target, "pass", "proxy allows CONNECT to this port but couldn't reach the endpoint (%s)" % status_line)
+    else:
+        report("connect", target, "fail", "proxy refused CONNECT (%s)" % status_line)
+
+
+def check_auth(proxy, timeout, ca_file, target):
+    try:
+        code, status_line = connect(proxy, timeout, ca_file, target)
+        anonymous_code, _ = connect(proxy, timeout, ca_file, target, with_auth=False)
+    except (OSError, ValueError) as e:
+        report("auth", target, "fail", "unable to reach proxy: %s" % e)
+        return
+    if proxy.username and code == 407:
+        report("auth", target, "fail", "proxy rejected the provided credentials (%s)" % status_line)
+    elif proxy.username and anonymous_code != 407:
+        report("auth", target, "pass", "proxy accepted the provided credentials, but doesn't require any")
+    elif proxy.username:
+        report("auth", target, "pass", "proxy accepted the provided credentials")
+    elif code == 407:
+        report("auth", target, "fail", "proxy requires authentication, but the proxy URL contains no credentials (%s)" % status_line)
+    else:
+        report("auth", target, "skip", "no credentials provided and none required")
+
+
+def no_proxy_match(host):
+    """Returns the no_proxy entry that host matches, or None"""
+    for entry in os.environ.get("no_proxy", "").split(","):
+        entry = entry.strip().lower()
+        if not entry:
+            continue
+        if entry == "*":
+            return entry
+        try:
+            if ipaddress.ip_address(host) in ipaddress.ip_network(entry, strict=False):
+                return entry
+            continue
+        except ValueError:
+            pass
+        domain = entry.rsplit(":", 1)[0] if entry.count(":") == 1 else entry
+        domain = domain.lstrip("*").lstrip(".")
+        if domain and (host == domain or host.endswith("." + domain)):
+            return entry
+    return None
+
+
+def check_no_proxy(timeout, urls):
+    checked = set()
+    for url in urls:
+        parsed = urllib.parse.urlparse(url)
+        host = parsed.hostname
+        port = parsed.port or (443 if parsed.scheme == "https" else 80)
+        entry = no_proxy_match(host)
+        if entry is None or (host, port) in checked:
+            continue
+        checked.add((host, port))
+        target = "%s:%d" % (host, port)
+        try:
+            socket.create_connection((host, port), timeout).close()
+            report("no-proxy", target, "pass", "matches no_proxy entry '%s' and is reachable without the proxy" % entry)
+        except OSError as e:
+            report("no-proxy", target, "fail", "matches no_proxy entry '%s' but isn't reachable without the proxy: %s" % (entry, e))
+    if not checked:
+        report("no-proxy", "", "skip", "no egress endpoints match no_proxy")
+
+
+def check_block_page(proxy, timeout, ca_file):
+    target = "http://%s/" % NONEXISTENT_HOST
+    request = "GET %s HTTP/1.1\r\nHost: %s\r\n%sConnection: close\r\n\r\n" % (target, NONEXISTENT_HOST, auth_header(proxy))
+    try:
+        code, status_line = send(proxy, timeout, ca_file, request)
+        tunnel_code, tunnel_status_line = connect(proxy, timeout, ca_file, NONEXISTENT_HOST + ":443")
+    except (OSError, ValueError) as e:
+        report("block-page", target, "fail", "unable to reach proxy: %s" % e)
+        return
+    if code == 200:
+        report("block-page", target, "fail", "proxy answered a request for a nonexistent host with its own page (%s), which can hide egress failures" % status_line)
+    elif tunnel_code == 200:
+        report("block-page", NONEXISTENT_HOST + ":443", "fail", "proxy claimed to establish a tunnel to a nonexistent host (%s), which can hide egress failures" % tunnel_status_line)
+    else:
+        report("block-page", target, "pass", "proxy reported the failure (%s)" % status_line)
+
+
+def s_client(proxy, timeout, target, ca_file=None):
+    host = target.rsplit(":", 1)[0]
+    args = ["openssl", "s_client", "-connect", target, "-servername", host, "-verify_hostname", host,
+            "-proxy", "%s:%d" % (proxy.hostname, proxy.port or 80)]
+    if proxy.username:
+        args += ["-proxy_user", urllib.parse.unquote(proxy.username), "-proxy_pass", "pass:" + urllib.parse.unquote(proxy.password or "")]
+    if ca_file:
+        args += ["-CAfile", ca_file]
+    out = subprocess.run(args, input="", capture_output=True, text=True, timeout=timeout).stdout
+    verify = VERIFY_RE.search(out)
+    issuer = ISSUER_RE.search(out)
+    # openssl reports "Verify return code: 0 (ok)" even if no certificate was presented at all
+    if not verify or not issuer:
+        return None, "", ""
+    return int(verify.group(1)), verify.group(2), issuer.group(1).strip()
+
+
+def check_cacert(proxy, timeout, ca_file, target):
+    if proxy.scheme == "https":
+        # openssl s_client can only tunnel through plain HTTP proxies
+        report("cacert", target, "skip", "unable to inspect certificate chains through an HTTPS proxy")
+        return
+    try:
+        code, message, issuer = s_client(proxy, timeout, target)
+        if code is None:
+            report("cacert", target, "fail", "no certificate chain was presented through the proxy")
+            return
+        if code == 0:
+            detail = "chain presented through the proxy is publicly trusted, so the proxy doesn't intercept TLS"
+            if ca_file:
+                detail += " and the provided CA isn't needed for this endpoint"
+            report("cacert", target, "pass", detail)
+            return
+        if not ca_file:
+            report("cacert", target, "fail", "chain presented through the proxy isn't publicly trusted (%s; issuer: %s), but no proxy CA was provided" % (message, issuer))
+            return
+        code, message, issuer = s_client(proxy, timeout, target, ca_file)
+    except subprocess.TimeoutExpired:
+        report("cacert", target, "fail", "timed out waiting for TLS handshake")
+        return
+    if code == 0:
+        report("cacert", target, "pass", "proxy intercepts TLS with a chain signed by the provided CA (issuer: %s)" % issuer)
+    else:
+        report("cacert", target, "fail", "chain presented through the proxy doesn't match the provided CA (%s; issuer: %s)" % (message, issuer))
+
+
+def main():
+    timeout = float(sys.argv[1])
+    ca_file = sys.argv[2] if os.path.isfile(sys.argv[2]) and os.path.getsize(sys.argv[2]) > 0 else None
+    connect_targets = [target for target in sys.argv[3].split(",") if target]
+    urls = sys.argv[4:]
+    proxy = proxy_url()
+
+    for target in connect_targets:
+        check_connect(proxy, timeout, ca_file, target)
+    check_auth(proxy, timeout, ca_file, connect_targets[0])
+    check_no_proxy(timeout, urls)
+    check_block_page(proxy, timeout, ca_file)
+    check_cacert(proxy, timeout, ca_file, connect_targets[0])
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,211 @@
+package proxydiag
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+)
+
+// proxydiag.Probe is an implementation of the probes.Probe interface that checks how the
+// configured HTTP(S) proxy behaves, beyond whether egress endpoints are reachable through it.
+// It launches the same unmodified RHEL9 image as curl.Probe and uses userdata to install a small
+// Python script that checks whether the proxy accepts CONNECT to port 443 and to the non-standard
+// ports on the egress list (as well as 9997), whether it accepts (or requires) the credentials in
+// the proxy URL, whether egress endpoints matching NoProxy are reachable without the proxy,
+// whether it answers requests for a nonexistent host with its own 200 page, and whether the
+// certificate chain it presents for HTTPS endpoints matches the provided CA certificate. Failed
+// checks are reported as proxy findings, apart from egress failures; passed and skipped checks
+// are reported as informational results. This probe relies on cloud-init and therefore only
+// supports AWS.
+type Probe struct{}
+
+//go:embed userdata-template.yaml
+var userDataTemplate string
+
+//go:embed diagnose.py
+var diagnoseScript string
+
+const startingToken = "NV_PROXYDIAGJSON_BEGIN" //nolint:gosec
+const endingToken = "NV_PROXYDIAGJSON_END"     //nolint:gosec
+
+// outputLinePrefix is printed by diagnose.py before each JSON-formatted check result
+const outputLinePrefix = "@NV@"
+
+// alwaysCheckedPort is a non-standard port that the proxy is always asked to CONNECT to, as
+// clusters need it (e.g., for Splunk log forwarding) even if the egress list doesn't include it
+const alwaysCheckedPort = 9997
+
+var presetUserDataVariables = map[string]string{
+	"USERDATA_BEGIN":   startingToken,
+	"USERDATA_END":     endingToken,
+	"SEQUENCE_COMMAND": helpers.SequenceLinesCommand(""),
+}
+
+// GetStartingToken returns the string token used to signal the beginning of the probe's output
+func (pdp Probe) GetStartingToken() string { return startingToken }
+
+// GetEndingToken returns the string token used to signal the end of the probe's output
+func (pdp Probe) GetEndingToken() string { return endingToken }
+
+// GetMachineImageID returns the string ID of the VM image to be used for the probe instance. This
+// probe only needs python3 and openssl (already present on RHEL images), so it shares its images
+// with curl.Probe
+func (pdp Probe) GetMachineImageID(platformType cloud.Platform, cpuArch cpu.Architecture, region string) (string, error) {
+	return curl.Probe{}.GetMachineImageID(platformType, cpuArch, region)
+}
+
+// GetExpandedUserData returns a YAML-formatted userdata string filled-in ("expanded") with
+// the values provided in userDataVariables according to os.Expand(). Callers can pass the same
+// variables they would pass to curl.Probe: the proxy is taken from HTTPS_PROXY (or HTTP_PROXY, at
+// least one of which is required), the endpoints to diagnose it with from URLS and
+// TLSDISABLED_URLS, and the CA certificate (base64-encoded) from CACERT. Errors will be returned if
+// values aren't provided for required variables listed in the template's
+// "network-verifier-required-variables" directive, or if values *are* provided for variables that
+// must be set to a certain value for the probe to function correctly (presetUserDataVariables) --
+// this function will fill-in those values for you.
+func (pdp Probe) GetExpandedUserData(userDataVariables map[string]string) (string, error) {
+	// Platforms without cloud-init (e.g., GCP) ask for a systemd-based script instead
+	if userDataVariables["USE_SYSTEMD"] == "true" {
+		return "", errors.New("the proxydiag probe requires cloud-init and does not support systemd-based userdata")
+	}
+	if userDataVariables["HTTP_PROXY"] == "" && userDataVariables["HTTPS_PROXY"] == "" {
+		return "", errors.New("the proxydiag probe requires an HTTP or HTTPS proxy to be configured")
+	}
+
+	// Extract required variables specified in template (if any)
+	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(userDataTemplate)
+
+	var err error
+	// TIMEOUT applies to each individual check; diagnose.py accepts fractional seconds
+	userDataVariables["TIMEOUT"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["TIMEOUT"], "%.2f")
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable TIMEOUT: %w", err)
+	}
+	// cloud-init only accepts a positive integer number of seconds for DELAY
+	userDataVariables["DELAY"], err = helpers.NormalizeSaneNonzeroDuration(userDataVariables["DELAY"], "%.f")
+	if err != nil {
+		return "", fmt.Errorf("invalid userdata variable DELAY: %w", err)
+	}
+
+	connectTargets, err := connectTargetsFrom(userDataVariables["URLS"] + " " + userDataVariables["TLSDISABLED_URLS"])
+	if err != nil {
+		return "", err
+	}
+	userDataVariables["CONNECT_TARGETS"] = strings.Join(connectTargets, ",")
+	userDataVariables["DIAGNOSE_SCRIPT"] = base64.StdEncoding.EncodeToString([]byte(diagnoseScript))
+
+	// Ensure userDataVariables complies with requiredVariables and presetUserDataVariables. See
+	// docstring for helpers.ValidateProvidedVariables() for more details
+	err = helpers.ValidateProvidedVariables(userDataVariables, presetUserDataVariables, requiredVariables)
+	if err != nil {
+		return "", err
+	}
+
+	// Label the probe's output with this run's nonce (if any) so that stale output left on the
+	// console by a previous run is ignored
+	noncedPresetUserDataVariables := helpers.NoncedPresetUserDataVariables(presetUserDataVariables, userDataVariables["NONCE"])
+
+	// Expand template
+	return os.Expand(directivelessUserDataTemplate, func(userDataVar string) string {
+		if presetVal, isPreset := noncedPresetUserDataVariables[userDataVar]; isPreset {
+			return presetVal
+		}
+		return userDataVariables[userDataVar]
+	}), nil
+}
+
+// ParseProgressLine implements probes.ProgressReporter, describing a single check's result as
+// soon as it's printed. Skipped checks are reported as successful
+func (pdp Probe) ParseProgressLine(line string) (probes.ProgressEvent, bool) {
+	probeResult, err := deserializeProxyDiagProbeResult(helpers.RemoveTimestamps(line))
+	if err != nil {
+		return probes.ProgressEvent{}, false
+	}
+	return probes.ProgressEvent{
+		Target:  probeResult.Name(),
+		Success: !probeResult.IsFailed(),
+		Detail:  probeResult.String(),
+	}, true
+}
+
+// ParseProbeOutput accepts a string containing all probe output that appeared between
+// the startingToken and the endingToken and a pointer to an Output object. outputDestination
+// will be filled with a proxy finding for each failed check and an informational result for each
+// passed or skipped check. ensurePrivate is ignored, as this probe doesn't check egress endpoints
+func (pdp Probe) ParseProbeOutput(_ bool, probeOutput string, outputDestination *output.Output) {
+	// AWS inserts timestamps into long lines of console output; these must be removed first
+	probeResults, errMap := bulkDeserializeProxyDiagProbeResult(helpers.RemoveTimestamps(probeOutput))
+	for _, probeResult := range probeResults {
+		outputDestination.AddDebugLogs(fmt.Sprintf("%+v\n", probeResult))
+		if probeResult.IsFailed() {
+			outputDestination.AddProxyFinding(
+				handledErrors.NewProxyFinding(probeResult.Check, probeResult.Target, probeResult.Detail),
+			)
+			continue
+		}
+		outputDestination.AddInfo(probeResult.String())
+	}
+	for lineNum, err := range errMap {
+		outputDestination.AddError(
+			handledErrors.NewGenericError(
+				fmt.Errorf("error processing line %d: %w", lineNum, err),
+			),
+		)
+	}
+}
+
+// connectTargetsFrom returns the host:port pairs the proxy should be asked to CONNECT to, given
+// the space-separated URLs in urlsStr (e.g., "https://quay.io:443 telnet://example.com:6443"):
+// the first HTTPS endpoint's host on port 443, then one endpoint for each other non-standard port
+// on the list, then the first HTTPS endpoint's host on alwaysCheckedPort (unless already present).
+// The first target is also used for the checks that only need a single HTTPS endpoint
+func connectTargetsFrom(urlsStr string) ([]string, error) {
+	var firstHost, firstHTTPSHost string
+	var otherTargets []string
+	seenPorts := map[string]bool{"80": true, "443": true}
+	for _, urlStr := range strings.Fields(urlsStr) {
+		parsedURL, err := url.Parse(urlStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse URL '%s': %w", urlStr, err)
+		}
+		if parsedURL.Hostname() == "" {
+			continue
+		}
+		if firstHost == "" {
+			firstHost = parsedURL.Hostname()
+		}
+		if firstHTTPSHost == "" && strings.EqualFold(parsedURL.Scheme, "https") {
+			firstHTTPSHost = parsedURL.Hostname()
+		}
+		if port := parsedURL.Port(); port != "" && !seenPorts[port] {
+			seenPorts[port] = true
+			otherTargets = append(otherTargets, parsedURL.Host)
+		}
+	}
+	if firstHTTPSHost == "" {
+		firstHTTPSHost = firstHost
+	}
+	if firstHTTPSHost == "" {
+		return nil, errors.New("the proxydiag probe requires at least one egress URL to diagnose the proxy with")
+	}
+
+	targets := append([]string{net.JoinHostPort(firstHTTPSHost, "443")}, otherTargets...)
+	if alwaysCheckedPortStr := strconv.Itoa(alwaysCheckedPort); !seenPorts[alwaysCheckedPortStr] {
+		targets = append(targets, net.JoinHostPort(firstHTTPSHost, alwaysCheckedPortStr))
+	}
+	return targets, nil
+}
@@ -0,0 +1,94 @@
+package proxydiag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Statuses a ProxyDiagProbeResult can have
+const (
+	statusPass = "pass"
+	statusFail = "fail"
+	statusSkip = "skip"
+)
+
+// A ProxyDiagProbeResult represents the outcome of a single proxy diagnostic check (e.g.,
+// "connect" to a single host:port), as reported by diagnose.py
+type ProxyDiagProbeResult struct {
+	Check  string `json:"check"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// IsFailed returns true if the check found a problem with the proxy. Results with an unknown
+// status are treated as failures, so that they aren't silently ignored
+func (res ProxyDiagProbeResult) IsFailed() bool {
+	return res.Status != statusPass && res.Status != statusSkip
+}
+
+// Name returns the check's name along with its target (if any), e.g., "connect quay.io:9997"
+func (res ProxyDiagProbeResult) Name() string {
+	if res.Target == "" {
+		return res.Check
+	}
+	return res.Check + " " + res.Target
+}
+
+// String returns a one-line summary of the check's outcome suitable for display to users
+func (res ProxyDiagProbeResult) String() string {
+	return fmt.Sprintf("proxy %s check %s: %s", res.Name(), res.statusDescription(), res.Detail)
+}
+
+// statusDescription returns the check's status as a past-tense verb (e.g., "passed")
+func (res ProxyDiagProbeResult) statusDescription() string {
+	switch res.Status {
+	case statusPass:
+		return "passed"
+	case statusSkip:
+		return "skipped"
+	case statusFail:
+		return "failed"
+	default:
+		return fmt.Sprintf("returned unknown status %q", res.Status)
+	}
+}
+
+// bulkDeserializeProxyDiagProbeResult wraps deserializeProxyDiagProbeResult, creating a
+// ProxyDiagProbeResult from each non-blank line (containing prefixed JSON) of the provided
+// string. A slice of successfully-deserialized ProxyDiagProbeResult-pointers is returned
+// along with a mapping between any malformed lines and their line numbers
+func bulkDeserializeProxyDiagProbeResult(serializedLines string) ([]*ProxyDiagProbeResult, map[int]error) {
+	var results []*ProxyDiagProbeResult
+	deserializationErrs := make(map[int]error)
+	for lineNum, serializedLine := range strings.Split(serializedLines, "\n") {
+		if strings.TrimSpace(serializedLine) == "" {
+			continue
+		}
+		probeResultPtr, err := deserializeProxyDiagProbeResult(serializedLine)
+		if err != nil {
+			deserializationErrs[lineNum] = err
+		}
+		if probeResultPtr != nil {
+			results = append(results, probeResultPtr)
+		}
+	}
+	return results, deserializationErrs
+}
+
+// deserializeProxyDiagProbeResult creates a ProxyDiagProbeResult from a single line of probe
+// console output, which should start with outputLinePrefix followed by a serialized JSON
+// string. If the prefix is missing or JSON deserialization (unmarshalling) fails, (nil, error)
+// is returned
+func deserializeProxyDiagProbeResult(prefixedJSON string) (*ProxyDiagProbeResult, error) {
+	jsonStr, prefixFound := strings.CutPrefix(strings.TrimSpace(prefixedJSON), outputLinePrefix)
+	if !prefixFound {
+		return nil, fmt.Errorf("missing prefix '%s': %s", outputLinePrefix, prefixedJSON)
+	}
+	var result ProxyDiagProbeResult
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,262 @@
+package proxydiag
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"gopkg.in/yaml.v3"
+)
+
+// TestProxyDiagProbe_ImplementsProbeInterface simply forces the compiler to confirm
+// that the Probe type properly implements the Probe interface
+func TestProxyDiagProbe_ImplementsProbeInterface(t *testing.T) {
+	var _ probes.Probe = (*Probe)(nil)
+	var _ probes.ProgressReporter = (*Probe)(nil)
+}
+
+// TestProxyDiagProbe_GetExpandedUserData tests the correctness of the userdata
+// produced by the probe using regexes and basic YAML syntax validation
+func TestProxyDiagProbe_GetExpandedUserData(t *testing.T) {
+	tests := []struct {
+		name              string
+		userDataVariables map[string]string
+		wantRegex         string
+		wantErr           bool
+	}{
+		{
+			name: "happy path",
+			userDataVariables: map[string]string{
+				"TIMEOUT":     "1",
+				"DELAY":       "2",
+				"URLS":        "http://example.com:80 https://example.org:443 telnet://example.net:6443",
+				"HTTPS_PROXY": "http://proxy.example.com:3128",
+				"NO_PROXY":    ".internal.example.com",
+			},
+			wantRegex: `#cloud-config[\s\S]*https_proxy=http://proxy.example.com:3128 no_proxy=".internal.example.com"[\s\S]*nv-diagnose.py 1.00 /etc/nv-proxy-ca.pem example.org:443,example.net:6443,example.org:9997 http://example.com:80 `,
+		},
+		{
+			name: "CA cert provided",
+			userDataVariables: map[string]string{
+				"TIMEOUT":    "1",
+				"DELAY":      "2",
+				"URLS":       "https://example.org:443",
+				"HTTP_PROXY": "http://proxy.example.com:3128",
+				"CACERT":     "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==",
+			},
+			wantRegex: `#cloud-config[\s\S]*content: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg=="`,
+		},
+		{
+			name: "no proxy configured",
+			userDataVariables: map[string]string{
+				"TIMEOUT": "1",
+				"DELAY":   "2",
+				"URLS":    "https://example.org:443",
+			},
+			wantErr: true,
+		},
+		{
+			name: "no URLs",
+			userDataVariables: map[string]string{
+				"TIMEOUT":     "1",
+				"DELAY":       "2",
+				"HTTPS_PROXY": "http://proxy.example.com:3128",
+			},
+			wantErr: true,
+		},
+		{
+			name: "systemd requested",
+			userDataVariables: map[string]string{
+				"TIMEOUT":     "1",
+				"DELAY":       "2",
+				"URLS":        "https://example.org:443",
+				"HTTPS_PROXY": "http://proxy.example.com:3128",
+				"USE_SYSTEMD": "true",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Probe{}.GetExpandedUserData(tt.userDataVariables)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("proxydiag.Probe.GetExpandedUserData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(tt.wantRegex) > 0 && !regexp.MustCompile(tt.wantRegex).MatchString(got) {
+				t.Errorf("proxydiag.Probe.GetExpandedUserData() output does not match regex `%s`, content=%v", tt.wantRegex, got)
+			}
+
+			var unmarshalled any
+			if err := yaml.Unmarshal([]byte(got), &unmarshalled); err != nil {
+				t.Errorf("proxydiag.Probe.GetExpandedUserData() produced invalid YAML (err: %v), content=%v", err, got)
+			}
+		})
+	}
+}
+
+// TestProxyDiagProbe_UserDataTemplateContainsDeclaredVariables ensures that this probe's
+// userdata-template.yaml contains all of the variables it declares as required or preset
+func TestProxyDiagProbe_UserDataTemplateContainsDeclaredVariables(t *testing.T) {
+	for presetVariableName := range presetUserDataVariables {
+		if !strings.Contains(userDataTemplate, "${"+presetVariableName+"}") {
+			t.Errorf("proxydiag.Probe.presetUserDataVariables has key %[1]s, but could not find required '${%[1]s}' in probe's userdata-template.yaml", presetVariableName)
+		}
+	}
+
+	directivelessUserDataTemplate, requiredVariables := helpers.ExtractRequiredVariablesDirective(userDataTemplate)
+	for _, requiredVariableName := range requiredVariables {
+		if !strings.Contains(directivelessUserDataTemplate, "${"+requiredVariableName+"}") {
+			t.Errorf("proxydiag.Probe's userdata-template.yaml declares %[1]s as required, but could not find '${%[1]s}' in file", requiredVariableName)
+		}
+	}
+}
+
+func Test_connectTargetsFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		urls    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "HTTPS endpoint not listed first",
+			urls: "http://example.com:80 https://example.org:443",
+			want: []string{"example.org:443", "example.org:9997"},
+		},
+		{
+			name: "non-standard ports deduplicated",
+			urls: "https://example.org:443 telnet://a.example.net:6443 telnet://b.example.net:6443 telnet://c.example.net:9997",
+			want: []string{"example.org:443", "a.example.net:6443", "c.example.net:9997"},
+		},
+		{
+			name: "no HTTPS endpoints",
+			urls: "http://example.com:80",
+			want: []string{"example.com:443", "example.com:9997"},
+		},
+		{
+			name:    "no endpoints",
+			urls:    " ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := connectTargetsFrom(tt.urls)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("connectTargetsFrom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("connectTargetsFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyDiagProbe_ParseProbeOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		probeOutput  string
+		wantInfo     []string
+		wantFindings []string
+		wantErrors   bool
+	}{
+		{
+			name: "passed and skipped checks",
+			probeOutput: `@NV@{"check":"connect","target":"quay.io:443","status":"pass","detail":"proxy established a tunnel (HTTP/1.1 200 Connection established)"}
+@NV@{"check":"no-proxy","target":"","status":"skip","detail":"no egress endpoints match no_proxy"}`,
+			wantInfo: []string{
+				"proxy connect quay.io:443 check passed: proxy established a tunnel (HTTP/1.1 200 Connection established)",
+				"proxy no-proxy check skipped: no egress endpoints match no_proxy",
+			},
+		},
+		{
+			name: "failed checks",
+			probeOutput: `@NV@{"check":"connect","target":"quay.io:9997","status":"fail","detail":"proxy refused CONNECT (HTTP/1.1 403 Forbidden)"}
+@NV@{"check":"block-page","target":"http://osd-network-verifier.invalid/","status":"fail","detail":"proxy answered a request for a nonexistent host with its own page (HTTP/1.1 200 OK), which can hide egress failures"}`,
+			wantFindings: []string{
+				"proxy connect check failed: quay.io:9997 proxy refused CONNECT (HTTP/1.1 403 Forbidden)",
+				"proxy block-page check failed: http://osd-network-verifier.invalid/ proxy answered a request for a nonexistent host with its own page (HTTP/1.1 200 OK), which can hide egress failures",
+			},
+		},
+		{
+			name:         "unknown status",
+			probeOutput:  `@NV@{"check":"auth","target":"","status":"error","detail":"unexpected"}`,
+			wantFindings: []string{"proxy auth check failed: unexpected"},
+		},
+		{
+			name:        "malformed line",
+			probeOutput: `Traceback (most recent call last):`,
+			wantErrors:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &output.Output{}
+			Probe{}.ParseProbeOutput(false, tt.probeOutput, out)
+
+			if !reflect.DeepEqual(out.GetInfo(), tt.wantInfo) {
+				t.Errorf("proxydiag.Probe.ParseProbeOutput() info = %v, want %v", out.GetInfo(), tt.wantInfo)
+			}
+			var gotFindings []string
+			for _, finding := range out.GetProxyFindings() {
+				gotFindings = append(gotFindings, finding.Error())
+			}
+			if !reflect.DeepEqual(gotFindings, tt.wantFindings) {
+				t.Errorf("proxydiag.Probe.ParseProbeOutput() proxy findings = %v, want %v", gotFindings, tt.wantFindings)
+			}
+			if got := len(out.GetEgressURLFailures()); got != 0 {
+				t.Errorf("proxydiag.Probe.ParseProbeOutput() reported %d egress failures, want 0", got)
+			}
+			_, _, gotErrors := out.Parse()
+			if (len(gotErrors) > 0) != tt.wantErrors {
+				t.Errorf("proxydiag.Probe.ParseProbeOutput() errors = %v, wantErrors %v", gotErrors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestProxyDiagProbe_ParseProgressLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantEvent probes.ProgressEvent
+		wantOK    bool
+	}{
+		{
+			name: "failed check",
+			line: `@NV@{"check":"connect","target":"quay.io:9997","status":"fail","detail":"proxy refused CONNECT (HTTP/1.1 403 Forbidden)"}`,
+			wantEvent: probes.ProgressEvent{
+				Target:  "connect quay.io:9997",
+				Success: false,
+				Detail:  "proxy connect quay.io:9997 check failed: proxy refused CONNECT (HTTP/1.1 403 Forbidden)",
+			},
+			wantOK: true,
+		},
+		{
+			name:      "unrelated line ignored",
+			line:      `cloud-init: running modules`,
+			wantEvent: probes.ProgressEvent{},
+			wantOK:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEvent, gotOK := Probe{}.ParseProgressLine(tt.line)
+			if gotOK != tt.wantOK {
+				t.Fatalf("proxydiag.Probe.ParseProgressLine() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+			if gotEvent != tt.wantEvent {
+				t.Errorf("proxydiag.Probe.ParseProgressLine() = %+v, want %+v", gotEvent, tt.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Route describes how a single request would be sent given a ProxyConfig
+type Route struct {
+	// Proxy is the URL (with any password redacted) of the proxy the request would be sent
+	// through, or empty if the request would be sent directly
+	Proxy string
+	// Reason explains the decision, e.g., "matches no_proxy entry '.example.com'"
+	Reason string
+}
+
+// IsDirect returns true if the request would bypass the proxy
+func (r Route) IsDirect() bool {
+	return r.Proxy == ""
+}
+
+// String returns a short description of the route suitable for display to users
+func (r Route) String() string {
+	if r.IsDirect() {
+		return fmt.Sprintf("direct (%s)", r.Reason)
+	}
+	return fmt.Sprintf("via %s (%s)", r.Proxy, r.Reason)
+}
+
+// EndpointRoutes describes how a request to a single egress endpoint would be sent by curl (and
+// thus the curl probe) and by Go's net/http (and thus the local verifier), which interpret
+// no_proxy differently
+type EndpointRoutes struct {
+	URL  string
+	Curl Route
+	Go   Route
+}
+
+// Disagree returns true if curl and Go would route the request differently, i.e., if one would
+// send it through the proxy and the other wouldn't
+func (e EndpointRoutes) Disagree() bool {
+	return e.Curl.IsDirect() != e.Go.IsDirect()
+}
+
+// Explain decides, for each of the given egress URLs (e.g., "https://quay.io:443"), whether a
+// request to it would go through the configured proxy or directly, following both curl's and Go's
+// no_proxy semantics. Only HTTP(S) URLs are ever proxied, via HttpProxy and HttpsProxy
+// respectively. An error is returned if a URL or proxy URL can't be parsed
+func (p *ProxyConfig) Explain(egressURLs []string) ([]EndpointRoutes, error) {
+	noProxy := p.NoProxyAsString()
+	routes := make([]EndpointRoutes, 0, len(egressURLs))
+	for _, egressURL := range egressURLs {
+		parsedURL, err := url.Parse(egressURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse URL '%s': %w", egressURL, err)
+		}
+		host, port := strings.ToLower(parsedURL.Hostname()), parsedURL.Port()
+		if port == "" {
+			port = defaultPort(parsedURL.Scheme)
+		}
+
+		var proxyURL string
+		switch strings.ToLower(parsedURL.Scheme) {
+		case "http":
+			proxyURL = p.HttpProxy
+		case "https":
+			proxyURL = p.HttpsProxy
+		default:
+			// Both curl and Go only consult http_proxy and https_proxy for HTTP(S) requests
+			noProxyConfigured := Route{Reason: fmt.Sprintf("%s requests are never proxied", parsedURL.Scheme)}
+			routes = append(routes, EndpointRoutes{URL: egressURL, Curl: noProxyConfigured, Go: noProxyConfigured})
+			continue
+		}
+		if proxyURL == "" {
+			noProxyConfigured := Route{Reason: fmt.Sprintf("no %s proxy configured", parsedURL.Scheme)}
+			routes = append(routes, EndpointRoutes{URL: egressURL, Curl: noProxyConfigured, Go: noProxyConfigured})
+			continue
+		}
+		redactedProxyURL, err := redactProxyURL(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+
+		endpointRoutes := EndpointRoutes{
+			URL:  egressURL,
+			Curl: route(curlBypassReason(noProxy, host, port), redactedProxyURL),
+			Go:   route(goBypassReason(noProxy, host, port), redactedProxyURL),
+		}
+		routes = append(routes, endpointRoutes)
+	}
+	return routes, nil
+}
+
+// route returns a Route through proxyURL, unless bypassReason explains why the proxy is bypassed
+func route(bypassReason string, proxyURL string) Route {
+	if bypassReason != "" {
+		return Route{Reason: bypassReason}
+	}
+	return Route{Proxy: proxyURL, Reason: "no matching no_proxy entry"}
+}
+
+// curlBypassReason returns why curl would send a request to host directly given noProxy, or an
+// empty string if it would use the proxy. This mirrors curl's lib/noproxy.c: "*" only counts if
+// it's the entire value; other entries are domains (matching themselves and their subdomains,
+// ignoring leading and trailing dots) or, for IP hosts only, IP addresses and CIDR blocks. Ports
+// aren't supported (entries with ports never match hostnames), "*." prefixes aren't supported,
+// and a CIDR prefix length of 0 is treated as a single address. Parsing stops at the first entry
+// not followed by a comma
+func curlBypassReason(noProxy string, host string, _ string) string {
+	if noProxy == "*" {
+		return "no_proxy is '*'"
+	}
+
+	hostAddr, hostAddrErr := netip.ParseAddr(host)
+	isIP := hostAddrErr == nil
+	host = strings.TrimSuffix(host, ".")
+
+	rest := noProxy
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " \t")
+		tokenEnd := strings.IndexAny(rest, " \t,")
+		if tokenEnd < 0 {
+			tokenEnd = len(rest)
+		}
+		entry := rest[:tokenEnd]
+		rest = strings.TrimLeft(rest[tokenEnd:], " \t")
+
+		if entry != "" {
+			if isIP && curlCIDRMatch(hostAddr, entry) {
+				return fmt.Sprintf("matches no_proxy entry '%s'", entry)
+			}
+			if !isIP {
+				domain := strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(entry), "."), ".")
+				if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+					return fmt.Sprintf("matches no_proxy entry '%s'", entry)
+				}
+			}
+		}
+
+		// curl stops at the first entry that isn't followed by a comma
+		if !strings.HasPrefix(rest, ",") {
+			break
+		}
+		rest = strings.TrimLeft(rest, ",")
+	}
+	return ""
+}
+
+// curlCIDRMatch returns true if hostAddr matches entry (an IP address or CIDR block) the way
+// curl's Curl_cidr4_match and Curl_cidr6_match do
+func curlCIDRMatch(hostAddr netip.Addr, entry string) bool {
+	addrStr, bitsStr, hasBits := strings.Cut(entry, "/")
+	entryAddr, err := netip.ParseAddr(addrStr)
+	if err != nil || entryAddr.Is4() != hostAddr.Is4() {
+		return false
+	}
+	bits := 0
+	if hasBits {
+		// Like C's atoi, ignore anything after the leading digits
+		digits := bitsStr[:len(bitsStr)-len(strings.TrimLeft(bitsStr, "0123456789"))]
+		bits, _ = strconv.Atoi(digits)
+	}
+	if bits > hostAddr.BitLen() {
+		return false
+	}
+	if bits == 0 {
+		bits = hostAddr.BitLen()
+	}
+	prefix, err := entryAddr.Prefix(bits)
+	return err == nil && prefix.Contains(hostAddr)
+}
+
+// goBypassReason returns why Go's net/http (via golang.org/x/net/http/httpproxy) would send a
+// request to host:port directly given noProxy, or an empty string if it would use the proxy. Go
+// never proxies localhost or loopback addresses, and "*" counts anywhere in the list. Other
+// entries may have ports; entries with a leading "." (or "*.") only match subdomains, while
+// entries without one also match the domain itself; and CIDR blocks only match IP hosts
+func goBypassReason(noProxy string, host string, port string) string {
+	hostIP := net.ParseIP(host)
+	if host == "localhost" {
+		return "Go never proxies localhost"
+	}
+	if hostIP != nil && hostIP.IsLoopback() {
+		return "Go never proxies loopback addresses"
+	}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		normalizedEntry := strings.ToLower(strings.TrimSpace(entry))
+		if normalizedEntry == "" {
+			continue
+		}
+		matched := fmt.Sprintf("matches no_proxy entry '%s'", strings.TrimSpace(entry))
+		if normalizedEntry == "*" {
+			return matched
+		}
+		if _, cidr, err := net.ParseCIDR(normalizedEntry); err == nil {
+			if hostIP != nil && cidr.Contains(hostIP) {
+				return matched
+			}
+			continue
+		}
+
+		entryHost, entryPort, err := net.SplitHostPort(normalizedEntry)
+		if err != nil {
+			entryHost, entryPort = normalizedEntry, ""
+		} else if entryHost == "" {
+			// Malformed entries (e.g., ":443") are ignored
+			continue
+		}
+		entryHost = strings.TrimSuffix(strings.TrimPrefix(entryHost, "["), "]")
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if hostIP != nil && entryIP.Equal(hostIP) {
+				return matched
+			}
+			continue
+		}
+
+		if strings.HasPrefix(entryHost, "*.") {
+			entryHost = entryHost[1:]
+		}
+		matchesDomainItself := !strings.HasPrefix(entryHost, ".")
+		if matchesDomainItself {
+			entryHost = "." + entryHost
+		}
+		if entryHost != "." && (strings.HasSuffix(host, entryHost) || (matchesDomainItself && host == entryHost[1:])) {
+			return matched
+		}
+	}
+	return ""
+}
+
+// defaultPort returns the port implied by an egress URL's scheme
+func defaultPort(scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "https":
+		return "443"
+	default:
+		return "80"
+	}
+}
+
+// redactProxyURL returns proxyURL with any password replaced by "xxxxx". Like curl and Go,
+// proxy URLs without a scheme are assumed to be HTTP
+func redactProxyURL(proxyURL string) (string, error) {
+	if !strings.Contains(proxyURL, "://") {
+		proxyURL = "http://" + proxyURL
+	}
+	parsedProxyURL, err := url.Parse(proxyURL)
+	if err != nil {
+		// url.Error includes the unredacted URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("unable to parse proxy URL: %w", err)
+	}
+	return parsedProxyURL.Redacted(), nil
+}
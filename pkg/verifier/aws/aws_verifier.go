@@ -1,24 +1,31 @@
 package awsverifier
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"maps"
 	"net/netip"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	awsTools "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
 	"github.com/go-playground/validator"
 	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
 	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
 	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
 	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
 	"github.com/openshift/osd-network-verifier/pkg/helpers"
 	"github.com/openshift/osd-network-verifier/pkg/output"
@@ -26,9 +33,25 @@ import (
 	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
 )
 
-// defaultIpPermissions contains the base set of ipPermissions (egress rules)
-// allowed on the verifier's temporary security group (only created when the
-// user doesn't provide their own security group)
+// baseIpPermissions contains the ipPermissions (egress rules) allowed on the
+// verifier's temporary security group regardless of the egress list, i.e., DNS,
+// which probes need before they can reach any endpoint
+var baseIpPermissions = []ec2Types.IpPermission{
+	{
+		FromPort:   awsTools.Int32(53),
+		ToPort:     awsTools.Int32(53),
+		IpProtocol: awsTools.String("udp"),
+		IpRanges: []ec2Types.IpRange{
+			{
+				CidrIp: awsTools.String("0.0.0.0/0"),
+			},
+		},
+	},
+}
+
+// defaultIpPermissions contains the fixed set of ipPermissions (egress rules)
+// allowed on the verifier's temporary security group when the egress list isn't
+// known in advance, i.e., when using legacy.Probe (which ships its own list)
 var defaultIpPermissions = []ec2Types.IpPermission{
 	{
 		FromPort:   awsTools.Int32(80),
@@ -72,6 +95,26 @@ var defaultIpPermissions = []ec2Types.IpPermission{
 	},
 }
 
+// withIPv6AnyRanges returns a copy of ipPermissions in which every rule allowing egress to any IPv4
+// address (0.0.0.0/0) also allows egress to any IPv6 address (::/0), so that the verifier's
+// temporary security group doesn't block egress checks over IPv6
+func withIPv6AnyRanges(ipPermissions []ec2Types.IpPermission) []ec2Types.IpPermission {
+	dualStackIPPermissions := make([]ec2Types.IpPermission, 0, len(ipPermissions))
+	for _, ipPerm := range ipPermissions {
+		for _, ipRange := range ipPerm.IpRanges {
+			if *ipRange.CidrIp == "0.0.0.0/0" {
+				ipPerm.Ipv6Ranges = append(slices.Clone(ipPerm.Ipv6Ranges), ec2Types.Ipv6Range{
+					CidrIpv6:    awsTools.String("::/0"),
+					Description: ipRange.Description,
+				})
+				break
+			}
+		}
+		dualStackIPPermissions = append(dualStackIPPermissions, ipPerm)
+	}
+	return dualStackIPPermissions
+}
+
 const (
 	instanceCount int32 = 1
 
@@ -80,15 +123,39 @@ const (
 	networkValidatorImage = "quay.io/app-sre/osd-network-verifier@sha256:137bf177c2e87732b2692c1af39d3b79b2f84c7f0ee9254df4ea4412dddfab1e"
 	networkValidatorRepo  = "quay.io/app-sre/osd-network-verifier"
 	invalidKMSCode        = "Client.InvalidKMSKey.InvalidState"
+	// permissionNotFoundCode is returned when revoking a security group rule that doesn't exist
+	permissionNotFoundCode = "InvalidPermission.NotFound"
+
+	// maxUserDataBytes is the AWS-imposed limit on (pre-base64-encoding) userdata size
+	maxUserDataBytes = 16384 // 16KB
+
+	// defaultConsolePollInterval and minConsolePollTimeout control how often and for how long
+	// (at least) the probe instance's console output is scraped, unless the user requests otherwise
+	defaultConsolePollInterval = 10 * time.Second
+	minConsolePollTimeout      = 270 * time.Second
 )
 
+// errUserDataTooLarge is returned by fitUserData when userdata exceeds maxUserDataBytes even
+// after compression
+var errUserDataTooLarge = errors.New("userdata size exceeds AWS-imposed 16KB limit")
+
 // AwsVerifier holds an aws client and knows how to fulfill the VerifierService which contains all functions needed for verifier
 type AwsVerifier struct {
 	AwsClient *aws.Client
 	Logger    ocmlog.Logger
-	Output    output.Output
+	// Output mirrors the output returned by the most recently completed call to ValidateEgress,
+	// VerifyDns or CleanupSecurityGroup.
+	//
+	// Deprecated: Output is overwritten by every call and is unreliable when an AwsVerifier is used
+	// by several goroutines at once. Use the *output.Output returned by each call instead
+	Output output.Output
+	// outputMutex guards Output
+	outputMutex sync.Mutex
 	// This cache is only to be used inside describeInstanceType() to minimize nil ptr error risk
 	cachedInstanceTypeInfo *ec2Types.InstanceTypeInfo
+	// cachedInstanceTypeInfoMutex guards cachedInstanceTypeInfo, as an AwsVerifier may be used by
+	// several goroutines at once
+	cachedInstanceTypeInfoMutex sync.Mutex
 }
 
 // GetAMIForRegion returns the default X86 AWS AMI for the CurlJSONProbe given a region. This is unused within this codebase,
@@ -135,16 +202,26 @@ func NewAwsVerifier(accessID, accessSecret, sessionToken, region, profile string
 	return &AwsVerifier{
 		AwsClient: awsClient,
 		Logger:    logger,
-		Output:    output.Output{},
 	}, nil
 }
 
+// mirrorOutput copies out, the output of a completed call, into the deprecated a.Output field so
+// that callers still reading it see that call's results
+func (a *AwsVerifier) mirrorOutput(out *output.Output) {
+	a.outputMutex.Lock()
+	defer a.outputMutex.Unlock()
+	a.Output = *out
+}
+
 // describeInstanceType calls the AWS EC2 API's "DescribeInstanceTypes" endpoint for the given
 // instanceType and caches the answer in a.cachedInstanceTypeInfo. Subsequent
-func (a *AwsVerifier) describeInstanceType(ctx context.Context, instanceType string) (*ec2Types.InstanceTypeInfo, error) {
+func (a *AwsVerifier) describeInstanceType(ctx context.Context, out *output.Output, instanceType string) (*ec2Types.InstanceTypeInfo, error) {
+	a.cachedInstanceTypeInfoMutex.Lock()
+	defer a.cachedInstanceTypeInfoMutex.Unlock()
+
 	// Make API request if cache is empty or doesn't match requested instanceType
 	if a.cachedInstanceTypeInfo == nil || string(a.cachedInstanceTypeInfo.InstanceType) != instanceType {
-		a.writeDebugLogs(ctx, fmt.Sprintf("Gathering description of instance type %s from EC2", instanceType))
+		a.writeDebugLogs(ctx, out, fmt.Sprintf("Gathering description of instance type %s from EC2", instanceType))
 		descInput := ec2.DescribeInstanceTypesInput{
 			InstanceTypes: []ec2Types.InstanceType{ec2Types.InstanceType(instanceType)},
 		}
@@ -169,9 +246,9 @@ func (a *AwsVerifier) describeInstanceType(ctx context.Context, instanceType str
 // instanceTypeUsesNitro asks the AWS API whether the provided instanceType uses the "Nitro"
 // hypervisor. Nitro is the only hypervisor supporting serial console output, which we need to
 // collect in order to gather probe results
-func (a *AwsVerifier) instanceTypeUsesNitro(ctx context.Context, instanceType string) (bool, error) {
+func (a *AwsVerifier) instanceTypeUsesNitro(ctx context.Context, out *output.Output, instanceType string) (bool, error) {
 	// Fetch instance type info
-	instanceTypeInfo, err := a.describeInstanceType(ctx, instanceType)
+	instanceTypeInfo, err := a.describeInstanceType(ctx, out, instanceType)
 	if err != nil {
 		return false, err
 	}
@@ -183,9 +260,9 @@ func (a *AwsVerifier) instanceTypeUsesNitro(ctx context.Context, instanceType st
 // instanceTypeArchitecture asks the AWS API about the CPU architecture(s) supported by the provided
 // instanceType and returns the first answer matching a cpu.Architecture known to the verifier. An
 // error is returned if the API call fails or if the verifier has no support for the instanceType's CPU
-func (a *AwsVerifier) instanceTypeArchitecture(ctx context.Context, instanceType string) (cpu.Architecture, error) {
+func (a *AwsVerifier) instanceTypeArchitecture(ctx context.Context, out *output.Output, instanceType string) (cpu.Architecture, error) {
 	// Fetch instance type info
-	instanceTypeInfo, err := a.describeInstanceType(ctx, instanceType)
+	instanceTypeInfo, err := a.describeInstanceType(ctx, out, instanceType)
 	if err != nil {
 		return cpu.Architecture{}, err
 	}
@@ -205,10 +282,10 @@ func (a *AwsVerifier) instanceTypeArchitecture(ctx context.Context, instanceType
 // passing "zero values") will result in a value being inferred from other parameters or from a
 // pre-programmed default. Any provided parameters may be overridden (e.g., if an unsupported
 // non-Nitro instance type is requested) with the "next best" supported alternative. For example,
-// calling selectInstanceType(ctx, "c4.large", cpu.ArchX86) will return ("t3.micro", cpu.ArchX86,
+// calling selectInstanceType(ctx, out, "c4.large", cpu.ArchX86) will return ("t3.micro", cpu.ArchX86,
 // nil) because c4-type instances do not use Nitro hypervisors and t3.micro is a suitable
 // alternative that uses the same CPU architecture as c4.large.
-func (a *AwsVerifier) selectInstanceType(ctx context.Context, instanceType string, cpuArchitecture cpu.Architecture) (string, cpu.Architecture, error) {
+func (a *AwsVerifier) selectInstanceType(ctx context.Context, out *output.Output, instanceType string, cpuArchitecture cpu.Architecture) (string, cpu.Architecture, error) {
 	var err error
 
 	// Validate any requested instance type
@@ -217,13 +294,13 @@ func (a *AwsVerifier) selectInstanceType(ctx context.Context, instanceType strin
 		// Derive CPU arch from requested InstanceType so that we can pick an appropriate AMI, and
 		// if necessary (e.g., because given type is non-Nitro), an alternative instance type with
 		// the same CPU arch
-		cpuArchitecture, err = a.instanceTypeArchitecture(ctx, instanceType)
+		cpuArchitecture, err = a.instanceTypeArchitecture(ctx, out, instanceType)
 		if err != nil {
 			return "", cpu.Architecture{}, fmt.Errorf("failed to validate CPU architecture of instance type %s: %w", instanceType, err)
 		}
 
 		// Determine if given InstanceType uses the required Nitro hypervisor
-		validInstanceTypeRequested, err = a.instanceTypeUsesNitro(ctx, instanceType)
+		validInstanceTypeRequested, err = a.instanceTypeUsesNitro(ctx, out, instanceType)
 		if err != nil {
 			return "", cpu.Architecture{}, fmt.Errorf("failed to determine hypervisor of instance type %s: %w", instanceType, err)
 		}
@@ -232,21 +309,21 @@ func (a *AwsVerifier) selectInstanceType(ctx context.Context, instanceType strin
 	// Ensure we have a valid CPU arch beyond this point, defaulting to X86 if necessary
 	if !cpuArchitecture.IsValid() {
 		cpuArchitecture = cpu.ArchX86
-		a.writeDebugLogs(ctx, fmt.Sprintf("defaulted to %s CPU architecture", cpuArchitecture))
+		a.writeDebugLogs(ctx, out, fmt.Sprintf("defaulted to %s CPU architecture", cpuArchitecture))
 	}
 
 	// If no instance type was requested (or if instance type  is invalid), select one based on CPU arch
 	if !validInstanceTypeRequested {
 		if instanceType != "" {
 			// Warn user that we're ignoring their invalid requested instance type
-			a.writeDebugLogs(ctx, fmt.Sprintf("ignoring requested instance type %s because it uses a non-Nitro hypervisor", instanceType))
+			a.writeDebugLogs(ctx, out, fmt.Sprintf("ignoring requested instance type %s because it uses a non-Nitro hypervisor", instanceType))
 		}
 
 		instanceType, err = cpuArchitecture.DefaultInstanceType(cloud.AWSClassic)
 		if err != nil {
 			return "", cpu.Architecture{}, fmt.Errorf("failed to determine default instance type for CPU architecture %s: %w", cpuArchitecture, err)
 		}
-		a.writeDebugLogs(ctx, fmt.Sprintf("defaulted to instance type %s", instanceType))
+		a.writeDebugLogs(ctx, out, fmt.Sprintf("defaulted to instance type %s", instanceType))
 	}
 
 	return instanceType, cpuArchitecture, nil
@@ -265,6 +342,9 @@ type createEC2InstanceInput struct {
 	ctx                 context.Context
 	keyPair             string
 	vpcID               string
+	// ipFamily, if it includes IPv6, gives the instance an IPv6 address even in subnets that don't
+	// assign one automatically
+	ipFamily ipfamily.Family
 }
 
 func (a *AwsVerifier) createEC2Instance(input createEC2InstanceInput) (string, error) {
@@ -283,8 +363,14 @@ func (a *AwsVerifier) createEC2Instance(input createEC2InstanceInput) (string, e
 		SubnetId:    awsTools.String(input.SubnetID),
 	}
 
+	if input.ipFamily.IncludesIPv6() {
+		eniSpecification.Ipv6AddressCount = awsTools.Int32(1)
+	}
+
 	if len(input.securityGroupIDs) > 0 {
-		eniSpecification.Groups = input.securityGroupIDs
+		// Copied so that appending the temporary security group never modifies the caller's slice,
+		// which may be shared with concurrent calls
+		eniSpecification.Groups = slices.Clone(input.securityGroupIDs)
 	}
 
 	if input.tempSecurityGroupID != "" {
@@ -391,13 +477,41 @@ func (a *AwsVerifier) createEC2Instance(input createEC2InstanceInput) (string, e
 	return instanceID, nil
 }
 
-func (a *AwsVerifier) findUnreachableEndpoints(ctx context.Context, instanceID string, probe probes.Probe, ensurePrivate bool) error {
+// consolePollOptions controls how findUnreachableEndpoints scrapes a probe instance's console output
+type consolePollOptions struct {
+	// nonce labels this run's probe output (see helpers.TokenWithNonce)
+	nonce string
+	// interval and timeout control how often the console output is read and for how long.
+	// Default to defaultConsolePollInterval and minConsolePollTimeout, respectively, if unset
+	interval, timeout time.Duration
+	// onProgress, if set, is called for each check reported by the probe as soon as it's read
+	// from the console output (see probes.ReportProgress)
+	onProgress func(probes.ProgressEvent)
+}
+
+// findUnreachableEndpoints waits for the probe running on instanceID to finish and stores its
+// parsed results (and any debug logs) in out
+func (a *AwsVerifier) findUnreachableEndpoints(ctx context.Context, instanceID string, probe probes.Probe, ensurePrivate bool, opts consolePollOptions, out *output.Output) error {
 	var consoleOutput string
+	// Only output labeled with this run's nonce is accepted; anything else was left on the console
+	// by a previous run
+	startingToken := helpers.TokenWithNonce(probe.GetStartingToken(), opts.nonce)
+	endingToken := helpers.TokenWithNonce(probe.GetEndingToken(), opts.nonce)
+	// Probes that print sequence-numbered output can have it reassembled across several console
+	// reads, which matters because GetConsoleOutput only returns the latest 64KB of output
+	sequencedOutput := helpers.NewSequencedOutputCollector(opts.nonce)
+
+	if opts.interval <= 0 {
+		opts.interval = defaultConsolePollInterval
+	}
+	if opts.timeout <= 0 {
+		opts.timeout = minConsolePollTimeout
+	}
 
-	a.writeDebugLogs(ctx, "Scraping console output and waiting for user data script to complete...")
+	a.writeDebugLogs(ctx, out, "Scraping console output and waiting for user data script to complete...")
 
 	// Periodically scrape console output and analyze the logs for any errors or a successful completion
-	err := helpers.PollImmediate(10*time.Second, 270*time.Second, func() (bool, error) {
+	err := helpers.PollImmediate(opts.interval, opts.timeout, func() (bool, error) {
 		b64EncodedConsoleOutput, err := a.AwsClient.GetConsoleOutput(ctx, &ec2.GetConsoleOutputInput{
 			InstanceId: awsTools.String(instanceID),
 			Latest:     awsTools.Bool(true),
@@ -413,52 +527,188 @@ func (a *AwsVerifier) findUnreachableEndpoints(ctx context.Context, instanceID s
 
 		// In the early stages, an ec2 instance may be running but the console is not populated with any data
 		if len(*b64EncodedConsoleOutput.Output) == 0 {
-			a.writeDebugLogs(ctx, "EC2 console consoleOutput not yet populated with data, continuing to wait...")
+			a.writeDebugLogs(ctx, out, "EC2 console consoleOutput not yet populated with data, continuing to wait...")
 			return false, nil
 		}
 
 		// Decode base64-encoded console output
 		consoleOutputBytes, err := base64.StdEncoding.DecodeString(*b64EncodedConsoleOutput.Output)
 		if err != nil {
-			a.writeDebugLogs(ctx, fmt.Sprintf("Error decoding console consoleOutput, will retry on next check interval: %s", err))
+			a.writeDebugLogs(ctx, out, fmt.Sprintf("Error decoding console consoleOutput, will retry on next check interval: %s", err))
 			return false, nil
 		}
 		consoleOutput = string(consoleOutputBytes)
 
+		probes.ReportProgress(probe, sequencedOutput.Collect(consoleOutput), opts.onProgress)
+		if sequencedOutput.Started() {
+			if !sequencedOutput.Ended() {
+				a.writeDebugLogs(ctx, out, "consoleOutput contains sequenced probe output, but probe has not yet finished printing it, continuing to wait...")
+				return false, nil
+			}
+			return true, a.parseSequencedProbeOutput(ctx, sequencedOutput, probe, ensurePrivate, out)
+		}
+
 		// Check for startingToken and endingToken
-		startingTokenSeen := strings.Contains(consoleOutput, probe.GetStartingToken())
-		endingTokenSeen := strings.Contains(consoleOutput, probe.GetEndingToken())
+		startingTokenSeen := strings.Contains(consoleOutput, startingToken)
+		endingTokenSeen := strings.Contains(consoleOutput, endingToken)
 		if !startingTokenSeen {
 			if endingTokenSeen {
-				a.writeDebugLogs(ctx, fmt.Sprintf("raw console logs:\n---\n%s\n---", consoleOutput))
+				a.writeDebugLogs(ctx, out, fmt.Sprintf("raw console logs:\n---\n%s\n---", consoleOutput))
 				return false, handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: endingToken encountered before startingToken"))
 			}
-			a.writeDebugLogs(ctx, "consoleOutput contains data, but probe has not yet printed startingToken, continuing to wait...")
+			a.writeDebugLogs(ctx, out, "consoleOutput contains data, but probe has not yet printed startingToken, continuing to wait...")
 			return false, nil
 		}
 		if !endingTokenSeen {
-			a.writeDebugLogs(ctx, "consoleOutput contains startingToken, but probe has not yet printed endingToken, continuing to wait...")
+			a.writeDebugLogs(ctx, out, "consoleOutput contains startingToken, but probe has not yet printed endingToken, continuing to wait...")
 			return false, nil
 		}
 
 		// If we make it this far, we know that both startingTokenSeen and endingTokenSeen are true
 
 		// Separate the probe's output from the rest of the console output (using startingToken and endingToken)
-		rawProbeOutput := strings.TrimSpace(helpers.CutBetween(consoleOutput, probe.GetStartingToken(), probe.GetEndingToken()))
+		rawProbeOutput := strings.TrimSpace(helpers.CutBetween(consoleOutput, startingToken, endingToken))
 		if len(rawProbeOutput) < 1 {
-			a.writeDebugLogs(ctx, fmt.Sprintf("raw console logs:\n---\n%s\n---", consoleOutput))
+			a.writeDebugLogs(ctx, out, fmt.Sprintf("raw console logs:\n---\n%s\n---", consoleOutput))
 			return false, handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: no data between startingToken and endingToken"))
 		}
 
 		// Send probe's output off to the Probe interface for parsing
-		a.writeDebugLogs(ctx, fmt.Sprintf("probe output:\n---\n%s\n---", rawProbeOutput))
-		probe.ParseProbeOutput(ensurePrivate, rawProbeOutput, &a.Output)
+		a.writeDebugLogs(ctx, out, fmt.Sprintf("probe output:\n---\n%s\n---", rawProbeOutput))
+		probe.ParseProbeOutput(ensurePrivate, rawProbeOutput, out)
 		return true, nil
 	})
 
+	// If the probe started printing sequenced output but didn't finish before the deadline, the
+	// lines collected so far are still parsed rather than discarded
+	if err != nil && sequencedOutput.Started() && !sequencedOutput.Ended() {
+		if parseErr := a.parseSequencedProbeOutput(ctx, sequencedOutput, probe, ensurePrivate, out); parseErr != nil {
+			a.writeDebugLogs(ctx, out, fmt.Sprintf("unable to parse unfinished probe output: %s", parseErr))
+		}
+	}
+
 	return err
 }
 
+// parseSequencedProbeOutput sends the probe output reassembled by sequencedOutput off to the Probe
+// interface for parsing into out. Any lines that couldn't be collected (e.g., because they scrolled out of
+// the console output buffer between reads, or because the probe never finished printing them) are
+// reported as an error, but the remaining lines are still parsed
+func (a *AwsVerifier) parseSequencedProbeOutput(ctx context.Context, sequencedOutput *helpers.SequencedOutputCollector, probe probes.Probe, ensurePrivate bool, out *output.Output) error {
+	rawProbeOutput := strings.TrimSpace(sequencedOutput.String())
+	if len(rawProbeOutput) < 1 {
+		return handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: no data between startingToken and endingToken"))
+	}
+	incompleteErr := sequencedOutput.MissingError()
+	if !sequencedOutput.Ended() {
+		incompleteErr = sequencedOutput.UnfinishedError()
+	}
+	if incompleteErr != nil {
+		out.AddError(handledErrors.NewGenericError(incompleteErr))
+	}
+
+	a.writeDebugLogs(ctx, out, fmt.Sprintf("probe output:\n---\n%s\n---", rawProbeOutput))
+	probe.ParseProbeOutput(ensurePrivate, rawProbeOutput, out)
+	return nil
+}
+
+// fitUserData returns unencodedUserData as-is if it fits within maxUserDataBytes. Otherwise, it
+// returns a gzip-compressed copy (which cloud-init detects and decompresses automatically) if that
+// fits, or errUserDataTooLarge if neither fits
+func fitUserData(unencodedUserData string) ([]byte, error) {
+	if len(unencodedUserData) <= maxUserDataBytes {
+		return []byte(unencodedUserData), nil
+	}
+
+	var compressed bytes.Buffer
+	gzipWriter, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gzipWriter.Write([]byte(unencodedUserData)); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	if compressed.Len() > maxUserDataBytes {
+		return nil, errUserDataTooLarge
+	}
+
+	return compressed.Bytes(), nil
+}
+
+// buildUserDataBatches expands the probe's userdata using userDataVariables and returns it
+// base64-encoded and ready to be passed to RunInstances. If the userdata doesn't fit within
+// maxUserDataBytes even after compression, the egress list (i.e., the URLS and TLSDISABLED_URLS
+// variables) is split in half repeatedly until each half fits, and one userdata string is
+// returned per batch. Each batch must be run on its own probe instance. Checks that don't depend on
+// the egress list (see withoutOneOffChecks) only run in the first batch
+func buildUserDataBatches(probe probes.Probe, userDataVariables map[string]string) ([]string, error) {
+	return buildUserDataBatchesFromURLs(
+		probe,
+		userDataVariables,
+		strings.Fields(userDataVariables["URLS"]),
+		strings.Fields(userDataVariables["TLSDISABLED_URLS"]),
+	)
+}
+
+func buildUserDataBatchesFromURLs(probe probes.Probe, userDataVariables map[string]string, urls []string, tlsDisabledURLs []string) ([]string, error) {
+	// GetExpandedUserData may modify the variables it's given, so each attempt gets its own copy
+	batchVariables := maps.Clone(userDataVariables)
+	batchVariables["URLS"] = strings.Join(urls, " ")
+	batchVariables["TLSDISABLED_URLS"] = strings.Join(tlsDisabledURLs, " ")
+
+	unencodedUserData, err := probe.GetExpandedUserData(batchVariables)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := fitUserData(unencodedUserData)
+	if err == nil {
+		return []string{base64.StdEncoding.EncodeToString(userData)}, nil
+	}
+	if !errors.Is(err, errUserDataTooLarge) {
+		return nil, err
+	}
+
+	// Still too large; split the egress list in half (keeping the two URL lists' order) and retry
+	urlCount := len(urls) + len(tlsDisabledURLs)
+	if urlCount < 2 {
+		return nil, fmt.Errorf("%w even after compression and splitting the egress list into batches; if using a CA certificate, please check its file size", errUserDataTooLarge)
+	}
+	half := urlCount / 2
+	firstURLs, secondURLs := urls, []string{}
+	firstTLSDisabledURLs, secondTLSDisabledURLs := []string{}, tlsDisabledURLs
+	if half <= len(urls) {
+		firstURLs, secondURLs = urls[:half], urls[half:]
+	} else {
+		firstTLSDisabledURLs, secondTLSDisabledURLs = tlsDisabledURLs[:half-len(urls)], tlsDisabledURLs[half-len(urls):]
+	}
+
+	firstBatches, err := buildUserDataBatchesFromURLs(probe, userDataVariables, firstURLs, firstTLSDisabledURLs)
+	if err != nil {
+		return nil, err
+	}
+	// The egress IP and large-payload transfer checks don't depend on the egress list, so only the
+	// first batch runs them; later batches get no-op commands instead (see curl.Probe)
+	secondBatches, err := buildUserDataBatchesFromURLs(probe, withoutOneOffChecks(userDataVariables), secondURLs, secondTLSDisabledURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(firstBatches, secondBatches...), nil
+}
+
+// withoutOneOffChecks returns a copy of userDataVariables that disables the checks that only need to
+// run once per subnet, rather than once per batch of egress URLs
+func withoutOneOffChecks(userDataVariables map[string]string) map[string]string {
+	batchVariables := maps.Clone(userDataVariables)
+	for _, variable := range []string{"EGRESS_IP_URL", "EGRESS_IP_CHECKS", "TRANSFER_URLS", "TRANSFER_SIZE"} {
+		delete(batchVariables, variable)
+	}
+	return batchVariables
+}
+
 func buildTags(tags map[string]string) []ec2Types.Tag {
 	tagList := make([]ec2Types.Tag, 0, len(tags))
 	for k, v := range tags {
@@ -472,40 +722,66 @@ func buildTags(tags map[string]string) []ec2Types.Tag {
 	return tagList
 }
 
-func (a *AwsVerifier) writeDebugLogs(ctx context.Context, log string) {
-	a.Output.AddDebugLogs(log)
+// writeDebugLogs stores log in out (the output of the current call, if any) and logs it at debug
+// level
+func (a *AwsVerifier) writeDebugLogs(ctx context.Context, out *output.Output, log string) {
+	if out != nil {
+		out.AddDebugLogs(log)
+	}
 	a.Logger.Debug(ctx, log)
 }
 
 // CreateSecurityGroup creates a security group with the specified name and cluster tag key in a specified VPC
 func (a *AwsVerifier) CreateSecurityGroup(ctx context.Context, tags map[string]string, name, vpcId string) (*ec2.CreateSecurityGroupOutput, error) {
+	return a.createSecurityGroup(ctx, createSecurityGroupInput{
+		tags:          tags,
+		name:          name,
+		vpcId:         vpcId,
+		ipPermissions: defaultIpPermissions,
+	})
+}
+
+// createSecurityGroupInput holds the parameters of createSecurityGroup
+type createSecurityGroupInput struct {
+	tags        map[string]string
+	name, vpcId string
+	// ipPermissions replace the security group's default allow-all egress rule
+	ipPermissions []ec2Types.IpPermission
+	// ipFamily, if it includes IPv6, makes ipPermissions also allow egress over IPv6
+	ipFamily ipfamily.Family
+}
+
+// createSecurityGroup creates a security group with the specified name and cluster tag key in a specified VPC,
+// replacing its default allow-all egress rule with input.ipPermissions. If input.ipFamily includes IPv6, its
+// egress rules also allow egress over IPv6
+func (a *AwsVerifier) createSecurityGroup(ctx context.Context, sgInput createSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
 	seq, err := helpers.RandSeq(5)
 	if err != nil {
 		return &ec2.CreateSecurityGroupOutput{}, err
 	}
 
 	input := &ec2.CreateSecurityGroupInput{
-		GroupName:   awsTools.String(name + "-" + seq),
-		VpcId:       &vpcId,
+		GroupName:   awsTools.String(sgInput.name + "-" + seq),
+		VpcId:       &sgInput.vpcId,
 		Description: awsTools.String("osd-network-verifier security group"),
 		TagSpecifications: []ec2Types.TagSpecification{
 			{
 				ResourceType: ec2Types.ResourceTypeSecurityGroup,
-				Tags:         buildTags(tags),
+				Tags:         buildTags(sgInput.tags),
 			},
 		},
 	}
-	a.writeDebugLogs(ctx, "Creating a Security group")
+	a.writeDebugLogs(ctx, nil, "Creating a Security group")
 	output, err := a.AwsClient.CreateSecurityGroup(ctx, input)
 	if err != nil {
 		return &ec2.CreateSecurityGroupOutput{}, err
 	}
 
-	a.writeDebugLogs(ctx, fmt.Sprintf("Waiting for the Security Group to exist: %s", *output.GroupId))
+	a.writeDebugLogs(ctx, nil, fmt.Sprintf("Waiting for the Security Group to exist: %s", *output.GroupId))
 	// Wait up to 1 minutes for the security group to exist
 	waiter := ec2.NewSecurityGroupExistsWaiter(a.AwsClient)
 	if err := waiter.Wait(ctx, &ec2.DescribeSecurityGroupsInput{GroupIds: []string{*output.GroupId}}, 1*time.Minute); err != nil {
-		a.writeDebugLogs(ctx, fmt.Sprintf("Error waiting for the security group to exist: %s, attempting to delete the Security Group", *output.GroupId))
+		a.writeDebugLogs(ctx, nil, fmt.Sprintf("Error waiting for the security group to exist: %s, attempting to delete the Security Group", *output.GroupId))
 		_, err := a.AwsClient.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: output.GroupId})
 		if err != nil {
 			return &ec2.CreateSecurityGroupOutput{}, handledErrors.NewGenericError(err)
@@ -517,7 +793,10 @@ func (a *AwsVerifier) CreateSecurityGroup(ctx context.Context, tags map[string]s
 
 	inputRules := &ec2.AuthorizeSecurityGroupEgressInput{
 		GroupId:       output.GroupId,
-		IpPermissions: defaultIpPermissions,
+		IpPermissions: sgInput.ipPermissions,
+	}
+	if sgInput.ipFamily.IncludesIPv6() {
+		inputRules.IpPermissions = withIPv6AnyRanges(sgInput.ipPermissions)
 	}
 
 	if _, err := a.AwsClient.AuthorizeSecurityGroupEgress(ctx, inputRules); err != nil {
@@ -544,6 +823,24 @@ func (a *AwsVerifier) CreateSecurityGroup(ctx context.Context, tags map[string]s
 		return &ec2.CreateSecurityGroupOutput{}, err
 	}
 
+	// Security groups created in VPCs with an IPv6 CIDR block also allow all egress over IPv6
+	// by default, which would hide blocked IPv6 egress. VPCs without one have no such rule
+	if sgInput.ipFamily.IncludesIPv6() {
+		revokeDefaultEgress.IpPermissions[0].IpRanges = nil
+		revokeDefaultEgress.IpPermissions[0].Ipv6Ranges = []ec2Types.Ipv6Range{
+			{
+				CidrIpv6: awsTools.String("::/0"),
+			},
+		}
+		var apiErr smithy.APIError
+		if _, err := a.AwsClient.RevokeSecurityGroupEgress(ctx, revokeDefaultEgress); err != nil {
+			if !errors.As(err, &apiErr) || apiErr.ErrorCode() != permissionNotFoundCode {
+				return &ec2.CreateSecurityGroupOutput{}, err
+			}
+			a.writeDebugLogs(ctx, nil, fmt.Sprintf("Security group %s has no default IPv6 egress rule to revoke", *output.GroupId))
+		}
+	}
+
 	return output, nil
 }
 
@@ -635,11 +932,11 @@ func ipPermissionFromURL(urlStr string, description string) (*ec2Types.IpPermiss
 // ipPermissionSetFromURLs wraps ipPermissionFromURL() with deduplication logic. I.e.,
 // for each URL string given in urlStrs, an IpPermission (with a description
 // based on the provided descriptionPrefix) will be generated and added to the
-// returned slice of IpPermissions UNLESS that slice (or defaultIpPermissions) already
+// returned slice of IpPermissions UNLESS that slice (or existingIPPermissions) already
 // contains an equivalent IpPermission (which would cause an API call using that slice
 // to be rejected by AWS). It may return an empty slice if no additional IpPermissions
-// (beyond defaultIpPermissions) are needed to allow egress to the provided urlStrs
-func ipPermissionSetFromURLs(urlStrs []string, descriptionPrefix string) ([]ec2Types.IpPermission, error) {
+// (beyond existingIPPermissions) are needed to allow egress to the provided urlStrs
+func ipPermissionSetFromURLs(urlStrs []string, descriptionPrefix string, existingIPPermissions []ec2Types.IpPermission) ([]ec2Types.IpPermission, error) {
 	// Create zero-length slice of ipPermissionSet with a capacity equal to the quantity
 	// of proxy URLs provided
 	var ipPermissionSet = make([]ec2Types.IpPermission, 0, len(urlStrs))
@@ -655,9 +952,9 @@ func ipPermissionSetFromURLs(urlStrs []string, descriptionPrefix string) ([]ec2T
 		for _, existingIPPerm := range ipPermissionSet {
 			ipPermAlreadyExists = ipPermAlreadyExists || helpers.IPPermissionsEquivalent(*ipPerm, existingIPPerm)
 		}
-		// Also check against defaultIpPermissions
-		for _, defaultIPPerm := range defaultIpPermissions {
-			ipPermAlreadyExists = ipPermAlreadyExists || helpers.IPPermissionsEquivalent(*ipPerm, defaultIPPerm)
+		// Also check against existingIPPermissions
+		for _, existingIPPerm := range existingIPPermissions {
+			ipPermAlreadyExists = ipPermAlreadyExists || helpers.IPPermissionsEquivalent(*ipPerm, existingIPPerm)
 		}
 		if !ipPermAlreadyExists {
 			ipPermissionSet = append(ipPermissionSet, *ipPerm)
@@ -671,11 +968,20 @@ func ipPermissionSetFromURLs(urlStrs []string, descriptionPrefix string) ([]ec2T
 // egress to the specified proxies. It returns nil if the necessary rules already exist
 // in defaultIpPermissions
 func (a *AwsVerifier) AllowSecurityGroupProxyEgress(ctx context.Context, securityGroupID string, proxyURLs []string) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+	return a.allowSecurityGroupProxyEgress(ctx, securityGroupID, proxyURLs, ipfamily.Family{})
+}
+
+// allowSecurityGroupProxyEgress is AllowSecurityGroupProxyEgress, except that if ipFamily
+// includes IPv6, proxies specified by hostname are also reachable over IPv6
+func (a *AwsVerifier) allowSecurityGroupProxyEgress(ctx context.Context, securityGroupID string, proxyURLs []string, ipFamily ipfamily.Family) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
 	// Generate a deduplicated set of IpPermissions from the given proxy URLs
-	ipPermissions, err := ipPermissionSetFromURLs(proxyURLs, "Egress to user-provided proxy ")
+	ipPermissions, err := ipPermissionSetFromURLs(proxyURLs, "Egress to user-provided proxy ", defaultIpPermissions)
 	if err != nil {
 		return nil, handledErrors.NewGenericError(fmt.Errorf("error occurred while authorizing egress to proxy: %w", err))
 	}
+	if ipFamily.IncludesIPv6() {
+		ipPermissions = withIPv6AnyRanges(ipPermissions)
+	}
 
 	// Make AWS call to add rule to security group
 	if len(ipPermissions) > 0 {
@@ -719,6 +1025,44 @@ func (a *AwsVerifier) GetVpcIdFromSubnetId(ctx context.Context, vpcSubnetID stri
 	return vpcId, nil
 }
 
+// subnetPlacement describes where a subnet lives
+type subnetPlacement struct {
+	subnetID, vpcID, availabilityZone string
+}
+
+// label identifies the subnet in multi-subnet results, e.g., "subnet-0123 (us-east-1a)"
+func (p subnetPlacement) label() string {
+	return fmt.Sprintf("%s (%s)", p.subnetID, p.availabilityZone)
+}
+
+// describeSubnetPlacements looks up the VPC and availability zone of each of subnetIDs in a single
+// request, returning an error if any of them can't be found
+func (a *AwsVerifier) describeSubnetPlacements(ctx context.Context, subnetIDs []string) (map[string]subnetPlacement, error) {
+	output, err := a.AwsClient.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: subnetIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	placements := make(map[string]subnetPlacement, len(subnetIDs))
+	for _, subnet := range output.Subnets {
+		placement := subnetPlacement{
+			subnetID:         awsTools.ToString(subnet.SubnetId),
+			vpcID:            awsTools.ToString(subnet.VpcId),
+			availabilityZone: awsTools.ToString(subnet.AvailabilityZone),
+		}
+		if placement.vpcID == "" {
+			return nil, fmt.Errorf("empty vpc id for the returned subnet: %s", placement.subnetID)
+		}
+		placements[placement.subnetID] = placement
+	}
+	for _, subnetID := range subnetIDs {
+		if _, ok := placements[subnetID]; !ok {
+			return nil, fmt.Errorf("no subnets returned for subnet id: %s", subnetID)
+		}
+	}
+	return placements, nil
+}
+
 // fetchVpcDefaultSecurityGroup will return either the 'default' SG ID, or an empty string if not found/an error is hit
 func (a *AwsVerifier) fetchVpcDefaultSecurityGroup(ctx context.Context, vpcId string) string {
 	describeSGOutput, err := a.AwsClient.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
@@ -1,23 +1,40 @@
 package awsverifier
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	awss "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
 	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
 	gomock "go.uber.org/mock/gomock"
 
 	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
 	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
 	"github.com/openshift/osd-network-verifier/pkg/mocks"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
 	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
 	"github.com/openshift/osd-network-verifier/pkg/probes/legacy"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
 )
 
 func TestFindUnreachableEndpointsWithCurlProbe(t *testing.T) {
@@ -87,18 +104,183 @@ func TestFindUnreachableEndpointsWithCurlProbe(t *testing.T) {
 			cli.AwsClient.SetClient(FakeEC2Cli)
 			cli.Logger = &ocmlog.GlogLogger{}
 
-			err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", curl.Probe{}, tt.ensurePrivate)
+			verifierOutput := &output.Output{}
+			err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", curl.Probe{}, tt.ensurePrivate, consolePollOptions{}, verifierOutput)
 			if err != nil {
 				t.Errorf("err should be nil when there's success in output, got: %v", err)
 			}
 
-			if tt.expectSuccess != cli.Output.IsSuccessful() {
+			if tt.expectSuccess != verifierOutput.IsSuccessful() {
 				t.Error(tt.errorMessage)
 			}
 		})
 	}
 }
 
+// TestFindUnreachableEndpointsWithSequencedOutput ensures that sequence-numbered probe output is
+// parsed even if the probe's startingToken has scrolled out of the console output buffer, and
+// that any lines missing from it are reported
+func TestFindUnreachableEndpointsWithSequencedOutput(t *testing.T) {
+	reachableLine := `@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"52.1.2.3"}`
+	unreachableLine := `@NV@{"url":"https://example.com:443","exitcode":28,"errormsg":"Connection timed out","remote_ip":""}`
+
+	tests := []struct {
+		name          string
+		nonce         string
+		consoleOutput string
+		expectFailure bool
+		expectErrors  bool
+	}{
+		{
+			name:          "complete output without startingToken",
+			consoleOutput: "@NVSEQ@1@" + reachableLine + "\n@NVSEQ@2@" + unreachableLine + "\n@NVSEQ@END@2\nNV_CURLJSON_END\n",
+			expectFailure: true,
+		},
+		{
+			name:  "stale output from a previous run ignored",
+			nonce: "abc123",
+			consoleOutput: "NV_CURLJSON_BEGIN\n@NVSEQ@1@" + unreachableLine + "\n@NVSEQ@END@1\nNV_CURLJSON_END\n" +
+				"NV_CURLJSON_BEGIN_abc123\n@NVSEQ@abc123@1@" + reachableLine + "\n@NVSEQ@abc123@END@1\nNV_CURLJSON_END_abc123\n",
+		},
+		{
+			name:          "missing lines reported",
+			consoleOutput: "NV_CURLJSON_BEGIN\n@NVSEQ@1@" + reachableLine + "\n@NVSEQ@END@3\nNV_CURLJSON_END\n",
+			expectErrors:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+
+			out := &ec2.GetConsoleOutputOutput{
+				InstanceId: awss.String("dummy-instance"),
+				Output:     awss.String(base64.StdEncoding.EncodeToString([]byte(tt.consoleOutput))),
+			}
+			FakeEC2Cli.EXPECT().GetConsoleOutput(gomock.Any(), gomock.Any()).Times(1).Return(out, nil)
+
+			cli := AwsVerifier{
+				AwsClient: &aws.Client{
+					Region: "us-west-2",
+				},
+			}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+			cli.Logger = &ocmlog.GlogLogger{}
+
+			verifierOutput := &output.Output{}
+			if err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", curl.Probe{}, false, consolePollOptions{nonce: tt.nonce}, verifierOutput); err != nil {
+				t.Errorf("err should be nil when the probe finished printing its output, got: %v", err)
+			}
+
+			if gotFailure := len(verifierOutput.GetEgressURLFailures()) > 0; gotFailure != tt.expectFailure {
+				t.Errorf("expected egress failures: %v, got: %v", tt.expectFailure, verifierOutput.GetEgressURLFailures())
+			}
+			_, _, gotErrors := verifierOutput.Parse()
+			if (len(gotErrors) > 0) != tt.expectErrors {
+				t.Errorf("expected errors: %v, got: %v", tt.expectErrors, gotErrors)
+			}
+		})
+	}
+}
+
+// TestFindUnreachableEndpointsReportsProgress ensures that each endpoint check is reported as soon
+// as it's read from the console output, before the probe has finished printing all of its output
+func TestFindUnreachableEndpointsReportsProgress(t *testing.T) {
+	reachableLine := `@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"52.1.2.3","time_total":0.5}`
+	unreachableLine := `@NV@{"url":"https://example.com:443","exitcode":28,"errormsg":"Connection timed out","remote_ip":""}`
+	consoleOutputs := []string{
+		"NV_CURLJSON_BEGIN\n@NVSEQ@1@" + reachableLine + "\n",
+		"NV_CURLJSON_BEGIN\n@NVSEQ@1@" + reachableLine + "\n@NVSEQ@2@" + unreachableLine + "\n@NVSEQ@3@@NVIP@52.1.2.3\n@NVSEQ@END@3\nNV_CURLJSON_END\n",
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+	var progress []probes.ProgressEvent
+	for i, consoleOutput := range consoleOutputs {
+		out := &ec2.GetConsoleOutputOutput{
+			InstanceId: awss.String("dummy-instance"),
+			Output:     awss.String(base64.StdEncoding.EncodeToString([]byte(consoleOutput))),
+		}
+		wantProgressBeforeRead := i
+		FakeEC2Cli.EXPECT().GetConsoleOutput(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+			func(_ context.Context, _ *ec2.GetConsoleOutputInput, _ ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error) {
+				if len(progress) != wantProgressBeforeRead {
+					t.Errorf("expected %d progress events before read %d, got %d", wantProgressBeforeRead, i+1, len(progress))
+				}
+				return out, nil
+			},
+		)
+	}
+
+	cli := AwsVerifier{
+		AwsClient: &aws.Client{
+			Region: "us-west-2",
+		},
+	}
+	cli.AwsClient.SetClient(FakeEC2Cli)
+	cli.Logger = &ocmlog.GlogLogger{}
+
+	opts := consolePollOptions{
+		interval:   time.Millisecond,
+		timeout:    time.Second,
+		onProgress: func(event probes.ProgressEvent) { progress = append(progress, event) },
+	}
+	verifierOutput := &output.Output{}
+	if err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", curl.Probe{}, false, opts, verifierOutput); err != nil {
+		t.Errorf("err should be nil when the probe finished printing its output, got: %v", err)
+	}
+
+	wantProgress := []probes.ProgressEvent{
+		{Target: "https://quay.io:443", Success: true, Detail: "0.50s"},
+		{Target: "https://example.com:443", Success: false, Detail: "Connection timed out"},
+	}
+	if !reflect.DeepEqual(progress, wantProgress) {
+		t.Errorf("expected progress events %+v, got %+v", wantProgress, progress)
+	}
+	if len(verifierOutput.GetEgressURLFailures()) != 1 {
+		t.Errorf("expected 1 egress failure, got: %v", verifierOutput.GetEgressURLFailures())
+	}
+}
+
+// TestFindUnreachableEndpointsUnfinished ensures that the output collected from a probe that didn't
+// finish before the deadline is still parsed, along with an error naming the missing lines
+func TestFindUnreachableEndpointsUnfinished(t *testing.T) {
+	unreachableLine := `@NV@{"url":"https://example.com:443","exitcode":28,"errormsg":"Connection timed out","remote_ip":""}`
+	consoleOutput := "NV_CURLJSON_BEGIN\n@NVSEQ@1@" + unreachableLine + "\n"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+	FakeEC2Cli.EXPECT().GetConsoleOutput(gomock.Any(), gomock.Any()).AnyTimes().Return(&ec2.GetConsoleOutputOutput{
+		InstanceId: awss.String("dummy-instance"),
+		Output:     awss.String(base64.StdEncoding.EncodeToString([]byte(consoleOutput))),
+	}, nil)
+
+	cli := AwsVerifier{
+		AwsClient: &aws.Client{
+			Region: "us-west-2",
+		},
+	}
+	cli.AwsClient.SetClient(FakeEC2Cli)
+	cli.Logger = &ocmlog.GlogLogger{}
+
+	opts := consolePollOptions{interval: time.Millisecond, timeout: 5 * time.Millisecond}
+	verifierOutput := &output.Output{}
+	if err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", curl.Probe{}, false, opts, verifierOutput); err == nil {
+		t.Errorf("err should not be nil when the probe didn't finish before the deadline")
+	}
+
+	if len(verifierOutput.GetEgressURLFailures()) != 1 {
+		t.Errorf("expected the collected egress failure to be reported, got: %v", verifierOutput.GetEgressURLFailures())
+	}
+	_, _, gotErrors := verifierOutput.Parse()
+	if len(gotErrors) != 1 || !strings.Contains(gotErrors[0].Error(), "probe did not finish; missing lines 2 onward") {
+		t.Errorf("expected an error naming the missing lines, got: %v", gotErrors)
+	}
+}
+
 func TestFindUnreachableEndpointsSuccessWithLegacyProbe(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -123,7 +305,8 @@ func TestFindUnreachableEndpointsSuccessWithLegacyProbe(t *testing.T) {
 	cli.AwsClient.SetClient(FakeEC2Cli)
 	cli.Logger = &ocmlog.GlogLogger{}
 
-	err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", legacy.Probe{}, false)
+	verifierOutput := &output.Output{}
+	err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", legacy.Probe{}, false, consolePollOptions{}, verifierOutput)
 	if err != nil {
 		t.Errorf("err should be nil when there's success in output, got: %v", err)
 	}
@@ -152,13 +335,14 @@ func TestFindUnreachableEndpointsNoSuccessWithLegacyProbe(t *testing.T) {
 	cli.AwsClient.SetClient(FakeEC2Cli)
 	cli.Logger = &ocmlog.GlogLogger{}
 
-	err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", legacy.Probe{}, false)
+	verifierOutput := &output.Output{}
+	err := cli.findUnreachableEndpoints(context.TODO(), "dummy-instance", legacy.Probe{}, false, consolePollOptions{}, verifierOutput)
 	if err != nil {
 		t.Errorf("Success! not found, but userdata end exists, err should be nil, got: %v", err)
 	}
 
-	if !cli.Output.IsSuccessful() {
-		t.Errorf("Success! not found, userdata end exists but no regex match for failure, it means success, got : %v", cli.Output)
+	if !verifierOutput.IsSuccessful() {
+		t.Errorf("Success! not found, userdata end exists but no regex match for failure, it means success, got : %v", verifierOutput)
 	}
 }
 
@@ -330,8 +514,9 @@ func TestIpPermissionFromURL(t *testing.T) {
 
 func Test_ipPermissionSetFromURLs(t *testing.T) {
 	type args struct {
-		urlStrs           []string
-		descriptionPrefix string
+		urlStrs               []string
+		descriptionPrefix     string
+		existingIPPermissions []ec2Types.IpPermission
 	}
 	tests := []struct {
 		name    string
@@ -453,16 +638,48 @@ func Test_ipPermissionSetFromURLs(t *testing.T) {
 		{
 			name: "domain URLs overlapping with default SG set",
 			args: args{
-				urlStrs:           []string{"http://proxy.example.org:80", "https://proxy.example.org:443"},
-				descriptionPrefix: "multi-identical test: ",
+				urlStrs:               []string{"http://proxy.example.org:80", "https://proxy.example.org:443"},
+				descriptionPrefix:     "multi-identical test: ",
+				existingIPPermissions: defaultIpPermissions,
 			},
 			want: []ec2Types.IpPermission{},
 		},
+		{
+			name: "domain URLs without existing rules",
+			args: args{
+				urlStrs:           []string{"https://quay.io:443", "telnet://inputs1.osdsecuritylogs.splunkcloud.com:9997", "https://api.openshift.com:443"},
+				descriptionPrefix: "no-existing test: ",
+			},
+			want: []ec2Types.IpPermission{
+				{
+					FromPort:   awss.Int32(443),
+					ToPort:     awss.Int32(443),
+					IpProtocol: awss.String("tcp"),
+					IpRanges: []ec2Types.IpRange{
+						{
+							CidrIp:      awss.String("0.0.0.0/0"),
+							Description: awss.String("no-existing test: https://quay.io:443"),
+						},
+					},
+				},
+				{
+					FromPort:   awss.Int32(9997),
+					ToPort:     awss.Int32(9997),
+					IpProtocol: awss.String("tcp"),
+					IpRanges: []ec2Types.IpRange{
+						{
+							CidrIp:      awss.String("0.0.0.0/0"),
+							Description: awss.String("no-existing test: telnet://inputs1.osdsecuritylogs.splunkcloud.com:9997"),
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ipPermissionSetFromURLs(tt.args.urlStrs, tt.args.descriptionPrefix)
+			got, err := ipPermissionSetFromURLs(tt.args.urlStrs, tt.args.descriptionPrefix, tt.args.existingIPPermissions)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ipPermissionSetFromURLs() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -474,6 +691,61 @@ func Test_ipPermissionSetFromURLs(t *testing.T) {
 	}
 }
 
+func Test_withIPv6AnyRanges(t *testing.T) {
+	ipPermissions := []ec2Types.IpPermission{
+		{
+			FromPort:   awss.Int32(443),
+			ToPort:     awss.Int32(443),
+			IpProtocol: awss.String("tcp"),
+			IpRanges: []ec2Types.IpRange{
+				{
+					CidrIp:      awss.String("0.0.0.0/0"),
+					Description: awss.String("Egress to user-provided proxy https://proxy.example.com:443"),
+				},
+			},
+		},
+		{
+			FromPort:   awss.Int32(3128),
+			ToPort:     awss.Int32(3128),
+			IpProtocol: awss.String("tcp"),
+			IpRanges: []ec2Types.IpRange{
+				{
+					CidrIp: awss.String("10.0.0.5/32"),
+				},
+			},
+		},
+	}
+	want := []ec2Types.IpPermission{
+		{
+			FromPort:   awss.Int32(443),
+			ToPort:     awss.Int32(443),
+			IpProtocol: awss.String("tcp"),
+			IpRanges: []ec2Types.IpRange{
+				{
+					CidrIp:      awss.String("0.0.0.0/0"),
+					Description: awss.String("Egress to user-provided proxy https://proxy.example.com:443"),
+				},
+			},
+			Ipv6Ranges: []ec2Types.Ipv6Range{
+				{
+					CidrIpv6:    awss.String("::/0"),
+					Description: awss.String("Egress to user-provided proxy https://proxy.example.com:443"),
+				},
+			},
+		},
+		ipPermissions[1],
+	}
+
+	got := withIPv6AnyRanges(ipPermissions)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("withIPv6AnyRanges() = %+v, want %+v", got, want)
+	}
+	// The original rules must be left untouched, as they may be defaultIpPermissions
+	if len(ipPermissions[0].Ipv6Ranges) != 0 {
+		t.Errorf("withIPv6AnyRanges() modified its input: %+v", ipPermissions[0])
+	}
+}
+
 // TestAwsVerifier_selectInstanceType uses a mock EC2 API client to test the logic used for selecting
 // an instance type and CPU architecture based on user inputs and programmed defaults
 func TestAwsVerifier_selectInstanceType(t *testing.T) {
@@ -591,7 +863,7 @@ func TestAwsVerifier_selectInstanceType(t *testing.T) {
 			}
 			a.AwsClient.SetClient(mockEC2Client)
 
-			gotInstanceType, gotCPUArch, err := a.selectInstanceType(context.TODO(), tt.inputInstanceType, tt.inputCPUArch)
+			gotInstanceType, gotCPUArch, err := a.selectInstanceType(context.TODO(), &output.Output{}, tt.inputInstanceType, tt.inputCPUArch)
 			if (err != nil) != tt.expectErr {
 				t.Errorf("AwsVerifier.selectInstanceType() error = %v, wantErr %v", err, tt.expectErr)
 				return
@@ -605,3 +877,500 @@ func TestAwsVerifier_selectInstanceType(t *testing.T) {
 		})
 	}
 }
+
+func TestFitUserData(t *testing.T) {
+	// Random bytes are incompressible, so hex-encoding them yields data that gzip can only
+	// compress to about half its size
+	randomBytes := make([]byte, maxUserDataBytes)
+	if _, err := rand.Read(randomBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		userData       string
+		wantCompressed bool
+		wantErr        bool
+	}{
+		{
+			name:     "small userdata returned as-is",
+			userData: "#cloud-config\nruncmd:\n  - echo hello\n",
+		},
+		{
+			name:     "userdata exactly at limit returned as-is",
+			userData: strings.Repeat("a", maxUserDataBytes),
+		},
+		{
+			name:           "large but compressible userdata compressed",
+			userData:       "#cloud-config\n" + strings.Repeat("  - echo hello\n", 2*maxUserDataBytes),
+			wantCompressed: true,
+		},
+		{
+			name:     "large incompressible userdata rejected",
+			userData: hex.EncodeToString(randomBytes),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fitUserData(tt.userData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fitUserData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, errUserDataTooLarge) {
+					t.Errorf("fitUserData() error = %v, want errUserDataTooLarge", err)
+				}
+				return
+			}
+			if len(got) > maxUserDataBytes {
+				t.Errorf("fitUserData() returned %d bytes, want at most %d", len(got), maxUserDataBytes)
+			}
+			if gotUserData := decompressUserData(t, got); gotUserData != tt.userData {
+				t.Errorf("fitUserData() content changed after decompression")
+			}
+			if gotCompressed := bytes.HasPrefix(got, []byte{0x1f, 0x8b}); gotCompressed != tt.wantCompressed {
+				t.Errorf("fitUserData() compressed = %v, want %v", gotCompressed, tt.wantCompressed)
+			}
+		})
+	}
+}
+
+func TestBuildUserDataBatches(t *testing.T) {
+	// Hostnames made of random hex are hard to compress, so a long enough egress list can't
+	// fit in a single batch even after compression
+	randomURLs := func(count int) []string {
+		urls := make([]string, 0, count)
+		randomBytes := make([]byte, 32)
+		for range count {
+			if _, err := rand.Read(randomBytes); err != nil {
+				t.Fatal(err)
+			}
+			urls = append(urls, fmt.Sprintf("https://%s.example.com:443", hex.EncodeToString(randomBytes)))
+		}
+		return urls
+	}
+
+	tests := []struct {
+		name            string
+		urls            []string
+		tlsDisabledURLs []string
+		cacert          string
+		wantMinBatches  int
+		wantMaxBatches  int
+		wantErr         bool
+	}{
+		{
+			name:           "short egress list fits in one batch",
+			urls:           []string{"https://quay.io:443", "https://example.com:443"},
+			wantMinBatches: 1,
+			wantMaxBatches: 1,
+		},
+		{
+			name:            "long egress list split into batches",
+			urls:            randomURLs(800),
+			tlsDisabledURLs: randomURLs(200),
+			wantMinBatches:  2,
+			wantMaxBatches:  16,
+		},
+		{
+			name:    "oversized CA cert can't be split",
+			urls:    []string{"https://quay.io:443", "https://example.com:443"},
+			cacert:  strings.Join(randomURLs(400), ""),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userDataVariables := map[string]string{
+				"TIMEOUT":          "1",
+				"DELAY":            "2",
+				"CACERT":           tt.cacert,
+				"URLS":             strings.Join(tt.urls, " "),
+				"TLSDISABLED_URLS": strings.Join(tt.tlsDisabledURLs, " "),
+				"EGRESS_IP_URL":    "https://ip.example.net",
+				"TRANSFER_URLS":    "https://transfer.example.net/payload",
+			}
+			got, err := buildUserDataBatches(curl.Probe{}, userDataVariables)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildUserDataBatches() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) < tt.wantMinBatches || len(got) > tt.wantMaxBatches {
+				t.Errorf("buildUserDataBatches() returned %d batches, want between %d and %d", len(got), tt.wantMinBatches, tt.wantMaxBatches)
+			}
+
+			// Every URL must appear in exactly one batch
+			wantURLs := append(append([]string{}, tt.urls...), tt.tlsDisabledURLs...)
+			gotURLCounts := make(map[string]int)
+			for i, encodedUserData := range got {
+				userData, err := base64.StdEncoding.DecodeString(encodedUserData)
+				if err != nil {
+					t.Fatalf("buildUserDataBatches() returned invalid base64: %v", err)
+				}
+				if len(userData) > maxUserDataBytes {
+					t.Errorf("buildUserDataBatches() returned a %d-byte batch, want at most %d", len(userData), maxUserDataBytes)
+				}
+				decompressedUserData := decompressUserData(t, userData)
+				// The egress IP and transfer checks only run in the first batch
+				for _, oneOffURL := range []string{"https://ip.example.net", "https://transfer.example.net/payload"} {
+					if got := strings.Contains(decompressedUserData, oneOffURL); got != (i == 0) {
+						t.Errorf("buildUserDataBatches() batch %d includes %s: %v, want %v", i+1, oneOffURL, got, i == 0)
+					}
+				}
+				for _, url := range wantURLs {
+					if strings.Contains(decompressedUserData, url) {
+						gotURLCounts[url]++
+					}
+				}
+			}
+			for _, url := range wantURLs {
+				if gotURLCounts[url] != 1 {
+					t.Errorf("buildUserDataBatches() included %s in %d batches, want 1", url, gotURLCounts[url])
+				}
+			}
+		})
+	}
+}
+
+// decompressUserData returns userData as a string, decompressing it first if it's gzipped
+func decompressUserData(t *testing.T, userData []byte) string {
+	t.Helper()
+	if !bytes.HasPrefix(userData, []byte{0x1f, 0x8b}) {
+		return string(userData)
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(userData))
+	if err != nil {
+		t.Fatalf("unable to decompress userdata: %v", err)
+	}
+	decompressed, err := io.ReadAll(gzipReader)
+	if err != nil {
+		t.Fatalf("unable to decompress userdata: %v", err)
+	}
+	return string(decompressed)
+}
+
+func TestCreateEC2InstanceAssignsIPv6Address(t *testing.T) {
+	tests := []struct {
+		name     string
+		ipFamily ipfamily.Family
+		want     *int32
+	}{
+		{
+			name: "unspecified family leaves IPv6 assignment to the subnet",
+		},
+		{
+			name:     "IPv4 leaves IPv6 assignment to the subnet",
+			ipFamily: ipfamily.IPv4,
+		},
+		{
+			name:     "IPv6 requests an IPv6 address",
+			ipFamily: ipfamily.IPv6,
+			want:     awss.Int32(1),
+		},
+		{
+			name:     "dual-stack requests an IPv6 address",
+			ipFamily: ipfamily.DualStack,
+			want:     awss.Int32(1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+
+			var got *int32
+			FakeEC2Cli.EXPECT().RunInstances(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+				func(_ context.Context, input *ec2.RunInstancesInput, _ ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+					got = input.NetworkInterfaces[0].Ipv6AddressCount
+					return nil, errors.New("stop after RunInstances")
+				},
+			)
+
+			cli := AwsVerifier{
+				AwsClient: &aws.Client{
+					Region: "us-west-2",
+				},
+			}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+			cli.Logger = &ocmlog.GlogLogger{}
+
+			if _, err := cli.createEC2Instance(createEC2InstanceInput{
+				ctx:           context.TODO(),
+				SubnetID:      "subnet-a",
+				instanceCount: 1,
+				ipFamily:      test.ipFamily,
+			}); err == nil {
+				t.Fatal("expected the RunInstances error to be returned")
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected Ipv6AddressCount %v, got %v", awss.ToInt32(test.want), awss.ToInt32(got))
+			}
+		})
+	}
+}
+
+func TestCreateSecurityGroupRevokesDefaultIPv6Egress(t *testing.T) {
+	tests := []struct {
+		name        string
+		ipFamily    ipfamily.Family
+		ipv6Err     error
+		wantRevokes int
+		wantErr     bool
+	}{
+		{
+			name:        "IPv4 only revokes the IPv4 rule",
+			ipFamily:    ipfamily.IPv4,
+			wantRevokes: 1,
+		},
+		{
+			name:        "dual-stack revokes both rules",
+			ipFamily:    ipfamily.DualStack,
+			wantRevokes: 2,
+		},
+		{
+			name:        "dual-stack tolerates a VPC without an IPv6 CIDR",
+			ipFamily:    ipfamily.DualStack,
+			ipv6Err:     &smithy.GenericAPIError{Code: permissionNotFoundCode},
+			wantRevokes: 2,
+		},
+		{
+			name:        "dual-stack fails on other revoke errors",
+			ipFamily:    ipfamily.DualStack,
+			ipv6Err:     &smithy.GenericAPIError{Code: "UnauthorizedOperation"},
+			wantRevokes: 2,
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+
+			FakeEC2Cli.EXPECT().CreateSecurityGroup(gomock.Any(), gomock.Any()).Times(1).Return(&ec2.CreateSecurityGroupOutput{GroupId: awss.String("sg-1")}, nil)
+			FakeEC2Cli.EXPECT().DescribeSecurityGroups(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []ec2Types.SecurityGroup{{GroupId: awss.String("sg-1")}}}, nil)
+			FakeEC2Cli.EXPECT().AuthorizeSecurityGroupEgress(gomock.Any(), gomock.Any()).Times(1).Return(&ec2.AuthorizeSecurityGroupEgressOutput{}, nil)
+			revokes := 0
+			FakeEC2Cli.EXPECT().RevokeSecurityGroupEgress(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(_ context.Context, input *ec2.RevokeSecurityGroupEgressInput, _ ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+					revokes++
+					if len(input.IpPermissions[0].Ipv6Ranges) > 0 {
+						return nil, test.ipv6Err
+					}
+					return &ec2.RevokeSecurityGroupEgressOutput{}, nil
+				},
+			)
+
+			cli := AwsVerifier{
+				AwsClient: &aws.Client{
+					Region: "us-west-2",
+				},
+			}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+			cli.Logger = &ocmlog.GlogLogger{}
+
+			_, err := cli.createSecurityGroup(context.TODO(), createSecurityGroupInput{
+				name:          "osd-network-verifier",
+				vpcId:         "vpc-1",
+				ipPermissions: defaultIpPermissions,
+				ipFamily:      test.ipFamily,
+			})
+			if (err != nil) != test.wantErr {
+				t.Errorf("createSecurityGroup() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if revokes != test.wantRevokes {
+				t.Errorf("expected %d revoke calls, got %d", test.wantRevokes, revokes)
+			}
+		})
+	}
+}
+
+func TestValidateEgressFromMultipleSubnets(t *testing.T) {
+	subnets := []ec2Types.Subnet{
+		{SubnetId: awss.String("subnet-a"), VpcId: awss.String("vpc-1"), AvailabilityZone: awss.String("us-east-1a")},
+		{SubnetId: awss.String("subnet-b"), VpcId: awss.String("vpc-1"), AvailabilityZone: awss.String("us-east-1b")},
+		{SubnetId: awss.String("subnet-c"), VpcId: awss.String("vpc-2"), AvailabilityZone: awss.String("us-east-1c")},
+	}
+	noncePattern := regexp.MustCompile(`NV_CURLJSON_BEGIN_([0-9a-f]+)`)
+	reachableLine := `@NV@{"url":"https://quay.io:443","exitcode":0,"errormsg":"","scheme":"HTTPS","remote_ip":"52.1.2.3"}`
+	unreachableLine := `@NV@{"url":"https://quay.io:443","exitcode":28,"errormsg":"Connection timed out","remote_ip":""}`
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+
+	var mutex sync.Mutex
+	var createdSecurityGroups, deletedSecurityGroups []string
+	consoleOutputs := map[string]string{}
+	running, maxRunning := 0, 0
+
+	FakeEC2Cli.EXPECT().DescribeSubnets(gomock.Any(), gomock.Any()).Times(1).Return(&ec2.DescribeSubnetsOutput{Subnets: subnets}, nil)
+	// Each subnet's routing, network ACL, and VPC endpoints are checked before its probe runs
+	FakeEC2Cli.EXPECT().DescribeRouteTables(gomock.Any(), gomock.Any()).Times(len(subnets)).Return(&ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{staticTestConfig().routeTable}}, nil)
+	FakeEC2Cli.EXPECT().DescribeNatGateways(gomock.Any(), gomock.Any()).Times(len(subnets)).Return(&ec2.DescribeNatGatewaysOutput{NatGateways: []ec2Types.NatGateway{staticTestConfig().natGateways["nat-1"]}}, nil)
+	FakeEC2Cli.EXPECT().DescribeNetworkAcls(gomock.Any(), gomock.Any()).Times(len(subnets)).Return(&ec2.DescribeNetworkAclsOutput{NetworkAcls: []ec2Types.NetworkAcl{staticTestConfig().networkACL}}, nil)
+	FakeEC2Cli.EXPECT().DescribeVpcEndpoints(gomock.Any(), gomock.Any()).Times(len(subnets)).Return(&ec2.DescribeVpcEndpointsOutput{}, nil)
+	FakeEC2Cli.EXPECT().CreateSecurityGroup(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, input *ec2.CreateSecurityGroupInput, _ ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			createdSecurityGroups = append(createdSecurityGroups, "sg-"+*input.VpcId)
+			return &ec2.CreateSecurityGroupOutput{GroupId: awss.String("sg-" + *input.VpcId)}, nil
+		},
+	)
+	FakeEC2Cli.EXPECT().DescribeSecurityGroups(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, input *ec2.DescribeSecurityGroupsInput, _ ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+			return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []ec2Types.SecurityGroup{{GroupId: awss.String(input.GroupIds[0])}}}, nil
+		},
+	)
+	FakeEC2Cli.EXPECT().AuthorizeSecurityGroupEgress(gomock.Any(), gomock.Any()).AnyTimes().Return(&ec2.AuthorizeSecurityGroupEgressOutput{}, nil)
+	FakeEC2Cli.EXPECT().RevokeSecurityGroupEgress(gomock.Any(), gomock.Any()).AnyTimes().Return(&ec2.RevokeSecurityGroupEgressOutput{}, nil)
+	FakeEC2Cli.EXPECT().RunInstances(gomock.Any(), gomock.Any()).Times(len(subnets)).DoAndReturn(
+		func(_ context.Context, input *ec2.RunInstancesInput, _ ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+			eni := input.NetworkInterfaces[0]
+			subnetID := *eni.SubnetId
+			wantSecurityGroup := "sg-vpc-1"
+			if subnetID == "subnet-c" {
+				wantSecurityGroup = "sg-vpc-2"
+			}
+			if !reflect.DeepEqual(eni.Groups, []string{wantSecurityGroup}) {
+				t.Errorf("instance in %s launched with security groups %v, want [%s]", subnetID, eni.Groups, wantSecurityGroup)
+			}
+
+			userData, _ := base64.StdEncoding.DecodeString(*input.UserData)
+			nonce := noncePattern.FindStringSubmatch(decompressUserData(t, userData))[1]
+			probeLine := reachableLine
+			if subnetID == "subnet-a" {
+				probeLine = unreachableLine
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			consoleOutputs["i-"+subnetID] = fmt.Sprintf("NV_CURLJSON_BEGIN_%s\n%s\nNV_CURLJSON_END_%s\n", nonce, probeLine, nonce)
+			running++
+			maxRunning = max(maxRunning, running)
+			return &ec2.RunInstancesOutput{Instances: []ec2Types.Instance{{InstanceId: awss.String("i-" + subnetID)}}}, nil
+		},
+	)
+	FakeEC2Cli.EXPECT().DescribeInstances(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(&ec2.DescribeInstancesOutput{
+		Reservations: []ec2Types.Reservation{{Instances: []ec2Types.Instance{{State: &ec2Types.InstanceState{Name: ec2Types.InstanceStateNameRunning}}}}},
+	}, nil)
+	FakeEC2Cli.EXPECT().GetConsoleOutput(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, input *ec2.GetConsoleOutputInput, _ ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			running--
+			return &ec2.GetConsoleOutputOutput{
+				InstanceId: input.InstanceId,
+				Output:     awss.String(base64.StdEncoding.EncodeToString([]byte(consoleOutputs[*input.InstanceId]))),
+			}, nil
+		},
+	)
+	FakeEC2Cli.EXPECT().DeleteSecurityGroup(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, input *ec2.DeleteSecurityGroupInput, _ ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			deletedSecurityGroups = append(deletedSecurityGroups, *input.GroupId)
+			return &ec2.DeleteSecurityGroupOutput{}, nil
+		},
+	)
+
+	cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+	cli.AwsClient.SetClient(FakeEC2Cli)
+
+	out := cli.ValidateEgress(verifier.ValidateEgressInput{
+		Ctx:                     context.TODO(),
+		SubnetIDs:               []string{"subnet-a", "subnet-b", "subnet-c"},
+		MaxParallelSubnets:      1,
+		CloudImageID:            "ami-123",
+		EgressListYaml:          "endpoints:\n  - host: quay.io\n    ports:\n      - 443\n",
+		SkipInstanceTermination: true,
+		PollInterval:            time.Millisecond,
+		PollTimeout:             time.Second,
+	})
+
+	slices.Sort(createdSecurityGroups)
+	if want := []string{"sg-vpc-1", "sg-vpc-2"}; !reflect.DeepEqual(createdSecurityGroups, want) {
+		t.Errorf("created security groups %v, want one per VPC %v", createdSecurityGroups, want)
+	}
+	slices.Sort(deletedSecurityGroups)
+	if !reflect.DeepEqual(deletedSecurityGroups, createdSecurityGroups) {
+		t.Errorf("deleted security groups %v, want %v", deletedSecurityGroups, createdSecurityGroups)
+	}
+	if maxRunning != 1 {
+		t.Errorf("up to %d subnets verified at once, want 1", maxRunning)
+	}
+
+	var gotLabels []string
+	for _, subnetResult := range out.GetSubnetResults() {
+		gotLabels = append(gotLabels, subnetResult.Label)
+	}
+	if want := []string{"subnet-a (us-east-1a)", "subnet-b (us-east-1b)", "subnet-c (us-east-1c)"}; !reflect.DeepEqual(gotLabels, want) {
+		t.Errorf("subnet results labeled %v, want %v", gotLabels, want)
+	}
+	if out.IsSuccessful() {
+		t.Error("expected output to be unsuccessful when one subnet can't reach an endpoint")
+	}
+	matrix := out.GetSubnetEgressMatrix()
+	if len(matrix) != 1 || !matrix[0].Diverges() {
+		t.Errorf("expected a single diverging matrix row, got %+v", matrix)
+	}
+}
+
+// TestValidateEgressConcurrentCalls ensures that concurrent calls to the same AwsVerifier each get
+// their own results (run with -race to also catch data races, e.g., in the instance type cache)
+func TestValidateEgressConcurrentCalls(t *testing.T) {
+	instanceTypes := []string{"m5.large", "m6g.large"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+	FakeEC2Cli.EXPECT().DescribeInstanceTypes(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, input *ec2.DescribeInstanceTypesInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+			architecture := ec2Types.ArchitectureTypeX8664
+			if input.InstanceTypes[0] == "m6g.large" {
+				architecture = ec2Types.ArchitectureTypeArm64
+			}
+			return &ec2.DescribeInstanceTypesOutput{InstanceTypes: []ec2Types.InstanceTypeInfo{{
+				InstanceType:  input.InstanceTypes[0],
+				Hypervisor:    ec2Types.InstanceTypeHypervisorNitro,
+				ProcessorInfo: &ec2Types.ProcessorInfo{SupportedArchitectures: []ec2Types.ArchitectureType{architecture}},
+			}}}, nil
+		},
+	)
+
+	cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+	cli.AwsClient.SetClient(FakeEC2Cli)
+
+	const calls = 8
+	outputs := make([]*output.Output, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each call fails once its instance type has been validated, as its egress list is invalid
+			outputs[i] = cli.ValidateEgress(verifier.ValidateEgressInput{
+				Ctx:            context.TODO(),
+				SubnetID:       "subnet-a",
+				CloudImageID:   "ami-123",
+				InstanceType:   instanceTypes[i%len(instanceTypes)],
+				EgressListYaml: fmt.Sprintf("not an egress list %d", i),
+			})
+		}()
+	}
+	wg.Wait()
+
+	for i, out := range outputs {
+		_, _, errs := out.Parse()
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unmarshal") {
+			t.Errorf("ValidateEgress() call %d errors = %v, want a single error about its egress list", i, errs)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package awsverifier
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	awsTools "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+	resolverTypes "github.com/aws/aws-sdk-go-v2/service/route53resolver/types"
+
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// clusterDnsDomains are the domains clusters must resolve exactly as public DNS (or, for VPC
+// endpoints with private DNS, Route 53) does
+var clusterDnsDomains = []string{"amazonaws.com", "openshift.com"}
+
+// amazonProvidedDnsServers are the addresses of the Route 53 Resolver reachable from every VPC, in
+// addition to the VPC's base address plus two
+var amazonProvidedDnsServers = []string{"AmazonProvidedDNS", "169.254.169.253", "fd00:ec2::253"}
+
+// dhcpOptionsDomainNameServers is the DHCP options set key holding the DNS servers handed to instances
+const dhcpOptionsDomainNameServers = "domain-name-servers"
+
+// resolveDnsVpc returns the ID of the VPC whose DNS configuration vdi asks to verify, i.e.,
+// vdi.VpcID or, if only vdi.SubnetID is set, the VPC of that subnet
+func (a *AwsVerifier) resolveDnsVpc(vdi verifier.VerifyDnsInput) (string, error) {
+	if vdi.SubnetID == "" {
+		return vdi.VpcID, nil
+	}
+
+	subnetVpcID, err := a.GetVpcIdFromSubnetId(vdi.Ctx, vdi.SubnetID)
+	if err != nil {
+		return "", err
+	}
+	if vdi.VpcID != "" && vdi.VpcID != subnetVpcID {
+		return "", fmt.Errorf("subnet %s belongs to VPC %s rather than VPC %s", vdi.SubnetID, subnetVpcID, vdi.VpcID)
+	}
+	return subnetVpcID, nil
+}
+
+// checkDhcpOptions inspects the DNS servers the DHCP options set of vpcID hands to instances.
+// Custom DNS servers must be reachable: if subnetID is set, the route table effective for it must
+// route to each of them, otherwise only servers within the VPC are known to be reachable. Problems
+// are stored in out as DNS findings (see handledErrors.DnsFinding)
+func (a *AwsVerifier) checkDhcpOptions(ctx context.Context, vpcID string, subnetID string, out *output.Output) {
+	vpcsOutput, err := a.AwsClient.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{vpcID}})
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to check the DHCP options of %s: %w", vpcID, err))
+		return
+	}
+	if len(vpcsOutput.Vpcs) == 0 {
+		out.AddWarning(fmt.Errorf("unable to check the DHCP options of %s: VPC not found", vpcID))
+		return
+	}
+	vpc := vpcsOutput.Vpcs[0]
+
+	dhcpOptionsID := awsTools.ToString(vpc.DhcpOptionsId)
+	if dhcpOptionsID == "" || dhcpOptionsID == "default" {
+		out.AddInfo(fmt.Sprintf("%s uses the default DHCP options (AmazonProvidedDNS)", vpcID))
+		return
+	}
+	dhcpOptionsOutput, err := a.AwsClient.DescribeDhcpOptions(ctx, &ec2.DescribeDhcpOptionsInput{DhcpOptionsIds: []string{dhcpOptionsID}})
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to check DHCP options set %s of %s: %w", dhcpOptionsID, vpcID, err))
+		return
+	}
+	var dnsServers []string
+	for _, dhcpOptions := range dhcpOptionsOutput.DhcpOptions {
+		for _, configuration := range dhcpOptions.DhcpConfigurations {
+			if awsTools.ToString(configuration.Key) != dhcpOptionsDomainNameServers {
+				continue
+			}
+			for _, value := range configuration.Values {
+				dnsServers = append(dnsServers, awsTools.ToString(value.Value))
+			}
+		}
+	}
+	if len(dnsServers) == 0 {
+		out.AddWarning(handledErrors.NewDnsFinding(dhcpOptionsID, fmt.Sprintf("DHCP options set %s of %s has no %s, so instances get no DNS servers", dhcpOptionsID, vpcID, dhcpOptionsDomainNameServers)))
+		return
+	}
+
+	var vpcPrefixes []netip.Prefix
+	for _, association := range vpc.CidrBlockAssociationSet {
+		if prefix, err := netip.ParsePrefix(awsTools.ToString(association.CidrBlock)); err == nil {
+			vpcPrefixes = append(vpcPrefixes, prefix)
+		}
+	}
+	amazonDnsServers := slices.Clone(amazonProvidedDnsServers)
+	if vpcPrefix, err := netip.ParsePrefix(awsTools.ToString(vpc.CidrBlock)); err == nil {
+		amazonDnsServers = append(amazonDnsServers, vpcPrefix.Masked().Addr().Next().Next().String())
+	}
+
+	var customDnsServers []netip.Addr
+	for _, dnsServer := range dnsServers {
+		if slices.Contains(amazonDnsServers, dnsServer) {
+			continue
+		}
+		addr, err := netip.ParseAddr(dnsServer)
+		if err != nil {
+			out.AddWarning(handledErrors.NewDnsFinding(dhcpOptionsID, fmt.Sprintf("DHCP options set %s of %s lists %q as a DNS server, which isn't an IP address", dhcpOptionsID, vpcID, dnsServer)))
+			continue
+		}
+		customDnsServers = append(customDnsServers, addr)
+	}
+	if len(customDnsServers) == 0 {
+		out.AddInfo(fmt.Sprintf("DHCP options set %s of %s uses AmazonProvidedDNS", dhcpOptionsID, vpcID))
+		return
+	}
+	out.AddInfo(fmt.Sprintf("DHCP options set %s of %s uses custom DNS servers %s, which must resolve %s like public DNS does", dhcpOptionsID, vpcID, strings.Join(dnsServers, ", "), strings.Join(clusterDnsDomains, " and ")))
+
+	var routeTable ec2Types.RouteTable
+	if subnetID != "" {
+		routeTable, _, err = a.describeEffectiveRouteTable(ctx, subnetID, vpcID)
+		if err != nil {
+			out.AddWarning(fmt.Errorf("unable to check whether the DNS servers of %s are reachable from %s: %w", vpcID, subnetID, err))
+			return
+		}
+	}
+	routeTableID := awsTools.ToString(routeTable.RouteTableId)
+	for _, dnsServer := range customDnsServers {
+		if subnetID == "" {
+			inVpc := slices.ContainsFunc(vpcPrefixes, func(prefix netip.Prefix) bool { return prefix.Contains(dnsServer) })
+			if !inVpc {
+				out.AddInfo(fmt.Sprintf("DNS server %s is outside of %s; pass a subnet to check whether it's routed", dnsServer, vpcID))
+			}
+			continue
+		}
+
+		route, ok := effectiveRoute(routeTable, netip.PrefixFrom(dnsServer, dnsServer.BitLen()))
+		switch {
+		case !ok:
+			out.AddWarning(handledErrors.NewDnsFinding(dhcpOptionsID, fmt.Sprintf("DHCP options set %s of %s points at DNS server %s, but route table %s of %s has no route to it", dhcpOptionsID, vpcID, dnsServer, routeTableID, subnetID)))
+		case route.State == ec2Types.RouteStateBlackhole:
+			out.AddWarning(handledErrors.NewDnsFinding(dhcpOptionsID, fmt.Sprintf("DHCP options set %s of %s points at DNS server %s, but route table %s of %s routes it to %s, which no longer exists (blackhole)", dhcpOptionsID, vpcID, dnsServer, routeTableID, subnetID, routeTarget(route))))
+		default:
+			out.AddInfo(fmt.Sprintf("route table %s of %s routes DNS server %s to %s", routeTableID, subnetID, dnsServer, routeTarget(route)))
+		}
+	}
+}
+
+// checkResolverRules reports Route 53 Resolver forwarding rules associated with vpcID that send
+// queries for any of clusterDnsDomains (or their subdomains) to other resolvers, as DNS findings
+// (see handledErrors.DnsFinding)
+func (a *AwsVerifier) checkResolverRules(ctx context.Context, vpcID string, out *output.Output) {
+	rules, err := a.describeAssociatedResolverRules(ctx, vpcID)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to check the Route 53 Resolver rules of %s: %w", vpcID, err))
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.RuleType != resolverTypes.RuleTypeOptionForward {
+			continue
+		}
+		ruleDomain := normalizeDnsName(awsTools.ToString(rule.DomainName))
+		var hijacked []string
+		for _, domain := range clusterDnsDomains {
+			switch {
+			case dnsNameWithin(ruleDomain, domain):
+				hijacked = append(hijacked, ruleDomain)
+			case dnsNameWithin(domain, ruleDomain) && !resolverRuleOverridden(rules, ruleDomain, domain):
+				hijacked = append(hijacked, domain)
+			}
+		}
+		if len(hijacked) == 0 {
+			continue
+		}
+
+		var targets []string
+		for _, target := range rule.TargetIps {
+			address := awsTools.ToString(target.Ip)
+			if address == "" {
+				address = fmt.Sprintf("[%s]", awsTools.ToString(target.Ipv6))
+			}
+			targets = append(targets, fmt.Sprintf("%s:%d", address, awsTools.ToInt32(target.Port)))
+		}
+		ruleID := awsTools.ToString(rule.Id)
+		ruleLabel := ruleID
+		if name := awsTools.ToString(rule.Name); name != "" {
+			ruleLabel += fmt.Sprintf(" (%s)", name)
+		}
+		out.AddWarning(handledErrors.NewDnsFinding(ruleID, fmt.Sprintf("Route 53 Resolver rule %s associated with %s forwards queries for %s to %s, which must resolve them like public DNS does", ruleLabel, vpcID, strings.Join(hijacked, " and "), strings.Join(targets, ", "))))
+	}
+}
+
+// describeAssociatedResolverRules returns the Route 53 Resolver rules associated with vpcID
+func (a *AwsVerifier) describeAssociatedResolverRules(ctx context.Context, vpcID string) ([]resolverTypes.ResolverRule, error) {
+	var ruleIDs []string
+	associationsInput := &route53resolver.ListResolverRuleAssociationsInput{
+		Filters: []resolverTypes.Filter{
+			{
+				Name:   awsTools.String("VPCId"),
+				Values: []string{vpcID},
+			},
+		},
+	}
+	for {
+		associationsOutput, err := a.AwsClient.ListResolverRuleAssociations(ctx, associationsInput)
+		if err != nil {
+			return nil, err
+		}
+		for _, association := range associationsOutput.ResolverRuleAssociations {
+			ruleIDs = append(ruleIDs, awsTools.ToString(association.ResolverRuleId))
+		}
+		if associationsOutput.NextToken == nil {
+			break
+		}
+		associationsInput.NextToken = associationsOutput.NextToken
+	}
+	if len(ruleIDs) == 0 {
+		return nil, nil
+	}
+
+	var rules []resolverTypes.ResolverRule
+	rulesInput := &route53resolver.ListResolverRulesInput{}
+	for {
+		rulesOutput, err := a.AwsClient.ListResolverRules(ctx, rulesInput)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rulesOutput.ResolverRules {
+			if slices.Contains(ruleIDs, awsTools.ToString(rule.Id)) {
+				rules = append(rules, rule)
+			}
+		}
+		if rulesOutput.NextToken == nil {
+			break
+		}
+		rulesInput.NextToken = rulesOutput.NextToken
+	}
+	return rules, nil
+}
+
+// resolverRuleOverridden returns true if rules include a system rule more specific than
+// ruleDomain that still covers domain, i.e., one that makes the Route 53 Resolver answer queries
+// for domain itself instead of following a forwarding rule for ruleDomain
+func resolverRuleOverridden(rules []resolverTypes.ResolverRule, ruleDomain string, domain string) bool {
+	for _, rule := range rules {
+		systemDomain := normalizeDnsName(awsTools.ToString(rule.DomainName))
+		if rule.RuleType == resolverTypes.RuleTypeOptionSystem && dnsNameWithin(domain, systemDomain) && len(systemDomain) > len(ruleDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPrivateHostedZones reports the private hosted zones associated with vpcID, since they
+// shadow any public records for the same names. Zones shadowing any of clusterDnsDomains are
+// reported as DNS findings (see handledErrors.DnsFinding), unless managed by an AWS service (e.g.,
+// those of VPC endpoints with private DNS)
+func (a *AwsVerifier) checkPrivateHostedZones(ctx context.Context, vpcID string, out *output.Output) {
+	var zones []route53Types.HostedZoneSummary
+	zonesInput := &route53.ListHostedZonesByVPCInput{
+		VPCId:     awsTools.String(vpcID),
+		VPCRegion: route53Types.VPCRegion(a.AwsClient.Region),
+	}
+	for {
+		zonesOutput, err := a.AwsClient.ListHostedZonesByVPC(ctx, zonesInput)
+		if err != nil {
+			out.AddWarning(fmt.Errorf("unable to check the private hosted zones of %s: %w", vpcID, err))
+			return
+		}
+		zones = append(zones, zonesOutput.HostedZoneSummaries...)
+		if zonesOutput.NextToken == nil {
+			break
+		}
+		zonesInput.NextToken = zonesOutput.NextToken
+	}
+
+	for _, zone := range zones {
+		zoneID := awsTools.ToString(zone.HostedZoneId)
+		zoneName := normalizeDnsName(awsTools.ToString(zone.Name))
+		if zone.Owner != nil && awsTools.ToString(zone.Owner.OwningService) != "" {
+			out.AddInfo(fmt.Sprintf("private hosted zone %s (%s) associated with %s is managed by %s", zoneID, zoneName, vpcID, awsTools.ToString(zone.Owner.OwningService)))
+			continue
+		}
+
+		var shadowed []string
+		for _, domain := range clusterDnsDomains {
+			if dnsNameWithin(zoneName, domain) || dnsNameWithin(domain, zoneName) {
+				shadowed = append(shadowed, domain)
+			}
+		}
+		if len(shadowed) > 0 {
+			out.AddWarning(handledErrors.NewDnsFinding(zoneID, fmt.Sprintf("private hosted zone %s (%s) associated with %s shadows public records for %s, so names missing from the zone won't resolve", zoneID, zoneName, vpcID, strings.Join(shadowed, " and "))))
+			continue
+		}
+		out.AddInfo(fmt.Sprintf("private hosted zone %s (%s) associated with %s shadows any public records for the same names", zoneID, zoneName, vpcID))
+	}
+}
+
+// normalizeDnsName returns name in lowercase and without its trailing dot, with the root domain
+// (".") as an empty string
+func normalizeDnsName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// dnsNameWithin returns true if name (normalized by normalizeDnsName) equals or is a subdomain of
+// domain (also normalized)
+func dnsNameWithin(name string, domain string) bool {
+	return domain == "" || name == domain || strings.HasSuffix(name, "."+domain)
+}
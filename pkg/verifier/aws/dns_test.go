@@ -0,0 +1,318 @@
+package awsverifier
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	awss "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53resolver"
+	resolverTypes "github.com/aws/aws-sdk-go-v2/service/route53resolver/types"
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/mocks"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// dnsFindings returns the DNS findings stored in out, failing t on any other warning
+func dnsFindings(t *testing.T, out *output.Output) []string {
+	var findings []string
+	for _, warning := range out.GetWarnings() {
+		finding, ok := warning.(*handledErrors.DnsFinding)
+		if !ok {
+			t.Fatalf("unexpected warning: %v", warning)
+		}
+		findings = append(findings, finding.Error())
+	}
+	return findings
+}
+
+func TestAwsVerifier_checkDhcpOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		dhcpOptionID string
+		dnsServers   []string
+		subnetID     string
+		wantInfo     []string
+		wantFindings []string
+	}{
+		{
+			name:         "default DHCP options",
+			dhcpOptionID: "default",
+			wantInfo:     []string{"vpc-1 uses the default DHCP options (AmazonProvidedDNS)"},
+		},
+		{
+			name:         "Amazon-provided DNS servers",
+			dhcpOptionID: "dopt-1",
+			dnsServers:   []string{"AmazonProvidedDNS", "10.0.0.2"},
+			wantInfo:     []string{"DHCP options set dopt-1 of vpc-1 uses AmazonProvidedDNS"},
+		},
+		{
+			name:         "no DNS servers",
+			dhcpOptionID: "dopt-1",
+			wantFindings: []string{"DNS finding: DHCP options set dopt-1 of vpc-1 has no domain-name-servers, so instances get no DNS servers"},
+		},
+		{
+			name:         "custom DNS servers without a subnet",
+			dhcpOptionID: "dopt-1",
+			dnsServers:   []string{"10.0.5.10", "192.168.1.53"},
+			wantInfo: []string{
+				"DHCP options set dopt-1 of vpc-1 uses custom DNS servers 10.0.5.10, 192.168.1.53, which must resolve amazonaws.com and openshift.com like public DNS does",
+				"DNS server 192.168.1.53 is outside of vpc-1; pass a subnet to check whether it's routed",
+			},
+		},
+		{
+			name:         "custom DNS servers routed from a subnet",
+			dhcpOptionID: "dopt-1",
+			dnsServers:   []string{"10.0.5.10", "192.168.1.53", "172.16.0.53", "resolver.example.com"},
+			subnetID:     "subnet-a",
+			wantInfo: []string{
+				"DHCP options set dopt-1 of vpc-1 uses custom DNS servers 10.0.5.10, 192.168.1.53, 172.16.0.53, resolver.example.com, which must resolve amazonaws.com and openshift.com like public DNS does",
+				"route table rtb-1 of subnet-a routes DNS server 10.0.5.10 to the VPC (local)",
+				"route table rtb-1 of subnet-a routes DNS server 192.168.1.53 to NAT gateway nat-1",
+			},
+			wantFindings: []string{
+				"DNS finding: DHCP options set dopt-1 of vpc-1 lists \"resolver.example.com\" as a DNS server, which isn't an IP address",
+				"DNS finding: DHCP options set dopt-1 of vpc-1 points at DNS server 172.16.0.53, but route table rtb-1 of subnet-a routes it to transit gateway tgw-1, which no longer exists (blackhole)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routeTable := staticTestConfig().routeTable
+			routeTable.Routes = append(routeTable.Routes, ec2Types.Route{DestinationCidrBlock: awss.String("172.16.0.0/12"), TransitGatewayId: awss.String("tgw-1"), State: ec2Types.RouteStateBlackhole})
+			var values []ec2Types.AttributeValue
+			for _, dnsServer := range test.dnsServers {
+				values = append(values, ec2Types.AttributeValue{Value: awss.String(dnsServer)})
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+			FakeEC2Cli.EXPECT().DescribeVpcs(gomock.Any(), gomock.Any()).Return(&ec2.DescribeVpcsOutput{
+				Vpcs: []ec2Types.Vpc{
+					{
+						VpcId:                   awss.String("vpc-1"),
+						DhcpOptionsId:           awss.String(test.dhcpOptionID),
+						CidrBlock:               awss.String("10.0.0.0/16"),
+						CidrBlockAssociationSet: []ec2Types.VpcCidrBlockAssociation{{CidrBlock: awss.String("10.0.0.0/16")}},
+					},
+				},
+			}, nil)
+			FakeEC2Cli.EXPECT().DescribeDhcpOptions(gomock.Any(), gomock.Any()).Return(&ec2.DescribeDhcpOptionsOutput{
+				DhcpOptions: []ec2Types.DhcpOptions{
+					{
+						DhcpOptionsId: awss.String(test.dhcpOptionID),
+						DhcpConfigurations: []ec2Types.DhcpConfiguration{
+							{Key: awss.String("domain-name"), Values: []ec2Types.AttributeValue{{Value: awss.String("ec2.internal")}}},
+							{Key: awss.String("domain-name-servers"), Values: values},
+						},
+					},
+				},
+			}, nil).AnyTimes()
+			FakeEC2Cli.EXPECT().DescribeRouteTables(gomock.Any(), gomock.Any()).Return(&ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{routeTable}}, nil).AnyTimes()
+
+			cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+
+			out := &output.Output{}
+			cli.checkDhcpOptions(context.TODO(), "vpc-1", test.subnetID, out)
+
+			if !reflect.DeepEqual(out.GetInfo(), test.wantInfo) {
+				t.Errorf("checkDhcpOptions() info = %q, want %q", out.GetInfo(), test.wantInfo)
+			}
+			if got := dnsFindings(t, out); !reflect.DeepEqual(got, test.wantFindings) {
+				t.Errorf("checkDhcpOptions() findings = %q, want %q", got, test.wantFindings)
+			}
+		})
+	}
+}
+
+func TestAwsVerifier_checkResolverRules(t *testing.T) {
+	forwardRule := func(id string, domain string) resolverTypes.ResolverRule {
+		return resolverTypes.ResolverRule{
+			Id:         awss.String(id),
+			DomainName: awss.String(domain),
+			RuleType:   resolverTypes.RuleTypeOptionForward,
+			TargetIps:  []resolverTypes.TargetAddress{{Ip: awss.String("192.168.1.53"), Port: awss.Int32(53)}},
+		}
+	}
+	systemRule := func(id string, domain string) resolverTypes.ResolverRule {
+		return resolverTypes.ResolverRule{Id: awss.String(id), DomainName: awss.String(domain), RuleType: resolverTypes.RuleTypeOptionSystem}
+	}
+
+	tests := []struct {
+		name         string
+		rules        []resolverTypes.ResolverRule
+		associated   []string
+		wantFindings []string
+	}{
+		{
+			name:       "unrelated and unassociated rules",
+			rules:      []resolverTypes.ResolverRule{forwardRule("rslvr-rr-1", "corp.example.com."), forwardRule("rslvr-rr-2", "amazonaws.com.")},
+			associated: []string{"rslvr-rr-1"},
+		},
+		{
+			name:       "forwarding rules for cluster domains",
+			rules:      []resolverTypes.ResolverRule{forwardRule("rslvr-rr-1", "s3.us-east-1.amazonaws.com."), forwardRule("rslvr-rr-2", "openshift.com.")},
+			associated: []string{"rslvr-rr-1", "rslvr-rr-2"},
+			wantFindings: []string{
+				"DNS finding: Route 53 Resolver rule rslvr-rr-1 associated with vpc-1 forwards queries for s3.us-east-1.amazonaws.com to 192.168.1.53:53, which must resolve them like public DNS does",
+				"DNS finding: Route 53 Resolver rule rslvr-rr-2 associated with vpc-1 forwards queries for openshift.com to 192.168.1.53:53, which must resolve them like public DNS does",
+			},
+		},
+		{
+			name: "forwarding everything except amazonaws.com",
+			rules: func() []resolverTypes.ResolverRule {
+				root := forwardRule("rslvr-rr-1", ".")
+				root.Name = awss.String("on-prem")
+				return []resolverTypes.ResolverRule{root, systemRule("rslvr-rr-2", "amazonaws.com.")}
+			}(),
+			associated: []string{"rslvr-rr-1", "rslvr-rr-2"},
+			wantFindings: []string{
+				"DNS finding: Route 53 Resolver rule rslvr-rr-1 (on-prem) associated with vpc-1 forwards queries for openshift.com to 192.168.1.53:53, which must resolve them like public DNS does",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var associations []resolverTypes.ResolverRuleAssociation
+			for _, ruleID := range test.associated {
+				associations = append(associations, resolverTypes.ResolverRuleAssociation{ResolverRuleId: awss.String(ruleID), VPCId: awss.String("vpc-1")})
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeResolverCli := mocks.NewMockRoute53ResolverClient(ctrl)
+			FakeResolverCli.EXPECT().ListResolverRuleAssociations(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, input *route53resolver.ListResolverRuleAssociationsInput, _ ...func(*route53resolver.Options)) (*route53resolver.ListResolverRuleAssociationsOutput, error) {
+					if *input.Filters[0].Name != "VPCId" || !reflect.DeepEqual(input.Filters[0].Values, []string{"vpc-1"}) {
+						t.Errorf("ListResolverRuleAssociations() called with unexpected filter: %+v", input.Filters[0])
+					}
+					return &route53resolver.ListResolverRuleAssociationsOutput{ResolverRuleAssociations: associations}, nil
+				},
+			)
+			// Rules are listed in two pages
+			FakeResolverCli.EXPECT().ListResolverRules(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, input *route53resolver.ListResolverRulesInput, _ ...func(*route53resolver.Options)) (*route53resolver.ListResolverRulesOutput, error) {
+					if input.NextToken == nil {
+						return &route53resolver.ListResolverRulesOutput{ResolverRules: test.rules[:1], NextToken: awss.String("page-2")}, nil
+					}
+					return &route53resolver.ListResolverRulesOutput{ResolverRules: test.rules[1:]}, nil
+				},
+			).Times(2)
+
+			cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+			cli.AwsClient.SetRoute53ResolverClient(FakeResolverCli)
+
+			out := &output.Output{}
+			cli.checkResolverRules(context.TODO(), "vpc-1", out)
+
+			if got := dnsFindings(t, out); !reflect.DeepEqual(got, test.wantFindings) {
+				t.Errorf("checkResolverRules() findings = %q, want %q", got, test.wantFindings)
+			}
+		})
+	}
+}
+
+func TestAwsVerifier_checkPrivateHostedZones(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	FakeRoute53Cli := mocks.NewMockRoute53Client(ctrl)
+	FakeRoute53Cli.EXPECT().ListHostedZonesByVPC(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, input *route53.ListHostedZonesByVPCInput, _ ...func(*route53.Options)) (*route53.ListHostedZonesByVPCOutput, error) {
+			if *input.VPCId != "vpc-1" || input.VPCRegion != route53Types.VPCRegionUsEast1 {
+				t.Errorf("ListHostedZonesByVPC() called with unexpected VPC: %s (%s)", *input.VPCId, input.VPCRegion)
+			}
+			return &route53.ListHostedZonesByVPCOutput{
+				HostedZoneSummaries: []route53Types.HostedZoneSummary{
+					{HostedZoneId: awss.String("Z1"), Name: awss.String("corp.example.com.")},
+					{HostedZoneId: awss.String("Z2"), Name: awss.String("api.openshift.com.")},
+					{HostedZoneId: awss.String("Z3"), Name: awss.String("sts.us-east-1.amazonaws.com."), Owner: &route53Types.HostedZoneOwner{OwningService: awss.String("vpce.amazonaws.com")}},
+				},
+			}, nil
+		},
+	)
+
+	cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+	cli.AwsClient.SetRoute53Client(FakeRoute53Cli)
+
+	out := &output.Output{}
+	cli.checkPrivateHostedZones(context.TODO(), "vpc-1", out)
+
+	wantInfo := []string{
+		"private hosted zone Z1 (corp.example.com) associated with vpc-1 shadows any public records for the same names",
+		"private hosted zone Z3 (sts.us-east-1.amazonaws.com) associated with vpc-1 is managed by vpce.amazonaws.com",
+	}
+	if !reflect.DeepEqual(out.GetInfo(), wantInfo) {
+		t.Errorf("checkPrivateHostedZones() info = %q, want %q", out.GetInfo(), wantInfo)
+	}
+	wantFindings := []string{
+		"DNS finding: private hosted zone Z2 (api.openshift.com) associated with vpc-1 shadows public records for openshift.com, so names missing from the zone won't resolve",
+	}
+	if got := dnsFindings(t, out); !reflect.DeepEqual(got, wantFindings) {
+		t.Errorf("checkPrivateHostedZones() findings = %q, want %q", got, wantFindings)
+	}
+}
+
+// TestAwsVerifier_checkRoute53_UnsetClients ensures Route 53 checks warn rather than panic when the
+// AWS client was built with only an EC2 client (e.g., via SetClient)
+func TestAwsVerifier_checkRoute53_UnsetClients(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+	cli.AwsClient.SetClient(mocks.NewMockEC2Client(ctrl))
+
+	out := &output.Output{}
+	cli.checkResolverRules(context.TODO(), "vpc-1", out)
+	cli.checkPrivateHostedZones(context.TODO(), "vpc-1", out)
+
+	if got := len(out.GetWarnings()); got != 2 {
+		t.Errorf("checkResolverRules() and checkPrivateHostedZones() warnings = %v, want 2", out.GetWarnings())
+	}
+}
+
+func TestAwsVerifier_resolveDnsVpc(t *testing.T) {
+	tests := []struct {
+		name     string
+		vpcID    string
+		subnetID string
+		want     string
+		wantErr  bool
+	}{
+		{name: "VPC only", vpcID: "vpc-1", want: "vpc-1"},
+		{name: "subnet only", subnetID: "subnet-a", want: "vpc-1"},
+		{name: "matching subnet and VPC", vpcID: "vpc-1", subnetID: "subnet-a", want: "vpc-1"},
+		{name: "subnet of another VPC", vpcID: "vpc-2", subnetID: "subnet-a", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+			FakeEC2Cli.EXPECT().DescribeSubnets(gomock.Any(), gomock.Any()).Return(&ec2.DescribeSubnetsOutput{Subnets: []ec2Types.Subnet{staticTestConfig().subnet}}, nil).AnyTimes()
+
+			cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+
+			got, err := cli.resolveDnsVpc(verifier.VerifyDnsInput{Ctx: context.TODO(), VpcID: test.vpcID, SubnetID: test.subnetID})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("resolveDnsVpc() error = %v, wantErr %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("resolveDnsVpc() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
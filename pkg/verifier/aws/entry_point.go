@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 
 	awsTools "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -14,7 +16,9 @@ import (
 
 	"github.com/openshift/osd-network-verifier/pkg/data/egress_lists"
 	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
 	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
 	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
 	"github.com/openshift/osd-network-verifier/pkg/verifier"
 )
@@ -30,8 +34,13 @@ const (
 // - prepare for ec2 instance creation
 // - create instance and wait till it gets ready, wait for userdata script execution
 // - find unreachable endpoints & parse output, then terminate instance
-// - return `a.output` which stores the execution results
+// - return an output which stores the execution results
+//
+// Each call returns its own output, so an AwsVerifier may be reused and called concurrently
 func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.Output {
+	out := &output.Output{}
+	defer a.mirrorOutput(out)
+
 	// Validate cloud platform type
 	if !vei.PlatformType.IsValid() {
 		vei.PlatformType = cloud.AWSClassic
@@ -39,29 +48,41 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 	// Default to curl.Probe if no Probe specified
 	if vei.Probe == nil {
 		vei.Probe = curl.Probe{}
-		a.writeDebugLogs(vei.Ctx, "defaulted to curl probe")
+		a.writeDebugLogs(vei.Ctx, out, "defaulted to curl probe")
 	}
 
+	// Replace the probe's built-in userdata template if requested
+	probe, err := probes.WithUserDataTemplate(vei.Probe, vei.UserDataTemplate)
+	if err != nil {
+		return out.AddError(err)
+	}
+	vei.Probe = probe
+
 	// Default to 5sec per-request timeout if none specified
 	if vei.Timeout <= 0 {
 		vei.Timeout = verifier.DefaultTimeout
 	}
-	a.writeDebugLogs(vei.Ctx, fmt.Sprintf("configured a %s timeout for each egress request", vei.Timeout))
+	a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("configured a %s timeout for each egress request", vei.Timeout))
+
+	// Static analysis predicts egress from the VPC's configuration alone, without launching instances
+	if vei.AWS.StaticAnalysis {
+		a.analyzeEgressStatically(vei, out)
+		return out
+	}
 
-	var err error
 	// Determine instance type and CPUArchitecture
-	vei.InstanceType, vei.CPUArchitecture, err = a.selectInstanceType(vei.Ctx, vei.InstanceType, vei.CPUArchitecture)
+	vei.InstanceType, vei.CPUArchitecture, err = a.selectInstanceType(vei.Ctx, out, vei.InstanceType, vei.CPUArchitecture)
 	if err != nil {
-		return a.Output.AddError(err)
+		return out.AddError(err)
 	}
 
 	// If no AMI specified, select one based on CPU arch and region
 	if vei.CloudImageID == "" {
 		vei.CloudImageID, err = vei.Probe.GetMachineImageID(vei.PlatformType, vei.CPUArchitecture, a.AwsClient.Region)
 		if err != nil {
-			return a.Output.AddError(fmt.Errorf("failed to determine default machine image: %w", err))
+			return out.AddError(fmt.Errorf("failed to determine default machine image: %w", err))
 		}
-		a.writeDebugLogs(vei.Ctx, fmt.Sprintf("defaulted to machine image %s", vei.CloudImageID))
+		a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("defaulted to machine image %s", vei.CloudImageID))
 	}
 
 	// Select legacy probe config file based on platform type (ignored unless legacy.Probe in use)
@@ -74,7 +95,7 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		PubKey, err := os.ReadFile(vei.ImportKeyPair)
 		debugPubKey = PubKey
 		if err != nil {
-			return a.Output.AddError(err)
+			return out.AddError(err)
 		}
 
 		// Import Keypair into aws keypairs to be attached later to the created debug instance
@@ -83,7 +104,7 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 			PublicKeyMaterial: debugPubKey,
 		})
 		if err != nil {
-			return a.Output.AddError(err)
+			return out.AddError(err)
 		}
 
 		// If we have imported a pubkey for debug we would like debug instance to stay up.
@@ -96,7 +117,7 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 
 		//Terminate the debug instance
 		if err := a.AwsClient.TerminateEC2Instance(vei.Ctx, vei.TerminateDebugInstance); err != nil {
-			a.Output.AddError(err)
+			out.AddError(err)
 		}
 
 		// Check if a keypair was uploaded
@@ -114,11 +135,11 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 			})
 			// If there were any issues deleting the keypair.
 			if err != nil {
-				a.Output.AddError(err)
+				out.AddError(err)
 			}
 		}
 
-		return &a.Output
+		return out
 	}
 
 	// Generate both egress lists for the given PlatformType. Note: the result of this is ignored by the Legacy probe.
@@ -127,7 +148,7 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 
 	egressListStr, tlsDisabledEgressListStr, err := generator.GenerateEgressLists(vei.Ctx, vei.EgressListYaml)
 	if err != nil {
-		return a.Output.AddError(err)
+		return out.AddError(err)
 	}
 
 	// Generate the userData file
@@ -137,6 +158,10 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		vei.Proxy.Cacert = ""
 	}
 
+	// Each run labels its probe output with a random nonce, so that stale output left on the
+	// console by a previous run can't be mistaken for this run's results
+	nonce := helpers.NewNonce()
+
 	userDataVariables := map[string]string{
 		"AWS_REGION":       a.AwsClient.Region,
 		"VALIDATOR_IMAGE":  networkValidatorImage,
@@ -151,63 +176,244 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		"DELAY":            "5",
 		"URLS":             egressListStr,
 		"TLSDISABLED_URLS": tlsDisabledEgressListStr,
+		"EGRESS_IP_URL":    vei.EgressIPEchoURL,
+		"TRANSFER_URLS":    strings.Join(vei.TransferURLs, " "),
+		"TRANSFER_SIZE":    vei.TransferSize,
+		"CURLOPT":          strings.Join(vei.CurlOptions, "\n"),
+		"NONCE":            nonce,
+	}
+
+	if vei.IPFamily.IsValid() {
+		userDataVariables["IP_FAMILY"] = vei.IPFamily.String()
+	}
+
+	if vei.EgressIPChecks > 0 {
+		userDataVariables["EGRESS_IP_CHECKS"] = strconv.Itoa(vei.EgressIPChecks)
 	}
 
 	if vei.SkipInstanceTermination {
 		userDataVariables["DELAY"] = "60"
 	}
 
-	unencodedUserData, err := vei.Probe.GetExpandedUserData(userDataVariables)
+	// Userdata exceeding the AWS-imposed 16KB limit is compressed and, if necessary, split into
+	// batches of egress URLs that are each run on a separate instance
+	userDataBatches, err := buildUserDataBatches(vei.Probe, userDataVariables)
 	if err != nil {
-		return a.Output.AddError(err)
+		return out.AddError(err)
 	}
-	unencodedUserDataBytes := []byte(unencodedUserData)
-	// Enforce AWS-imposed userdata limit
-	if len(unencodedUserDataBytes) > 16384 { // 16KB
-		return a.Output.AddError(
-			fmt.Errorf("userdata size exceeds AWS-imposed 16KB limit; if using a CA certificate, please check its file size"),
-		)
+
+	for _, userData := range userDataBatches {
+		a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("base64-encoded generated userdata script:\n---\n%s\n---", userData))
 	}
-	userData := base64.StdEncoding.EncodeToString([]byte(unencodedUserData))
 
-	a.writeDebugLogs(vei.Ctx, fmt.Sprintf("base64-encoded generated userdata script:\n---\n%s\n---", userData))
+	// ensurePrivate is a flag to ensure the return IP address from the given hosts are private defined in RFC1918
+	// (or, for IPv6, unique local addresses defined in RFC4193)
+	// Currently, it will be used the Zero Egress cluster check only
+	var ensurePrivate bool
+	if vei.PlatformType == cloud.AWSHCPZeroEgress {
+		ensurePrivate = true
+	}
+
+	// Unless the user requests otherwise, wait long enough for the probe to check every endpoint
+	pollOpts := consolePollOptions{
+		nonce:      nonce,
+		interval:   vei.PollInterval,
+		timeout:    vei.PollTimeout,
+		onProgress: vei.OnProgress,
+	}
+	if pollOpts.timeout <= 0 {
+		endpointCount := len(strings.Fields(egressListStr)) + len(strings.Fields(tlsDisabledEgressListStr))
+		// Dual-stack checks make one pass over the endpoints per IP family
+		endpointCount *= max(len(vei.IPFamily.Passes()), 1)
+		pollOpts.timeout = verifier.DerivedProbePollTimeout(vei.Probe, endpointCount, vei.Timeout, minConsolePollTimeout)
+	}
+	a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("Waiting up to %s for probe results from each instance", pollOpts.timeout))
+
+	egressURLs := append(strings.Fields(egressListStr), strings.Fields(tlsDisabledEgressListStr)...)
+
+	// Multi-subnet runs verify each subnet concurrently, nesting each subnet's results in out
+	if subnetIDs := vei.TargetSubnetIDs(); len(subnetIDs) > 1 {
+		a.validateEgressFromSubnets(vei, subnetIDs, egressURLs, userDataBatches, ensurePrivate, pollOpts, out)
+		return out
+	} else if len(subnetIDs) == 1 {
+		vei.SubnetID = subnetIDs[0]
+	}
 
 	vpcId, err := a.GetVpcIdFromSubnetId(vei.Ctx, vei.SubnetID)
 	if err != nil {
-		return a.Output.AddError(err)
+		return out.AddError(err)
+	}
+
+	// Routing problems, network ACL denies, VPC endpoint problems, and gaps in user-provided
+	// security groups are reported up front, before any probe runs
+	a.checkSubnetRouting(vei, vei.SubnetID, vpcId, out)
+	a.analyzeNetworkACL(vei, vei.SubnetID, vpcId, egressURLs, out)
+	a.checkVpcEndpoints(vei, vei.SubnetID, vpcId, out)
+	if len(vei.AWS.SecurityGroupIDs) > 0 {
+		a.analyzeUserSecurityGroups(vei, vei.SubnetID, vpcId, egressURLs, out)
 	}
 
 	// If security group not given, create a temporary one
 	if len(vei.AWS.SecurityGroupIDs) == 0 || vei.ForceTempSecurityGroup {
-		createSecurityGroupOutput, err := a.CreateSecurityGroup(vei.Ctx, vei.Tags, "osd-network-verifier", vpcId)
+		vei.AWS.TempSecurityGroup, err = a.createTempSecurityGroup(vei, vpcId, egressURLs, out)
+
+		// Now that security group has been created, ensure we clean it up
+		if vei.AWS.TempSecurityGroup != "" {
+			defer a.cleanupSecurityGroup(vei, out)
+		}
 		if err != nil {
-			return a.Output.AddError(err)
+			return out.AddError(err)
 		}
-		vei.AWS.TempSecurityGroup = *createSecurityGroupOutput.GroupId
+	}
 
-		// Now that security group has been created, ensure we clean it up
-		defer CleanupSecurityGroup(vei, a)
+	for i, userData := range userDataBatches {
+		if len(userDataBatches) > 1 {
+			a.Logger.Info(vei.Ctx, "Running probe batch %d of %d", i+1, len(userDataBatches))
+		}
+		a.runProbeInstance(vei, userData, vpcId, ensurePrivate, pollOpts, out)
+	}
 
-		// If proxy information given, add rules for it to the security group
-		if vei.Proxy.HttpProxy != "" || vei.Proxy.HttpsProxy != "" {
+	return out
+}
 
-			// Build a slice of proxy URLs (up to 2)
-			proxyUrls := make([]string, 0, 2)
-			if vei.Proxy.HttpProxy != "" {
-				proxyUrls = append(proxyUrls, vei.Proxy.HttpProxy)
-			}
-			if vei.Proxy.HttpsProxy != "" {
-				proxyUrls = append(proxyUrls, vei.Proxy.HttpsProxy)
+// validateEgressFromSubnets verifies egress from each of subnetIDs concurrently (up to
+// vei.MaxParallelSubnets at a time), sharing a single temporary security group between all subnets
+// in the same VPC. Each subnet's results are nested in out (see output.Output.AddSubnetResult)
+func (a *AwsVerifier) validateEgressFromSubnets(vei verifier.ValidateEgressInput, subnetIDs []string, egressURLs []string, userDataBatches []string, ensurePrivate bool, pollOpts consolePollOptions, out *output.Output) {
+	placements, err := a.describeSubnetPlacements(vei.Ctx, subnetIDs)
+	if err != nil {
+		out.AddError(err)
+		return
+	}
+
+	// Gaps in user-provided security groups are reported up front (once per VPC), before any probe
+	// runs
+	if len(vei.AWS.SecurityGroupIDs) > 0 {
+		analyzedVpcIDs := map[string]bool{}
+		for _, subnetID := range subnetIDs {
+			vpcId := placements[subnetID].vpcID
+			if analyzedVpcIDs[vpcId] {
+				continue
 			}
+			analyzedVpcIDs[vpcId] = true
+			a.analyzeUserSecurityGroups(vei, subnetID, vpcId, egressURLs, out)
+		}
+	}
 
-			// Add the new rules to the temp security group
-			_, err := a.AllowSecurityGroupProxyEgress(vei.Ctx, vei.AWS.TempSecurityGroup, proxyUrls)
+	// If security group not given, create a temporary one for each VPC
+	tempSecurityGroupIDs := map[string]string{}
+	if len(vei.AWS.SecurityGroupIDs) == 0 || vei.ForceTempSecurityGroup {
+		for _, subnetID := range subnetIDs {
+			vpcId := placements[subnetID].vpcID
+			if _, ok := tempSecurityGroupIDs[vpcId]; ok {
+				continue
+			}
+			tempSecurityGroupID, err := a.createTempSecurityGroup(vei, vpcId, egressURLs, out)
+
+			// Now that security group has been created, ensure we clean it up (once every subnet
+			// has been verified)
+			if tempSecurityGroupID != "" {
+				tempSecurityGroupIDs[vpcId] = tempSecurityGroupID
+				cleanupVei := vei
+				cleanupVei.AWS.TempSecurityGroup = tempSecurityGroupID
+				defer a.cleanupSecurityGroup(cleanupVei, out)
+			}
 			if err != nil {
-				return a.Output.AddError(err)
+				out.AddError(err)
+				return
+			}
+		}
+	}
+
+	maxParallelSubnets := vei.MaxParallelSubnets
+	if maxParallelSubnets <= 0 {
+		maxParallelSubnets = verifier.DefaultMaxParallelSubnets
+	}
+	a.Logger.Info(vei.Ctx, "Verifying egress from %d subnets, up to %d at a time", len(subnetIDs), maxParallelSubnets)
+
+	subnetOutputs := make([]*output.Output, len(subnetIDs))
+	semaphore := make(chan struct{}, maxParallelSubnets)
+	// onProgress isn't necessarily safe to call concurrently
+	var progressMutex sync.Mutex
+	var wg sync.WaitGroup
+	for i, subnetID := range subnetIDs {
+		placement := placements[subnetID]
+		subnetOutputs[i] = &output.Output{}
+
+		subnetVei := vei
+		subnetVei.SubnetID = subnetID
+		subnetVei.AWS.TempSecurityGroup = tempSecurityGroupIDs[placement.vpcID]
+
+		// Progress is labeled with the subnet it was reported from
+		subnetPollOpts := pollOpts
+		if pollOpts.onProgress != nil {
+			subnetPollOpts.onProgress = func(event probes.ProgressEvent) {
+				progressMutex.Lock()
+				defer progressMutex.Unlock()
+				event.Target = fmt.Sprintf("%s: %s", subnetID, event.Target)
+				pollOpts.onProgress(event)
 			}
 		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			a.checkSubnetRouting(subnetVei, subnetID, placement.vpcID, subnetOutputs[i])
+			a.analyzeNetworkACL(subnetVei, subnetID, placement.vpcID, egressURLs, subnetOutputs[i])
+			a.checkVpcEndpoints(subnetVei, subnetID, placement.vpcID, subnetOutputs[i])
+			for j, userData := range userDataBatches {
+				if len(userDataBatches) > 1 {
+					a.Logger.Info(vei.Ctx, "Running probe batch %d of %d in subnet %s", j+1, len(userDataBatches), subnetID)
+				}
+				a.runProbeInstance(subnetVei, userData, placement.vpcID, ensurePrivate, subnetPollOpts, subnetOutputs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, subnetID := range subnetIDs {
+		out.AddSubnetResult(placements[subnetID].label(), subnetOutputs[i])
+	}
+}
+
+// createTempSecurityGroup creates a temporary security group for probe instances in vpcId, allowing
+// egress to the ports of egressURLs and to the configured proxy (if any; see
+// tempSecurityGroupIPPermissions). The rules in effect are stored in out. If the security group is
+// created but can't be configured, its ID is returned along with the error so that it can still be
+// cleaned up
+func (a *AwsVerifier) createTempSecurityGroup(vei verifier.ValidateEgressInput, vpcId string, egressURLs []string, out *output.Output) (string, error) {
+	ipPermissions, err := tempSecurityGroupIPPermissions(vei, egressURLs)
+	if err != nil {
+		return "", handledErrors.NewGenericError(fmt.Errorf("unable to derive temporary security group rules: %w", err))
 	}
 
+	createSecurityGroupOutput, err := a.createSecurityGroup(vei.Ctx, createSecurityGroupInput{
+		tags:          vei.Tags,
+		name:          "osd-network-verifier",
+		vpcId:         vpcId,
+		ipPermissions: ipPermissions,
+		ipFamily:      vei.IPFamily,
+	})
+	if err != nil {
+		return "", err
+	}
+	tempSecurityGroupID := *createSecurityGroupOutput.GroupId
+
+	if vei.IPFamily.IncludesIPv6() {
+		ipPermissions = withIPv6AnyRanges(ipPermissions)
+	}
+	out.AddInfo(fmt.Sprintf("temporary security group %s allows egress to %s", tempSecurityGroupID, describeIPPermissions(ipPermissions)))
+
+	return tempSecurityGroupID, nil
+}
+
+// runProbeInstance launches a single probe instance with the given base64-encoded userdata, stores
+// the probe's results in out, and then terminates the instance (unless the user requests
+// otherwise). Errors are also stored in out
+func (a *AwsVerifier) runProbeInstance(vei verifier.ValidateEgressInput, userData string, vpcId string, ensurePrivate bool, pollOpts consolePollOptions, out *output.Output) {
 	// Create EC2 instance
 	instanceID, err := a.createEC2Instance(createEC2InstanceInput{
 		amiID:               vei.CloudImageID,
@@ -222,23 +428,19 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		tempSecurityGroupID: vei.AWS.TempSecurityGroup,
 		keyPair:             vei.ImportKeyPair,
 		vpcID:               vpcId,
+		ipFamily:            vei.IPFamily,
 	})
 	if err != nil {
-		return a.Output.AddError(err)
+		out.AddError(err)
+		return
 	}
 
-	// ensurePrivate is a flag to ensure the return IP address from the given hosts are private defined in RFC1918
-	// Currently, it will be used the Zero Egress cluster check only
-	var ensurePrivate bool
-	if vei.PlatformType == cloud.AWSHCPZeroEgress {
-		ensurePrivate = true
-	}
-	// findUnreachableEndpoints will call Probe.ParseProbeOutput(), which will store egress failures in a.Output.failures
+	// findUnreachableEndpoints will call Probe.ParseProbeOutput(), which will store egress failures in out
 	// when ensurePrivate is true, it will also check if the returned IP is private
-	err = a.findUnreachableEndpoints(vei.Ctx, instanceID, vei.Probe, ensurePrivate)
+	err = a.findUnreachableEndpoints(vei.Ctx, instanceID, vei.Probe, ensurePrivate, pollOpts, out)
 
 	if err != nil {
-		a.Output.AddError(err)
+		out.AddError(err)
 		// Don't return yet; still need to terminate instance
 	}
 
@@ -251,25 +453,38 @@ func (a *AwsVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 			err = a.modifyInstanceSecurityGroup(vei.Ctx, instanceID, defaultSecurityGroupID)
 			if err != nil {
 				a.Logger.Info(vei.Ctx, "Unable to detach instance from security group. Falling back to slower cloud resource cleanup method.")
-				a.writeDebugLogs(vei.Ctx, fmt.Sprintf("Fell back to slower cloud resource cleanup because faster method (network interface detatchment) blocked by AWS: %s.", err))
+				a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("Fell back to slower cloud resource cleanup because faster method (network interface detatchment) blocked by AWS: %s.", err))
 			}
 			a.Logger.Info(vei.Ctx, "Modified the instance to use the default security group")
 		}
 
 		a.Logger.Info(vei.Ctx, "Deleting instance with ID: %s", instanceID)
 		if err := a.AwsClient.TerminateEC2Instance(vei.Ctx, instanceID); err != nil {
-			a.Output.AddError(err)
+			out.AddError(err)
 		}
 	}
 
-	return &a.Output
 }
 
 // VerifyDns performs verification process for VPC's DNS
 // Basic workflow is:
+// - find the VPC (of the subnet, if one is given)
 // - ask AWS API for VPC attributes
 // - ensure they're set correctly
+// - look for DHCP options, Route 53 Resolver rules, and private hosted zones that may break name resolution
 func (a *AwsVerifier) VerifyDns(vdi verifier.VerifyDnsInput) *output.Output {
+	out := &output.Output{}
+	defer a.mirrorOutput(out)
+	vpcID, err := a.resolveDnsVpc(vdi)
+	if err != nil {
+		out.AddError(handledErrors.NewGenericError(err))
+		out.AddException(handledErrors.NewGenericError(
+			fmt.Errorf("failed to find the VPC of subnet: %s", vdi.SubnetID)),
+		)
+		return out
+	}
+	vdi.VpcID = vpcID
+
 	a.Logger.Info(vdi.Ctx, "Verifying DNS config for VPC %s", vdi.VpcID)
 	// Request boolean values from AWS API
 	dnsSprtResult, err := a.AwsClient.DescribeVpcAttribute(vdi.Ctx, &ec2.DescribeVpcAttributeInput{
@@ -277,11 +492,11 @@ func (a *AwsVerifier) VerifyDns(vdi verifier.VerifyDnsInput) *output.Output {
 		VpcId:     awsTools.String(vdi.VpcID),
 	})
 	if err != nil {
-		a.Output.AddError(handledErrors.NewGenericError(err))
-		a.Output.AddException(handledErrors.NewGenericError(
+		out.AddError(handledErrors.NewGenericError(err))
+		out.AddException(handledErrors.NewGenericError(
 			fmt.Errorf("failed to validate the %s attribute on VPC: %s is true", ec2Types.VpcAttributeNameEnableDnsSupport, vdi.VpcID)),
 		)
-		return &a.Output
+		return out
 	}
 
 	dnsHostResult, err := a.AwsClient.DescribeVpcAttribute(vdi.Ctx, &ec2.DescribeVpcAttributeInput{
@@ -289,38 +504,49 @@ func (a *AwsVerifier) VerifyDns(vdi verifier.VerifyDnsInput) *output.Output {
 		VpcId:     awsTools.String(vdi.VpcID),
 	})
 	if err != nil {
-		a.Output.AddError(handledErrors.NewGenericError(err))
-		a.Output.AddException(handledErrors.NewGenericError(
+		out.AddError(handledErrors.NewGenericError(err))
+		out.AddException(handledErrors.NewGenericError(
 			fmt.Errorf("failed to validate the %s attribute on VPC: %s is true", ec2Types.VpcAttributeNameEnableDnsHostnames, vdi.VpcID),
 		))
-		return &a.Output
+		return out
 	}
 	// Verify results
 	a.Logger.Info(vdi.Ctx, "DNS Support for VPC %s: %t", vdi.VpcID, *dnsSprtResult.EnableDnsSupport.Value)
 	a.Logger.Info(vdi.Ctx, "DNS Hostnames for VPC %s: %t", vdi.VpcID, *dnsHostResult.EnableDnsHostnames.Value)
 	if !(*dnsSprtResult.EnableDnsSupport.Value) {
-		a.Output.AddException(handledErrors.NewGenericError(
+		out.AddException(handledErrors.NewGenericError(
 			fmt.Errorf("the %s attribute on VPC: %s is %t, must be true", ec2Types.VpcAttributeNameEnableDnsSupport, vdi.VpcID, *dnsSprtResult.EnableDnsSupport.Value),
 		))
 	}
 
 	if !(*dnsHostResult.EnableDnsHostnames.Value) {
-		a.Output.AddException(handledErrors.NewGenericError(
+		out.AddException(handledErrors.NewGenericError(
 			fmt.Errorf("the %s attribute on VPC: %s is %t, must be true", ec2Types.VpcAttributeNameEnableDnsHostnames, vdi.VpcID, *dnsHostResult.EnableDnsHostnames.Value),
 		))
 	}
 
-	return &a.Output
+	a.checkDhcpOptions(vdi.Ctx, vdi.VpcID, vdi.SubnetID, out)
+	a.checkResolverRules(vdi.Ctx, vdi.VpcID, out)
+	a.checkPrivateHostedZones(vdi.Ctx, vdi.VpcID, out)
+
+	return out
 }
 
 // CleanupSecurityGroup cleans up the security groups created by network-verifier
 func CleanupSecurityGroup(vei verifier.ValidateEgressInput, a *AwsVerifier) *output.Output {
+	out := &output.Output{}
+	defer a.mirrorOutput(out)
+	a.cleanupSecurityGroup(vei, out)
+	return out
+}
+
+// cleanupSecurityGroup deletes vei.AWS.TempSecurityGroup, storing any errors in out
+func (a *AwsVerifier) cleanupSecurityGroup(vei verifier.ValidateEgressInput, out *output.Output) {
 	a.Logger.Info(vei.Ctx, "Deleting security group with ID: %s", vei.AWS.TempSecurityGroup)
 	_, err := a.AwsClient.DeleteSecurityGroup(vei.Ctx, &ec2.DeleteSecurityGroupInput{GroupId: awsTools.String(vei.AWS.TempSecurityGroup)})
 	if err != nil {
-		a.Output.AddError(handledErrors.NewGenericError(err))
-		a.Output.AddException(handledErrors.NewGenericError(fmt.Errorf("unable to cleanup security group %s, please manually clean up", vei.AWS.TempSecurityGroup)))
+		out.AddError(handledErrors.NewGenericError(err))
+		out.AddException(handledErrors.NewGenericError(fmt.Errorf("unable to cleanup security group %s, please manually clean up", vei.AWS.TempSecurityGroup)))
 
 	}
-	return &a.Output
 }
@@ -0,0 +1,194 @@
+package awsverifier
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+
+	awsTools "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// naclDefaultRuleNumber is the number of the catch-all rule ("*") that ends every network ACL
+const naclDefaultRuleNumber = 32767
+
+// ephemeralPortFrom and ephemeralPortTo bound the ports Linux (and so both the probe instance and
+// cluster nodes) picks the local port of outgoing connections from, i.e., the ports return traffic
+// is sent to. Network ACLs are stateless, so inbound rules must allow return traffic to all of them
+const (
+	ephemeralPortFrom = 32768
+	ephemeralPortTo   = 60999
+)
+
+// describeSubnetNetworkACL returns the network ACL associated with subnetID
+func (a *AwsVerifier) describeSubnetNetworkACL(ctx context.Context, subnetID string) (ec2Types.NetworkAcl, error) {
+	networkACLsOutput, err := a.AwsClient.DescribeNetworkAcls(ctx, &ec2.DescribeNetworkAclsInput{
+		Filters: []ec2Types.Filter{
+			{
+				Name:   awsTools.String("association.subnet-id"),
+				Values: []string{subnetID},
+			},
+		},
+	})
+	if err != nil {
+		return ec2Types.NetworkAcl{}, err
+	}
+	if len(networkACLsOutput.NetworkAcls) == 0 {
+		return ec2Types.NetworkAcl{}, fmt.Errorf("no network ACL found for subnet %s", subnetID)
+	}
+	return networkACLsOutput.NetworkAcls[0], nil
+}
+
+// analyzeNetworkACL checks, before any probe runs, whether the rules of the network ACL associated
+// with subnetID (part of vpcID) allow traffic to every endpoint in egressURLs (or the proxy, if a
+// request would be proxied) over every IP family vei checks, as well as the return traffic. Denied
+// traffic is stored in out as network ACL findings (see handledErrors.NetworkACLFinding)
+func (a *AwsVerifier) analyzeNetworkACL(vei verifier.ValidateEgressInput, subnetID string, vpcID string, egressURLs []string, out *output.Output) {
+	acl, err := a.describeSubnetNetworkACL(vei.Ctx, subnetID)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to evaluate the network ACL of %s: %w", subnetID, err))
+		return
+	}
+	aclID := awsTools.ToString(acl.NetworkAclId)
+
+	endpoints, err := staticEndpoints(vei.Proxy, egressURLs)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to evaluate network ACL %s: %w", aclID, err))
+		return
+	}
+	cfg := vpcEgressConfig{networkACL: acl}
+	// Zero-egress clusters reach every endpoint within the VPC, whose CIDR blocks are found in the
+	// route table's local routes
+	if vei.PlatformType == cloud.AWSHCPZeroEgress {
+		cfg.routeTable, _, err = a.describeEffectiveRouteTable(vei.Ctx, subnetID, vpcID)
+		if err != nil {
+			out.AddWarning(fmt.Errorf("unable to evaluate network ACL %s: %w", aclID, err))
+			return
+		}
+	}
+
+	blocks, blockedTargets := groupBlockedTargets(cfg.destinations(vei, endpoints), func(dest staticDestination) *egressBlock {
+		return networkACLBlock(acl, dest)
+	})
+	for _, block := range blocks {
+		out.AddWarning(handledErrors.NewNetworkACLFinding(aclID, fmt.Sprintf("%s, needed by %s", block, summarizeTargets(blockedTargets[block]))))
+	}
+	if len(blocks) == 0 {
+		a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("network ACL %s of %s allows egress to every endpoint and the return traffic", aclID, subnetID))
+	}
+}
+
+// networkACLBlock returns nil if acl's outbound rules allow traffic to dest and its inbound rules
+// allow the return traffic
+func networkACLBlock(acl ec2Types.NetworkAcl, dest staticDestination) *egressBlock {
+	component := "network ACL " + awsTools.ToString(acl.NetworkAclId)
+	entry, ok := networkACLEntry(acl, true, dest.port, dest.prefix)
+	if !ok {
+		return &egressBlock{component: component, reason: fmt.Sprintf("no outbound rule allows %s", dest)}
+	}
+	if entry.RuleAction == ec2Types.RuleActionDeny {
+		return &egressBlock{component: component, reason: fmt.Sprintf("outbound rule %s denies %s", naclRuleNumber(entry), dest)}
+	}
+
+	// Return traffic comes from wherever the traffic was sent
+	returnTraffic := fmt.Sprintf("return traffic from %s", describePrefix(dest.prefix))
+	if dest.proxy != "" {
+		returnTraffic += fmt.Sprintf(" (proxy %s)", dest.proxy)
+	}
+	fromPort, toPort, entry, ok := networkACLReturnBlock(acl, dest.prefix)
+	switch {
+	case fromPort == 0:
+		return nil
+	case !ok:
+		return &egressBlock{component: component, reason: fmt.Sprintf("no inbound rule allows %s to tcp/%d-%d (ephemeral ports)", returnTraffic, fromPort, toPort)}
+	default:
+		return &egressBlock{component: component, reason: fmt.Sprintf("inbound rule %s denies %s to tcp/%d-%d (ephemeral ports)", naclRuleNumber(entry), returnTraffic, fromPort, toPort)}
+	}
+}
+
+// networkACLReturnBlock returns the first range of ephemeral ports to which acl's inbound rules
+// don't allow TCP traffic from every address in prefix, along with the rule denying it (if any,
+// as indicated by ok). fromPort is 0 if return traffic is allowed to every ephemeral port
+func networkACLReturnBlock(acl ec2Types.NetworkAcl, prefix netip.Prefix) (fromPort int32, toPort int32, entry ec2Types.NetworkAclEntry, ok bool) {
+	// Split the ephemeral ports wherever an inbound rule's port range starts or ends, so that the
+	// same rule decides every port within each range
+	bounds := []int32{ephemeralPortFrom}
+	for _, aclEntry := range acl.Entries {
+		if awsTools.ToBool(aclEntry.Egress) || aclEntry.PortRange == nil {
+			continue
+		}
+		for _, bound := range []int32{awsTools.ToInt32(aclEntry.PortRange.From), awsTools.ToInt32(aclEntry.PortRange.To) + 1} {
+			if bound > ephemeralPortFrom && bound <= ephemeralPortTo {
+				bounds = append(bounds, bound)
+			}
+		}
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	for i, rangeFrom := range bounds {
+		rangeTo := int32(ephemeralPortTo)
+		if i+1 < len(bounds) {
+			rangeTo = bounds[i+1] - 1
+		}
+		rangeEntry, rangeOK := networkACLEntry(acl, false, rangeFrom, prefix)
+		blocked := !rangeOK || rangeEntry.RuleAction == ec2Types.RuleActionDeny
+		switch {
+		case fromPort == 0 && blocked:
+			fromPort, toPort, entry, ok = rangeFrom, rangeTo, rangeEntry, rangeOK
+		case fromPort != 0 && blocked && rangeOK == ok && awsTools.ToInt32(rangeEntry.RuleNumber) == awsTools.ToInt32(entry.RuleNumber):
+			// Adjacent ranges blocked the same way are reported together
+			toPort = rangeTo
+		case fromPort != 0:
+			return fromPort, toPort, entry, ok
+		}
+	}
+	return fromPort, toPort, entry, ok
+}
+
+// networkACLEntry returns the rule of acl that decides whether TCP traffic in the given direction
+// to (or, for inbound rules, from) port and every address in prefix is allowed, i.e., the matching
+// rule with the lowest rule number. Rules only matching some of the addresses in prefix (e.g., a
+// rule for 10.0.0.0/8 when the destination could be any address) are skipped
+func networkACLEntry(acl ec2Types.NetworkAcl, egress bool, port int32, prefix netip.Prefix) (ec2Types.NetworkAclEntry, bool) {
+	entries := slices.Clone(acl.Entries)
+	slices.SortFunc(entries, func(a, b ec2Types.NetworkAclEntry) int {
+		return int(awsTools.ToInt32(a.RuleNumber) - awsTools.ToInt32(b.RuleNumber))
+	})
+
+	for _, entry := range entries {
+		if awsTools.ToBool(entry.Egress) != egress {
+			continue
+		}
+		switch awsTools.ToString(entry.Protocol) {
+		case "-1":
+		case "6":
+			if entry.PortRange != nil && (port < awsTools.ToInt32(entry.PortRange.From) || port > awsTools.ToInt32(entry.PortRange.To)) {
+				continue
+			}
+		default:
+			continue
+		}
+		if prefixCovers(awsTools.ToString(entry.CidrBlock), prefix) || prefixCovers(awsTools.ToString(entry.Ipv6CidrBlock), prefix) {
+			return entry, true
+		}
+	}
+	return ec2Types.NetworkAclEntry{}, false
+}
+
+// naclRuleNumber returns a network ACL rule's number as shown in the AWS console, where the
+// catch-all rule is "*"
+func naclRuleNumber(entry ec2Types.NetworkAclEntry) string {
+	if awsTools.ToInt32(entry.RuleNumber) == naclDefaultRuleNumber {
+		return "*"
+	}
+	return strconv.Itoa(int(awsTools.ToInt32(entry.RuleNumber)))
+}
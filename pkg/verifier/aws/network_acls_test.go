@@ -0,0 +1,112 @@
+package awsverifier
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/mocks"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+func TestAwsVerifier_analyzeNetworkACL(t *testing.T) {
+	egressURLs := []string{
+		"https://quay.io:443",
+		"https://api.openshift.com:443",
+		"telnet://inputs1.osdsecuritylogs.splunkcloud.com:9997",
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(acl *ec2Types.NetworkAcl)
+		describeErr error
+		want        []string
+		wantWarning string
+	}{
+		{
+			name: "all traffic allowed",
+		},
+		{
+			name: "outbound deny",
+			modify: func(acl *ec2Types.NetworkAcl) {
+				acl.Entries = append(acl.Entries, naclEntry(90, true, ec2Types.RuleActionDeny, "0.0.0.0/0", 9997, 9997))
+			},
+			want: []string{
+				"network ACL finding: network ACL acl-1: outbound rule 90 denies tcp/9997 to any IPv4 address, needed by tcp://inputs1.osdsecuritylogs.splunkcloud.com:9997",
+			},
+		},
+		{
+			name: "missing ephemeral port inbound rule",
+			modify: func(acl *ec2Types.NetworkAcl) {
+				acl.Entries[2] = naclEntry(100, false, ec2Types.RuleActionAllow, "0.0.0.0/0", 22, 22)
+			},
+			want: []string{
+				"network ACL finding: network ACL acl-1: inbound rule * denies return traffic from any IPv4 address to tcp/32768-60999 (ephemeral ports), needed by https://quay.io:443, https://api.openshift.com:443, tcp://inputs1.osdsecuritylogs.splunkcloud.com:9997",
+			},
+		},
+		{
+			name:        "network ACL can't be described",
+			describeErr: errors.New("UnauthorizedOperation"),
+			wantWarning: "unable to evaluate the network ACL of subnet-a: UnauthorizedOperation",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			acl := staticTestConfig().networkACL
+			if test.modify != nil {
+				test.modify(&acl)
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+			FakeEC2Cli.EXPECT().DescribeNetworkAcls(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, input *ec2.DescribeNetworkAclsInput, _ ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error) {
+					if *input.Filters[0].Name != "association.subnet-id" || !reflect.DeepEqual(input.Filters[0].Values, []string{"subnet-a"}) {
+						t.Errorf("DescribeNetworkAcls() called with unexpected filter: %+v", input.Filters[0])
+					}
+					if test.describeErr != nil {
+						return nil, test.describeErr
+					}
+					return &ec2.DescribeNetworkAclsOutput{NetworkAcls: []ec2Types.NetworkAcl{acl}}, nil
+				},
+			)
+
+			cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+
+			out := &output.Output{}
+			cli.analyzeNetworkACL(verifier.ValidateEgressInput{Ctx: context.TODO()}, "subnet-a", "vpc-1", egressURLs, out)
+
+			var got []string
+			var gotWarning string
+			for _, warning := range out.GetWarnings() {
+				finding, ok := warning.(*handledErrors.NetworkACLFinding)
+				if !ok {
+					gotWarning = warning.Error()
+					continue
+				}
+				if finding.NetworkACLID() != "acl-1" {
+					t.Errorf("analyzeNetworkACL() finding about %s, want acl-1", finding.NetworkACLID())
+				}
+				got = append(got, finding.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("analyzeNetworkACL() findings = %q, want %q", got, test.want)
+			}
+			if gotWarning != test.wantWarning {
+				t.Errorf("analyzeNetworkACL() warning = %q, want %q", gotWarning, test.wantWarning)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package awsverifier
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	awsTools "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// defaultRoutePrefixIPv4 and defaultRoutePrefixIPv6 are the destinations of IPv4 and IPv6 default
+// routes, respectively
+var (
+	defaultRoutePrefixIPv4 = netip.MustParsePrefix("0.0.0.0/0")
+	defaultRoutePrefixIPv6 = netip.MustParsePrefix("::/0")
+)
+
+// checkSubnetRouting inspects the route table effective for subnetID (part of vpcID), i.e., its own
+// route table or the VPC's main route table, before any probe runs. It stores in out the default
+// route's target and state, whether the subnet is public (i.e., its IPv4 default route targets an
+// internet gateway) or private, and a routing finding (see handledErrors.RouteFinding) for each
+// blackhole route, unavailable NAT gateway, and conflict with what vei.PlatformType expects
+func (a *AwsVerifier) checkSubnetRouting(vei verifier.ValidateEgressInput, subnetID string, vpcID string, out *output.Output) {
+	routeTable, mainRouteTable, err := a.describeEffectiveRouteTable(vei.Ctx, subnetID, vpcID)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to check routing of %s: %w", subnetID, err))
+		return
+	}
+	natGateways, err := a.describeRoutedNatGateways(vei.Ctx, routeTable)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to check routing of %s: %w", subnetID, err))
+		return
+	}
+	routeTableLabel := awsTools.ToString(routeTable.RouteTableId)
+	if mainRouteTable {
+		routeTableLabel += " (main)"
+	}
+
+	defaultPrefixes := []netip.Prefix{defaultRoutePrefixIPv4}
+	if vei.IPFamily.IncludesIPv6() {
+		defaultPrefixes = append(defaultPrefixes, defaultRoutePrefixIPv6)
+	}
+	public := false
+	for _, defaultPrefix := range defaultPrefixes {
+		route, ok := effectiveRoute(routeTable, defaultPrefix)
+		if !ok {
+			out.AddInfo(fmt.Sprintf("route table %s of %s has no default route (%s)", routeTableLabel, subnetID, defaultPrefix))
+			continue
+		}
+		if defaultPrefix.Addr().Is4() {
+			public = strings.HasPrefix(awsTools.ToString(route.GatewayId), "igw-")
+		}
+
+		state := string(route.State)
+		if route.NatGatewayId != nil {
+			natGatewayState := "not found"
+			if natGateway, ok := natGateways[*route.NatGatewayId]; ok {
+				natGatewayState = string(natGateway.State)
+			}
+			state += ", NAT gateway " + natGatewayState
+			if natGatewayState != string(ec2Types.NatGatewayStateAvailable) && route.State != ec2Types.RouteStateBlackhole {
+				out.AddWarning(handledErrors.NewRouteFinding(subnetID, fmt.Sprintf("route table %s routes %s to %s, which is %s", routeTableLabel, defaultPrefix, routeTarget(route), natGatewayState)))
+			}
+		}
+		out.AddInfo(fmt.Sprintf("route table %s of %s routes %s to %s (%s)", routeTableLabel, subnetID, defaultPrefix, routeTarget(route), state))
+	}
+
+	for _, route := range routeTable.Routes {
+		if route.State != ec2Types.RouteStateBlackhole {
+			continue
+		}
+		destination := routeDestination(route, false)
+		if destination == "" {
+			destination = routeDestination(route, true)
+		}
+		if destination == "" {
+			destination = "prefix list " + awsTools.ToString(route.DestinationPrefixListId)
+		}
+		out.AddWarning(handledErrors.NewRouteFinding(subnetID, fmt.Sprintf("route table %s routes %s to %s, which no longer exists (blackhole)", routeTableLabel, destination, routeTarget(route))))
+	}
+
+	classification := "private"
+	if public {
+		classification = "public"
+	}
+	out.AddInfo(fmt.Sprintf("%s is a %s subnet", subnetID, classification))
+
+	// Cluster nodes run in private subnets, which (except for zero-egress clusters) reach the
+	// internet through something like a NAT gateway, transit gateway, or firewall
+	defaultRoute, hasDefaultRoute := effectiveRoute(routeTable, defaultRoutePrefixIPv4)
+	switch {
+	case public:
+		out.AddWarning(handledErrors.NewRouteFinding(subnetID, fmt.Sprintf("%s is public (route table %s routes %s to %s), but %s cluster nodes are expected to run in private subnets", subnetID, routeTableLabel, defaultRoutePrefixIPv4, routeTarget(defaultRoute), vei.PlatformType)))
+	case !hasDefaultRoute && vei.PlatformType != cloud.AWSHCPZeroEgress:
+		out.AddWarning(handledErrors.NewRouteFinding(subnetID, fmt.Sprintf("%s has no default route (%s), but %s clusters need egress to the internet (e.g., through a NAT gateway)", subnetID, defaultRoutePrefixIPv4, vei.PlatformType)))
+	}
+}
+
+// describeRoutedNatGateways returns every NAT gateway routed to by routeTable, by ID. NAT gateways
+// that no longer exist are omitted
+func (a *AwsVerifier) describeRoutedNatGateways(ctx context.Context, routeTable ec2Types.RouteTable) (map[string]ec2Types.NatGateway, error) {
+	natGateways := map[string]ec2Types.NatGateway{}
+	var natGatewayIDs []string
+	for _, route := range routeTable.Routes {
+		if route.NatGatewayId != nil && !slices.Contains(natGatewayIDs, *route.NatGatewayId) {
+			natGatewayIDs = append(natGatewayIDs, *route.NatGatewayId)
+		}
+	}
+	if len(natGatewayIDs) == 0 {
+		return natGateways, nil
+	}
+
+	// Filtering by ID (rather than requesting the IDs directly) omits missing NAT gateways instead
+	// of failing the whole request
+	natGatewaysOutput, err := a.AwsClient.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []ec2Types.Filter{
+			{
+				Name:   awsTools.String("nat-gateway-id"),
+				Values: natGatewayIDs,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, natGateway := range natGatewaysOutput.NatGateways {
+		natGateways[awsTools.ToString(natGateway.NatGatewayId)] = natGateway
+	}
+	return natGateways, nil
+}
+
+// describeEffectiveRouteTable returns the route table associated with subnetID or, if there isn't
+// one, the main route table of vpcID (which applies to every subnet without a route table of its
+// own). The returned bool is true in the latter case
+func (a *AwsVerifier) describeEffectiveRouteTable(ctx context.Context, subnetID string, vpcID string) (ec2Types.RouteTable, bool, error) {
+	associatedOutput, err := a.AwsClient.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []ec2Types.Filter{
+			{
+				Name:   awsTools.String("association.subnet-id"),
+				Values: []string{subnetID},
+			},
+		},
+	})
+	if err != nil {
+		return ec2Types.RouteTable{}, false, err
+	}
+	if len(associatedOutput.RouteTables) > 0 {
+		return associatedOutput.RouteTables[0], false, nil
+	}
+
+	mainOutput, err := a.AwsClient.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []ec2Types.Filter{
+			{
+				Name:   awsTools.String("vpc-id"),
+				Values: []string{vpcID},
+			},
+			{
+				Name:   awsTools.String("association.main"),
+				Values: []string{"true"},
+			},
+		},
+	})
+	if err != nil {
+		return ec2Types.RouteTable{}, false, err
+	}
+	if len(mainOutput.RouteTables) == 0 {
+		return ec2Types.RouteTable{}, false, fmt.Errorf("no route table found for subnet %s or VPC %s", subnetID, vpcID)
+	}
+	return mainOutput.RouteTables[0], true, nil
+}
+
+// effectiveRoute returns the most specific route in routeTable whose destination includes every
+// address in prefix. Routes to prefix lists aren't considered
+func effectiveRoute(routeTable ec2Types.RouteTable, prefix netip.Prefix) (ec2Types.Route, bool) {
+	var bestRoute ec2Types.Route
+	bestBits := -1
+	for _, route := range routeTable.Routes {
+		routePrefix, err := netip.ParsePrefix(routeDestination(route, prefix.Addr().Is6()))
+		if err != nil || !prefixCovers(routePrefix.String(), prefix) {
+			continue
+		}
+		if routePrefix.Bits() > bestBits {
+			bestRoute, bestBits = route, routePrefix.Bits()
+		}
+	}
+	return bestRoute, bestBits >= 0
+}
+
+// routeDestination returns a route's IPv6 (or IPv4, if ipv6 is false) destination CIDR block, if any
+func routeDestination(route ec2Types.Route, ipv6 bool) string {
+	if ipv6 {
+		return awsTools.ToString(route.DestinationIpv6CidrBlock)
+	}
+	return awsTools.ToString(route.DestinationCidrBlock)
+}
+
+// routeTarget describes where a route sends traffic, e.g., "NAT gateway nat-0123"
+func routeTarget(route ec2Types.Route) string {
+	gatewayID := awsTools.ToString(route.GatewayId)
+	switch {
+	case gatewayID == "local":
+		return "the VPC (local)"
+	case strings.HasPrefix(gatewayID, "igw-"):
+		return "internet gateway " + gatewayID
+	case strings.HasPrefix(gatewayID, "vgw-"):
+		return "virtual private gateway " + gatewayID
+	case strings.HasPrefix(gatewayID, "vpce-"):
+		// Gateway Load Balancer endpoints, e.g., those of AWS Network Firewall
+		return "firewall endpoint " + gatewayID
+	case gatewayID != "":
+		return "gateway " + gatewayID
+	case route.NatGatewayId != nil:
+		return "NAT gateway " + *route.NatGatewayId
+	case route.EgressOnlyInternetGatewayId != nil:
+		return "egress-only internet gateway " + *route.EgressOnlyInternetGatewayId
+	case route.TransitGatewayId != nil:
+		return "transit gateway " + *route.TransitGatewayId
+	case route.VpcPeeringConnectionId != nil:
+		return "VPC peering connection " + *route.VpcPeeringConnectionId
+	case route.NetworkInterfaceId != nil:
+		return "network interface " + *route.NetworkInterfaceId
+	case route.InstanceId != nil:
+		return "instance " + *route.InstanceId
+	case route.LocalGatewayId != nil:
+		return "local gateway " + *route.LocalGatewayId
+	case route.CarrierGatewayId != nil:
+		return "carrier gateway " + *route.CarrierGatewayId
+	case route.CoreNetworkArn != nil:
+		return "core network " + *route.CoreNetworkArn
+	default:
+		return "an unknown target"
+	}
+}
@@ -0,0 +1,166 @@
+package awsverifier
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	awss "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/mocks"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+func TestAwsVerifier_checkSubnetRouting(t *testing.T) {
+	tests := []struct {
+		name string
+		// modify changes the subnet's route table (see staticTestConfig) before it's described
+		modify       func(routeTable *ec2Types.RouteTable)
+		natGateways  []ec2Types.NatGateway
+		mainFallback bool
+		platform     cloud.Platform
+		wantInfo     []string
+		wantFindings []string
+	}{
+		{
+			name:        "private subnet routed through a NAT gateway",
+			natGateways: []ec2Types.NatGateway{{NatGatewayId: awss.String("nat-1"), State: ec2Types.NatGatewayStateAvailable}},
+			platform:    cloud.AWSClassic,
+			wantInfo: []string{
+				"route table rtb-1 of subnet-a routes 0.0.0.0/0 to NAT gateway nat-1 (active, NAT gateway available)",
+				"subnet-a is a private subnet",
+			},
+		},
+		{
+			name:         "main route table fallback",
+			natGateways:  []ec2Types.NatGateway{{NatGatewayId: awss.String("nat-1"), State: ec2Types.NatGatewayStateAvailable}},
+			mainFallback: true,
+			platform:     cloud.AWSHCP,
+			wantInfo: []string{
+				"route table rtb-1 (main) of subnet-a routes 0.0.0.0/0 to NAT gateway nat-1 (active, NAT gateway available)",
+				"subnet-a is a private subnet",
+			},
+		},
+		{
+			name: "public subnet",
+			modify: func(routeTable *ec2Types.RouteTable) {
+				routeTable.Routes[1] = ec2Types.Route{DestinationCidrBlock: awss.String("0.0.0.0/0"), GatewayId: awss.String("igw-1"), State: ec2Types.RouteStateActive}
+			},
+			platform: cloud.AWSClassic,
+			wantInfo: []string{
+				"route table rtb-1 of subnet-a routes 0.0.0.0/0 to internet gateway igw-1 (active)",
+				"subnet-a is a public subnet",
+			},
+			wantFindings: []string{
+				"routing finding: subnet-a is public (route table rtb-1 routes 0.0.0.0/0 to internet gateway igw-1), but aws-classic cluster nodes are expected to run in private subnets",
+			},
+		},
+		{
+			name: "default route to a deleted NAT gateway",
+			modify: func(routeTable *ec2Types.RouteTable) {
+				routeTable.Routes[1].State = ec2Types.RouteStateBlackhole
+			},
+			platform: cloud.AWSClassic,
+			wantInfo: []string{
+				"route table rtb-1 of subnet-a routes 0.0.0.0/0 to NAT gateway nat-1 (blackhole, NAT gateway not found)",
+				"subnet-a is a private subnet",
+			},
+			wantFindings: []string{
+				"routing finding: route table rtb-1 routes 0.0.0.0/0 to NAT gateway nat-1, which no longer exists (blackhole)",
+			},
+		},
+		{
+			name:        "failed NAT gateway and blackhole transit gateway route",
+			natGateways: []ec2Types.NatGateway{{NatGatewayId: awss.String("nat-1"), State: ec2Types.NatGatewayStateFailed}},
+			modify: func(routeTable *ec2Types.RouteTable) {
+				routeTable.Routes = append(routeTable.Routes, ec2Types.Route{DestinationCidrBlock: awss.String("192.168.0.0/16"), TransitGatewayId: awss.String("tgw-1"), State: ec2Types.RouteStateBlackhole})
+			},
+			platform: cloud.AWSClassic,
+			wantInfo: []string{
+				"route table rtb-1 of subnet-a routes 0.0.0.0/0 to NAT gateway nat-1 (active, NAT gateway failed)",
+				"subnet-a is a private subnet",
+			},
+			wantFindings: []string{
+				"routing finding: route table rtb-1 routes 0.0.0.0/0 to NAT gateway nat-1, which is failed",
+				"routing finding: route table rtb-1 routes 192.168.0.0/16 to transit gateway tgw-1, which no longer exists (blackhole)",
+			},
+		},
+		{
+			name: "no default route",
+			modify: func(routeTable *ec2Types.RouteTable) {
+				routeTable.Routes = routeTable.Routes[:1]
+			},
+			platform: cloud.AWSClassic,
+			wantInfo: []string{
+				"route table rtb-1 of subnet-a has no default route (0.0.0.0/0)",
+				"subnet-a is a private subnet",
+			},
+			wantFindings: []string{
+				"routing finding: subnet-a has no default route (0.0.0.0/0), but aws-classic clusters need egress to the internet (e.g., through a NAT gateway)",
+			},
+		},
+		{
+			name: "no default route for zero-egress",
+			modify: func(routeTable *ec2Types.RouteTable) {
+				routeTable.Routes = routeTable.Routes[:1]
+			},
+			platform: cloud.AWSHCPZeroEgress,
+			wantInfo: []string{
+				"route table rtb-1 of subnet-a has no default route (0.0.0.0/0)",
+				"subnet-a is a private subnet",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routeTable := staticTestConfig().routeTable
+			if test.modify != nil {
+				test.modify(&routeTable)
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+			FakeEC2Cli.EXPECT().DescribeRouteTables(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, input *ec2.DescribeRouteTablesInput, _ ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+					if (*input.Filters[0].Name == "association.subnet-id") == test.mainFallback {
+						return &ec2.DescribeRouteTablesOutput{}, nil
+					}
+					return &ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{routeTable}}, nil
+				},
+			).MinTimes(1)
+			// NAT gateways are only described if the route table routes to any
+			FakeEC2Cli.EXPECT().DescribeNatGateways(gomock.Any(), gomock.Any()).Return(&ec2.DescribeNatGatewaysOutput{NatGateways: test.natGateways}, nil).AnyTimes()
+
+			cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+
+			out := &output.Output{}
+			cli.checkSubnetRouting(verifier.ValidateEgressInput{Ctx: context.TODO(), PlatformType: test.platform}, "subnet-a", "vpc-1", out)
+
+			if !reflect.DeepEqual(out.GetInfo(), test.wantInfo) {
+				t.Errorf("checkSubnetRouting() info = %q, want %q", out.GetInfo(), test.wantInfo)
+			}
+			var gotFindings []string
+			for _, warning := range out.GetWarnings() {
+				finding, ok := warning.(*handledErrors.RouteFinding)
+				if !ok {
+					t.Fatalf("checkSubnetRouting() unexpected warning: %v", warning)
+				}
+				gotFindings = append(gotFindings, finding.Error())
+			}
+			if !reflect.DeepEqual(gotFindings, test.wantFindings) {
+				t.Errorf("checkSubnetRouting() findings = %q, want %q", gotFindings, test.wantFindings)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package awsverifier
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	awsTools "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes/legacy"
+	"github.com/openshift/osd-network-verifier/pkg/proxy"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// maxFindingTargets caps how many affected endpoints are listed in a single security group finding
+const maxFindingTargets = 3
+
+// securityGroupRules holds the egress rules of a single security group
+type securityGroupRules struct {
+	// label identifies the group in results, e.g., "security group sg-0123456789abcdef0"
+	label  string
+	egress []ec2Types.IpPermission
+}
+
+// describeProbeSecurityGroups returns the egress rules of the security groups a probe instance in
+// vpcID verifying egress to egressURLs would be attached to: the user-provided groups (if any) and, unless only user-provided
+// groups are used, the temporary security group (which isn't created by this function). Findings
+// about the user-provided groups are stored in out (see checkUserSecurityGroups)
+func (a *AwsVerifier) describeProbeSecurityGroups(vei verifier.ValidateEgressInput, vpcID string, egressURLs []string, out *output.Output) ([]securityGroupRules, error) {
+	var groups []securityGroupRules
+	if len(vei.AWS.SecurityGroupIDs) > 0 {
+		userGroups, err := a.checkUserSecurityGroups(vei.Ctx, vpcID, vei.AWS.SecurityGroupIDs, out)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, securityGroupRulesOf(userGroups)...)
+	}
+
+	if len(vei.AWS.SecurityGroupIDs) == 0 || vei.ForceTempSecurityGroup {
+		egress, err := tempSecurityGroupEgressRules(vei, egressURLs)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, securityGroupRules{label: "the temporary security group", egress: egress})
+	}
+	return groups, nil
+}
+
+// securityGroupRulesOf returns the egress rules of each of groups
+func securityGroupRulesOf(groups []ec2Types.SecurityGroup) []securityGroupRules {
+	rules := make([]securityGroupRules, 0, len(groups))
+	for _, group := range groups {
+		rules = append(rules, securityGroupRules{
+			label:  "security group " + awsTools.ToString(group.GroupId),
+			egress: group.IpPermissionsEgress,
+		})
+	}
+	return rules
+}
+
+// analyzeUserSecurityGroups checks, before any probe runs, whether the user-provided security groups
+// allow a probe instance in subnetID (part of vpcID) to reach every endpoint in egressURLs (or the
+// proxy, if a request would be proxied) over every IP family vei checks. The groups are analyzed on
+// their own, as that's how they'll be used by the cluster. Gaps and stale references are stored in
+// out as security group findings (see handledErrors.SecurityGroupFinding)
+func (a *AwsVerifier) analyzeUserSecurityGroups(vei verifier.ValidateEgressInput, subnetID string, vpcID string, egressURLs []string, out *output.Output) {
+	userGroups, err := a.checkUserSecurityGroups(vei.Ctx, vpcID, vei.AWS.SecurityGroupIDs, out)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to analyze security groups %s: %w", strings.Join(vei.AWS.SecurityGroupIDs, ", "), err))
+		return
+	}
+	if len(userGroups) == 0 {
+		return
+	}
+
+	endpoints, err := staticEndpoints(vei.Proxy, egressURLs)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to analyze security groups %s: %w", strings.Join(vei.AWS.SecurityGroupIDs, ", "), err))
+		return
+	}
+	cfg := vpcEgressConfig{securityGroups: securityGroupRulesOf(userGroups)}
+	// Zero-egress clusters reach every endpoint within the VPC, whose CIDR blocks are found in the
+	// route table's local routes
+	if vei.PlatformType == cloud.AWSHCPZeroEgress {
+		cfg.routeTable, _, err = a.describeEffectiveRouteTable(vei.Ctx, subnetID, vpcID)
+		if err != nil {
+			out.AddWarning(fmt.Errorf("unable to analyze security groups %s: %w", strings.Join(vei.AWS.SecurityGroupIDs, ", "), err))
+			return
+		}
+	}
+
+	// Endpoints blocked for the same reason (e.g., every endpoint on port 9997) share a finding
+	gaps, gapTargets := groupBlockedTargets(cfg.destinations(vei, endpoints), func(dest staticDestination) *egressBlock {
+		return securityGroupsBlock(cfg.securityGroups, dest)
+	})
+	for _, gap := range gaps {
+		out.AddWarning(handledErrors.NewSecurityGroupFinding(vei.AWS.SecurityGroupIDs, fmt.Sprintf("%s, needed by %s", gap, summarizeTargets(gapTargets[gap]))))
+	}
+	if len(gaps) == 0 {
+		a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("%s allow egress to every endpoint", securityGroupsLabel(cfg.securityGroups)))
+	}
+}
+
+// summarizeTargets lists up to maxFindingTargets of targets, e.g., "tcp://a:9997, tcp://b:9997 and
+// 3 more"
+func summarizeTargets(targets []string) string {
+	if len(targets) <= maxFindingTargets {
+		return strings.Join(targets, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(targets[:maxFindingTargets], ", "), len(targets)-maxFindingTargets)
+}
+
+// checkUserSecurityGroups describes the user-provided security groups groupIDs, storing a finding in
+// out for each group that doesn't exist or belongs to a VPC other than vpcID, and for each group
+// with stale egress rules (i.e., rules referencing security groups that no longer exist, such as
+// groups in a deleted peered VPC). It returns the groups that can be attached to instances in vpcID
+func (a *AwsVerifier) checkUserSecurityGroups(ctx context.Context, vpcID string, groupIDs []string, out *output.Output) ([]ec2Types.SecurityGroup, error) {
+	// Filtering by ID (rather than requesting the IDs directly) omits missing groups instead of
+	// failing the whole request
+	describeOutput, err := a.AwsClient.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []ec2Types.Filter{
+			{
+				Name:   awsTools.String("group-id"),
+				Values: groupIDs,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []ec2Types.SecurityGroup
+	found := map[string]bool{}
+	for _, group := range describeOutput.SecurityGroups {
+		groupID := awsTools.ToString(group.GroupId)
+		found[groupID] = true
+		if groupVpcID := awsTools.ToString(group.VpcId); groupVpcID != vpcID {
+			out.AddWarning(handledErrors.NewSecurityGroupFinding([]string{groupID}, fmt.Sprintf("security group %s belongs to VPC %s rather than the subnet's VPC %s, so it can't be attached to instances in the subnet", groupID, groupVpcID, vpcID)))
+			continue
+		}
+		groups = append(groups, group)
+	}
+	for _, groupID := range groupIDs {
+		if !found[groupID] {
+			out.AddWarning(handledErrors.NewSecurityGroupFinding([]string{groupID}, fmt.Sprintf("security group %s doesn't exist (it may have been deleted)", groupID)))
+		}
+	}
+
+	staleOutput, err := a.AwsClient.DescribeStaleSecurityGroups(ctx, &ec2.DescribeStaleSecurityGroupsInput{VpcId: awsTools.String(vpcID)})
+	if err != nil {
+		// Stale rules are only reported on a best-effort basis, e.g., in case of missing permissions
+		a.writeDebugLogs(ctx, out, fmt.Sprintf("unable to check for stale security group rules: %s", err))
+		return groups, nil
+	}
+	for _, staleGroup := range staleOutput.StaleSecurityGroupSet {
+		groupID := awsTools.ToString(staleGroup.GroupId)
+		if !slices.Contains(groupIDs, groupID) || len(staleGroup.StaleIpPermissionsEgress) == 0 {
+			continue
+		}
+		var staleReferences []string
+		for _, staleIPPermission := range staleGroup.StaleIpPermissionsEgress {
+			for _, groupPair := range staleIPPermission.UserIdGroupPairs {
+				if staleReference := awsTools.ToString(groupPair.GroupId); staleReference != "" && !slices.Contains(staleReferences, staleReference) {
+					staleReferences = append(staleReferences, staleReference)
+				}
+			}
+		}
+		out.AddWarning(handledErrors.NewSecurityGroupFinding([]string{groupID}, fmt.Sprintf("security group %s has %d stale egress rules referencing security groups that no longer exist (%s)", groupID, len(staleGroup.StaleIpPermissionsEgress), strings.Join(staleReferences, ", "))))
+	}
+	return groups, nil
+}
+
+// tempSecurityGroupEgressRules returns the egress rules of the temporary security group created for
+// probe instances verifying egress to egressURLs (see createTempSecurityGroup)
+func tempSecurityGroupEgressRules(vei verifier.ValidateEgressInput, egressURLs []string) ([]ec2Types.IpPermission, error) {
+	egress, err := tempSecurityGroupIPPermissions(vei, egressURLs)
+	if err != nil {
+		return nil, err
+	}
+	if vei.IPFamily.IncludesIPv6() {
+		egress = withIPv6AnyRanges(egress)
+	}
+	return egress, nil
+}
+
+// tempSecurityGroupIPPermissions returns the IPv4 egress rules of the temporary security group
+// created for probe instances verifying egress to egressURLs: baseIpPermissions plus one rule per
+// distinct port (and IP address, for URLs given by IP) among egressURLs, vei's transfer and egress
+// IP echo URLs, and the configured proxies. legacy.Probe ignores egressURLs in favor of its own
+// egress list, so defaultIpPermissions are used in its place
+func tempSecurityGroupIPPermissions(vei verifier.ValidateEgressInput, egressURLs []string) ([]ec2Types.IpPermission, error) {
+	ipPermissions := slices.Clone(baseIpPermissions)
+	probeURLs := slices.Clone(egressURLs)
+	if _, isLegacy := vei.Probe.(legacy.Probe); isLegacy {
+		ipPermissions = slices.Clone(defaultIpPermissions)
+		probeURLs = nil
+	}
+	probeURLs = append(probeURLs, vei.TransferURLs...)
+	if vei.EgressIPEchoURL != "" {
+		probeURLs = append(probeURLs, vei.EgressIPEchoURL)
+	}
+
+	// Rules are described by the first URL needing them, as hostnames share a rule per port
+	for _, urlSet := range []struct {
+		urlStrs           []string
+		descriptionPrefix string
+	}{
+		{urlStrs: probeURLs, descriptionPrefix: "Egress to endpoints such as "},
+		{urlStrs: proxyURLs(vei.Proxy), descriptionPrefix: "Egress to user-provided proxy "},
+	} {
+		urlIPPermissions, err := ipPermissionSetFromURLs(urlSet.urlStrs, urlSet.descriptionPrefix, ipPermissions)
+		if err != nil {
+			return nil, err
+		}
+		ipPermissions = append(ipPermissions, urlIPPermissions...)
+	}
+	return ipPermissions, nil
+}
+
+// describeIPPermissions summarizes ipPermissions for the report, e.g., "udp/53 to 0.0.0.0/0,
+// tcp/443 to 0.0.0.0/0 and ::/0"
+func describeIPPermissions(ipPermissions []ec2Types.IpPermission) string {
+	descriptions := make([]string, 0, len(ipPermissions))
+	for _, ipPermission := range ipPermissions {
+		var cidrs []string
+		for _, ipRange := range ipPermission.IpRanges {
+			cidrs = append(cidrs, awsTools.ToString(ipRange.CidrIp))
+		}
+		for _, ipv6Range := range ipPermission.Ipv6Ranges {
+			cidrs = append(cidrs, awsTools.ToString(ipv6Range.CidrIpv6))
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s/%d to %s", awsTools.ToString(ipPermission.IpProtocol), awsTools.ToInt32(ipPermission.FromPort), strings.Join(cidrs, " and ")))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// proxyURLs returns the configured HTTP and HTTPS proxy URLs, if any
+func proxyURLs(proxyConfig proxy.ProxyConfig) []string {
+	urls := make([]string, 0, 2)
+	if proxyConfig.HttpProxy != "" {
+		urls = append(urls, proxyConfig.HttpProxy)
+	}
+	if proxyConfig.HttpsProxy != "" {
+		urls = append(urls, proxyConfig.HttpsProxy)
+	}
+	return urls
+}
+
+// securityGroupsBlock returns nil if any egress rule of groups allows traffic to dest (security
+// groups attached to the same instance allow the union of their rules). Rules referencing prefix
+// lists or other security groups aren't evaluated
+func securityGroupsBlock(groups []securityGroupRules, dest staticDestination) *egressBlock {
+	if len(groups) == 0 {
+		return nil
+	}
+	var unevaluatedRules bool
+	for _, group := range groups {
+		for _, ipPermission := range group.egress {
+			if ipPermissionAllows(ipPermission, dest) {
+				return nil
+			}
+			unevaluatedRules = unevaluatedRules || len(ipPermission.PrefixListIds) > 0 || len(ipPermission.UserIdGroupPairs) > 0
+		}
+	}
+
+	reason := fmt.Sprintf("no egress rule allows %s", dest)
+	if unevaluatedRules {
+		reason += " (rules referencing prefix lists or security groups aren't evaluated)"
+	}
+	return &egressBlock{component: securityGroupsLabel(groups), reason: reason}
+}
+
+// securityGroupsLabel identifies groups in results, e.g., "security group sg-0123"
+func securityGroupsLabel(groups []securityGroupRules) string {
+	labels := make([]string, 0, len(groups))
+	for _, group := range groups {
+		labels = append(labels, group.label)
+	}
+	return strings.Join(labels, " and ")
+}
+
+// ipPermissionAllows returns true if a security group rule allows TCP traffic to every address
+// that dest could be sent to
+func ipPermissionAllows(ipPermission ec2Types.IpPermission, dest staticDestination) bool {
+	switch awsTools.ToString(ipPermission.IpProtocol) {
+	case "-1":
+	case "tcp", "6":
+		if ipPermission.FromPort == nil || ipPermission.ToPort == nil || dest.port < *ipPermission.FromPort || dest.port > *ipPermission.ToPort {
+			return false
+		}
+	default:
+		return false
+	}
+
+	for _, ipRange := range ipPermission.IpRanges {
+		if prefixCovers(awsTools.ToString(ipRange.CidrIp), dest.prefix) {
+			return true
+		}
+	}
+	for _, ipv6Range := range ipPermission.Ipv6Ranges {
+		if prefixCovers(awsTools.ToString(ipv6Range.CidrIpv6), dest.prefix) {
+			return true
+		}
+	}
+	return false
+}
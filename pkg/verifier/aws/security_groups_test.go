@@ -0,0 +1,181 @@
+package awsverifier
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	awss "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/mocks"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+	"github.com/openshift/osd-network-verifier/pkg/probes/legacy"
+	"github.com/openshift/osd-network-verifier/pkg/proxy"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+func TestAwsVerifier_analyzeUserSecurityGroups(t *testing.T) {
+	egressURLs := []string{
+		"https://quay.io:443",
+		"tcp://a.example.com:9997",
+		"tcp://b.example.com:9997",
+		"tcp://c.example.com:9997",
+		"tcp://d.example.com:9997",
+		"tcp://e.example.com:9997",
+	}
+	allowHTTPS := []ec2Types.IpPermission{
+		{
+			IpProtocol: awss.String("tcp"), FromPort: awss.Int32(443), ToPort: awss.Int32(443),
+			IpRanges: []ec2Types.IpRange{{CidrIp: awss.String("0.0.0.0/0")}},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		groupIDs       []string
+		proxy          proxy.ProxyConfig
+		securityGroups []ec2Types.SecurityGroup
+		staleGroups    []ec2Types.StaleSecurityGroup
+		want           []string
+	}{
+		{
+			name:     "gaps are grouped by port",
+			groupIDs: []string{"sg-1"},
+			securityGroups: []ec2Types.SecurityGroup{
+				{GroupId: awss.String("sg-1"), VpcId: awss.String("vpc-1"), IpPermissionsEgress: allowHTTPS},
+			},
+			want: []string{
+				"security group finding: security group sg-1: no egress rule allows tcp/9997 to any IPv4 address, needed by tcp://a.example.com:9997, tcp://b.example.com:9997, tcp://c.example.com:9997 and 2 more",
+			},
+		},
+		{
+			name:     "proxied requests only need the proxy port",
+			groupIDs: []string{"sg-1"},
+			proxy:    proxy.ProxyConfig{HttpsProxy: "http://proxy.example.com:8080"},
+			securityGroups: []ec2Types.SecurityGroup{
+				{GroupId: awss.String("sg-1"), VpcId: awss.String("vpc-1"), IpPermissionsEgress: allowHTTPS},
+			},
+			want: []string{
+				"security group finding: security group sg-1: no egress rule allows tcp/8080 to any IPv4 address (proxy http://proxy.example.com:8080), needed by https://quay.io:443",
+				"security group finding: security group sg-1: no egress rule allows tcp/9997 to any IPv4 address, needed by tcp://a.example.com:9997, tcp://b.example.com:9997, tcp://c.example.com:9997 and 2 more",
+			},
+		},
+		{
+			name:     "missing, foreign, and stale groups",
+			groupIDs: []string{"sg-1", "sg-2", "sg-3"},
+			securityGroups: []ec2Types.SecurityGroup{
+				{GroupId: awss.String("sg-1"), VpcId: awss.String("vpc-1"), IpPermissionsEgress: []ec2Types.IpPermission{{IpProtocol: awss.String("-1"), IpRanges: []ec2Types.IpRange{{CidrIp: awss.String("0.0.0.0/0")}}}}},
+				{GroupId: awss.String("sg-2"), VpcId: awss.String("vpc-2")},
+			},
+			staleGroups: []ec2Types.StaleSecurityGroup{
+				{
+					GroupId: awss.String("sg-1"),
+					StaleIpPermissionsEgress: []ec2Types.StaleIpPermission{
+						{UserIdGroupPairs: []ec2Types.UserIdGroupPair{{GroupId: awss.String("sg-9")}}},
+					},
+				},
+				{
+					GroupId: awss.String("sg-unrelated"),
+					StaleIpPermissionsEgress: []ec2Types.StaleIpPermission{
+						{UserIdGroupPairs: []ec2Types.UserIdGroupPair{{GroupId: awss.String("sg-8")}}},
+					},
+				},
+			},
+			want: []string{
+				"security group finding: security group sg-2 belongs to VPC vpc-2 rather than the subnet's VPC vpc-1, so it can't be attached to instances in the subnet",
+				"security group finding: security group sg-3 doesn't exist (it may have been deleted)",
+				"security group finding: security group sg-1 has 1 stale egress rules referencing security groups that no longer exist (sg-9)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+			FakeEC2Cli.EXPECT().DescribeSecurityGroups(gomock.Any(), gomock.Any()).Return(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: test.securityGroups}, nil)
+			FakeEC2Cli.EXPECT().DescribeStaleSecurityGroups(gomock.Any(), gomock.Any()).Return(&ec2.DescribeStaleSecurityGroupsOutput{StaleSecurityGroupSet: test.staleGroups}, nil)
+
+			cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+
+			out := &output.Output{}
+			cli.analyzeUserSecurityGroups(verifier.ValidateEgressInput{
+				Ctx:   context.TODO(),
+				Proxy: test.proxy,
+				AWS:   verifier.AwsEgressConfig{SecurityGroupIDs: test.groupIDs},
+			}, "subnet-a", "vpc-1", egressURLs, out)
+
+			var got []string
+			for _, warning := range out.GetWarnings() {
+				finding, ok := warning.(*handledErrors.SecurityGroupFinding)
+				if !ok {
+					t.Fatalf("analyzeUserSecurityGroups() unexpected warning: %v", warning)
+				}
+				got = append(got, finding.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("analyzeUserSecurityGroups() findings = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_tempSecurityGroupIPPermissions(t *testing.T) {
+	egressURLs := []string{
+		"https://quay.io:443",
+		"https://api.openshift.com:443",
+		"telnet://db.example.com:5432",
+		"telnet://10.0.0.5:8443",
+	}
+
+	tests := []struct {
+		name string
+		vei  verifier.ValidateEgressInput
+		want string
+	}{
+		{
+			name: "egress list ports",
+			vei:  verifier.ValidateEgressInput{Probe: curl.Probe{}},
+			want: "udp/53 to 0.0.0.0/0, tcp/443 to 0.0.0.0/0, tcp/5432 to 0.0.0.0/0, tcp/8443 to 10.0.0.5/32",
+		},
+		{
+			name: "proxy, transfer, and egress IP echo URLs",
+			vei: verifier.ValidateEgressInput{
+				Probe:           curl.Probe{},
+				Proxy:           proxy.ProxyConfig{HttpProxy: "http://proxy.example.com:3128", HttpsProxy: "http://proxy.example.com:3128"},
+				TransferURLs:    []string{"https://mirror.example.com/large-file"},
+				EgressIPEchoURL: "http://checkip.example.com",
+			},
+			want: "udp/53 to 0.0.0.0/0, tcp/443 to 0.0.0.0/0, tcp/5432 to 0.0.0.0/0, tcp/8443 to 10.0.0.5/32, tcp/80 to 0.0.0.0/0, tcp/3128 to 0.0.0.0/0",
+		},
+		{
+			name: "legacy probe",
+			vei: verifier.ValidateEgressInput{
+				Probe: legacy.Probe{},
+				Proxy: proxy.ProxyConfig{HttpsProxy: "http://10.0.0.9:443"},
+			},
+			want: "tcp/80 to 0.0.0.0/0, tcp/443 to 0.0.0.0/0, tcp/9997 to 0.0.0.0/0, udp/53 to 0.0.0.0/0, tcp/443 to 10.0.0.9/32",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := tempSecurityGroupIPPermissions(test.vei, egressURLs)
+			if err != nil {
+				t.Fatalf("tempSecurityGroupIPPermissions() unexpected error: %v", err)
+			}
+			if description := describeIPPermissions(got); description != test.want {
+				t.Errorf("tempSecurityGroupIPPermissions() = %q, want %q", description, test.want)
+			}
+		})
+	}
+}
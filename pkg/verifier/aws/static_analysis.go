@@ -0,0 +1,417 @@
+package awsverifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	awsTools "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	"github.com/openshift/osd-network-verifier/pkg/data/egress_lists"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/proxy"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// vpcEgressConfig is a snapshot of the VPC configuration that decides whether traffic from a
+// subnet can leave its VPC
+type vpcEgressConfig struct {
+	subnet     ec2Types.Subnet
+	routeTable ec2Types.RouteTable
+	// mainRouteTable is true if the subnet has no route table of its own, and so uses the VPC's
+	// main route table
+	mainRouteTable bool
+	networkACL     ec2Types.NetworkAcl
+	// natGateways holds every NAT gateway routed to by routeTable, by ID
+	natGateways map[string]ec2Types.NatGateway
+	// securityGroups holds the egress rules of every security group a probe instance would be
+	// attached to
+	securityGroups []securityGroupRules
+}
+
+// staticEndpoint is an egress endpoint along with where the probe would actually connect to reach it
+type staticEndpoint struct {
+	// url is the endpoint's URL on the egress list, e.g., "https://quay.io:443"
+	url string
+	// host and port are what the probe connects to, i.e., the endpoint's or, if the request would
+	// be proxied, the proxy's
+	host string
+	port int32
+	// proxy is the (redacted) URL of the proxy the request would be sent through, if any
+	proxy string
+}
+
+// staticDestination is where traffic to a single egress endpoint is sent over a single IP family
+type staticDestination struct {
+	port int32
+	// prefix holds the address traffic is sent to or, if that isn't known until DNS resolution,
+	// every address traffic could be sent to (e.g., 0.0.0.0/0)
+	prefix netip.Prefix
+	proxy  string
+}
+
+// String describes the destination in results, e.g., "tcp/443 to any IPv4 address"
+func (d staticDestination) String() string {
+	destination := fmt.Sprintf("tcp/%d to %s", d.port, describePrefix(d.prefix))
+	if d.proxy != "" {
+		destination += fmt.Sprintf(" (proxy %s)", d.proxy)
+	}
+	return destination
+}
+
+// labeledDestination is a staticDestination labeled with its endpoint the way the curl probe would
+// label the endpoint's result, e.g., "https://quay.io:443 [ipv4]"
+type labeledDestination struct {
+	target string
+	staticDestination
+}
+
+// egressBlock describes the component of a VPC predicted to block traffic to a destination
+type egressBlock struct {
+	// component identifies the blocking resource, e.g., "network ACL acl-0123456789abcdef0"
+	component string
+	// reason explains why the component blocks the traffic
+	reason string
+}
+
+func (b egressBlock) String() string {
+	return fmt.Sprintf("%s: %s", b.component, b.reason)
+}
+
+// analyzeEgressStatically predicts whether each endpoint on the egress list can be reached from
+// each target subnet using only the configuration of the subnet's VPC (its route table, NAT and
+// internet gateways, network ACL, and the security groups a probe instance would use), without
+// launching any instances. Endpoints predicted to be blocked are stored in out as egress failures
+// naming the blocking component. Multi-subnet results are nested in out, as they would be for a
+// regular multi-subnet run
+func (a *AwsVerifier) analyzeEgressStatically(vei verifier.ValidateEgressInput, out *output.Output) {
+	subnetIDs := vei.TargetSubnetIDs()
+	if len(subnetIDs) == 0 {
+		out.AddError(errors.New("static analysis requires a subnet ID"))
+		return
+	}
+
+	generatorVariables := map[string]string{"AWS_REGION": a.AwsClient.Region}
+	generator := egress_lists.NewGenerator(vei.PlatformType, generatorVariables, a.Logger)
+	egressListStr, tlsDisabledEgressListStr, err := generator.GenerateEgressLists(vei.Ctx, vei.EgressListYaml)
+	if err != nil {
+		out.AddError(err)
+		return
+	}
+	egressURLs := append(strings.Fields(egressListStr), strings.Fields(tlsDisabledEgressListStr)...)
+	endpoints, err := staticEndpoints(vei.Proxy, egressURLs)
+	if err != nil {
+		out.AddError(err)
+		return
+	}
+
+	out.AddInfo("static analysis only predicts whether traffic can leave the VPC; DNS, proxies, and anything beyond the VPC (e.g., firewalls behind a transit gateway) aren't checked")
+	if len(subnetIDs) == 1 {
+		a.analyzeSubnetStatically(vei, subnetIDs[0], egressURLs, endpoints, out)
+		return
+	}
+	for _, subnetID := range subnetIDs {
+		subnetOut := &output.Output{}
+		label := a.analyzeSubnetStatically(vei, subnetID, egressURLs, endpoints, subnetOut)
+		out.AddSubnetResult(label, subnetOut)
+	}
+}
+
+// analyzeSubnetStatically stores the results of static analysis of egress from subnetID to endpoints
+// (the destinations of egressURLs) in out (see analyzeEgressStatically), returning a label
+// identifying the subnet
+func (a *AwsVerifier) analyzeSubnetStatically(vei verifier.ValidateEgressInput, subnetID string, egressURLs []string, endpoints []staticEndpoint, out *output.Output) string {
+	cfg, err := a.describeVpcEgressConfig(vei.Ctx, subnetID)
+	if err != nil {
+		out.AddError(err)
+		return subnetID
+	}
+	label := subnetPlacement{
+		subnetID:         subnetID,
+		availabilityZone: awsTools.ToString(cfg.subnet.AvailabilityZone),
+	}.label()
+
+	cfg.securityGroups, err = a.describeProbeSecurityGroups(vei, awsTools.ToString(cfg.subnet.VpcId), egressURLs, out)
+	if err != nil {
+		out.AddError(err)
+		return label
+	}
+
+	routeTableLabel := awsTools.ToString(cfg.routeTable.RouteTableId)
+	if cfg.mainRouteTable {
+		routeTableLabel += " (main)"
+	}
+	a.writeDebugLogs(vei.Ctx, out, fmt.Sprintf("analyzing egress from %s using route table %s and network ACL %s", subnetID, routeTableLabel, awsTools.ToString(cfg.networkACL.NetworkAclId)))
+
+	var failures []string
+	var exits []string
+	exitCounts := map[string]int{}
+	destinations := cfg.destinations(vei, endpoints)
+	for _, destination := range destinations {
+		exit, block := cfg.predictEgress(destination.staticDestination)
+		if block != nil {
+			failures = append(failures, fmt.Sprintf("%s (predicted blocked by %s)", destination.target, block))
+			continue
+		}
+		if exit != "" && exitCounts[exit] == 0 {
+			exits = append(exits, exit)
+		}
+		exitCounts[exit]++
+	}
+	out.SetEgressFailures(failures)
+
+	out.AddInfo(fmt.Sprintf("static analysis: %d of %d endpoints predicted reachable from %s (route table %s, network ACL %s, %s)",
+		len(destinations)-len(failures), len(destinations), subnetID, routeTableLabel, awsTools.ToString(cfg.networkACL.NetworkAclId), securityGroupsLabel(cfg.securityGroups)))
+	for _, exit := range exits {
+		out.AddInfo(fmt.Sprintf("%d endpoints predicted to leave the VPC via %s", exitCounts[exit], exit))
+	}
+	return label
+}
+
+// groupBlockedTargets returns the reasons block gives for blocking destinations, in order of first
+// appearance, along with the targets blocked for each reason, so that, e.g., every endpoint on a
+// port without an egress rule shares a single finding
+func groupBlockedTargets(destinations []labeledDestination, block func(staticDestination) *egressBlock) ([]string, map[string][]string) {
+	var blocks []string
+	blockedTargets := map[string][]string{}
+	for _, destination := range destinations {
+		destinationBlock := block(destination.staticDestination)
+		if destinationBlock == nil {
+			continue
+		}
+		reason := destinationBlock.String()
+		if _, ok := blockedTargets[reason]; !ok {
+			blocks = append(blocks, reason)
+		}
+		blockedTargets[reason] = append(blockedTargets[reason], destination.target)
+	}
+	return blocks, blockedTargets
+}
+
+// destinations returns where traffic to each of endpoints would be sent over each IP family vei
+// checks (IPv4, if none was requested)
+func (cfg vpcEgressConfig) destinations(vei verifier.ValidateEgressInput, endpoints []staticEndpoint) []labeledDestination {
+	// The curl probe only labels results with an IP family if one was requested
+	families := vei.IPFamily.Passes()
+	labelFamilies := len(families) > 0
+	if !labelFamilies {
+		families = []ipfamily.Family{ipfamily.IPv4}
+	}
+	// Zero-egress clusters reach every endpoint through VPC endpoints within the VPC
+	private := vei.PlatformType == cloud.AWSHCPZeroEgress
+
+	var destinations []labeledDestination
+	for _, family := range families {
+		for _, endpoint := range endpoints {
+			prefix, ok := cfg.destinationPrefix(endpoint.host, family == ipfamily.IPv6, private)
+			if !ok {
+				continue
+			}
+			target := strings.Replace(endpoint.url, "telnet", "tcp", 1)
+			if labelFamilies {
+				target += " [" + family.String() + "]"
+			}
+			destinations = append(destinations, labeledDestination{
+				target:            target,
+				staticDestination: staticDestination{port: endpoint.port, prefix: prefix, proxy: endpoint.proxy},
+			})
+		}
+	}
+	return destinations
+}
+
+// describeVpcEgressConfig gathers the configuration deciding whether traffic from subnetID can leave
+// its VPC, except for security groups (see describeProbeSecurityGroups)
+func (a *AwsVerifier) describeVpcEgressConfig(ctx context.Context, subnetID string) (vpcEgressConfig, error) {
+	var cfg vpcEgressConfig
+
+	subnetsOutput, err := a.AwsClient.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: []string{subnetID}})
+	if err != nil {
+		return cfg, err
+	}
+	if len(subnetsOutput.Subnets) == 0 {
+		return cfg, fmt.Errorf("no subnets returned for subnet id: %s", subnetID)
+	}
+	cfg.subnet = subnetsOutput.Subnets[0]
+
+	cfg.routeTable, cfg.mainRouteTable, err = a.describeEffectiveRouteTable(ctx, subnetID, awsTools.ToString(cfg.subnet.VpcId))
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.networkACL, err = a.describeSubnetNetworkACL(ctx, subnetID)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.natGateways, err = a.describeRoutedNatGateways(ctx, cfg.routeTable)
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// staticEndpoints returns where the probe would connect to reach each of egressURLs, following
+// curl's interpretation of proxyConfig
+func staticEndpoints(proxyConfig proxy.ProxyConfig, egressURLs []string) ([]staticEndpoint, error) {
+	routes, err := proxyConfig.Explain(egressURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]staticEndpoint, 0, len(routes))
+	for _, route := range routes {
+		endpoint := staticEndpoint{url: route.URL}
+		connectURL := route.URL
+		if !route.Curl.IsDirect() {
+			endpoint.proxy = route.Curl.Proxy
+			connectURL = route.Curl.Proxy
+		}
+		endpoint.host, endpoint.port, err = urlHostPort(connectURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to analyze egress to '%s': %w", route.URL, err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// urlHostPort returns the host and TCP port that a request to rawURL connects to
+func urlHostPort(rawURL string) (string, int32, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0, err
+	}
+	portStr := parsedURL.Port()
+	if portStr == "" {
+		switch parsedURL.Scheme {
+		case "http":
+			portStr = "80"
+		case "https":
+			portStr = "443"
+		default:
+			return "", 0, errors.New("unsupported URL scheme")
+		}
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, errors.New("invalid port")
+	}
+	return parsedURL.Hostname(), int32(port), nil
+}
+
+// destinationPrefix returns the addresses that traffic to host could be sent to over IPv6 (or IPv4,
+// if ipv6 is false). Hostnames could resolve to any address, unless private is true, in which case
+// they resolve within the VPC (e.g., to interface VPC endpoints). The returned bool is false if host
+// is an IP address of the other family, so can't be reached at all
+func (cfg vpcEgressConfig) destinationPrefix(host string, ipv6 bool, private bool) (netip.Prefix, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		if addr.Is6() != ipv6 {
+			return netip.Prefix{}, false
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+
+	anyAddress := netip.MustParsePrefix("0.0.0.0/0")
+	if ipv6 {
+		anyAddress = netip.MustParsePrefix("::/0")
+	}
+	if private {
+		for _, route := range cfg.routeTable.Routes {
+			if awsTools.ToString(route.GatewayId) != "local" {
+				continue
+			}
+			if vpcPrefix, err := netip.ParsePrefix(routeDestination(route, ipv6)); err == nil {
+				return vpcPrefix.Masked(), true
+			}
+		}
+	}
+	return anyAddress, true
+}
+
+// predictEgress predicts whether traffic to dest can leave the VPC, checking the subnet, security
+// groups, network ACL and route table in the order the traffic would encounter them. It returns a
+// description of where the traffic would leave the VPC (empty if dest is within the VPC) or, if the
+// traffic would be blocked, the first component blocking it
+func (cfg vpcEgressConfig) predictEgress(dest staticDestination) (string, *egressBlock) {
+	if dest.prefix.Addr().Is6() && !awsTools.ToBool(cfg.subnet.AssignIpv6AddressOnCreation) {
+		return "", &egressBlock{
+			component: "subnet " + awsTools.ToString(cfg.subnet.SubnetId),
+			reason:    "doesn't assign IPv6 addresses to instances",
+		}
+	}
+	if block := securityGroupsBlock(cfg.securityGroups, dest); block != nil {
+		return "", block
+	}
+	if block := networkACLBlock(cfg.networkACL, dest); block != nil {
+		return "", block
+	}
+	return cfg.routeExit(dest)
+}
+
+// routeExit returns where the route table would send traffic to dest out of the VPC (empty if dest
+// is within the VPC), or why the traffic can't leave
+func (cfg vpcEgressConfig) routeExit(dest staticDestination) (string, *egressBlock) {
+	component := "route table " + awsTools.ToString(cfg.routeTable.RouteTableId)
+	route, ok := effectiveRoute(cfg.routeTable, dest.prefix)
+	if !ok {
+		return "", &egressBlock{component: component, reason: fmt.Sprintf("no route to %s", describePrefix(dest.prefix))}
+	}
+
+	target := routeTarget(route)
+	if route.State == ec2Types.RouteStateBlackhole {
+		return "", &egressBlock{component: component, reason: fmt.Sprintf("route to %s via %s is a blackhole (its target no longer exists)", routeDestination(route, dest.prefix.Addr().Is6()), target)}
+	}
+
+	switch {
+	case awsTools.ToString(route.GatewayId) == "local":
+		return "", nil
+	case route.NatGatewayId != nil:
+		natGateway, ok := cfg.natGateways[*route.NatGatewayId]
+		state := "not found"
+		if ok {
+			state = string(natGateway.State)
+		}
+		if state != string(ec2Types.NatGatewayStateAvailable) {
+			return "", &egressBlock{component: component, reason: fmt.Sprintf("routes %s to %s, which is %s", describePrefix(dest.prefix), target, state)}
+		}
+	case strings.HasPrefix(awsTools.ToString(route.GatewayId), "igw-") && dest.prefix.Addr().Is4() && !awsTools.ToBool(cfg.subnet.MapPublicIpOnLaunch):
+		return "", &egressBlock{component: component, reason: fmt.Sprintf("routes %s to %s, but the subnet doesn't assign public IPv4 addresses to instances", describePrefix(dest.prefix), target)}
+	}
+	return target, nil
+}
+
+// prefixCovers returns true if cidr (e.g., a security group rule's CIDR block) includes every
+// address in prefix
+func prefixCovers(cidr string, prefix netip.Prefix) bool {
+	cidrPrefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return false
+	}
+	cidrPrefix = cidrPrefix.Masked()
+	return cidrPrefix.Addr().Is4() == prefix.Addr().Is4() && cidrPrefix.Bits() <= prefix.Bits() && cidrPrefix.Contains(prefix.Addr())
+}
+
+// describePrefix describes a destination prefix in results, e.g., "any IPv4 address"
+func describePrefix(prefix netip.Prefix) string {
+	switch {
+	case prefix.Bits() == 0 && prefix.Addr().Is4():
+		return "any IPv4 address"
+	case prefix.Bits() == 0:
+		return "any IPv6 address"
+	case prefix.IsSingleIP():
+		return prefix.Addr().String()
+	default:
+		return prefix.String()
+	}
+}
@@ -0,0 +1,294 @@
+package awsverifier
+
+import (
+	"context"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	awss "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
+	"github.com/openshift/osd-network-verifier/pkg/mocks"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// naclEntry returns a TCP network ACL rule for the given ports (or every protocol and port, if
+// fromPort is 0)
+func naclEntry(ruleNumber int32, egress bool, action ec2Types.RuleAction, cidr string, fromPort, toPort int32) ec2Types.NetworkAclEntry {
+	entry := ec2Types.NetworkAclEntry{
+		RuleNumber: awss.Int32(ruleNumber),
+		Egress:     awss.Bool(egress),
+		RuleAction: action,
+		Protocol:   awss.String("-1"),
+		CidrBlock:  awss.String(cidr),
+	}
+	if fromPort != 0 {
+		entry.Protocol = awss.String("6")
+		entry.PortRange = &ec2Types.PortRange{From: awss.Int32(fromPort), To: awss.Int32(toPort)}
+	}
+	return entry
+}
+
+// staticTestConfig returns the configuration of a private subnet whose traffic leaves the VPC
+// through an available NAT gateway, with a network ACL allowing all traffic and a security group
+// allowing all egress
+func staticTestConfig() vpcEgressConfig {
+	return vpcEgressConfig{
+		subnet: ec2Types.Subnet{SubnetId: awss.String("subnet-a"), VpcId: awss.String("vpc-1"), AvailabilityZone: awss.String("us-east-1a")},
+		routeTable: ec2Types.RouteTable{
+			RouteTableId: awss.String("rtb-1"),
+			Routes: []ec2Types.Route{
+				{DestinationCidrBlock: awss.String("10.0.0.0/16"), GatewayId: awss.String("local"), State: ec2Types.RouteStateActive},
+				{DestinationCidrBlock: awss.String("0.0.0.0/0"), NatGatewayId: awss.String("nat-1"), State: ec2Types.RouteStateActive},
+			},
+		},
+		networkACL: ec2Types.NetworkAcl{
+			NetworkAclId: awss.String("acl-1"),
+			Entries: []ec2Types.NetworkAclEntry{
+				naclEntry(100, true, ec2Types.RuleActionAllow, "0.0.0.0/0", 0, 0),
+				naclEntry(naclDefaultRuleNumber, true, ec2Types.RuleActionDeny, "0.0.0.0/0", 0, 0),
+				naclEntry(100, false, ec2Types.RuleActionAllow, "0.0.0.0/0", 0, 0),
+				naclEntry(naclDefaultRuleNumber, false, ec2Types.RuleActionDeny, "0.0.0.0/0", 0, 0),
+			},
+		},
+		natGateways: map[string]ec2Types.NatGateway{
+			"nat-1": {NatGatewayId: awss.String("nat-1"), State: ec2Types.NatGatewayStateAvailable},
+		},
+		securityGroups: []securityGroupRules{
+			{
+				label: "security group sg-1",
+				egress: []ec2Types.IpPermission{
+					{IpProtocol: awss.String("-1"), IpRanges: []ec2Types.IpRange{{CidrIp: awss.String("0.0.0.0/0")}}},
+				},
+			},
+		},
+	}
+}
+
+func TestVpcEgressConfig_predictEgress(t *testing.T) {
+	anyIPv4 := netip.MustParsePrefix("0.0.0.0/0")
+	tests := []struct {
+		name      string
+		modify    func(cfg *vpcEgressConfig)
+		dest      staticDestination
+		wantExit  string
+		wantBlock string
+	}{
+		{
+			name:     "reachable via NAT gateway",
+			dest:     staticDestination{port: 443, prefix: anyIPv4},
+			wantExit: "NAT gateway nat-1",
+		},
+		{
+			name:     "destination within the VPC",
+			dest:     staticDestination{port: 443, prefix: netip.MustParsePrefix("10.0.1.5/32")},
+			wantExit: "",
+		},
+		{
+			name: "network ACL denies port",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.networkACL.Entries = append(cfg.networkACL.Entries, naclEntry(90, true, ec2Types.RuleActionDeny, "0.0.0.0/0", 9000, 9999))
+			},
+			dest:      staticDestination{port: 9997, prefix: anyIPv4},
+			wantBlock: "network ACL acl-1: outbound rule 90 denies tcp/9997 to any IPv4 address",
+		},
+		{
+			name: "network ACL only allows some addresses",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.networkACL.Entries[0] = naclEntry(100, true, ec2Types.RuleActionAllow, "10.0.0.0/8", 0, 0)
+			},
+			dest:      staticDestination{port: 443, prefix: anyIPv4},
+			wantBlock: "network ACL acl-1: outbound rule * denies tcp/443 to any IPv4 address",
+		},
+		{
+			name: "network ACL doesn't allow return traffic",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.networkACL.Entries[2] = naclEntry(100, false, ec2Types.RuleActionAllow, "0.0.0.0/0", 443, 443)
+			},
+			dest:      staticDestination{port: 443, prefix: anyIPv4},
+			wantBlock: "network ACL acl-1: inbound rule * denies return traffic from any IPv4 address to tcp/32768-60999 (ephemeral ports)",
+		},
+		{
+			name: "network ACL denies some ephemeral ports",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.networkACL.Entries = append(cfg.networkACL.Entries,
+					naclEntry(80, false, ec2Types.RuleActionDeny, "0.0.0.0/0", 40000, 45000),
+					naclEntry(90, false, ec2Types.RuleActionDeny, "0.0.0.0/0", 45001, 50000),
+				)
+			},
+			dest:      staticDestination{port: 443, prefix: anyIPv4},
+			wantBlock: "network ACL acl-1: inbound rule 80 denies return traffic from any IPv4 address to tcp/40000-45000 (ephemeral ports)",
+		},
+		{
+			name: "network ACL allows ephemeral ports across several rules",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.networkACL.Entries[2] = naclEntry(100, false, ec2Types.RuleActionAllow, "0.0.0.0/0", 1024, 40000)
+				cfg.networkACL.Entries = append(cfg.networkACL.Entries, naclEntry(110, false, ec2Types.RuleActionAllow, "0.0.0.0/0", 40001, 65535))
+			},
+			dest:     staticDestination{port: 443, prefix: anyIPv4},
+			wantExit: "NAT gateway nat-1",
+		},
+		{
+			name: "security group doesn't allow port",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.securityGroups[0].egress = []ec2Types.IpPermission{
+					{
+						IpProtocol: awss.String("tcp"), FromPort: awss.Int32(443), ToPort: awss.Int32(443),
+						IpRanges: []ec2Types.IpRange{{CidrIp: awss.String("0.0.0.0/0")}},
+					},
+					{IpProtocol: awss.String("-1"), PrefixListIds: []ec2Types.PrefixListId{{PrefixListId: awss.String("pl-1")}}},
+				}
+			},
+			dest:      staticDestination{port: 3128, prefix: netip.MustParsePrefix("10.1.2.3/32"), proxy: "http://proxy.example.com:3128"},
+			wantBlock: "security group sg-1: no egress rule allows tcp/3128 to 10.1.2.3 (proxy http://proxy.example.com:3128) (rules referencing prefix lists or security groups aren't evaluated)",
+		},
+		{
+			name: "no default route",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.routeTable.Routes = cfg.routeTable.Routes[:1]
+			},
+			dest:      staticDestination{port: 443, prefix: anyIPv4},
+			wantBlock: "route table rtb-1: no route to any IPv4 address",
+		},
+		{
+			name: "blackhole route",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.routeTable.Routes[1].State = ec2Types.RouteStateBlackhole
+			},
+			dest:      staticDestination{port: 443, prefix: anyIPv4},
+			wantBlock: "route table rtb-1: route to 0.0.0.0/0 via NAT gateway nat-1 is a blackhole (its target no longer exists)",
+		},
+		{
+			name: "failed NAT gateway",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.natGateways["nat-1"] = ec2Types.NatGateway{NatGatewayId: awss.String("nat-1"), State: ec2Types.NatGatewayStateFailed}
+			},
+			dest:      staticDestination{port: 443, prefix: anyIPv4},
+			wantBlock: "route table rtb-1: routes any IPv4 address to NAT gateway nat-1, which is failed",
+		},
+		{
+			name: "internet gateway without public IPs",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.routeTable.Routes[1] = ec2Types.Route{DestinationCidrBlock: awss.String("0.0.0.0/0"), GatewayId: awss.String("igw-1"), State: ec2Types.RouteStateActive}
+			},
+			dest:      staticDestination{port: 443, prefix: anyIPv4},
+			wantBlock: "route table rtb-1: routes any IPv4 address to internet gateway igw-1, but the subnet doesn't assign public IPv4 addresses to instances",
+		},
+		{
+			name: "internet gateway with public IPs",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.subnet.MapPublicIpOnLaunch = awss.Bool(true)
+				cfg.routeTable.Routes[1] = ec2Types.Route{DestinationCidrBlock: awss.String("0.0.0.0/0"), GatewayId: awss.String("igw-1"), State: ec2Types.RouteStateActive}
+			},
+			dest:     staticDestination{port: 443, prefix: anyIPv4},
+			wantExit: "internet gateway igw-1",
+		},
+		{
+			name: "more specific transit gateway route",
+			modify: func(cfg *vpcEgressConfig) {
+				cfg.routeTable.Routes = append(cfg.routeTable.Routes, ec2Types.Route{DestinationCidrBlock: awss.String("192.168.0.0/16"), TransitGatewayId: awss.String("tgw-1"), State: ec2Types.RouteStateActive})
+			},
+			dest:     staticDestination{port: 443, prefix: netip.MustParsePrefix("192.168.1.1/32")},
+			wantExit: "transit gateway tgw-1",
+		},
+		{
+			name:      "IPv6 without IPv6 addresses",
+			dest:      staticDestination{port: 443, prefix: netip.MustParsePrefix("::/0")},
+			wantBlock: "subnet subnet-a: doesn't assign IPv6 addresses to instances",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := staticTestConfig()
+			if tt.modify != nil {
+				tt.modify(&cfg)
+			}
+			gotExit, gotBlock := cfg.predictEgress(tt.dest)
+			if tt.wantBlock != "" {
+				if gotBlock == nil || gotBlock.String() != tt.wantBlock {
+					t.Errorf("predictEgress() block = %v, want %q", gotBlock, tt.wantBlock)
+				}
+				return
+			}
+			if gotBlock != nil {
+				t.Fatalf("predictEgress() unexpected block: %v", gotBlock)
+			}
+			if gotExit != tt.wantExit {
+				t.Errorf("predictEgress() exit = %q, want %q", gotExit, tt.wantExit)
+			}
+		})
+	}
+}
+
+func TestValidateEgressStaticAnalysis(t *testing.T) {
+	cfg := staticTestConfig()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+	FakeEC2Cli.EXPECT().DescribeSubnets(gomock.Any(), gomock.Any()).Return(&ec2.DescribeSubnetsOutput{Subnets: []ec2Types.Subnet{cfg.subnet}}, nil)
+	// The subnet has no route table of its own, so the VPC's main route table is used
+	FakeEC2Cli.EXPECT().DescribeRouteTables(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, input *ec2.DescribeRouteTablesInput, _ ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+			if *input.Filters[0].Name == "association.subnet-id" {
+				return &ec2.DescribeRouteTablesOutput{}, nil
+			}
+			return &ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{cfg.routeTable}}, nil
+		},
+	).Times(2)
+	FakeEC2Cli.EXPECT().DescribeNetworkAcls(gomock.Any(), gomock.Any()).Return(&ec2.DescribeNetworkAclsOutput{NetworkAcls: []ec2Types.NetworkAcl{cfg.networkACL}}, nil)
+	FakeEC2Cli.EXPECT().DescribeNatGateways(gomock.Any(), gomock.Any()).Return(&ec2.DescribeNatGatewaysOutput{NatGateways: []ec2Types.NatGateway{cfg.natGateways["nat-1"]}}, nil)
+	FakeEC2Cli.EXPECT().DescribeSecurityGroups(gomock.Any(), gomock.Any()).Return(&ec2.DescribeSecurityGroupsOutput{
+		SecurityGroups: []ec2Types.SecurityGroup{
+			{
+				GroupId: awss.String("sg-1"),
+				VpcId:   awss.String("vpc-1"),
+				IpPermissionsEgress: []ec2Types.IpPermission{
+					{
+						IpProtocol: awss.String("tcp"), FromPort: awss.Int32(443), ToPort: awss.Int32(443),
+						IpRanges: []ec2Types.IpRange{{CidrIp: awss.String("0.0.0.0/0")}},
+					},
+				},
+			},
+		},
+	}, nil)
+	FakeEC2Cli.EXPECT().DescribeStaleSecurityGroups(gomock.Any(), gomock.Any()).Return(&ec2.DescribeStaleSecurityGroupsOutput{}, nil)
+
+	cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+	cli.AwsClient.SetClient(FakeEC2Cli)
+
+	// No instances are launched, so any call to RunInstances fails the test
+	out := cli.ValidateEgress(verifier.ValidateEgressInput{
+		Ctx:      context.TODO(),
+		SubnetID: "subnet-a",
+		EgressListYaml: `endpoints:
+  - host: quay.io
+    ports:
+      - 443
+      - 9997
+`,
+		AWS: verifier.AwsEgressConfig{SecurityGroupIDs: []string{"sg-1"}, StaticAnalysis: true},
+	})
+
+	if _, _, errs := out.Parse(); len(errs) > 0 {
+		t.Fatalf("ValidateEgress() unexpected errors: %v", errs)
+	}
+	var gotFailures []string
+	for _, failure := range out.GetEgressURLFailures() {
+		gotFailures = append(gotFailures, failure.EgressURL())
+	}
+	wantFailures := []string{"tcp://quay.io:9997 (predicted blocked by security group sg-1: no egress rule allows tcp/9997 to any IPv4 address)"}
+	if !reflect.DeepEqual(gotFailures, wantFailures) {
+		t.Errorf("ValidateEgress() egress failures = %v, want %v", gotFailures, wantFailures)
+	}
+	wantInfo := "static analysis: 1 of 2 endpoints predicted reachable from subnet-a (route table rtb-1 (main), network ACL acl-1, security group sg-1)"
+	if info := strings.Join(out.GetInfo(), "\n"); !strings.Contains(info, wantInfo) {
+		t.Errorf("ValidateEgress() info = %q, want it to contain %q", info, wantInfo)
+	}
+}
@@ -0,0 +1,247 @@
+package awsverifier
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	awsTools "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// clusterVpcEndpointServices are the services (without the "com.amazonaws.<region>." prefix) whose
+// VPC endpoints, if any, are checked for misconfigurations that would break clusters (e.g., a
+// security group blocking HTTPS), regardless of platform. Once a VPC has an endpoint with private DNS
+// for a service, every request to that service from the VPC goes through it
+var clusterVpcEndpointServices = []string{"s3", "ecr.api", "ecr.dkr", "sts", "ec2", "elasticloadbalancing"}
+
+// requiredVpcEndpointServices returns the services (without the "com.amazonaws.<region>." prefix)
+// that clusters of the given platform can only reach through a VPC endpoint
+func requiredVpcEndpointServices(platform cloud.Platform) []string {
+	if platform == cloud.AWSHCPZeroEgress {
+		return []string{"s3", "ecr.api", "ecr.dkr", "sts"}
+	}
+	return nil
+}
+
+// checkVpcEndpoints compares the interface and gateway endpoints of vpcID with the set required for
+// vei.PlatformType in the verifier's region, before any probe runs. Each endpoint for a service in
+// clusterVpcEndpointServices must be available and usable from subnetID: interface endpoints must
+// have private DNS enabled, a network interface in subnetID's availability zone, and a security
+// group allowing HTTPS from subnetID, while gateway endpoints must be associated with subnetID's
+// route table. Missing and misconfigured endpoints are stored in out as VPC endpoint findings (see
+// handledErrors.VpcEndpointFinding), by service name
+func (a *AwsVerifier) checkVpcEndpoints(vei verifier.ValidateEgressInput, subnetID string, vpcID string, out *output.Output) {
+	vpcEndpointsOutput, err := a.AwsClient.DescribeVpcEndpoints(vei.Ctx, &ec2.DescribeVpcEndpointsInput{
+		Filters: []ec2Types.Filter{
+			{
+				Name:   awsTools.String("vpc-id"),
+				Values: []string{vpcID},
+			},
+		},
+	})
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to check the VPC endpoints of %s: %w", vpcID, err))
+		return
+	}
+
+	required := requiredVpcEndpointServices(vei.PlatformType)
+	endpointsByService := map[string][]ec2Types.VpcEndpoint{}
+	for _, service := range clusterVpcEndpointServices {
+		serviceName := vpcEndpointServiceName(a.AwsClient.Region, service)
+		for _, endpoint := range vpcEndpointsOutput.VpcEndpoints {
+			if awsTools.ToString(endpoint.ServiceName) == serviceName {
+				endpointsByService[service] = append(endpointsByService[service], endpoint)
+			}
+		}
+	}
+	for _, service := range required {
+		if len(endpointsByService[service]) == 0 {
+			out.AddWarning(handledErrors.NewVpcEndpointFinding(vpcEndpointServiceName(a.AwsClient.Region, service), fmt.Sprintf("%s has no VPC endpoint for this service, but %s clusters can only reach it through one", vpcID, vei.PlatformType)))
+		}
+	}
+	if len(endpointsByService) == 0 {
+		return
+	}
+
+	usage, err := a.describeVpcEndpointUsage(vei.Ctx, subnetID, vpcID, endpointsByService)
+	if err != nil {
+		out.AddWarning(fmt.Errorf("unable to check the VPC endpoints of %s: %w", vpcID, err))
+		return
+	}
+
+	for _, service := range clusterVpcEndpointServices {
+		serviceName := vpcEndpointServiceName(a.AwsClient.Region, service)
+		for _, endpoint := range endpointsByService[service] {
+			endpointLabel := fmt.Sprintf("%s endpoint %s", strings.ToLower(string(endpoint.VpcEndpointType)), awsTools.ToString(endpoint.VpcEndpointId))
+			problems := usage.problems(endpoint)
+			for _, problem := range problems {
+				out.AddWarning(handledErrors.NewVpcEndpointFinding(serviceName, fmt.Sprintf("%s %s", endpointLabel, problem)))
+			}
+			if len(problems) == 0 {
+				out.AddInfo(fmt.Sprintf("%s is reachable from %s through %s", serviceName, subnetID, endpointLabel))
+			}
+		}
+	}
+}
+
+// vpcEndpointServiceName returns the full name of a VPC endpoint service, e.g.,
+// "com.amazonaws.us-east-1.sts"
+func vpcEndpointServiceName(region string, service string) string {
+	return fmt.Sprintf("com.amazonaws.%s.%s", region, service)
+}
+
+// vpcEndpointUsage holds the configuration deciding whether a VPC endpoint can be used from a
+// single subnet
+type vpcEndpointUsage struct {
+	subnet ec2Types.Subnet
+	// routeTableID is the ID of the route table effective for subnet (only needed for gateway
+	// endpoints)
+	routeTableID string
+	// availabilityZones holds the availability zone of every subnet interface endpoints are in, by
+	// subnet ID
+	availabilityZones map[string]string
+	// securityGroups holds every security group attached to interface endpoints, by ID
+	securityGroups map[string]ec2Types.SecurityGroup
+}
+
+// describeVpcEndpointUsage gathers the configuration deciding whether any of endpointsByService can
+// be used from subnetID (part of vpcID)
+func (a *AwsVerifier) describeVpcEndpointUsage(ctx context.Context, subnetID string, vpcID string, endpointsByService map[string][]ec2Types.VpcEndpoint) (vpcEndpointUsage, error) {
+	usage := vpcEndpointUsage{
+		availabilityZones: map[string]string{},
+		securityGroups:    map[string]ec2Types.SecurityGroup{},
+	}
+
+	subnetIDs := []string{subnetID}
+	var securityGroupIDs []string
+	hasGatewayEndpoints := false
+	for _, endpoints := range endpointsByService {
+		for _, endpoint := range endpoints {
+			hasGatewayEndpoints = hasGatewayEndpoints || endpoint.VpcEndpointType == ec2Types.VpcEndpointTypeGateway
+			for _, endpointSubnetID := range endpoint.SubnetIds {
+				if !slices.Contains(subnetIDs, endpointSubnetID) {
+					subnetIDs = append(subnetIDs, endpointSubnetID)
+				}
+			}
+			for _, group := range endpoint.Groups {
+				if groupID := awsTools.ToString(group.GroupId); !slices.Contains(securityGroupIDs, groupID) {
+					securityGroupIDs = append(securityGroupIDs, groupID)
+				}
+			}
+		}
+	}
+
+	subnetsOutput, err := a.AwsClient.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: subnetIDs})
+	if err != nil {
+		return usage, err
+	}
+	for _, subnet := range subnetsOutput.Subnets {
+		usage.availabilityZones[awsTools.ToString(subnet.SubnetId)] = awsTools.ToString(subnet.AvailabilityZone)
+		if awsTools.ToString(subnet.SubnetId) == subnetID {
+			usage.subnet = subnet
+		}
+	}
+	if usage.subnet.SubnetId == nil {
+		return usage, fmt.Errorf("no subnets returned for subnet id: %s", subnetID)
+	}
+
+	if len(securityGroupIDs) > 0 {
+		// Filtering by ID (rather than requesting the IDs directly) omits missing groups instead
+		// of failing the whole request
+		securityGroupsOutput, err := a.AwsClient.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+			Filters: []ec2Types.Filter{
+				{
+					Name:   awsTools.String("group-id"),
+					Values: securityGroupIDs,
+				},
+			},
+		})
+		if err != nil {
+			return usage, err
+		}
+		for _, group := range securityGroupsOutput.SecurityGroups {
+			usage.securityGroups[awsTools.ToString(group.GroupId)] = group
+		}
+	}
+
+	if hasGatewayEndpoints {
+		routeTable, _, err := a.describeEffectiveRouteTable(ctx, subnetID, vpcID)
+		if err != nil {
+			return usage, err
+		}
+		usage.routeTableID = awsTools.ToString(routeTable.RouteTableId)
+	}
+	return usage, nil
+}
+
+// problems describes why endpoint can't be used from usage.subnet, e.g., "isn't associated with
+// route table rtb-0123 of subnet-0123"
+func (usage vpcEndpointUsage) problems(endpoint ec2Types.VpcEndpoint) []string {
+	subnetID := awsTools.ToString(usage.subnet.SubnetId)
+	// AWS reports endpoint states in lowercase, unlike the SDK's constants
+	if !strings.EqualFold(string(endpoint.State), string(ec2Types.StateAvailable)) {
+		return []string{fmt.Sprintf("is %s", strings.ToLower(string(endpoint.State)))}
+	}
+
+	if endpoint.VpcEndpointType == ec2Types.VpcEndpointTypeGateway {
+		if !slices.Contains(endpoint.RouteTableIds, usage.routeTableID) {
+			return []string{fmt.Sprintf("isn't associated with route table %s of %s", usage.routeTableID, subnetID)}
+		}
+		return nil
+	}
+	if endpoint.VpcEndpointType != ec2Types.VpcEndpointTypeInterface {
+		return nil
+	}
+
+	var problems []string
+	if !awsTools.ToBool(endpoint.PrivateDnsEnabled) {
+		problems = append(problems, "doesn't have private DNS enabled, so the service's default hostname still resolves to public addresses")
+	}
+
+	availabilityZone := awsTools.ToString(usage.subnet.AvailabilityZone)
+	coversAvailabilityZone := false
+	for _, endpointSubnetID := range endpoint.SubnetIds {
+		coversAvailabilityZone = coversAvailabilityZone || usage.availabilityZones[endpointSubnetID] == availabilityZone
+	}
+	if !coversAvailabilityZone {
+		problems = append(problems, fmt.Sprintf("has no network interface in %s (the availability zone of %s)", availabilityZone, subnetID))
+	}
+
+	// Security group rules allowing the subnet's entire CIDR block are the only ones that are
+	// evaluated, as the probe's (and cluster nodes') addresses aren't known in advance
+	subnetPrefix, err := netip.ParsePrefix(awsTools.ToString(usage.subnet.CidrBlock))
+	if err != nil {
+		return problems
+	}
+	source := staticDestination{port: 443, prefix: subnetPrefix}
+	var groupIDs []string
+	allowsHTTPS := false
+	unevaluatedRules := false
+	for _, groupIdentifier := range endpoint.Groups {
+		groupIDs = append(groupIDs, awsTools.ToString(groupIdentifier.GroupId))
+		for _, ipPermission := range usage.securityGroups[awsTools.ToString(groupIdentifier.GroupId)].IpPermissions {
+			allowsHTTPS = allowsHTTPS || ipPermissionAllows(ipPermission, source)
+			unevaluatedRules = unevaluatedRules || len(ipPermission.PrefixListIds) > 0 || len(ipPermission.UserIdGroupPairs) > 0
+		}
+	}
+	if !allowsHTTPS {
+		problem := fmt.Sprintf("has no security group rule allowing inbound HTTPS (tcp/443) from %s (%s)", subnetID, subnetPrefix)
+		if len(groupIDs) > 0 {
+			problem += fmt.Sprintf(" in %s", strings.Join(groupIDs, ", "))
+		}
+		if unevaluatedRules {
+			problem += " (rules referencing prefix lists or security groups aren't evaluated)"
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
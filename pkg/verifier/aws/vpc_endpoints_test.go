@@ -0,0 +1,147 @@
+package awsverifier
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	awss "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/openshift/osd-network-verifier/pkg/clients/aws"
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/mocks"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+func TestAwsVerifier_checkVpcEndpoints(t *testing.T) {
+	subnets := []ec2Types.Subnet{
+		{SubnetId: awss.String("subnet-a"), AvailabilityZone: awss.String("us-east-1a"), CidrBlock: awss.String("10.0.1.0/24")},
+		{SubnetId: awss.String("subnet-b"), AvailabilityZone: awss.String("us-east-1b"), CidrBlock: awss.String("10.0.2.0/24")},
+	}
+	endpointGroup := ec2Types.SecurityGroup{
+		GroupId: awss.String("sg-e"),
+		IpPermissions: []ec2Types.IpPermission{
+			{
+				IpProtocol: awss.String("tcp"), FromPort: awss.Int32(443), ToPort: awss.Int32(443),
+				IpRanges: []ec2Types.IpRange{{CidrIp: awss.String("10.0.0.0/16")}},
+			},
+		},
+	}
+	interfaceEndpoint := func(id string, service string) ec2Types.VpcEndpoint {
+		return ec2Types.VpcEndpoint{
+			VpcEndpointId:     awss.String(id),
+			VpcEndpointType:   ec2Types.VpcEndpointTypeInterface,
+			ServiceName:       awss.String("com.amazonaws.us-east-1." + service),
+			State:             "available",
+			PrivateDnsEnabled: awss.Bool(true),
+			SubnetIds:         []string{"subnet-a"},
+			Groups:            []ec2Types.SecurityGroupIdentifier{{GroupId: awss.String("sg-e")}},
+		}
+	}
+	s3Endpoint := ec2Types.VpcEndpoint{
+		VpcEndpointId:   awss.String("vpce-s3"),
+		VpcEndpointType: ec2Types.VpcEndpointTypeGateway,
+		ServiceName:     awss.String("com.amazonaws.us-east-1.s3"),
+		State:           "available",
+		RouteTableIds:   []string{"rtb-1"},
+	}
+
+	tests := []struct {
+		name         string
+		platform     cloud.Platform
+		endpoints    []ec2Types.VpcEndpoint
+		wantInfo     []string
+		wantFindings []string
+	}{
+		{
+			name:     "no endpoints needed",
+			platform: cloud.AWSClassic,
+		},
+		{
+			name:      "zero-egress endpoints in place",
+			platform:  cloud.AWSHCPZeroEgress,
+			endpoints: []ec2Types.VpcEndpoint{s3Endpoint, interfaceEndpoint("vpce-1", "ecr.api"), interfaceEndpoint("vpce-2", "ecr.dkr"), interfaceEndpoint("vpce-3", "sts")},
+			wantInfo: []string{
+				"com.amazonaws.us-east-1.s3 is reachable from subnet-a through gateway endpoint vpce-s3",
+				"com.amazonaws.us-east-1.ecr.api is reachable from subnet-a through interface endpoint vpce-1",
+				"com.amazonaws.us-east-1.ecr.dkr is reachable from subnet-a through interface endpoint vpce-2",
+				"com.amazonaws.us-east-1.sts is reachable from subnet-a through interface endpoint vpce-3",
+			},
+		},
+		{
+			name:      "missing zero-egress endpoints",
+			platform:  cloud.AWSHCPZeroEgress,
+			endpoints: []ec2Types.VpcEndpoint{interfaceEndpoint("vpce-3", "sts")},
+			wantInfo: []string{
+				"com.amazonaws.us-east-1.sts is reachable from subnet-a through interface endpoint vpce-3",
+			},
+			wantFindings: []string{
+				"VPC endpoint finding: com.amazonaws.us-east-1.s3: vpc-1 has no VPC endpoint for this service, but aws-hcp-zeroegress clusters can only reach it through one",
+				"VPC endpoint finding: com.amazonaws.us-east-1.ecr.api: vpc-1 has no VPC endpoint for this service, but aws-hcp-zeroegress clusters can only reach it through one",
+				"VPC endpoint finding: com.amazonaws.us-east-1.ecr.dkr: vpc-1 has no VPC endpoint for this service, but aws-hcp-zeroegress clusters can only reach it through one",
+			},
+		},
+		{
+			name:     "misconfigured endpoints",
+			platform: cloud.AWSClassic,
+			endpoints: func() []ec2Types.VpcEndpoint {
+				unassociated := s3Endpoint
+				unassociated.RouteTableIds = []string{"rtb-2"}
+				misconfigured := interfaceEndpoint("vpce-1", "sts")
+				misconfigured.PrivateDnsEnabled = awss.Bool(false)
+				misconfigured.SubnetIds = []string{"subnet-b"}
+				misconfigured.Groups = []ec2Types.SecurityGroupIdentifier{{GroupId: awss.String("sg-missing")}}
+				pending := interfaceEndpoint("vpce-2", "ec2")
+				pending.State = "pendingAcceptance"
+				unrelated := interfaceEndpoint("vpce-3", "sqs")
+				return []ec2Types.VpcEndpoint{unassociated, misconfigured, pending, unrelated}
+			}(),
+			wantFindings: []string{
+				"VPC endpoint finding: com.amazonaws.us-east-1.s3: gateway endpoint vpce-s3 isn't associated with route table rtb-1 of subnet-a",
+				"VPC endpoint finding: com.amazonaws.us-east-1.sts: interface endpoint vpce-1 doesn't have private DNS enabled, so the service's default hostname still resolves to public addresses",
+				"VPC endpoint finding: com.amazonaws.us-east-1.sts: interface endpoint vpce-1 has no network interface in us-east-1a (the availability zone of subnet-a)",
+				"VPC endpoint finding: com.amazonaws.us-east-1.sts: interface endpoint vpce-1 has no security group rule allowing inbound HTTPS (tcp/443) from subnet-a (10.0.1.0/24) in sg-missing",
+				"VPC endpoint finding: com.amazonaws.us-east-1.ec2: interface endpoint vpce-2 is pendingacceptance",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			FakeEC2Cli := mocks.NewMockEC2Client(ctrl)
+			FakeEC2Cli.EXPECT().DescribeVpcEndpoints(gomock.Any(), gomock.Any()).Return(&ec2.DescribeVpcEndpointsOutput{VpcEndpoints: test.endpoints}, nil)
+			FakeEC2Cli.EXPECT().DescribeSubnets(gomock.Any(), gomock.Any()).Return(&ec2.DescribeSubnetsOutput{Subnets: subnets}, nil).AnyTimes()
+			FakeEC2Cli.EXPECT().DescribeSecurityGroups(gomock.Any(), gomock.Any()).Return(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []ec2Types.SecurityGroup{endpointGroup}}, nil).AnyTimes()
+			FakeEC2Cli.EXPECT().DescribeRouteTables(gomock.Any(), gomock.Any()).Return(&ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{staticTestConfig().routeTable}}, nil).AnyTimes()
+
+			cli := AwsVerifier{AwsClient: &aws.Client{Region: "us-east-1"}, Logger: &ocmlog.GlogLogger{}}
+			cli.AwsClient.SetClient(FakeEC2Cli)
+
+			out := &output.Output{}
+			cli.checkVpcEndpoints(verifier.ValidateEgressInput{Ctx: context.TODO(), PlatformType: test.platform}, "subnet-a", "vpc-1", out)
+
+			if !reflect.DeepEqual(out.GetInfo(), test.wantInfo) {
+				t.Errorf("checkVpcEndpoints() info = %q, want %q", out.GetInfo(), test.wantInfo)
+			}
+			var gotFindings []string
+			for _, warning := range out.GetWarnings() {
+				finding, ok := warning.(*handledErrors.VpcEndpointFinding)
+				if !ok {
+					t.Fatalf("checkVpcEndpoints() unexpected warning: %v", warning)
+				}
+				gotFindings = append(gotFindings, finding.Error())
+			}
+			if !reflect.DeepEqual(gotFindings, test.wantFindings) {
+				t.Errorf("checkVpcEndpoints() findings = %q, want %q", gotFindings, test.wantFindings)
+			}
+		})
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"github.com/openshift/osd-network-verifier/pkg/data/egress_lists"
 	"github.com/openshift/osd-network-verifier/pkg/helpers"
 	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
 	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
 	"github.com/openshift/osd-network-verifier/pkg/verifier"
 	"strconv"
+	"strings"
 )
 
 // ValidateEgress performs validation process for egress
@@ -18,8 +20,13 @@ import (
 // - prepare for ComputeService instance creation
 // - create instance and wait till it gets ready, wait for startup script execution
 // - find unreachable endpoints & parse output, then terminate instance
-// - return `g.output` which stores the execution results
+// - return an output which stores the execution results
+//
+// Each call returns its own output, so a GcpVerifier may be reused and called concurrently
 func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.Output {
+	out := &output.Output{}
+	defer g.mirrorOutput(out)
+
 	// Validate cloud platform type and default to PlatformGCP if not specified
 	if !vei.PlatformType.IsValid() {
 		vei.PlatformType = cloud.GCPClassic
@@ -29,12 +36,26 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		vei.CPUArchitecture = cpu.ArchX86
 	}
 
+	// Only the AWS verifier can verify several subnets in a single run
+	if subnetIDs := vei.TargetSubnetIDs(); len(subnetIDs) > 1 {
+		return out.AddError(fmt.Errorf("verifying multiple subnets in a single run is only supported on AWS (got %d subnets)", len(subnetIDs)))
+	} else if len(subnetIDs) == 1 {
+		vei.SubnetID = subnetIDs[0]
+	}
+
 	// Default to curl.Probe if no Probe specified
 	if vei.Probe == nil {
 		vei.Probe = curl.Probe{}
 		g.Logger.Debug(vei.Ctx, "defaulted to curl probe")
 	}
 
+	// Replace the probe's built-in userdata template if requested
+	probe, err := probes.WithUserDataTemplate(vei.Probe, vei.UserDataTemplate)
+	if err != nil {
+		return out.AddError(err)
+	}
+	vei.Probe = probe
+
 	// Set timeout to default if not specified
 	if vei.Timeout <= 0 {
 		vei.Timeout = verifier.DefaultTimeout
@@ -46,14 +67,14 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		var err error
 		vei.InstanceType, err = vei.CPUArchitecture.DefaultInstanceType(cloud.GCPClassic)
 		if err != nil {
-			return g.Output.AddError(err)
+			return out.AddError(err)
 		}
 		g.Logger.Debug(vei.Ctx, fmt.Sprintf("defaulted to instance type %s", vei.InstanceType))
 	}
 
 	// Validate machine type
 	if err := g.validateMachineType(vei.GCP.ProjectID, vei.GCP.Zone, vei.InstanceType); err != nil {
-		return g.Output.AddError(fmt.Errorf("instance type %s is invalid: %s", vei.InstanceType, err))
+		return out.AddError(fmt.Errorf("instance type %s is invalid: %s", vei.InstanceType, err))
 	}
 
 	// Generate both egress lists for the given PlatformType. Note: the result of this is ignored by the Legacy probe.
@@ -62,7 +83,7 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 
 	egressListStr, tlsDisabledEgressListStr, err := generator.GenerateEgressLists(vei.Ctx, vei.EgressListYaml)
 	if err != nil {
-		return g.Output.AddError(err)
+		return out.AddError(err)
 	}
 
 	// Generate the userData file
@@ -71,6 +92,10 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		g.Logger.Info(vei.Ctx, "NoTls enabled; ignoring any provided CA certs")
 		vei.Proxy.Cacert = ""
 	}
+	// Each run labels its probe output with a random nonce, so that stale output left on the
+	// console by a previous run can't be mistaken for this run's results
+	nonce := helpers.NewNonce()
+
 	userDataVariables := map[string]string{
 		"TIMEOUT":          vei.Timeout.String(),
 		"HTTP_PROXY":       vei.Proxy.HttpProxy,
@@ -81,6 +106,11 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		"DELAY":            "5",
 		"URLS":             egressListStr,
 		"TLSDISABLED_URLS": tlsDisabledEgressListStr,
+		"EGRESS_IP_URL":    vei.EgressIPEchoURL,
+		"TRANSFER_URLS":    strings.Join(vei.TransferURLs, " "),
+		"TRANSFER_SIZE":    vei.TransferSize,
+		"CURLOPT":          strings.Join(vei.CurlOptions, "\n"),
+		"NONCE":            nonce,
 		// Add fake userDatavariables to replace normal shell variables in startup-script.sh which will otherwise be erased by os.Expand
 		"ret":         "${ret}",
 		"?":           "$?",
@@ -89,9 +119,17 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		"USE_SYSTEMD": "true",
 	}
 
+	if vei.EgressIPChecks > 0 {
+		userDataVariables["EGRESS_IP_CHECKS"] = strconv.Itoa(vei.EgressIPChecks)
+	}
+
+	if vei.IPFamily.IsValid() {
+		userDataVariables["IP_FAMILY"] = vei.IPFamily.String()
+	}
+
 	userData, err := vei.Probe.GetExpandedUserData(userDataVariables)
 	if err != nil {
-		return g.Output.AddError(err)
+		return out.AddError(err)
 	}
 	g.Logger.Debug(vei.Ctx, "Generated userdata script:\n---\n%s\n---", userData)
 
@@ -100,14 +138,14 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 	if vei.CloudImageID == "" {
 		vei.CloudImageID, err = vei.Probe.GetMachineImageID(vei.PlatformType, vei.CPUArchitecture, vei.GCP.Region)
 		if err != nil {
-			return g.Output.AddError(err)
+			return out.AddError(err)
 		}
 	}
 
 	// Generate a random integer to be used in the instanceName
 	randInt, err := helpers.RandBigInt(10000)
 	if err != nil {
-		return g.Output.AddError(err)
+		return out.AddError(err)
 	}
 
 	// Create the ComputeService instance
@@ -125,9 +163,9 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 	})
 	// Try to terminate instance if instance creation fails
 	if err != nil {
-		g.Output.AddError(err)
+		out.AddError(err)
 		err = g.GcpClient.TerminateComputeServiceInstance(vei.GCP.ProjectID, vei.GCP.Zone, instance.Name)
-		return g.Output.AddError(err) // fatal
+		return out.AddError(err) // fatal
 	}
 
 	// Wait for the ComputeService instance to be running
@@ -136,25 +174,38 @@ func (g *GcpVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.O
 		// try to terminate instance if instance is not running
 		err = g.GcpClient.TerminateComputeServiceInstance(vei.GCP.ProjectID, vei.GCP.Zone, instance.Name)
 		if err != nil {
-			g.Output.AddError(err)
+			out.AddError(err)
 		}
-		return g.Output.AddError(instanceReadyErr) // fatal
+		return out.AddError(instanceReadyErr) // fatal
 	}
 
-	// Wait for console output and parse
+	// Wait for console output and parse. Unless the user requests otherwise, wait long enough for
+	// the probe to check every endpoint
+	pollOpts := consolePollOptions{
+		nonce:      nonce,
+		interval:   vei.PollInterval,
+		timeout:    vei.PollTimeout,
+		onProgress: vei.OnProgress,
+	}
+	if pollOpts.timeout <= 0 {
+		endpointCount := len(strings.Fields(egressListStr)) + len(strings.Fields(tlsDisabledEgressListStr))
+		// Dual-stack checks make one pass over the endpoints per IP family
+		endpointCount *= max(len(vei.IPFamily.Passes()), 1)
+		pollOpts.timeout = verifier.DerivedProbePollTimeout(vei.Probe, endpointCount, vei.Timeout, minConsolePollTimeout)
+	}
 	g.Logger.Info(vei.Ctx, "Gathering and parsing console log output...")
-	err = g.findUnreachableEndpoints(vei.GCP.ProjectID, vei.GCP.Zone, instance.Name, vei.Probe)
+	err = g.findUnreachableEndpoints(vei.GCP.ProjectID, vei.GCP.Zone, instance.Name, vei.Probe, pollOpts, out)
 	if err != nil {
-		g.Output.AddError(err)
+		out.AddError(err)
 	}
 
 	// Terminate the ComputeService instance after probe output is parsed and stored
 	err = g.GcpClient.TerminateComputeServiceInstance(vei.GCP.ProjectID, vei.GCP.Zone, instance.Name)
 	if err != nil {
-		g.Output.AddError(err)
+		out.AddError(err)
 	}
 
-	return &g.Output
+	return out
 }
 
 // TODO():
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
@@ -20,7 +21,32 @@ import (
 type GcpVerifier struct {
 	GcpClient gcp.Client
 	Logger    ocmlog.Logger
-	Output    output.Output
+	// Output mirrors the output returned by the most recently completed call to ValidateEgress.
+	//
+	// Deprecated: Output is overwritten by every call and is unreliable when a GcpVerifier is used
+	// by several goroutines at once. Use the *output.Output returned by each call instead
+	Output output.Output
+	// outputMutex guards Output
+	outputMutex sync.Mutex
+}
+
+const (
+	// defaultConsolePollInterval and minConsolePollTimeout control how often and for how long
+	// (at least) the probe instance's serial port output is scraped, unless the user requests otherwise
+	defaultConsolePollInterval = 30 * time.Second
+	minConsolePollTimeout      = 4 * time.Minute
+)
+
+// consolePollOptions controls how findUnreachableEndpoints scrapes a probe instance's console output
+type consolePollOptions struct {
+	// nonce labels this run's probe output (see helpers.TokenWithNonce)
+	nonce string
+	// interval and timeout control how often the console output is read and for how long.
+	// Default to defaultConsolePollInterval and minConsolePollTimeout, respectively, if unset
+	interval, timeout time.Duration
+	// onProgress, if set, is called for each check reported by the probe as soon as it's read
+	// from the console output (see probes.ReportProgress)
+	onProgress func(probes.ProgressEvent)
 }
 
 type createComputeServiceInstanceInput struct {
@@ -51,7 +77,15 @@ func NewGcpVerifier(creds *google.Credentials, debug bool) (*GcpVerifier, error)
 		return &GcpVerifier{}, err
 	}
 
-	return &GcpVerifier{*gcpClient, logger, output.Output{}}, nil
+	return &GcpVerifier{GcpClient: *gcpClient, Logger: logger}, nil
+}
+
+// mirrorOutput copies out, the output of a completed call, into the deprecated g.Output field so
+// that callers still reading it see that call's results
+func (g *GcpVerifier) mirrorOutput(out *output.Output) {
+	g.outputMutex.Lock()
+	defer g.outputMutex.Unlock()
+	g.Output = *out
 }
 
 // Check that instance type is supported in zone
@@ -158,12 +192,26 @@ func (g *GcpVerifier) createComputeServiceInstance(input createComputeServiceIns
 }
 
 // Get the console output from the ComputeService instance and scrape it for the probe's output and parse
-func (g *GcpVerifier) findUnreachableEndpoints(projectID, zone, instanceName string, probe probes.Probe) error {
+func (g *GcpVerifier) findUnreachableEndpoints(projectID, zone, instanceName string, probe probes.Probe, opts consolePollOptions, out *output.Output) error {
 	var consoleOutput string
+	// Only output labeled with this run's nonce is accepted; anything else was left on the console
+	// by a previous run
+	startingToken := helpers.TokenWithNonce(probe.GetStartingToken(), opts.nonce)
+	endingToken := helpers.TokenWithNonce(probe.GetEndingToken(), opts.nonce)
+	// Probes that print sequence-numbered output can have it reassembled across several console
+	// reads, in case some of it scrolls out of the serial port output buffer between reads
+	sequencedOutput := helpers.NewSequencedOutputCollector(opts.nonce)
+
+	if opts.interval <= 0 {
+		opts.interval = defaultConsolePollInterval
+	}
+	if opts.timeout <= 0 {
+		opts.timeout = minConsolePollTimeout
+	}
 	g.Logger.Debug(context.TODO(), "Scraping console output and waiting for user data script to complete...")
 
 	// Scrapes console at specified interval up to specified timeout
-	err := helpers.PollImmediate(30*time.Second, 4*time.Minute, func() (bool, error) {
+	err := helpers.PollImmediate(opts.interval, opts.timeout, func() (bool, error) {
 		// Get the console output from the ComputeService instance
 		output, err := g.GcpClient.GetInstancePorts(projectID, zone, instanceName)
 		if err != nil {
@@ -182,13 +230,24 @@ func (g *GcpVerifier) findUnreachableEndpoints(projectID, zone, instanceName str
 		}
 		consoleOutput = output.Contents
 
+		probes.ReportProgress(probe, sequencedOutput.Collect(consoleOutput), opts.onProgress)
+		if sequencedOutput.Started() {
+			if !sequencedOutput.Ended() {
+				g.Logger.Debug(context.TODO(), "consoleOutput contains sequenced probe output, but probe has not yet finished printing it, continuing to wait...")
+				return false, nil
+			}
+
+			g.parseSequencedProbeOutput(sequencedOutput, probe, out)
+			return true, nil
+		}
+
 		// Check for startingToken and endingToken
-		startingTokenSeen := strings.Contains(consoleOutput, probe.GetStartingToken())
-		endingTokenSeen := strings.Contains(consoleOutput, probe.GetEndingToken())
+		startingTokenSeen := strings.Contains(consoleOutput, startingToken)
+		endingTokenSeen := strings.Contains(consoleOutput, endingToken)
 		if !startingTokenSeen {
 			if endingTokenSeen {
 				g.Logger.Debug(context.TODO(), "raw console logs:\n---\n%s\n---", output.Contents)
-				g.Output.AddException(handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: endingToken encountered before startingToken")))
+				out.AddException(handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: endingToken encountered before startingToken")))
 				return false, nil
 			}
 			g.Logger.Debug(context.TODO(), "consoleOutput contains data, but probe has not yet printed startingToken, continuing to wait...")
@@ -201,23 +260,51 @@ func (g *GcpVerifier) findUnreachableEndpoints(projectID, zone, instanceName str
 		// If we make it this far, we know that both startingTokenSeen and endingTokenSeen are true
 
 		// Separate the probe's output from the rest of the console output (using startingToken and endingToken)
-		rawProbeOutput := strings.TrimSpace(helpers.CutBetween(consoleOutput, probe.GetStartingToken(), probe.GetEndingToken()))
+		rawProbeOutput := strings.TrimSpace(helpers.CutBetween(consoleOutput, startingToken, endingToken))
 		if len(rawProbeOutput) < 1 {
 			g.Logger.Debug(context.TODO(), "raw console logs:\n---\n%s\n---", consoleOutput)
-			g.Output.AddException(handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: no data between startingToken and endingToken")))
+			out.AddException(handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: no data between startingToken and endingToken")))
 			return false, nil
 		}
 
 		// Send probe's output off to the Probe interface for parsing
 		g.Logger.Debug(context.TODO(), "probe output:\n---\n%s\n---", rawProbeOutput)
-		probe.ParseProbeOutput(false, rawProbeOutput, &g.Output)
+		probe.ParseProbeOutput(false, rawProbeOutput, out)
 
 		return true, nil
 	})
 
+	// If the probe started printing sequenced output but didn't finish before the deadline, the
+	// lines collected so far are still parsed rather than discarded
+	if err != nil && sequencedOutput.Started() && !sequencedOutput.Ended() {
+		g.parseSequencedProbeOutput(sequencedOutput, probe, out)
+	}
+
 	return err
 }
 
+// parseSequencedProbeOutput sends the probe output reassembled by sequencedOutput off to the Probe
+// interface for parsing into out. Any lines that couldn't be collected (e.g., because they scrolled
+// out of the serial port output buffer between reads, or because the probe never finished printing
+// them) are reported as an error, but the remaining lines are still parsed
+func (g *GcpVerifier) parseSequencedProbeOutput(sequencedOutput *helpers.SequencedOutputCollector, probe probes.Probe, out *output.Output) {
+	rawProbeOutput := strings.TrimSpace(sequencedOutput.String())
+	if len(rawProbeOutput) < 1 {
+		out.AddException(handledErrors.NewGenericError(fmt.Errorf("probe output corrupted: no data between startingToken and endingToken")))
+		return
+	}
+	incompleteErr := sequencedOutput.MissingError()
+	if !sequencedOutput.Ended() {
+		incompleteErr = sequencedOutput.UnfinishedError()
+	}
+	if incompleteErr != nil {
+		out.AddError(handledErrors.NewGenericError(incompleteErr))
+	}
+
+	g.Logger.Debug(context.TODO(), "probe output:\n---\n%s\n---", rawProbeOutput)
+	probe.ParseProbeOutput(false, rawProbeOutput, out)
+}
+
 // Describes the instance status
 // States: PROVISIONING, STAGING, RUNNING, STOPPING, STOPPED, TERMINATED, SUSPENDED
 // https://cloud.google.com/compute/docs/instances/instance-life-cycle
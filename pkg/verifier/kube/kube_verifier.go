@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -19,6 +20,7 @@ import (
 	"github.com/openshift/osd-network-verifier/pkg/data/curlgen"
 	"github.com/openshift/osd-network-verifier/pkg/data/egress_lists"
 	handledErrors "github.com/openshift/osd-network-verifier/pkg/errors"
+	"github.com/openshift/osd-network-verifier/pkg/helpers"
 	"github.com/openshift/osd-network-verifier/pkg/output"
 	"github.com/openshift/osd-network-verifier/pkg/probes"
 	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
@@ -36,7 +38,13 @@ const (
 type KubeVerifier struct {
 	KubeClient kube.ClientInterface
 	Logger     ocmlog.Logger
-	Output     output.Output
+	// Output mirrors the output returned by the most recently completed call to ValidateEgress.
+	//
+	// Deprecated: Output is overwritten by every call and is unreliable when a KubeVerifier is used
+	// by several goroutines at once. Use the *output.Output returned by each call instead
+	Output output.Output
+	// outputMutex guards Output
+	outputMutex sync.Mutex
 }
 
 type createJobInput struct {
@@ -69,46 +77,62 @@ func NewKubeVerifier(clientset kubernetes.Interface, debug bool) (*KubeVerifier,
 	return &KubeVerifier{
 		KubeClient: kubeClient,
 		Logger:     logger,
-		Output:     output.Output{},
 	}, nil
 }
 
+// ValidateEgress runs the curl probe's checks in a Job within the cluster. Each call returns its own
+// output, so a KubeVerifier may be reused and called concurrently
 func (k *KubeVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.Output {
+	out := &output.Output{}
+	defer k.mirrorOutput(out)
+
 	// Validate cloud platform type
 	if !vei.PlatformType.IsValid() {
 		vei.PlatformType = cloud.AWSClassic
 	}
 
-	if _, ok := vei.Probe.(curl.Probe); !ok {
-		return k.Output.AddError(errors.New("verification via pod mode only supports curl probe"))
+	curlProbe, ok := vei.Probe.(curl.Probe)
+	if !ok {
+		return out.AddError(errors.New("verification via pod mode only supports curl probe"))
+	}
+	if err := curlProbe.ValidateSampling(); err != nil {
+		return out.AddError(err)
 	}
 
 	if vei.Timeout <= 0 {
 		vei.Timeout = verifier.DefaultTimeout
 	}
-	k.writeDebugLogs(fmt.Sprintf("configured a %s timeout for each egress request", vei.Timeout))
+	k.writeDebugLogs(out, fmt.Sprintf("configured a %s timeout for each egress request", vei.Timeout))
 
 	// Generate egress lists for the given PlatformType
 	generatorVariables := map[string]string{"AWS_REGION": vei.AWS.Region}
 	generator := egress_lists.NewGenerator(vei.PlatformType, generatorVariables, k.Logger)
 	egressListStr, tlsDisabledEgressListStr, err := generator.GenerateEgressLists(vei.Ctx, vei.EgressListYaml)
 	if err != nil {
-		return k.Output.AddError(err)
+		return out.AddError(err)
 	}
 
 	// Generate curl commands
-	curlCommand, err := k.generateCurlCommands(egressListStr, tlsDisabledEgressListStr, vei.Timeout, vei.Proxy)
+	curlCommand, err := k.generateCurlCommands(egressListStr, tlsDisabledEgressListStr, vei.Timeout, vei.Proxy, curlProbe.Samples, vei.CurlOptions)
 	if err != nil {
-		return k.Output.AddError(err)
+		return out.AddError(err)
 	}
 
 	// We always want our Curl process to exit successfully, even if egress fails. This way, the pod succeeds, and then
 	// we parse the output to identify our failures like we would in EC2. Additionally, we send stdout to /dev/null, since
 	// we only parse the JSON output sent to stderr.
-	curlCommand = fmt.Sprintf("%s 1>/dev/null || true", curlCommand)
-
-	// Create and execute Job
-	jobName := fmt.Sprintf("osd-network-verifier-job-%d", time.Now().Unix())
+	// The output is bracketed by the probe's tokens, labeled with a random per-run nonce, so that only
+	// this run's output is parsed.
+	nonce := helpers.NewNonce()
+	curlCommand = fmt.Sprintf(
+		"echo %s; %s 1>/dev/null || true; echo %s",
+		helpers.TokenWithNonce(vei.Probe.GetStartingToken(), nonce),
+		curlCommand,
+		helpers.TokenWithNonce(vei.Probe.GetEndingToken(), nonce),
+	)
+
+	// Create and execute Job. The nonce keeps the names of Jobs created by concurrent calls unique
+	jobName := fmt.Sprintf("osd-network-verifier-job-%d-%s", time.Now().Unix(), nonce)
 	proxySettings := k.buildProxyEnvironment(vei.Proxy)
 	resourceLimits := k.buildResourceRequirements()
 
@@ -119,38 +143,43 @@ func (k *KubeVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.
 		CurlCommand:             curlCommand,
 		ProxySettings:           proxySettings,
 		TTLSecondsAfterFinished: defaultTTLSecondsAfterFinished,
-		ActiveDeadlineSeconds:   defaultActiveDeadlineSeconds,
+		ActiveDeadlineSeconds:   defaultActiveDeadlineSeconds * int32(max(curlProbe.Samples, 1)),
 		BackoffLimit:            defaultBackoffLimit,
 		ResourceLimits:          resourceLimits,
 		Ctx:                     vei.Ctx,
 	}
 
-	err = k.createAndExecuteJob(jobInput)
+	err = k.createAndExecuteJob(jobInput, out)
 	if err != nil {
 		// Best effort delete the job
 		_ = k.KubeClient.CleanupJob(jobInput.Ctx, jobInput.JobName)
-		return k.Output.AddError(err)
+		return out.AddError(err)
 	}
 
 	// Collect and parse output
-	err = k.collectAndParseJobOutput(vei.Ctx, jobName, vei.Probe)
+	err = k.collectAndParseJobOutput(vei.Ctx, jobName, vei.Probe, nonce, out)
 	if err != nil {
-		k.Output.AddError(err)
+		out.AddError(err)
 	}
 
 	// Best effort delete the job
 	_ = k.KubeClient.CleanupJob(jobInput.Ctx, jobInput.JobName)
 
-	return &k.Output
+	return out
 }
 
 func (k *KubeVerifier) VerifyDns(vdi verifier.VerifyDnsInput) *output.Output {
 	// Placeholder implementation for DNS verification
 	k.Logger.Info(vdi.Ctx, "DNS verification not yet implemented for Kubernetes verifier")
-	return &k.Output
+	return &output.Output{}
 }
 
-func (k *KubeVerifier) generateCurlCommands(egressListStr, tlsDisabledEgressListStr string, timeout time.Duration, proxyConfig proxy.ProxyConfig) (string, error) {
+func (k *KubeVerifier) generateCurlCommands(egressListStr, tlsDisabledEgressListStr string, timeout time.Duration, proxyConfig proxy.ProxyConfig, samples int, curlOptions []string) (string, error) {
+	extraOptions, err := curlgen.ParseExtraOptions(curlOptions)
+	if err != nil {
+		return "", err
+	}
+
 	// Build curlgen options
 	options := &curlgen.Options{
 		CaPath:          "/etc/pki/tls/certs/",
@@ -160,6 +189,9 @@ func (k *KubeVerifier) generateCurlCommands(egressListStr, tlsDisabledEgressList
 		NoTls:           fmt.Sprintf("%t", proxyConfig.NoTls),
 		Urls:            strings.TrimSpace(egressListStr),
 		TlsDisabledUrls: strings.TrimSpace(tlsDisabledEgressListStr),
+		Samples:         samples,
+		SampleJitter:    curl.SampleJitterSeconds,
+		ExtraOptions:    extraOptions,
 	}
 
 	// Generate the curl command using curlgen
@@ -171,19 +203,19 @@ func (k *KubeVerifier) generateCurlCommands(egressListStr, tlsDisabledEgressList
 	return curlCommand, nil
 }
 
-func (k *KubeVerifier) createAndExecuteJob(input createJobInput) error {
+func (k *KubeVerifier) createAndExecuteJob(input createJobInput, out *output.Output) error {
 	// Build the Job specification
 	job := k.buildJobSpec(input)
 
 	// Create the Job
-	k.writeDebugLogs(fmt.Sprintf("Creating Job: %s", input.JobName))
+	k.writeDebugLogs(out, fmt.Sprintf("Creating Job: %s", input.JobName))
 	_, err := k.KubeClient.CreateJob(input.Ctx, job)
 	if err != nil {
 		return handledErrors.NewGenericError(fmt.Errorf("failed to create job %s: %w", input.JobName, err))
 	}
 
 	// Wait for Job completion with timeout
-	k.writeDebugLogs(fmt.Sprintf("Waiting for Job completion: %s", input.JobName))
+	k.writeDebugLogs(out, fmt.Sprintf("Waiting for Job completion: %s", input.JobName))
 	err = k.KubeClient.WaitForJobCompletion(input.Ctx, input.JobName)
 	if err != nil {
 		return handledErrors.NewGenericError(fmt.Errorf("job %s failed or timed out: %w", input.JobName, err))
@@ -251,8 +283,8 @@ func (k *KubeVerifier) buildEnvVars(proxySettings map[string]string) []corev1.En
 	return envVars
 }
 
-func (k *KubeVerifier) collectAndParseJobOutput(ctx context.Context, jobName string, probe probes.Probe) error {
-	k.writeDebugLogs(fmt.Sprintf("Collecting logs from Job: %s", jobName))
+func (k *KubeVerifier) collectAndParseJobOutput(ctx context.Context, jobName string, probe probes.Probe, nonce string, out *output.Output) error {
+	k.writeDebugLogs(out, fmt.Sprintf("Collecting logs from Job: %s", jobName))
 
 	// Get logs from the Job
 	logs, err := k.KubeClient.GetJobLogs(ctx, jobName)
@@ -261,22 +293,26 @@ func (k *KubeVerifier) collectAndParseJobOutput(ctx context.Context, jobName str
 	}
 
 	// Extract probe output between separators
-	rawProbeOutput := k.parseJobLogs(logs)
+	rawProbeOutput := k.parseJobLogs(
+		logs,
+		helpers.TokenWithNonce(probe.GetStartingToken(), nonce),
+		helpers.TokenWithNonce(probe.GetEndingToken(), nonce),
+	)
 	if rawProbeOutput == "" {
 		return handledErrors.NewGenericError(fmt.Errorf("no valid probe output found in job logs"))
 	}
 
-	k.writeDebugLogs(fmt.Sprintf("Parsed probe output:\n---\n%s\n---", rawProbeOutput))
+	k.writeDebugLogs(out, fmt.Sprintf("Parsed probe output:\n---\n%s\n---", rawProbeOutput))
 
 	// Send probe output to the Probe interface for parsing
-	probe.ParseProbeOutput(false, rawProbeOutput, &k.Output)
+	probe.ParseProbeOutput(false, rawProbeOutput, out)
 
 	return nil
 }
 
-func (k *KubeVerifier) parseJobLogs(logs string) string {
-	// Look for all lines starting with @NV@
-	lines := strings.Split(logs, "\n")
+func (k *KubeVerifier) parseJobLogs(logs string, startingToken string, endingToken string) string {
+	// Only consider output between this run's tokens, then look for all lines starting with @NV@
+	lines := strings.Split(helpers.CutBetween(logs, startingToken, endingToken), "\n")
 	var probeOutput []string
 
 	for _, line := range lines {
@@ -325,6 +361,15 @@ func (k *KubeVerifier) buildResourceRequirements() corev1.ResourceRequirements {
 	}
 }
 
-func (k *KubeVerifier) writeDebugLogs(log string) {
-	k.Output.AddDebugLogs(log)
+// mirrorOutput copies out, the output of a completed call, into the deprecated k.Output field so
+// that callers still reading it see that call's results
+func (k *KubeVerifier) mirrorOutput(out *output.Output) {
+	k.outputMutex.Lock()
+	defer k.outputMutex.Unlock()
+	k.Output = *out
+}
+
+// writeDebugLogs stores log in out, the output of the current call
+func (k *KubeVerifier) writeDebugLogs(out *output.Output, log string) {
+	out.AddDebugLogs(log)
 }
@@ -3,10 +3,14 @@ package kube
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	fak8s "k8s.io/client-go/kubernetes/fake"
@@ -151,6 +155,7 @@ func TestKubeVerifier_generateCurlCommands(t *testing.T) {
 		tlsDisabledEgressListStr string
 		timeout                  time.Duration
 		proxyConfig              proxy.ProxyConfig
+		curlOptions              []string
 		wantErr                  bool
 	}{
 		{
@@ -169,6 +174,24 @@ func TestKubeVerifier_generateCurlCommands(t *testing.T) {
 			proxyConfig:              proxy.ProxyConfig{NoTls: true},
 			wantErr:                  false,
 		},
+		{
+			name:                     "with extra curl options",
+			egressListStr:            "https://example.com:443",
+			tlsDisabledEgressListStr: "",
+			timeout:                  30 * time.Second,
+			proxyConfig:              proxy.ProxyConfig{},
+			curlOptions:              []string{"--resolve=example.com:443:203.0.113.10"},
+			wantErr:                  false,
+		},
+		{
+			name:                     "with disallowed curl option",
+			egressListStr:            "https://example.com:443",
+			tlsDisabledEgressListStr: "",
+			timeout:                  30 * time.Second,
+			proxyConfig:              proxy.ProxyConfig{},
+			curlOptions:              []string{"--output=/etc/passwd"},
+			wantErr:                  true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -178,6 +201,8 @@ func TestKubeVerifier_generateCurlCommands(t *testing.T) {
 				tt.tlsDisabledEgressListStr,
 				tt.timeout,
 				tt.proxyConfig,
+				0,
+				tt.curlOptions,
 			)
 
 			if (err != nil) != tt.wantErr {
@@ -343,9 +368,11 @@ func TestKubeVerifier_parseJobLogs(t *testing.T) {
 		{
 			name: "valid logs with separators",
 			logs: `Starting job
+NV_CURLJSON_BEGIN_abc123
 @NV@
 {"url": "https://example.com", "success": true}
 @NV@
+NV_CURLJSON_END_abc123
 Job completed`,
 			expected: `@NV@
 @NV@`,
@@ -353,7 +380,9 @@ Job completed`,
 		{
 			name: "logs without separators",
 			logs: `Starting job
+NV_CURLJSON_BEGIN_abc123
 Some output
+NV_CURLJSON_END_abc123
 Job completed`,
 			expected: "",
 		},
@@ -365,12 +394,14 @@ Job completed`,
 		{
 			name: "real world sample with many @NV@ lines",
 			logs: `Starting job
+NV_CURLJSON_BEGIN_abc123
 @NV@line1
 @NV@line2
 @NV@line3
 Some other log output
 @NV@line4
 @NV@line5
+NV_CURLJSON_END_abc123
 Job completed`,
 			expected: `@NV@line1
 @NV@line2
@@ -378,11 +409,28 @@ Job completed`,
 @NV@line4
 @NV@line5`,
 		},
+		{
+			name: "output outside this run's tokens ignored",
+			logs: `NV_CURLJSON_BEGIN_ffff00
+@NV@stale
+NV_CURLJSON_END_ffff00
+NV_CURLJSON_BEGIN_abc123
+@NV@line1
+NV_CURLJSON_END_abc123
+@NV@trailing`,
+			expected: `@NV@line1`,
+		},
+		{
+			name: "missing ending token",
+			logs: `NV_CURLJSON_BEGIN_abc123
+@NV@line1`,
+			expected: "",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := kubeVerifier.parseJobLogs(tt.logs)
+			result := kubeVerifier.parseJobLogs(tt.logs, "NV_CURLJSON_BEGIN_abc123", "NV_CURLJSON_END_abc123")
 			if result != tt.expected {
 				t.Errorf("parseJobLogs() = %q, want %q", result, tt.expected)
 			}
@@ -507,7 +555,6 @@ func TestKubeVerifier_collectAndParseJobOutput(t *testing.T) {
 	kubeVerifier := &KubeVerifier{
 		KubeClient: mockKubeClient,
 		Logger:     &ocmlog.GlogLogger{},
-		Output:     output.Output{},
 	}
 
 	tests := []struct {
@@ -522,9 +569,11 @@ func TestKubeVerifier_collectAndParseJobOutput(t *testing.T) {
 		{
 			name:    "successful log collection and parsing",
 			jobName: "test-job",
-			logs: `@NV@
+			logs: `NV_CURLJSON_BEGIN_abc123
+@NV@
 {"url": "https://example.com", "exit_code": 0}
-@NV@`,
+@NV@
+NV_CURLJSON_END_abc123`,
 			logError:    nil,
 			probe:       curl.Probe{},
 			wantErr:     false,
@@ -556,7 +605,7 @@ Job completed`,
 		t.Run(tt.name, func(t *testing.T) {
 			mockKubeClient.SetGetJobLogsResult(tt.logs, tt.logError)
 
-			err := kubeVerifier.collectAndParseJobOutput(context.Background(), tt.jobName, tt.probe)
+			err := kubeVerifier.collectAndParseJobOutput(context.Background(), tt.jobName, tt.probe, "abc123", &output.Output{})
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("collectAndParseJobOutput() error = %v, wantErr %v", err, tt.wantErr)
@@ -570,7 +619,6 @@ func TestKubeVerifier_createAndExecuteJob_MockedClient(t *testing.T) {
 	kubeVerifier := &KubeVerifier{
 		KubeClient: mockKubeClient,
 		Logger:     &ocmlog.GlogLogger{},
-		Output:     output.Output{},
 	}
 
 	jobInput := createJobInput{
@@ -607,12 +655,11 @@ func TestKubeVerifier_createAndExecuteJob_MockedClient(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Reset output and mock state for each test
-			kubeVerifier.Output = output.Output{}
+			// Reset mock state for each test
 			mockKubeClient.SetCreateJobError(tt.createError)
 			mockKubeClient.SetWaitForJobCompletionError(tt.waitError)
 
-			err := kubeVerifier.createAndExecuteJob(jobInput)
+			err := kubeVerifier.createAndExecuteJob(jobInput, &output.Output{})
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("createAndExecuteJob() error = %v, wantErr %v", err, tt.wantErr)
@@ -629,14 +676,97 @@ func TestKubeVerifier_writeDebugLogs(t *testing.T) {
 	}
 
 	testLog := "test debug message"
+	out := &output.Output{}
 
 	// This should not panic or error
-	kubeVerifier.writeDebugLogs(testLog)
+	kubeVerifier.writeDebugLogs(out, testLog)
 
 	// Check that the log was added to the output
 	// Since output doesn't expose debug logs directly, we check via the Format method
-	output := kubeVerifier.Output.Format(true)
-	if !strings.Contains(output, testLog) {
+	if !strings.Contains(out.Format(true), testLog) {
 		t.Errorf("writeDebugLogs() did not add log to output debug logs")
 	}
 }
+
+// jobLogsClient is a goroutine-safe MockClient whose Jobs each report that the first egress URL
+// checked by their command is unreachable
+type jobLogsClient struct {
+	*MockClient
+	mutex sync.Mutex
+	jobs  map[string]*batchv1.Job
+}
+
+func (c *jobLogsClient) CreateJob(_ context.Context, job *batchv1.Job) (*batchv1.Job, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.jobs[job.Name]; ok {
+		return nil, fmt.Errorf("job %s already exists", job.Name)
+	}
+	c.jobs[job.Name] = job
+	return job, nil
+}
+
+func (c *jobLogsClient) GetJobLogs(_ context.Context, jobName string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	command := c.jobs[jobName].Spec.Template.Spec.Containers[0].Args[0]
+	nonce := regexp.MustCompile(`NV_CURLJSON_BEGIN_([0-9a-f]+)`).FindStringSubmatch(command)[1]
+	url := regexp.MustCompile(`https://[a-z0-9.-]+:443`).FindString(command)
+	return fmt.Sprintf("NV_CURLJSON_BEGIN_%s\n@NV@{\"url\":\"%s\",\"exitcode\":7,\"errormsg\":\"Failed to connect\"}\nNV_CURLJSON_END_%s\n", nonce, url, nonce), nil
+}
+
+// TestKubeVerifier_ValidateEgress_Concurrent ensures that concurrent calls to the same KubeVerifier
+// each get their own results (run with -race to also catch data races)
+func TestKubeVerifier_ValidateEgress_Concurrent(t *testing.T) {
+	client := &jobLogsClient{MockClient: NewMockClient(), jobs: map[string]*batchv1.Job{}}
+	kubeVerifier := &KubeVerifier{KubeClient: client, Logger: &ocmlog.GlogLogger{}}
+
+	const calls = 8
+	outputs := make([]*output.Output, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outputs[i] = kubeVerifier.ValidateEgress(verifier.ValidateEgressInput{
+				Ctx:            context.Background(),
+				Probe:          curl.Probe{},
+				EgressListYaml: fmt.Sprintf("endpoints:\n  - host: endpoint-%d.example.com\n    ports:\n      - 443\n", i),
+				AWS:            verifier.AwsEgressConfig{Region: "us-east-1"},
+			})
+		}()
+	}
+	wg.Wait()
+
+	if len(client.jobs) != calls {
+		t.Errorf("ValidateEgress() created %d uniquely-named jobs, want %d", len(client.jobs), calls)
+	}
+	for i, out := range outputs {
+		_, _, errs := out.Parse()
+		failures := out.GetEgressURLFailures()
+		wantURL := fmt.Sprintf("https://endpoint-%d.example.com:443", i)
+		if len(errs) != 0 || len(failures) != 1 || !strings.HasPrefix(failures[0].EgressURL(), wantURL) {
+			t.Errorf("ValidateEgress() call %d = failures %v, errors %v; want a single failure for %s", i, failures, errs, wantURL)
+		}
+	}
+}
+
+// TestKubeVerifier_ValidateEgress_MirrorsOutput ensures that the deprecated Output field still
+// holds the results of the most recent call for callers that read it
+func TestKubeVerifier_ValidateEgress_MirrorsOutput(t *testing.T) {
+	client := &jobLogsClient{MockClient: NewMockClient(), jobs: map[string]*batchv1.Job{}}
+	kubeVerifier := &KubeVerifier{KubeClient: client, Logger: &ocmlog.GlogLogger{}}
+
+	out := kubeVerifier.ValidateEgress(verifier.ValidateEgressInput{
+		Ctx:            context.Background(),
+		Probe:          curl.Probe{},
+		EgressListYaml: "endpoints:\n  - host: endpoint.example.com\n    ports:\n      - 443\n",
+		AWS:            verifier.AwsEgressConfig{Region: "us-east-1"},
+	})
+
+	got := kubeVerifier.Output.GetEgressURLFailures()
+	want := out.GetEgressURLFailures()
+	if len(want) != 1 || len(got) != len(want) || got[0].EgressURL() != want[0].EgressURL() {
+		t.Errorf("KubeVerifier.Output failures = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,279 @@
+package local
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
+	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+	"github.com/openshift/osd-network-verifier/pkg/proxy"
+)
+
+const (
+	// checkRetries is how many times a failed check is retried by default, matching the curl
+	// probe's --retry
+	checkRetries = 3
+	// maxEgressIPBodyBytes caps how much of the IP echo URL's response body is read, matching the
+	// curl probe
+	maxEgressIPBodyBytes = 256
+)
+
+// curl exit codes reported for failed checks, so that results read exactly like the curl probe's
+// (see https://curl.se/libcurl/c/libcurl-errors.html)
+const (
+	curlExitCouldntResolveProxy = 5
+	curlExitCouldntResolveHost  = 6
+	curlExitCouldntConnect      = 7
+	curlExitOperationTimedOut   = 28
+	curlExitSSLConnectError     = 35
+	// curlExitTelnetOptionSyntax is what curl returns after successfully connecting to a non-HTTP(S)
+	// endpoint using the curl probe's telnet hack (see curl.CurlJSONProbeResult.IsSuccessfulConnection)
+	curlExitTelnetOptionSyntax = 49
+	curlExitRecvError          = 56
+	curlExitPeerFailedVerify   = 60
+)
+
+// endpointChecker checks whether individual egress endpoints are reachable from the current host,
+// the same way the curl probe would: HTTP(S) endpoints are sent a HEAD request (through the
+// configured proxy, if any), and any response counts as success, while other ("telnet")
+// endpoints only need to accept a TCP connection
+type endpointChecker struct {
+	timeout    time.Duration
+	retries    int
+	retryDelay time.Duration
+	proxyFunc  func(*url.URL) (*url.URL, error)
+	rootCAs    *x509.CertPool
+	noTLS      bool
+}
+
+// newEndpointChecker returns an endpointChecker that honors proxyConfig, giving each attempt to
+// reach an endpoint up to timeout. Failed checks are retried (checkRetries times) after retryDelay
+// (doubling each time)
+func newEndpointChecker(proxyConfig proxy.ProxyConfig, timeout time.Duration, retryDelay time.Duration) (*endpointChecker, error) {
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if proxyConfig.Cacert != "" && !proxyConfig.NoTls {
+		if !rootCAs.AppendCertsFromPEM([]byte(proxyConfig.Cacert)) {
+			return nil, errors.New("failed to parse provided CA certificate: no PEM-formatted certificates found")
+		}
+	}
+
+	return &endpointChecker{
+		timeout:    timeout,
+		retries:    checkRetries,
+		retryDelay: retryDelay,
+		proxyFunc: (&httpproxy.Config{
+			HTTPProxy:  proxyConfig.HttpProxy,
+			HTTPSProxy: proxyConfig.HttpsProxy,
+			NoProxy:    proxyConfig.NoProxyAsString(),
+		}).ProxyFunc(),
+		rootCAs: rootCAs,
+		noTLS:   proxyConfig.NoTls,
+	}, nil
+}
+
+// check attempts to reach rawURL (retrying on failure) over ipFamily (or whichever IP family the
+// OS picks, if ipFamily is the zero Family) and describes the outcome as the curl probe would
+func (ec *endpointChecker) check(ctx context.Context, rawURL string, tlsDisabled bool, ipFamily ipfamily.Family) curl.CurlJSONProbeResult {
+	var result curl.CurlJSONProbeResult
+	delay := ec.retryDelay
+	for attempt := 0; attempt <= ec.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return result
+			}
+			delay *= 2
+		}
+		result = ec.checkOnce(ctx, rawURL, tlsDisabled, ipFamily)
+		if result.IsSuccessfulConnection() {
+			break
+		}
+	}
+	return result
+}
+
+// checkOnce makes a single attempt to reach rawURL (see check)
+func (ec *endpointChecker) checkOnce(ctx context.Context, rawURL string, tlsDisabled bool, ipFamily ipfamily.Family) curl.CurlJSONProbeResult {
+	result := curl.CurlJSONProbeResult{URL: rawURL}
+	if ipFamily.IsValid() {
+		result.IPFamily = ipFamily.String()
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		result.ExitCode, result.ErrorMsg = curlExitCouldntResolveHost, err.Error()
+		return result
+	}
+
+	start := time.Now()
+	if parsedURL.Scheme == "telnet" {
+		conn, err := ec.dialer().DialContext(ctx, network(ipFamily), parsedURL.Host)
+		result.TimeTotal = time.Since(start).Seconds()
+		if err != nil {
+			result.ExitCode, result.ErrorMsg = curlExitCode(err), errorMessage(err)
+			return result
+		}
+		defer conn.Close()
+		result.Scheme = "TELNET"
+		result.ExitCode = curlExitTelnetOptionSyntax
+		result.RemoteIP, result.RemotePort = splitAddr(conn.RemoteAddr())
+		return result
+	}
+
+	// The transport may dial from another goroutine
+	var remoteAddrMutex sync.Mutex
+	var remoteAddr net.Addr
+	client := ec.httpClient(ipFamily, tlsDisabled, func(conn net.Conn) {
+		remoteAddrMutex.Lock()
+		defer remoteAddrMutex.Unlock()
+		remoteAddr = conn.RemoteAddr()
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		result.ExitCode, result.ErrorMsg = curlExitCouldntResolveHost, err.Error()
+		return result
+	}
+	resp, err := client.Do(req)
+	result.TimeTotal = time.Since(start).Seconds()
+	remoteAddrMutex.Lock()
+	if remoteAddr != nil {
+		result.RemoteIP, result.RemotePort = splitAddr(remoteAddr)
+	}
+	remoteAddrMutex.Unlock()
+	if err != nil {
+		result.ExitCode, result.ErrorMsg = curlExitCode(err), errorMessage(err)
+		return result
+	}
+	defer resp.Body.Close()
+	// Like curl without --fail, any HTTP response (even an error status) means the endpoint is
+	// reachable
+	result.Scheme = strings.ToUpper(parsedURL.Scheme)
+	result.HTTPCode, result.ResponseCode = resp.StatusCode, resp.StatusCode
+	return result
+}
+
+// fetchBody GETs rawURL (through the configured proxy, if any) and returns up to
+// maxEgressIPBodyBytes of the response body with any line breaks removed
+func (ec *endpointChecker) fetchBody(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := ec.httpClient(ipfamily.Family{}, false, nil).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEgressIPBodyBytes))
+	return strings.NewReplacer("\r", "", "\n", "").Replace(string(body)), err
+}
+
+// dialer returns a net.Dialer giving up on connections after the checker's timeout
+func (ec *endpointChecker) dialer() *net.Dialer {
+	return &net.Dialer{Timeout: ec.timeout}
+}
+
+// httpClient returns a single-use HTTP client that connects over ipFamily, skips TLS verification if
+// insecure (or if the checker's proxy config disables TLS), and never follows redirects (like curl
+// without --location). onConnect (if set) is called with every connection the client makes
+func (ec *endpointChecker) httpClient(ipFamily ipfamily.Family, insecure bool, onConnect func(net.Conn)) *http.Client {
+	dialer := ec.dialer()
+	transport := &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return ec.proxyFunc(req.URL)
+		},
+		DialContext: func(ctx context.Context, _ string, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network(ipFamily), addr)
+			if err == nil && onConnect != nil {
+				onConnect(conn)
+			}
+			return conn, err
+		},
+		TLSClientConfig: &tls.Config{
+			RootCAs:            ec.rootCAs,
+			InsecureSkipVerify: insecure || ec.noTLS, //nolint:gosec
+		},
+		DisableKeepAlives: true,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   ec.timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// network returns the network name passed to net.Dialer to connect over ipFamily
+func network(ipFamily ipfamily.Family) string {
+	switch ipFamily {
+	case ipfamily.IPv4:
+		return "tcp4"
+	case ipfamily.IPv6:
+		return "tcp6"
+	default:
+		return "tcp"
+	}
+}
+
+// splitAddr returns the IP address and port of a TCP connection's address
+func splitAddr(addr net.Addr) (string, int) {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String(), tcpAddr.Port
+	}
+	return "", 0
+}
+
+// curlExitCode returns the curl exit code best describing err
+func curlExitCode(err error) int {
+	var dnsErr *net.DNSError
+	var certVerificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+	var netErr net.Error
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &dnsErr) && errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		return curlExitCouldntResolveProxy
+	case errors.As(err, &dnsErr):
+		return curlExitCouldntResolveHost
+	case errors.As(err, &certVerificationErr), errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr):
+		return curlExitPeerFailedVerify
+	case errors.As(err, &recordHeaderErr):
+		return curlExitSSLConnectError
+	case errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, context.DeadlineExceeded):
+		return curlExitOperationTimedOut
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return curlExitCouldntConnect
+	default:
+		return curlExitRecvError
+	}
+}
+
+// errorMessage returns a concise description of err, without the request method and URL that
+// net/http prefixes its errors with (the URL is reported separately)
+func errorMessage(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return fmt.Sprint(err)
+}
@@ -0,0 +1,249 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
+	"github.com/openshift/osd-network-verifier/pkg/data/curlgen"
+	"github.com/openshift/osd-network-verifier/pkg/data/egress_lists"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"github.com/openshift/osd-network-verifier/pkg/probes/curl"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+const (
+	// maxConcurrentChecks caps how many endpoints are checked at once
+	maxConcurrentChecks = 10
+	// defaultRetryDelay is how long NewLocalVerifier's verifiers wait before retrying a failed
+	// check (mirroring curl's --retry behavior, the delay doubles after each retry)
+	defaultRetryDelay = time.Second
+	// egressIPCheckPause is how long to pause between egress IP checks, so that unstable egress
+	// IPs have a chance to show up
+	egressIPCheckPause = 2 * time.Second
+)
+
+// LocalVerifier checks egress directly from the machine it runs on (e.g., a bastion host or CI
+// runner inside the target network) instead of from a cloud instance or pod. It uses Go's HTTP
+// and TLS clients in place of curl, but reports its results through the curl probe, so its output
+// is identical to that of the other verifiers
+type LocalVerifier struct {
+	Logger ocmlog.Logger
+
+	// retryDelay is how long to wait before the first retry of a failed check. The zero value
+	// retries immediately
+	retryDelay time.Duration
+}
+
+// NewLocalVerifier returns a LocalVerifier that checks egress from the current host
+func NewLocalVerifier(debug bool) (*LocalVerifier, error) {
+	builder := ocmlog.NewStdLoggerBuilder()
+	builder.Debug(debug)
+	logger, err := builder.Build()
+	if err != nil {
+		return &LocalVerifier{}, fmt.Errorf("unable to build logger: %s", err.Error())
+	}
+
+	return &LocalVerifier{
+		Logger:     logger,
+		retryDelay: defaultRetryDelay,
+	}, nil
+}
+
+// ValidateEgress checks every endpoint in the egress list for vei.PlatformType from the current
+// host, honoring vei.Proxy. Options that only make sense on a probe instance (e.g., TransferURLs
+// or CurlOptions) are ignored with a warning. Each call returns its own output, so a LocalVerifier
+// may be reused and called concurrently
+func (l *LocalVerifier) ValidateEgress(vei verifier.ValidateEgressInput) *output.Output {
+	out := &output.Output{}
+
+	// Validate cloud platform type
+	if !vei.PlatformType.IsValid() {
+		vei.PlatformType = cloud.AWSClassic
+	}
+
+	// Default to curl.Probe if no Probe specified. Only the curl probe's output format is
+	// emulated, as no probe actually runs
+	if vei.Probe == nil {
+		vei.Probe = curl.Probe{}
+	}
+	curlProbe, ok := vei.Probe.(curl.Probe)
+	if !ok {
+		return out.AddError(errors.New("verification via local mode only supports curl probe"))
+	}
+	if err := curlProbe.ValidateSampling(); err != nil {
+		return out.AddError(err)
+	}
+
+	if vei.Ctx == nil {
+		vei.Ctx = context.Background()
+	}
+	if vei.Timeout <= 0 {
+		vei.Timeout = verifier.DefaultTimeout
+	}
+	l.writeDebugLogs(out, fmt.Sprintf("configured a %s timeout for each egress request", vei.Timeout))
+
+	if len(vei.TransferURLs) > 0 {
+		out.AddWarning(errors.New("large-payload transfer checks are not supported in local mode; ignoring transfer URLs"))
+	}
+	if len(vei.CurlOptions) > 0 {
+		out.AddWarning(errors.New("extra curl options are not supported in local mode; ignoring them"))
+	}
+
+	// Generate egress lists for the given PlatformType
+	generatorVariables := map[string]string{"AWS_REGION": vei.AWS.Region}
+	generator := egress_lists.NewGenerator(vei.PlatformType, generatorVariables, l.Logger)
+	egressListStr, tlsDisabledEgressListStr, err := generator.GenerateEgressLists(vei.Ctx, vei.EgressListYaml)
+	if err != nil {
+		return out.AddError(err)
+	}
+
+	checker, err := newEndpointChecker(vei.Proxy, vei.Timeout, l.retryDelay)
+	if err != nil {
+		return out.AddError(err)
+	}
+	// Like the curl probe, failed checks aren't retried while sampling, as retries would hide the
+	// intermittent failures that sampling is meant to catch
+	if curlProbe.Samples > 1 {
+		checker.retries = 0
+	}
+
+	var endpoints []endpoint
+	for _, url := range strings.Fields(egressListStr) {
+		endpoints = append(endpoints, endpoint{url: url})
+	}
+	for _, url := range strings.Fields(tlsDisabledEgressListStr) {
+		endpoints = append(endpoints, endpoint{url: url, tlsDisabled: true})
+	}
+
+	// Results are serialized in the curl probe's output format, so that the curl probe can parse
+	// them exactly as it would parse its own output
+	var probeOutput []string
+	for _, ipFamily := range passes(vei.IPFamily) {
+		for sample := 1; sample <= max(curlProbe.Samples, 1); sample++ {
+			if sample > 1 {
+				sleepJitter(vei.Ctx, curl.SampleJitterSeconds)
+			}
+			probeOutput = append(probeOutput, l.checkEndpoints(vei.Ctx, checker, endpoints, ipFamily, curlProbe, vei.OnProgress)...)
+		}
+	}
+	if vei.EgressIPEchoURL != "" {
+		probeOutput = append(probeOutput, l.checkEgressIP(vei.Ctx, checker, vei.EgressIPEchoURL, max(vei.EgressIPChecks, 1), out)...)
+	}
+	l.writeDebugLogs(out, fmt.Sprintf("Local probe output:\n---\n%s\n---", strings.Join(probeOutput, "\n")))
+
+	// ensurePrivate is a flag to ensure the return IP address from the given hosts are private
+	// Currently, it will be used the Zero Egress cluster check only
+	ensurePrivate := vei.PlatformType == cloud.AWSHCPZeroEgress
+	curlProbe.ParseProbeOutput(ensurePrivate, strings.Join(probeOutput, "\n"), out)
+
+	return out
+}
+
+// VerifyDns is not applicable to local mode, as the current host isn't necessarily inside a VPC
+func (l *LocalVerifier) VerifyDns(vdi verifier.VerifyDnsInput) *output.Output {
+	l.Logger.Info(vdi.Ctx, "DNS verification not applicable to local verifier")
+	return &output.Output{}
+}
+
+// endpoint is a single egress URL to check
+type endpoint struct {
+	url         string
+	tlsDisabled bool
+}
+
+// passes returns the IP families to check every endpoint over, where the zero Family means
+// "whichever family the OS picks"
+func passes(ipFamily ipfamily.Family) []ipfamily.Family {
+	if familyPasses := ipFamily.Passes(); len(familyPasses) > 0 {
+		return familyPasses
+	}
+	return []ipfamily.Family{{}}
+}
+
+// checkEndpoints concurrently checks every endpoint over ipFamily, returning the results as lines
+// of curl probe output in the same order as endpoints. onProgress (if set) is called as soon as
+// each check completes
+func (l *LocalVerifier) checkEndpoints(ctx context.Context, checker *endpointChecker, endpoints []endpoint, ipFamily ipfamily.Family, probe probes.Probe, onProgress func(probes.ProgressEvent)) []string {
+	lines := make([]string, len(endpoints))
+	semaphore := make(chan struct{}, maxConcurrentChecks)
+	var progressMutex sync.Mutex
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			result := checker.check(ctx, ep.url, ep.tlsDisabled, ipFamily)
+			lines[i] = serializeResult(result)
+
+			// onProgress isn't necessarily safe to call concurrently
+			progressMutex.Lock()
+			defer progressMutex.Unlock()
+			probes.ReportProgress(probe, []string{lines[i]}, onProgress)
+		}()
+	}
+	wg.Wait()
+	return lines
+}
+
+// checkEgressIP queries the IP echo URL checks times (or until ctx is done), returning the response
+// bodies as lines of curl probe output. Failed queries are recorded in out's debug logs
+func (l *LocalVerifier) checkEgressIP(ctx context.Context, checker *endpointChecker, echoURL string, checks int, out *output.Output) []string {
+	lines := make([]string, 0, checks)
+	for i := 0; i < checks; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(egressIPCheckPause):
+			case <-ctx.Done():
+				return lines
+			}
+		}
+		body, err := checker.fetchBody(ctx, echoURL)
+		if err != nil {
+			l.writeDebugLogs(out, fmt.Sprintf("egress IP check failed: %s", err))
+		}
+		lines = append(lines, curlgen.DefaultEgressIPOutputSeparator+body)
+	}
+	return lines
+}
+
+// serializeResult returns result as a line of curl probe output
+func serializeResult(result curl.CurlJSONProbeResult) string {
+	// Marshalling a struct of plain strings and numbers can't fail
+	resultJSON, _ := json.Marshal(result)
+	label := ""
+	if result.IPFamily != "" {
+		label = curlgen.IPFamilyLabel(result.IPFamily)
+	}
+	return curlgen.DefaultCurlOutputSeparator + label + string(resultJSON)
+}
+
+// sleepJitter pauses for a random number of seconds between 1 and maxSeconds, or until ctx is done
+func sleepJitter(ctx context.Context, maxSeconds int) {
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(max(maxSeconds, 1))))
+	if err != nil {
+		jitter = big.NewInt(0)
+	}
+	select {
+	case <-time.After(time.Duration(jitter.Int64()+1) * time.Second):
+	case <-ctx.Done():
+	}
+}
+
+// writeDebugLogs stores log in out, the output of the current call
+func (l *LocalVerifier) writeDebugLogs(out *output.Output, log string) {
+	out.AddDebugLogs(log)
+}
@@ -0,0 +1,361 @@
+package local
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ocmlog "github.com/openshift-online/ocm-sdk-go/logging"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
+	"github.com/openshift/osd-network-verifier/pkg/output"
+	"github.com/openshift/osd-network-verifier/pkg/probes"
+	"github.com/openshift/osd-network-verifier/pkg/probes/dns"
+	"github.com/openshift/osd-network-verifier/pkg/proxy"
+	"github.com/openshift/osd-network-verifier/pkg/verifier"
+)
+
+// newTestLocalVerifier returns a LocalVerifier that retries failed checks immediately
+func newTestLocalVerifier(t *testing.T) *LocalVerifier {
+	logger, err := ocmlog.NewStdLoggerBuilder().Build()
+	if err != nil {
+		t.Fatalf("unable to build logger: %v", err)
+	}
+	return &LocalVerifier{Logger: logger}
+}
+
+// closedPort returns a local TCP port that nothing is listening on
+func closedPort(t *testing.T) int {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+// certPEM returns the PEM-encoded certificate of a TLS test server
+func certPEM(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func TestLocalVerifier_ValidateEgress(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer listener.Close()
+	openPort := listener.Addr().(*net.TCPAddr).Port
+	blockedPort := closedPort(t)
+
+	var progressMutex sync.Mutex
+	var progress []probes.ProgressEvent
+	vei := verifier.ValidateEgressInput{
+		Ctx:      context.Background(),
+		Timeout:  time.Second,
+		IPFamily: ipfamily.IPv4,
+		EgressListYaml: fmt.Sprintf(`endpoints:
+  - host: 127.0.0.1
+    ports:
+      - %d
+      - %d
+`, openPort, blockedPort),
+		OnProgress: func(event probes.ProgressEvent) {
+			progressMutex.Lock()
+			defer progressMutex.Unlock()
+			progress = append(progress, event)
+		},
+	}
+
+	out := newTestLocalVerifier(t).ValidateEgress(vei)
+
+	var gotFailures []string
+	for _, failure := range out.GetEgressURLFailures() {
+		gotFailures = append(gotFailures, failure.Error())
+	}
+	wantFailurePrefix := fmt.Sprintf("egressURL error: tcp://127.0.0.1:%d [ipv4] (", blockedPort)
+	if len(gotFailures) != 1 || !strings.HasPrefix(gotFailures[0], wantFailurePrefix) {
+		t.Errorf("LocalVerifier.ValidateEgress() egress failures = %v, want a single failure starting with %q", gotFailures, wantFailurePrefix)
+	}
+	if wantInfo := []string{"ipv4 egress: 1 of 2 endpoints reachable"}; !reflect.DeepEqual(out.GetInfo(), wantInfo) {
+		t.Errorf("LocalVerifier.ValidateEgress() info = %v, want %v", out.GetInfo(), wantInfo)
+	}
+	if len(progress) != 2 {
+		t.Errorf("LocalVerifier.ValidateEgress() reported %d progress events, want 2", len(progress))
+	}
+}
+
+// TestLocalVerifier_ValidateEgress_Concurrent ensures concurrent calls to the same LocalVerifier
+// each get their own results
+func TestLocalVerifier_ValidateEgress_Concurrent(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer listener.Close()
+	openPort := listener.Addr().(*net.TCPAddr).Port
+
+	const calls = 4
+	blockedPorts := make([]int, calls)
+	for i := range blockedPorts {
+		blockedPorts[i] = closedPort(t)
+	}
+
+	localVerifier := newTestLocalVerifier(t)
+	gotFailures := make([][]string, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := localVerifier.ValidateEgress(verifier.ValidateEgressInput{
+				Ctx:      context.Background(),
+				Timeout:  time.Second,
+				IPFamily: ipfamily.IPv4,
+				EgressListYaml: fmt.Sprintf(`endpoints:
+  - host: 127.0.0.1
+    ports:
+      - %d
+      - %d
+`, openPort, blockedPorts[i]),
+			})
+			for _, failure := range out.GetEgressURLFailures() {
+				gotFailures[i] = append(gotFailures[i], failure.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, failures := range gotFailures {
+		wantFailurePrefix := fmt.Sprintf("egressURL error: tcp://127.0.0.1:%d [ipv4] (", blockedPorts[i])
+		if len(failures) != 1 || !strings.HasPrefix(failures[0], wantFailurePrefix) {
+			t.Errorf("LocalVerifier.ValidateEgress() call %d egress failures = %v, want a single failure starting with %q", i, failures, wantFailurePrefix)
+		}
+	}
+}
+
+func TestLocalVerifier_ValidateEgress_UnsupportedProbe(t *testing.T) {
+	out := newTestLocalVerifier(t).ValidateEgress(verifier.ValidateEgressInput{Probe: dns.Probe{}})
+	if out.IsSuccessful() {
+		t.Error("LocalVerifier.ValidateEgress() succeeded with a non-curl probe, want error")
+	}
+}
+
+func TestEndpointChecker_check(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer tlsServer.Close()
+
+	// proxyServer stands in for an HTTP proxy, answering every request itself
+	var proxiedMutex sync.Mutex
+	var proxiedHosts []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedMutex.Lock()
+		defer proxiedMutex.Unlock()
+		proxiedHosts = append(proxiedHosts, r.URL.Host)
+	}))
+	defer proxyServer.Close()
+
+	tests := []struct {
+		name            string
+		proxyConfig     proxy.ProxyConfig
+		url             string
+		tlsDisabled     bool
+		wantSuccess     bool
+		wantExitCode    int
+		wantProxiedHost string
+	}{
+		{
+			name:        "trusted TLS endpoint with error status",
+			proxyConfig: proxy.ProxyConfig{Cacert: certPEM(tlsServer)},
+			url:         tlsServer.URL,
+			wantSuccess: true,
+		},
+		{
+			name:         "untrusted TLS endpoint",
+			url:          tlsServer.URL,
+			wantExitCode: curlExitPeerFailedVerify,
+		},
+		{
+			name:        "untrusted TLS endpoint with TLS disabled",
+			url:         tlsServer.URL,
+			tlsDisabled: true,
+			wantSuccess: true,
+		},
+		{
+			name:        "untrusted TLS endpoint with NoTls",
+			proxyConfig: proxy.ProxyConfig{NoTls: true},
+			url:         tlsServer.URL,
+			wantSuccess: true,
+		},
+		{
+			name:            "HTTP endpoint through proxy",
+			proxyConfig:     proxy.ProxyConfig{HttpProxy: proxyServer.URL},
+			url:             "http://egress.example.com:80",
+			wantSuccess:     true,
+			wantProxiedHost: "egress.example.com:80",
+		},
+		{
+			name:         "closed TCP port",
+			url:          fmt.Sprintf("telnet://127.0.0.1:%d", closedPort(t)),
+			wantExitCode: curlExitCouldntConnect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxiedHosts = nil
+			checker, err := newEndpointChecker(tt.proxyConfig, time.Second, 0)
+			if err != nil {
+				t.Fatalf("newEndpointChecker() unexpected error: %v", err)
+			}
+
+			got := checker.check(context.Background(), tt.url, tt.tlsDisabled, ipfamily.Family{})
+			if got.IsSuccessfulConnection() != tt.wantSuccess {
+				t.Errorf("endpointChecker.check() = %+v, want success %v", got, tt.wantSuccess)
+			}
+			if !tt.wantSuccess && got.ExitCode != tt.wantExitCode {
+				t.Errorf("endpointChecker.check() exit code = %d (%s), want %d", got.ExitCode, got.ErrorMsg, tt.wantExitCode)
+			}
+			if tt.wantProxiedHost != "" && !reflect.DeepEqual(proxiedHosts, []string{tt.wantProxiedHost}) {
+				t.Errorf("endpointChecker.check() proxied requests to %v, want [%s]", proxiedHosts, tt.wantProxiedHost)
+			}
+		})
+	}
+}
+
+// TestEndpointChecker_check_Retries ensures failed checks are retried unless retries are disabled
+// (as they are while sampling)
+func TestEndpointChecker_check_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		wantSuccess  bool
+		wantRequests int
+	}{
+		{
+			name:         "default retries",
+			retries:      checkRetries,
+			wantSuccess:  true,
+			wantRequests: 2,
+		},
+		{
+			name:         "retries disabled",
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// flakyServer drops the first connection without responding, then answers normally
+			var requestsMutex sync.Mutex
+			requests := 0
+			flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestsMutex.Lock()
+				requests++
+				first := requests == 1
+				requestsMutex.Unlock()
+				if first {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+				}
+			}))
+			defer flakyServer.Close()
+
+			checker, err := newEndpointChecker(proxy.ProxyConfig{}, time.Second, 0)
+			if err != nil {
+				t.Fatalf("newEndpointChecker() unexpected error: %v", err)
+			}
+			checker.retries = tt.retries
+
+			got := checker.check(context.Background(), flakyServer.URL, false, ipfamily.Family{})
+			if got.IsSuccessfulConnection() != tt.wantSuccess {
+				t.Errorf("endpointChecker.check() = %+v, want success %v", got, tt.wantSuccess)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("endpointChecker.check() made %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// TestLocalVerifier_checkEgressIP_Canceled ensures the pause between egress IP checks ends as soon
+// as the context is done
+func TestLocalVerifier_checkEgressIP_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	echoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		fmt.Fprintln(w, "203.0.113.10")
+	}))
+	defer echoServer.Close()
+
+	checker, err := newEndpointChecker(proxy.ProxyConfig{}, time.Second, 0)
+	if err != nil {
+		t.Fatalf("newEndpointChecker() unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	lines := newTestLocalVerifier(t).checkEgressIP(ctx, checker, echoServer.URL, 3, &output.Output{})
+	if elapsed := time.Since(start); elapsed >= egressIPCheckPause {
+		t.Errorf("LocalVerifier.checkEgressIP() took %s after its context was canceled", elapsed)
+	}
+	if len(lines) != 1 {
+		t.Errorf("LocalVerifier.checkEgressIP() = %v, want a single line", lines)
+	}
+}
+
+func TestNewEndpointChecker_NoProxy(t *testing.T) {
+	checker, err := newEndpointChecker(proxy.ProxyConfig{
+		HttpsProxy: "http://proxy.example.com:3128",
+		NoProxy:    []string{".internal.example.com"},
+	}, time.Second, 0)
+	if err != nil {
+		t.Fatalf("newEndpointChecker() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url       string
+		wantProxy string
+	}{
+		{"https://quay.io:443", "http://proxy.example.com:3128"},
+		{"https://api.internal.example.com:443", ""},
+		{"http://quay.io:80", ""},
+	}
+	for _, tt := range tests {
+		parsedURL, _ := url.Parse(tt.url)
+		gotProxy, err := checker.proxyFunc(parsedURL)
+		if err != nil {
+			t.Fatalf("endpointChecker.proxyFunc(%s) unexpected error: %v", tt.url, err)
+		}
+		got := ""
+		if gotProxy != nil {
+			got = gotProxy.String()
+		}
+		if got != tt.wantProxy {
+			t.Errorf("endpointChecker.proxyFunc(%s) = %q, want %q", tt.url, got, tt.wantProxy)
+		}
+	}
+}
+
+func TestNewEndpointChecker_InvalidCacert(t *testing.T) {
+	if _, err := newEndpointChecker(proxy.ProxyConfig{Cacert: "not a certificate"}, time.Second, 0); err == nil {
+		t.Error("newEndpointChecker() expected an error for an invalid CA certificate, got nil")
+	}
+}
+
+// TestLocalVerifier_VerifyDns also ensures LocalVerifier can be passed to the verifier package's
+// entry points
+func TestLocalVerifier_VerifyDns(t *testing.T) {
+	out := verifier.VerifyDns(newTestLocalVerifier(t), verifier.VerifyDnsInput{Ctx: context.Background()})
+	if !out.IsSuccessful() {
+		t.Errorf("LocalVerifier.VerifyDns() = %v, want success", out)
+	}
+}
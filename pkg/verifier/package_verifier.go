@@ -6,6 +6,7 @@ import (
 
 	"github.com/openshift/osd-network-verifier/pkg/data/cloud"
 	"github.com/openshift/osd-network-verifier/pkg/data/cpu"
+	"github.com/openshift/osd-network-verifier/pkg/data/ipfamily"
 	"github.com/openshift/osd-network-verifier/pkg/output"
 	"github.com/openshift/osd-network-verifier/pkg/probes"
 	"github.com/openshift/osd-network-verifier/pkg/proxy"
@@ -13,6 +14,14 @@ import (
 
 const DefaultTimeout = 5 * time.Second
 
+// DefaultMaxParallelSubnets is how many subnets are verified at once in a multi-subnet run (see
+// ValidateEgressInput.SubnetIDs), unless the caller requests otherwise
+const DefaultMaxParallelSubnets = 3
+
+// probeStartupAllowance is how long a probe instance is given to boot and start checking
+// endpoints, on top of the time allowed for the checks themselves (see DerivedPollTimeout)
+const probeStartupAllowance = 2 * time.Minute
+
 // VerifierService defines the behaviors necessary to run verifier completely.
 // Any clients that fulfill this interface will be able to run all verifier tests
 type verifierService interface {
@@ -58,6 +67,13 @@ type ValidateEgressInput struct {
 	// implementation of the probes.Probe interface
 	Probe probes.Probe
 
+	// UserDataTemplate, if set, replaces Probe's built-in userdata template, e.g., to perform extra
+	// setup required by hardened images (such as registering a CA, setting a DNS search domain, or
+	// enabling FIPS) before checking egress. Only probes implementing
+	// probes.UserDataTemplateOverrider (e.g., curl.Probe) support this; see curl.Probe's
+	// UserDataTemplate field for the template's requirements
+	UserDataTemplate string
+
 	// CPUArchitecture controls the CPU architecture of the default/fallback cloud instance type.
 	// Has no effect if a supported value of InstanceType is provided.
 	CPUArchitecture cpu.Architecture
@@ -65,12 +81,105 @@ type ValidateEgressInput struct {
 	// PlatformType controls the platform of the default/fallback cloud platform type.
 	// Defaults to cloud.AWSClassic if no PlatformType is provided.
 	PlatformType cloud.Platform
+
+	// EgressIPEchoURL, if set, is queried by the curl probe to discover the public IP address
+	// that the target subnet egresses from. The URL's response body must contain the caller's
+	// IP address, either as an "IP echo" service (e.g., https://checkip.amazonaws.com) or
+	// reflected within a larger response. Discovered IPs are reported as informational results
+	EgressIPEchoURL string
+
+	// EgressIPChecks controls how many times EgressIPEchoURL is queried. Repeating the check
+	// allows the verifier to warn about subnets that egress from multiple/unstable IPs.
+	// Defaults to 1 if unset
+	EgressIPChecks int
+
+	// TransferURLs, if set, are URLs from which the curl probe downloads TransferSize bytes in
+	// order to catch network paths that complete TCP/TLS handshakes but stall on large packets
+	// (e.g., VPN or transit gateway MTU blackholes). Such problems are reported as transfer
+	// failures, separately from egress failures
+	TransferURLs []string
+
+	// TransferSize controls how much data is downloaded from each of TransferURLs, as an integer
+	// number of bytes with an optional K, M, or G suffix (e.g., "512K"). Defaults to 1M if unset
+	TransferSize string
+
+	// CurlOptions are additional options passed through to curl for every endpoint check (curl
+	// probe only), e.g., "--resolve=quay.io:443:203.0.113.10" to test egress to a new IP before a
+	// DNS cutover. Only a small allowlist of options is supported; see curlgen.ParseExtraOptions
+	CurlOptions []string
+
+	// IPFamily controls which IP family (or families) the curl probe checks egress over, e.g.,
+	// ipfamily.DualStack checks every endpoint over IPv4 and then over IPv6, reporting results per
+	// family. When it includes IPv6, the temporary security group (AWS only) also allows egress to
+	// ::/0. If unset, curl picks an address family for each endpoint itself
+	IPFamily ipfamily.Family
+
+	// OnProgress, if set, is called with the result of each endpoint check as soon as it appears
+	// in the probe instance's console output, i.e., before the probe has finished checking every
+	// endpoint. Only probes implementing probes.ProgressReporter report progress
+	OnProgress func(probes.ProgressEvent)
+
+	// PollInterval controls how often the probe instance's console output is read. Defaults to a
+	// platform-specific interval if unset
+	PollInterval time.Duration
+
+	// PollTimeout controls how long the verifier waits for the probe to finish before giving up.
+	// Defaults to a deadline derived from the probe, the number of egress endpoints, and Timeout if
+	// unset (see DerivedProbePollTimeout)
+	PollTimeout time.Duration
+
+	// SubnetIDs, if set, lists several subnets (e.g., one per availability zone) to verify egress
+	// from in a single run, in addition to SubnetID (AWS only). A probe instance is launched into
+	// each subnet concurrently, and subnets in the same VPC share a single temporary security
+	// group. Each subnet's results are nested in the returned output (see
+	// output.Output.GetSubnetResults), along with a cross-subnet matrix of failed endpoints
+	SubnetIDs []string
+
+	// MaxParallelSubnets caps how many of SubnetIDs are verified at once. Defaults to
+	// DefaultMaxParallelSubnets if unset
+	MaxParallelSubnets int
 }
+
+// TargetSubnetIDs returns every subnet that egress should be verified from, i.e., SubnetID followed
+// by SubnetIDs, without duplicates or empty IDs
+func (vei ValidateEgressInput) TargetSubnetIDs() []string {
+	var subnetIDs []string
+	seen := map[string]bool{}
+	for _, subnetID := range append([]string{vei.SubnetID}, vei.SubnetIDs...) {
+		if subnetID == "" || seen[subnetID] {
+			continue
+		}
+		seen[subnetID] = true
+		subnetIDs = append(subnetIDs, subnetID)
+	}
+	return subnetIDs
+}
+
+// DerivedPollTimeout returns how long a verifier should wait for a probe to check endpointCount
+// endpoints, each of which may take up to timeout, plus some time for the probe instance to start
+// up. The result is never less than minTimeout
+func DerivedPollTimeout(endpointCount int, timeout time.Duration, minTimeout time.Duration) time.Duration {
+	return max(minTimeout, probeStartupAllowance+time.Duration(endpointCount)*timeout)
+}
+
+// DerivedProbePollTimeout is like DerivedPollTimeout, but allows for the worst-case runtime reported
+// by probe (see probes.RuntimeEstimator), e.g., for probes that retry, sample, or run several
+// checks per endpoint
+func DerivedProbePollTimeout(probe probes.Probe, endpointCount int, timeout time.Duration, minTimeout time.Duration) time.Duration {
+	return max(minTimeout, probeStartupAllowance+probes.MaxRuntime(probe, endpointCount, timeout))
+}
+
 type AwsEgressConfig struct {
 	KmsKeyID          string
 	SecurityGroupIDs  []string
 	TempSecurityGroup string
 
+	// StaticAnalysis, if set, predicts whether each egress endpoint is reachable using only the
+	// configuration of the target subnets' VPC (route tables, NAT and internet gateways, network
+	// ACLs, and security groups), instead of launching probe instances. Endpoints predicted to be
+	// blocked are reported as egress failures naming the blocking component
+	StaticAnalysis bool
+
 	// Region is currently required for pod mode only. When using EC2 for verification, the region is extracted from an AWSClient.
 	Region string
 }
@@ -86,6 +195,9 @@ func ValidateEgress(vs verifierService, vei ValidateEgressInput) *output.Output
 type VerifyDnsInput struct {
 	Ctx   context.Context
 	VpcID string
+	// SubnetID optionally identifies a subnet of the VPC to verify (or, if VpcID is empty, the VPC
+	// itself), from which custom DNS servers must be reachable
+	SubnetID string
 }
 
 // VerifyDns pass in a GCP or AWS client that know how to fulfill the above interface
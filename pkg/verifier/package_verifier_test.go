@@ -0,0 +1,60 @@
+package verifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openshift/osd-network-verifier/pkg/probes/dns"
+	"github.com/openshift/osd-network-verifier/pkg/probes/legacy"
+)
+
+func TestDerivedPollTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		endpointCount int
+		timeout       time.Duration
+		minTimeout    time.Duration
+		want          time.Duration
+	}{
+		{
+			name:          "few endpoints use minimum",
+			endpointCount: 10,
+			timeout:       5 * time.Second,
+			minTimeout:    270 * time.Second,
+			want:          270 * time.Second,
+		},
+		{
+			name:          "many endpoints extend deadline",
+			endpointCount: 100,
+			timeout:       5 * time.Second,
+			minTimeout:    270 * time.Second,
+			want:          probeStartupAllowance + 500*time.Second,
+		},
+		{
+			name:       "no endpoints",
+			minTimeout: time.Second,
+			want:       probeStartupAllowance,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DerivedPollTimeout(tt.endpointCount, tt.timeout, tt.minTimeout); got != tt.want {
+				t.Errorf("DerivedPollTimeout() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDerivedProbePollTimeout(t *testing.T) {
+	// dns.Probe resolves hosts concurrently, so its deadline doesn't grow by timeout per endpoint
+	got := DerivedProbePollTimeout(dns.Probe{}, 38, 5*time.Second, 270*time.Second)
+	if want := probeStartupAllowance + 150*time.Second; got != want {
+		t.Errorf("DerivedProbePollTimeout(dns.Probe{}) = %s, want %s", got, want)
+	}
+
+	// Probes that don't implement probes.RuntimeEstimator check each endpoint once
+	got = DerivedProbePollTimeout(legacy.Probe{}, 100, 5*time.Second, 270*time.Second)
+	if want := DerivedPollTimeout(100, 5*time.Second, 270*time.Second); got != want {
+		t.Errorf("DerivedProbePollTimeout(legacy.Probe{}) = %s, want %s", got, want)
+	}
+}